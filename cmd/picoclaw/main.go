@@ -19,13 +19,17 @@ import (
 
 	"github.com/chzyer/readline"
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/approval"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/contracts"
 	"github.com/sipeed/picoclaw/pkg/cron"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/marketdata"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/retention"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/voice"
@@ -91,6 +95,10 @@ func main() {
 		statusCmd()
 	case "cron":
 		cronCmd()
+	case "memory":
+		memoryCmd()
+	case "storage":
+		storageCmd()
 	case "skills":
 		if len(os.Args) < 3 {
 			skillsHelp()
@@ -160,6 +168,8 @@ func printHelp() {
 	fmt.Println("  gateway        Start picoclaw gateway (includes econ watcher)")
 	fmt.Println("  status         Show picoclaw status")
 	fmt.Println("  cron           Manage scheduled tasks")
+	fmt.Println("  memory         Consolidate daily notes into digests")
+	fmt.Println("  storage        Validate data files, show usage and apply retention")
 	fmt.Println("  skills         Manage skills (install, list, remove)")
 	fmt.Println("  version        Show version information")
 }
@@ -447,7 +457,7 @@ Built on PicoClaw framework, specialized for 24/7 market surveillance.
       "note": "ETH generally follows BTC with slight delay"
     },
     {
-      "pair": ["XAUUSDT", "EURUSDT"],
+      "pair": ["XAUUSD", "EURUSD"],
       "type": "positive",
       "strength": 0.60,
       "note": "Gold and EUR often move together vs USD"
@@ -655,6 +665,7 @@ func agentCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	registerSymbolAliases(cfg)
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -675,6 +686,16 @@ func agentCmd() {
 		})
 
 	if message != "" {
+		reader := bufio.NewReader(os.Stdin)
+		agentLoop.SetApprovalPrompter(func(ctx context.Context, req *approval.Request) (bool, error) {
+			fmt.Print(formatApprovalPrompt(req))
+			line, err := reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return false, err
+			}
+			return isApprovalAnswer(line), nil
+		})
+
 		ctx := context.Background()
 		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
 		if err != nil {
@@ -707,6 +728,20 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	}
 	defer rl.Close()
 
+	agentLoop.SetApprovalPrompter(func(ctx context.Context, req *approval.Request) (bool, error) {
+		rl.SetPrompt(formatApprovalPrompt(req))
+		defer rl.SetPrompt(prompt)
+
+		line, err := rl.Readline()
+		if err != nil {
+			if err == readline.ErrInterrupt || err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		return isApprovalAnswer(line), nil
+	})
+
 	for {
 		line, err := rl.Readline()
 		if err != nil {
@@ -741,6 +776,16 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 
 func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	reader := bufio.NewReader(os.Stdin)
+
+	agentLoop.SetApprovalPrompter(func(ctx context.Context, req *approval.Request) (bool, error) {
+		fmt.Print(formatApprovalPrompt(req))
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		return isApprovalAnswer(line), nil
+	})
+
 	for {
 		fmt.Print(fmt.Sprintf("%s You: ", logo))
 		line, err := reader.ReadString('\n')
@@ -774,6 +819,17 @@ func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	}
 }
 
+// formatApprovalPrompt renders an approval request for the terminal.
+func formatApprovalPrompt(req *approval.Request) string {
+	return fmt.Sprintf("\n⚠️  Approval required for %s (%s)\n   Arguments: %v\nApprove? [y/N]: ",
+		req.Tool, req.Reason, req.Args)
+}
+
+func isApprovalAnswer(line string) bool {
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes" || answer == "approve"
+}
+
 func gatewayCmd() {
 	// Check for --debug flag
 	args := os.Args[2:]
@@ -790,6 +846,7 @@ func gatewayCmd() {
 		fmt.Printf("Error loading config: %v\n", err)
 		os.Exit(1)
 	}
+	registerSymbolAliases(cfg)
 
 	provider, err := providers.CreateProvider(cfg)
 	if err != nil {
@@ -823,7 +880,10 @@ func gatewayCmd() {
 
 	// Auto-register economic cron jobs if econ_watcher agent is configured
 	if _, hasEcon := cfg.Agents.Named["econ_watcher"]; hasEcon {
-		registerEconCronJobs(cronService)
+		// The alert rules only replace volatility polling when they have
+		// somewhere to deliver to
+		alertCfg := cfg.Tools.Alerts
+		registerEconCronJobs(cronService, alertCfg.Enabled && alertCfg.Channel != "" && alertCfg.ChatID != "")
 	}
 
 	heartbeatService := heartbeat.NewHeartbeatService(
@@ -889,6 +949,38 @@ func gatewayCmd() {
 
 	go agentLoop.Run(ctx)
 
+	if interval, ok := agentLoop.StartMemoryConsolidation(ctx); ok {
+		fmt.Printf("✓ Memory consolidation every %dh\n", int(interval.Hours()))
+	} else if consolidation := cfg.Agents.Memory.Consolidation; consolidation.Enabled && consolidation.DryRun {
+		fmt.Println("⚠ Memory consolidation not scheduled in dry-run mode; preview it with: picoclaw memory consolidate --dry-run")
+	}
+
+	if agentLoop.StartMarketStream(ctx) {
+		fmt.Printf("✓ Market stream: %s\n", strings.Join(cfg.Tools.MarketData.Stream.Symbols, ", "))
+	}
+
+	if agentLoop.StartAlerts(ctx) {
+		fmt.Printf("✓ Price alerts checked every %ds\n", cfg.Tools.Alerts.CheckSeconds)
+	}
+
+	if started, err := agentLoop.StartCalendar(ctx, cronService); err != nil {
+		fmt.Printf("⚠ Economic calendar runs disabled: %v\n", err)
+	} else if started {
+		fmt.Printf("✓ Economic calendar: %s-importance events scheduled %dh ahead\n",
+			cfg.Tools.Calendar.Schedule.MinImportance, cfg.Tools.Calendar.Schedule.LookaheadHours)
+	}
+
+	if retentionCfg := cfg.Tools.Storage.Retention; retentionCfg.Enabled && len(retentionCfg.Policies) > 0 {
+		policies, err := retention.PoliciesFromConfig(retentionCfg.Policies)
+		if err != nil {
+			fmt.Printf("⚠ Data retention disabled: %v\n", err)
+		} else {
+			retention.Schedule(ctx, filepath.Join(cfg.WorkspacePath(), "data"), policies,
+				time.Duration(retentionCfg.IntervalHours)*time.Hour, retentionCfg.DryRun)
+			fmt.Printf("✓ Data retention every %dh (%d policies)\n", retentionCfg.IntervalHours, len(policies))
+		}
+	}
+
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt)
 	<-sigChan
@@ -981,13 +1073,23 @@ func setupCronTool(agentLoop *agent.AgentLoop, msgBus *bus.MessageBus, workspace
 	return cronService
 }
 
+// registerSymbolAliases adds the configured symbol aliases to the
+// process-wide registry every market data lookup resolves symbols with.
+func registerSymbolAliases(cfg *config.Config) {
+	for alias, symbol := range cfg.Tools.MarketData.Aliases {
+		marketdata.Symbols.AddAlias(alias, symbol)
+	}
+}
+
 func loadConfig() (*config.Config, error) {
 	return config.LoadConfig(getConfigPath())
 }
 
 // registerEconCronJobs sets up the autonomous economic monitoring cron jobs.
-// Jobs are only added if they don't already exist (idempotent).
-func registerEconCronJobs(cronService *cron.CronService) {
+// Jobs are only added if they don't already exist (idempotent). With the
+// alert engine running, the 5-minute volatility poll is replaced by alert
+// rules that wake the agent only when a move happens.
+func registerEconCronJobs(cronService *cron.CronService, alertEngine bool) {
 	existingJobs := cronService.ListJobs(true)
 
 	type econJob struct {
@@ -1060,6 +1162,14 @@ func registerEconCronJobs(cronService *cron.CronService) {
 	registered := 0
 	updated := 0
 	for _, j := range jobs {
+		if alertEngine && j.name == "econ:volatility_alert" {
+			if oldID, exists := existingByName[j.name]; exists {
+				cronService.RemoveJob(oldID)
+				fmt.Println("  Volatility polling replaced by alert rules")
+			}
+			continue
+		}
+
 		var schedule cron.CronSchedule
 		if j.kind == "every" {
 			everyMS := j.everyS * 1000
@@ -1134,6 +1244,213 @@ func cronCmd() {
 	}
 }
 
+func memoryCmd() {
+	if len(os.Args) < 3 {
+		memoryHelp()
+		return
+	}
+
+	switch os.Args[2] {
+	case "consolidate":
+		dryRun := false
+		for _, arg := range os.Args[3:] {
+			if arg == "--dry-run" || arg == "-n" {
+				dryRun = true
+			}
+		}
+		memoryConsolidateCmd(dryRun)
+	default:
+		fmt.Printf("Unknown memory command: %s\n", os.Args[2])
+		memoryHelp()
+	}
+}
+
+func memoryHelp() {
+	fmt.Println("\nMemory commands:")
+	fmt.Println("  consolidate       Roll daily notes into weekly/monthly digests, prune old notes")
+	fmt.Println("                    and promote recurring learnings to long-term facts")
+	fmt.Println()
+	fmt.Println("Consolidate options:")
+	fmt.Println("  -n, --dry-run     Show the proposed changes without writing anything")
+}
+
+func memoryConsolidateCmd(dryRun bool) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	provider, err := providers.CreateProvider(cfg)
+	if err != nil {
+		fmt.Printf("Error creating provider: %v\n", err)
+		os.Exit(1)
+	}
+
+	agentLoop := agent.NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	report, err := agentLoop.ConsolidateMemory(context.Background(), dryRun)
+	if report != nil {
+		fmt.Print(report.String())
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func storageCmd() {
+	if len(os.Args) < 3 {
+		storageHelp()
+		return
+	}
+
+	switch os.Args[2] {
+	case "validate":
+		path := ""
+		if len(os.Args) > 3 {
+			path = os.Args[3]
+		}
+		storageValidateCmd(path)
+	case "usage":
+		storageUsageCmd()
+	case "prune":
+		dryRun := false
+		for _, arg := range os.Args[3:] {
+			if arg == "--dry-run" || arg == "-n" {
+				dryRun = true
+			}
+		}
+		storagePruneCmd(dryRun)
+	default:
+		fmt.Printf("Unknown storage command: %s\n", os.Args[2])
+		storageHelp()
+	}
+}
+
+func storageHelp() {
+	fmt.Println("\nStorage commands:")
+	fmt.Println("  validate [path]   Check data files against the schema contracts declared in")
+	fmt.Println("                    schemas.json and skill metadata (all covered files, or one")
+	fmt.Println("                    path relative to data/)")
+	fmt.Println("  usage             Show disk usage per data/ directory and what the retention")
+	fmt.Println("                    policies would remove")
+	fmt.Println("  prune             Apply the retention policies now")
+	fmt.Println()
+	fmt.Println("Prune options:")
+	fmt.Println("  -n, --dry-run     Show what would be removed without removing anything")
+}
+
+func storageUsageCmd() {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	dataDir := filepath.Join(cfg.WorkspacePath(), "data")
+	usage, err := retention.Usage(dataDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var totalFiles int
+	var totalBytes int64
+	fmt.Printf("\nData usage (%s):\n", dataDir)
+	for _, u := range usage {
+		fmt.Printf("  %-20s %6d files %10s   %s .. %s\n", u.Path, u.Files, retention.FormatBytes(u.Bytes),
+			u.Oldest.Format("2006-01-02"), u.Newest.Format("2006-01-02"))
+		totalFiles += u.Files
+		totalBytes += u.Bytes
+	}
+	fmt.Printf("  %-20s %6d files %10s\n", "total", totalFiles, retention.FormatBytes(totalBytes))
+
+	retentionCfg := cfg.Tools.Storage.Retention
+	if len(retentionCfg.Policies) == 0 {
+		fmt.Println("\nNo retention policies configured.")
+		return
+	}
+	policies, err := retention.PoliciesFromConfig(retentionCfg.Policies)
+	if err != nil {
+		fmt.Printf("\nError: %v\n", err)
+		os.Exit(1)
+	}
+	status := "disabled"
+	if retentionCfg.Enabled {
+		status = fmt.Sprintf("every %dh in the gateway", retentionCfg.IntervalHours)
+	}
+	fmt.Printf("\nRetention (%s), next run would:\n", status)
+	fmt.Print(retention.Apply(dataDir, policies, time.Now(), true).String())
+}
+
+func storagePruneCmd(dryRun bool) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	policies, err := retention.PoliciesFromConfig(cfg.Tools.Storage.Retention.Policies)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(policies) == 0 {
+		fmt.Println("No retention policies configured.")
+		return
+	}
+
+	report := retention.Apply(filepath.Join(cfg.WorkspacePath(), "data"), policies, time.Now(), dryRun)
+	fmt.Print(report.String())
+	for _, p := range report.Policies {
+		if p.Error != "" {
+			os.Exit(1)
+		}
+	}
+}
+
+func storageValidateCmd(path string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	workspace := cfg.WorkspacePath()
+	registry := contracts.NewRegistry(workspace)
+	reports, err := registry.ValidateData(filepath.Join(workspace, "data"), path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(reports) == 0 {
+		fmt.Println("No data files are covered by a schema contract.")
+		return
+	}
+
+	failed := 0
+	for _, report := range reports {
+		checked := ""
+		if report.Entries > 0 {
+			checked = fmt.Sprintf(", %d entries", report.Entries)
+		}
+		if len(report.Problems) == 0 {
+			fmt.Printf("✓ %s (%s%s)\n", report.Path, report.Contract.Schema, checked)
+			continue
+		}
+		failed++
+		fmt.Printf("✗ %s (%s from %s%s)\n", report.Path, report.Contract.Schema, report.Contract.Source, checked)
+		for _, problem := range report.Problems {
+			fmt.Printf("    - %s\n", problem)
+		}
+	}
+
+	fmt.Printf("\n%d files checked, %d invalid\n", len(reports), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
 func cronHelp() {
 	fmt.Println("\nCron commands:")
 	fmt.Println("  list              List all scheduled jobs")
@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// consolidationDelay postpones the first background run so it doesn't
+// compete with startup work.
+const consolidationDelay = 10 * time.Minute
+
+// memoryConsolidator returns a consolidator for the agent's memory that
+// summarizes with the agent's own provider and model.
+func (al *AgentLoop) memoryConsolidator() *memory.Consolidator {
+	summarize := func(ctx context.Context, system, prompt string) (string, error) {
+		messages := []providers.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		}
+		response, err := al.provider.Chat(ctx, messages, nil, al.model, map[string]interface{}{
+			"max_tokens":  2048,
+			"temperature": 0.3,
+		})
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}
+
+	return memory.NewConsolidator(al.contextBuilder.memory, summarize, memory.ConsolidationPolicy{
+		RetentionDays: al.consolidation.RetentionDays,
+		Archive:       al.consolidation.Archive,
+		PromoteAfter:  al.consolidation.PromoteAfter,
+	})
+}
+
+// ConsolidateMemory rolls daily notes into digests, prunes old notes and
+// promotes recurring learnings. With dryRun set it only reports the changes.
+func (al *AgentLoop) ConsolidateMemory(ctx context.Context, dryRun bool) (*memory.ConsolidationReport, error) {
+	return al.memoryConsolidator().Run(ctx, dryRun)
+}
+
+// StartMemoryConsolidation runs consolidation in the background every
+// interval_hours until ctx is cancelled and returns the interval it uses. It
+// returns false when disabled or in dry-run mode: a dry run writes nothing,
+// so repeating it would re-summarize the same notes on every run. Dry runs
+// are one-off, from "picoclaw memory consolidate --dry-run".
+func (al *AgentLoop) StartMemoryConsolidation(ctx context.Context) (time.Duration, bool) {
+	cfg := al.consolidation
+	if !cfg.Enabled || cfg.DryRun {
+		return 0, false
+	}
+	interval := time.Duration(cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	go func() {
+		timer := time.NewTimer(consolidationDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			if _, err := al.ConsolidateMemory(ctx, false); err != nil {
+				logger.ErrorCF("agent", "Memory consolidation failed",
+					map[string]interface{}{
+						"error": err.Error(),
+					})
+			}
+			timer.Reset(interval)
+		}
+	}()
+	return interval, true
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,16 +10,26 @@ import (
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type ContextBuilder struct {
 	workspace    string
 	skillsLoader *skills.SkillsLoader
-	memory       *MemoryStore
+	memory       *memory.MemoryStore
 	tools        *tools.ToolRegistry // Direct reference to tool registry
+	imageMaxDim  int                 // Longest image side sent to vision models
+	imageMaxSize int                 // Max encoded image size in bytes
+	maxImages    int                 // Max images attached to one message
+
+	memoryIndex     *memory.Index    // When set, memory is retrieved per message
+	memoryRetriever memory.Retriever // Keyword or hybrid search over memoryIndex
+	memoryTokens    int              // Token budget for retrieved memory
+	memoryTopK      int              // Max snippets retrieved per message
 }
 
 func getGlobalConfigDir() string {
@@ -39,7 +50,7 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 	return &ContextBuilder{
 		workspace:    workspace,
 		skillsLoader: skills.NewSkillsLoader(workspace, globalSkillsDir, builtinSkillsDir),
-		memory:       NewMemoryStore(workspace),
+		memory:       memory.NewMemoryStore(workspace),
 	}
 }
 
@@ -48,6 +59,23 @@ func (cb *ContextBuilder) SetToolsRegistry(registry *tools.ToolRegistry) {
 	cb.tools = registry
 }
 
+// SetImageLimits sets how media images are normalized before being attached.
+func (cb *ContextBuilder) SetImageLimits(maxDimension, maxBytes, maxImages int) {
+	cb.imageMaxDim = maxDimension
+	cb.imageMaxSize = maxBytes
+	cb.maxImages = maxImages
+}
+
+// SetMemoryRetrieval switches the memory section from the full MEMORY.md and
+// recent notes to the snippets of index that retriever ranks most relevant
+// to the current message.
+func (cb *ContextBuilder) SetMemoryRetrieval(index *memory.Index, retriever memory.Retriever, tokenBudget, topK int) {
+	cb.memoryIndex = index
+	cb.memoryRetriever = retriever
+	cb.memoryTokens = tokenBudget
+	cb.memoryTopK = topK
+}
+
 func (cb *ContextBuilder) getIdentity() string {
 	now := time.Now().Format("2006-01-02 15:04 (Monday)")
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
@@ -80,8 +108,8 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When remembering something, write to %s/memory/MEMORY.md`,
-		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection, workspacePath)
+3. **Memory** - When remembering something, use the memory tool: "remember" a short fact, "update" or "forget" facts that changed instead of adding contradictions, and "append_daily" for day-to-day observations. Do not edit MEMORY.md directly.`,
+		now, runtime, workspacePath, workspacePath, workspacePath, workspacePath, toolsSection)
 }
 
 func (cb *ContextBuilder) buildToolsSection() string {
@@ -107,6 +135,11 @@ func (cb *ContextBuilder) buildToolsSection() string {
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
+	return cb.buildSystemPrompt("")
+}
+
+// buildSystemPrompt builds the system prompt, retrieving memory relevant to query.
+func (cb *ContextBuilder) buildSystemPrompt(query string) string {
 	parts := []string{}
 
 	// Core identity section
@@ -129,7 +162,7 @@ The following skills extend your capabilities. To use a skill, read its SKILL.md
 	}
 
 	// Memory context
-	memoryContext := cb.memory.GetMemoryContext()
+	memoryContext := cb.buildMemoryContext(query)
 	if memoryContext != "" {
 		parts = append(parts, "# Memory\n\n"+memoryContext)
 	}
@@ -160,7 +193,7 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary string, currentMessage string, media []string, channel, chatID string) []providers.Message {
 	messages := []providers.Message{}
 
-	systemPrompt := cb.BuildSystemPrompt()
+	systemPrompt := cb.buildSystemPrompt(currentMessage)
 
 	// Add Current Session info if provided
 	if channel != "" && chatID != "" {
@@ -208,11 +241,42 @@ func (cb *ContextBuilder) BuildMessages(history []providers.Message, summary str
 	messages = append(messages, providers.Message{
 		Role:    "user",
 		Content: currentMessage,
+		Parts:   cb.buildImageParts(media),
 	})
 
 	return messages
 }
 
+// buildImageParts encodes the image files among media as data URL content parts.
+// Non-image media and images that fail to load are skipped.
+func (cb *ContextBuilder) buildImageParts(media []string) []providers.ContentPart {
+	var parts []providers.ContentPart
+	for _, path := range media {
+		if cb.maxImages > 0 && len(parts) >= cb.maxImages {
+			break
+		}
+		if !utils.IsImageFile(path) {
+			continue
+		}
+
+		dataURL, err := utils.EncodeImageDataURL(context.Background(), path, cb.imageMaxDim, cb.imageMaxSize)
+		if err != nil {
+			logger.WarnCF("agent", "Failed to attach image",
+				map[string]interface{}{
+					"path":  path,
+					"error": err.Error(),
+				})
+			continue
+		}
+
+		parts = append(parts, providers.ContentPart{
+			Type:     "image_url",
+			ImageURL: &providers.ImageURL{URL: dataURL},
+		})
+	}
+	return parts
+}
+
 func (cb *ContextBuilder) AddToolResult(messages []providers.Message, toolCallID, toolName, result string) []providers.Message {
 	messages = append(messages, providers.Message{
 		Role:       "tool",
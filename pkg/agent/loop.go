@@ -16,10 +16,18 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/alerts"
+	"github.com/sipeed/picoclaw/pkg/approval"
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/calendar"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/marketdata"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
 	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/utils"
@@ -35,23 +43,38 @@ type AgentLoop struct {
 	sessions       *session.SessionManager
 	contextBuilder *ContextBuilder
 	tools          *tools.ToolRegistry
+	approvals      *approval.Manager
 	maxTokens      int
 	temperature    float64
+	vision         bool // Whether the model accepts image input
+	consolidation  config.ConsolidationConfig
+	marketStream   *marketdata.Stream // nil when streaming is disabled
+	alertEngine    *alerts.Engine     // nil when alerts are disabled
+	calendar       *calendar.Calendar // nil when the economic calendar is disabled
+	calendarSched  config.CalendarScheduleConfig
 	running        bool
 	summarizing    sync.Map      // Tracks which sessions are currently being summarized
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
+	SessionKey      string             // Session identifier for history/context
+	Channel         string             // Target channel for tool execution
+	ChatID          string             // Target chat ID for tool execution
+	UserMessage     string             // User message content (may include prefix)
+	Media           []string           // Local paths or URLs of media attached to the message
+	DefaultResponse string             // Response when LLM returns empty
+	EnableSummary   bool               // Whether to trigger summarization
+	SendResponse    bool               // Whether to send response via bus
+	SchemaName      string             // Name of the response schema, if any
+	Schema          *jsonschema.Schema // When set, the final answer must be JSON matching this schema
+	System          bool               // Injected by the system (alerts, subagent results), not sent by the user
 }
 
+// maxSchemaRepairs is how many times a structured answer that fails
+// validation is sent back to the model with the errors.
+const maxSchemaRepairs = 2
+
 func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
 	workspace := cfg.WorkspacePath()
 	os.MkdirAll(workspace, 0755)
@@ -62,9 +85,14 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	toolsRegistry.Register(&tools.ListDirTool{})
 	toolsRegistry.Register(tools.NewExecTool(workspace))
 
+	// One rate limiter for every tool that calls market, news and web hosts
+	limiter := ratelimit.NewFromConfig(cfg.Tools.RateLimit)
+
 	braveAPIKey := cfg.Tools.Web.Search.APIKey
 	toolsRegistry.Register(tools.NewWebSearchTool(braveAPIKey, cfg.Tools.Web.Search.MaxResults))
-	toolsRegistry.Register(tools.NewWebFetchTool(50000))
+	webFetchTool := tools.NewWebFetchTool(50000)
+	webFetchTool.SetRateLimiter(limiter)
+	toolsRegistry.Register(webFetchTool)
 
 	// Register message tool
 	messageTool := tools.NewMessageTool()
@@ -79,14 +107,49 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	toolsRegistry.Register(messageTool)
 
 	// Register economic monitoring tools
-	toolsRegistry.Register(tools.NewMarketDataTool())
-	toolsRegistry.Register(tools.NewNewsFeedTool())
+	marketRouter := marketdata.NewRouterFromConfig(cfg.Tools.MarketData, limiter.Wrap(nil))
+	marketRouter.SetCache(marketdata.NewCacheFromConfig(cfg.Tools.MarketData.Cache, workspace))
+	marketTool := tools.NewMarketDataTool(marketRouter)
+	marketTool.SetHistory(marketdata.NewHistoryFromConfig(cfg.Tools.MarketData.History, workspace, marketRouter),
+		cfg.Tools.MarketData.History.MaxCandles)
+	toolsRegistry.Register(marketTool)
+	marketStream := marketdata.NewStreamFromConfig(cfg.Tools.MarketData.Stream)
+	if marketStream != nil {
+		toolsRegistry.Register(tools.NewMarketStreamTool(marketStream))
+	}
+	alertEngine := alerts.NewEngine(cfg.Tools.Alerts, workspace, alerts.NewMarketSource(marketRouter, marketStream), msgBus)
+	if alertEngine != nil {
+		toolsRegistry.Register(tools.NewAlertsTool(alertEngine))
+	}
+	econCalendar := calendar.NewFromConfig(cfg.Tools.Calendar, workspace, limiter.Wrap(nil))
+	if econCalendar != nil {
+		toolsRegistry.Register(tools.NewEconomicCalendarTool(econCalendar))
+	}
+	newsFeedTool := tools.NewNewsFeedTool()
+	newsFeedTool.SetRateLimiter(limiter)
+	toolsRegistry.Register(newsFeedTool)
 	toolsRegistry.Register(tools.NewStorageTool(workspace))
+	toolsRegistry.Register(tools.NewStructuredOutputTool(provider, cfg.Agents.Defaults.Model, workspace))
+
+	// Register memory search over the same index the context builder retrieves from
+	memoryIndex := memory.NewIndex(workspace, cfg.Agents.Memory.DataFiles)
+	var memoryRetriever memory.Retriever = memoryIndex
+	if cfg.Agents.Memory.Retrieval == "hybrid" {
+		if embeddings := providers.CreateEmbeddingsClient(cfg); embeddings != nil {
+			vectors := memory.NewVectorIndex(memoryIndex, embeddings, filepath.Join(workspace, "index", "memory_vectors.json"))
+			memoryRetriever = memory.NewHybrid(memoryIndex, vectors)
+		} else {
+			logger.WarnC("agent", "Hybrid memory retrieval needs providers.embeddings or an OpenAI key, using keyword search")
+		}
+	}
+	toolsRegistry.Register(tools.NewMemorySearchTool(memoryRetriever))
 
-	// Register spawn tool
-	subagentManager := tools.NewSubagentManager(provider, workspace, msgBus)
+	// Register spawn tool; subagents draw their tools from this registry
+	subagentManager := tools.NewSubagentManager(provider, cfg.Agents.Defaults.Model, cfg.Agents.Subagents, workspace, msgBus)
+	subagentManager.SetTools(toolsRegistry)
 	spawnTool := tools.NewSpawnTool(subagentManager)
 	toolsRegistry.Register(spawnTool)
+	toolsRegistry.Register(tools.NewParallelTasksTool(subagentManager))
 
 	// Register edit file tool
 	editFileTool := tools.NewEditFileTool(workspace)
@@ -97,8 +160,26 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 	// Create context builder and set tools registry
 	contextBuilder := NewContextBuilder(workspace)
 	contextBuilder.SetToolsRegistry(toolsRegistry)
+	contextBuilder.SetImageLimits(cfg.Agents.Vision.MaxDimension, cfg.Agents.Vision.MaxBytes, cfg.Agents.Vision.MaxImages)
+	// Register the memory tool on the store the context builder reads from
+	toolsRegistry.Register(tools.NewMemoryTool(contextBuilder.memory))
+	if cfg.Agents.Memory.Retrieval != "full" {
+		contextBuilder.SetMemoryRetrieval(memoryIndex, memoryRetriever, cfg.Agents.Memory.ContextTokens, cfg.Agents.Memory.TopK)
+	}
+
+	approvals, err := approval.NewManager(cfg.Tools.Approval, workspace, msgBus)
+	if err != nil {
+		logger.ErrorCF("agent", "Invalid approval config, dangerous tools will be denied",
+			map[string]interface{}{
+				"error": err.Error(),
+			})
+		approvals, _ = approval.NewManager(config.ApprovalConfig{
+			Enabled: true,
+			Rules:   []config.ApprovalRuleConfig{{Tool: "*"}},
+		}, workspace, msgBus)
+	}
 
-	return &AgentLoop{
+	al := &AgentLoop{
 		bus:            msgBus,
 		provider:       provider,
 		workspace:      workspace,
@@ -107,12 +188,30 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		maxIterations:  cfg.Agents.Defaults.MaxToolIterations,
 		maxTokens:      cfg.Agents.Defaults.MaxTokens,
 		temperature:    cfg.Agents.Defaults.Temperature,
+		vision:         cfg.Agents.Vision.SupportsModel(cfg.Agents.Defaults.Model),
+		consolidation:  cfg.Agents.Memory.Consolidation,
+		marketStream:   marketStream,
+		alertEngine:    alertEngine,
+		calendar:       econCalendar,
+		calendarSched:  cfg.Tools.Calendar.Schedule,
 		sessions:       sessionsManager,
 		contextBuilder: contextBuilder,
 		tools:          toolsRegistry,
+		approvals:      approvals,
 		running:        false,
 		summarizing:    sync.Map{},
 	}
+
+	// Subagent tool calls go through the same approval rules as the main agent's
+	subagentManager.SetToolExecutor(func(ctx context.Context, tc providers.ToolCall, channel, chatID string) (string, error) {
+		return al.executeToolCall(ctx, tc, processOptions{
+			SessionKey: "subagent",
+			Channel:    channel,
+			ChatID:     chatID,
+		})
+	})
+
+	return al
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
@@ -150,10 +249,47 @@ func (al *AgentLoop) Stop() {
 	al.running = false
 }
 
+// StartMarketStream connects the live price stream in the background until
+// ctx is cancelled. It returns false when streaming is disabled.
+func (al *AgentLoop) StartMarketStream(ctx context.Context) bool {
+	if al.marketStream == nil {
+		return false
+	}
+	go al.marketStream.Run(ctx)
+	return true
+}
+
+// StartAlerts checks the alert rules in the background until ctx is
+// cancelled. It returns false when alerts are disabled.
+func (al *AgentLoop) StartAlerts(ctx context.Context) bool {
+	if al.alertEngine == nil {
+		return false
+	}
+	go al.alertEngine.Run(ctx)
+	return true
+}
+
+// StartCalendar keeps pre- and post-event cron jobs for the important
+// upcoming releases in the background until ctx is cancelled. It returns
+// false when the calendar or its scheduling is disabled.
+func (al *AgentLoop) StartCalendar(ctx context.Context, cronService *cron.CronService) (bool, error) {
+	scheduler, err := calendar.NewScheduler(al.calendar, cronService, al.calendarSched)
+	if scheduler == nil {
+		return false, err
+	}
+	go scheduler.Run(ctx)
+	return true, nil
+}
+
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	al.tools.Register(tool)
 }
 
+// SetApprovalPrompter sets how approval requests from the CLI channel are answered.
+func (al *AgentLoop) SetApprovalPrompter(prompter approval.Prompter) {
+	al.approvals.SetPrompter(prompter)
+}
+
 func (al *AgentLoop) ProcessDirect(ctx context.Context, content, sessionKey string) (string, error) {
 	return al.ProcessDirectWithChannel(ctx, content, sessionKey, "cli", "direct")
 }
@@ -170,6 +306,31 @@ func (al *AgentLoop) ProcessDirectWithChannel(ctx context.Context, content, sess
 	return al.processMessage(ctx, msg)
 }
 
+// ProcessStructured runs a full agent turn, tools included, whose final
+// answer must be a JSON document matching schema. Invalid answers are fed
+// back to the model with the validation errors before giving up.
+func (al *AgentLoop) ProcessStructured(ctx context.Context, content, sessionKey, schemaName string, schema map[string]interface{}) (json.RawMessage, error) {
+	compiled := jsonschema.New(schema)
+	schemaJSON, _ := json.MarshalIndent(compiled.Map(), "", "  ")
+
+	response, err := al.runAgentLoop(ctx, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         "cli",
+		ChatID:          "direct",
+		UserMessage:     fmt.Sprintf("%s\n\nWhen you are done, reply with only a JSON document matching this JSON Schema:\n%s", content, schemaJSON),
+		DefaultResponse: "",
+		EnableSummary:   true,
+		SendResponse:    false,
+		SchemaName:      schemaName,
+		Schema:          compiled,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return providers.CheckStructured(response, compiled)
+}
+
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
 	// Add message preview to log
 	preview := utils.Truncate(msg.Content, 80)
@@ -192,6 +353,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
 		UserMessage:     msg.Content,
+		Media:           msg.Media,
 		DefaultResponse: "I've completed processing but have no response to give.",
 		EnableSummary:   true,
 		SendResponse:    false,
@@ -233,6 +395,7 @@ func (al *AgentLoop) processSystemMessage(ctx context.Context, msg bus.InboundMe
 		DefaultResponse: "Background task completed.",
 		EnableSummary:   false,
 		SendResponse:    true, // Send response back to original channel
+		System:          true,
 	})
 }
 
@@ -242,42 +405,87 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 	// 1. Update tool contexts
 	al.updateToolContexts(opts.Channel, opts.ChatID)
 
-	// 2. Build messages
+	// 2. Resume a turn that ran out of tool iterations if the user asked to
+	// continue; any other user message drops it. System messages leave it be.
+	prompt := opts.UserMessage
+	var resumed *session.TruncatedTurn
+	if truncated := al.sessions.GetTruncated(opts.SessionKey); truncated != nil && !opts.System {
+		al.sessions.SetTruncated(opts.SessionKey, nil)
+		if isContinueRequest(opts.UserMessage) {
+			resumed = truncated
+			prompt = fmt.Sprintf("%s\n\n[Resuming my previous request: %s\nThe tool calls below already ran in that turn; carry on from their results and do not repeat steps that already succeeded.]",
+				opts.UserMessage, truncated.Request)
+			logger.InfoCF("agent", "Resuming truncated turn",
+				map[string]interface{}{
+					"session_key": opts.SessionKey,
+					"iterations":  truncated.Iterations,
+					"messages":    len(truncated.Messages),
+				})
+		}
+	}
+
+	// 3. Build messages; images are only attached for vision-capable models.
+	// A resumed turn gets its earlier tool calls and results back.
+	var media []string
+	if al.vision {
+		media = opts.Media
+	}
 	history := al.sessions.GetHistory(opts.SessionKey)
 	summary := al.sessions.GetSummary(opts.SessionKey)
 	messages := al.contextBuilder.BuildMessages(
 		history,
 		summary,
-		opts.UserMessage,
-		nil,
+		prompt,
+		media,
 		opts.Channel,
 		opts.ChatID,
 	)
+	if resumed != nil {
+		messages = append(messages, resumed.Messages...)
+	}
 
-	// 3. Save user message to session
+	// 4. Save user message to session
 	al.sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
 
-	// 4. Run LLM iteration loop
-	finalContent, iteration, err := al.runLLMIteration(ctx, messages, opts)
+	// 5. Run LLM iteration loop
+	finalContent, iteration, exhausted, turn, err := al.runLLMIteration(ctx, messages, opts)
 	if err != nil {
 		return "", err
 	}
 
-	// 5. Handle empty response
+	// 6. Handle empty response; remember user turns cut short so they can be
+	// resumed. Their tool calls and results move from the history to the
+	// truncated turn, so summarization can't drop them and a resume doesn't
+	// see them twice.
+	if exhausted && !opts.System {
+		truncated := &session.TruncatedTurn{
+			Request:    opts.UserMessage,
+			Iterations: iteration,
+			At:         time.Now(),
+		}
+		if resumed != nil {
+			truncated.Request = resumed.Request
+			truncated.Messages = resumed.Messages
+			truncated.Iterations += resumed.Iterations
+		}
+		truncated.Messages = append(truncated.Messages, turn...)
+		al.sessions.RemoveToolMessages(opts.SessionKey, turn)
+		al.sessions.SetTruncated(opts.SessionKey, truncated)
+	}
 	if finalContent == "" {
 		finalContent = opts.DefaultResponse
 	}
 
-	// 6. Save final assistant message to session
+	// 7. Save final assistant message to session
 	al.sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
 	al.sessions.Save(al.sessions.GetOrCreate(opts.SessionKey))
 
-	// 7. Optional: summarization
+	// 8. Optional: summarization
 	if opts.EnableSummary {
 		al.maybeSummarize(opts.SessionKey)
 	}
 
-	// 8. Optional: send response via bus
+	// 9. Optional: send response via bus
 	if opts.SendResponse {
 		al.bus.PublishOutbound(bus.OutboundMessage{
 			Channel: opts.Channel,
@@ -286,12 +494,13 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 		})
 	}
 
-	// 9. Log response
+	// 10. Log response
 	responsePreview := utils.Truncate(finalContent, 120)
 	logger.InfoCF("agent", fmt.Sprintf("Response: %s", responsePreview),
 		map[string]interface{}{
 			"session_key":  opts.SessionKey,
 			"iterations":   iteration,
+			"truncated":    exhausted,
 			"final_length": len(finalContent),
 		})
 
@@ -299,10 +508,15 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, opts processOptions) (str
 }
 
 // runLLMIteration executes the LLM call loop with tool handling.
-// Returns the final content, iteration count, and any error.
-func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.Message, opts processOptions) (string, int, error) {
+// Returns the final content, iteration count, whether the iteration limit was
+// exhausted before the model produced a final answer, the assistant tool
+// calls and tool results of the turn, and any error.
+func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.Message, opts processOptions) (string, int, bool, []providers.Message, error) {
 	iteration := 0
+	repairs := 0
 	var finalContent string
+	var turn []providers.Message
+	answered := false
 
 	for iteration < al.maxIterations {
 		iteration++
@@ -314,18 +528,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 			})
 
 		// Build tool definitions
-		toolDefs := al.tools.GetDefinitions()
-		providerToolDefs := make([]providers.ToolDefinition, 0, len(toolDefs))
-		for _, td := range toolDefs {
-			providerToolDefs = append(providerToolDefs, providers.ToolDefinition{
-				Type: td["type"].(string),
-				Function: providers.ToolFunctionDefinition{
-					Name:        td["function"].(map[string]interface{})["name"].(string),
-					Description: td["function"].(map[string]interface{})["description"].(string),
-					Parameters:  td["function"].(map[string]interface{})["parameters"].(map[string]interface{}),
-				},
-			})
-		}
+		providerToolDefs := al.tools.ProviderDefinitions()
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
@@ -348,10 +551,14 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 			})
 
 		// Call LLM
-		response, err := al.provider.Chat(ctx, messages, providerToolDefs, al.model, map[string]interface{}{
+		options := map[string]interface{}{
 			"max_tokens":  al.maxTokens,
 			"temperature": al.temperature,
-		})
+		}
+		if opts.Schema != nil {
+			options["response_format"] = providers.JSONSchemaFormat(opts.SchemaName, opts.Schema.Map())
+		}
+		response, err := al.provider.Chat(ctx, messages, providerToolDefs, al.model, options)
 
 		if err != nil {
 			logger.ErrorCF("agent", "LLM call failed",
@@ -359,12 +566,34 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 					"iteration": iteration,
 					"error":     err.Error(),
 				})
-			return "", iteration, fmt.Errorf("LLM call failed: %w", err)
+			return "", iteration, false, turn, fmt.Errorf("LLM call failed: %w", err)
 		}
 
-		// Check if no tool calls - we're done
+		// Check if no tool calls - we're done, unless the answer must match a schema
 		if len(response.ToolCalls) == 0 {
+			if opts.Schema != nil {
+				doc, err := providers.CheckStructured(response.Content, opts.Schema)
+				if err != nil && repairs < maxSchemaRepairs && iteration < al.maxIterations {
+					repairs++
+					logger.WarnCF("agent", "Structured response failed validation, asking for a repair",
+						map[string]interface{}{
+							"iteration": iteration,
+							"attempt":   repairs,
+							"error":     err.Error(),
+						})
+					messages = append(messages,
+						providers.Message{Role: "assistant", Content: response.Content},
+						providers.RepairMessage(err),
+					)
+					continue
+				}
+				if err == nil {
+					response.Content = string(doc)
+				}
+			}
+
 			finalContent = response.Content
+			answered = true
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]interface{}{
 					"iteration":     iteration,
@@ -402,6 +631,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 			})
 		}
 		messages = append(messages, assistantMsg)
+		turn = append(turn, assistantMsg)
 
 		// Save assistant message with tool calls to session
 		al.sessions.AddFullMessage(opts.SessionKey, assistantMsg)
@@ -417,7 +647,7 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 					"iteration": iteration,
 				})
 
-			result, err := al.tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID)
+			result, err := al.executeToolCall(ctx, tc, opts)
 			if err != nil {
 				result = fmt.Sprintf("Error: %v", err)
 			}
@@ -428,13 +658,108 @@ func (al *AgentLoop) runLLMIteration(ctx context.Context, messages []providers.M
 				ToolCallID: tc.ID,
 			}
 			messages = append(messages, toolResultMsg)
+			turn = append(turn, toolResultMsg)
 
 			// Save tool result message to session
 			al.sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
 		}
 	}
 
-	return finalContent, iteration, nil
+	if !answered {
+		logger.WarnCF("agent", "Max tool iterations reached, requesting progress summary",
+			map[string]interface{}{
+				"iterations":  iteration,
+				"session_key": opts.SessionKey,
+			})
+		return al.summarizeExhaustedTurn(ctx, messages), iteration, true, turn, nil
+	}
+
+	return finalContent, iteration, false, turn, nil
+}
+
+// summarizeExhaustedTurn makes a final tool-less call asking the model to report
+// progress when a turn hits the iteration limit. Falls back to a plain list of
+// the tools that ran if that call fails.
+func (al *AgentLoop) summarizeExhaustedTurn(ctx context.Context, messages []providers.Message) string {
+	prompt := fmt.Sprintf(`You have reached the maximum of %d tool iterations for this turn and cannot call any more tools now.
+Reply to the user with:
+1. What you have done so far and the key results gathered.
+2. What remains to be done.
+End by telling the user they can reply "continue" to resume.`, al.maxIterations)
+
+	summaryMessages := append(messages, providers.Message{
+		Role:    "user",
+		Content: prompt,
+	})
+
+	response, err := al.provider.Chat(ctx, summaryMessages, nil, al.model, map[string]interface{}{
+		"max_tokens":  al.maxTokens,
+		"temperature": al.temperature,
+	})
+	if err == nil && strings.TrimSpace(response.Content) != "" {
+		return response.Content
+	}
+
+	if err != nil {
+		logger.ErrorCF("agent", "Progress summary call failed",
+			map[string]interface{}{
+				"error": err.Error(),
+			})
+	}
+
+	var toolNames []string
+	for _, m := range messages {
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				toolNames = append(toolNames, tc.Function.Name)
+			}
+		}
+	}
+
+	return fmt.Sprintf("I reached the limit of %d tool iterations before finishing. Tools used so far: %s. Reply \"continue\" to resume.",
+		al.maxIterations, strings.Join(toolNames, ", "))
+}
+
+// isContinueRequest reports whether a user message asks to resume a truncated turn.
+func isContinueRequest(content string) bool {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(content), ".!/")) {
+	case "continue", "go on", "resume", "keep going", "carry on":
+		return true
+	}
+	return false
+}
+
+// executeToolCall runs a tool call, pausing for human approval first when the
+// approval policy requires it. A denial is returned to the model as the result.
+func (al *AgentLoop) executeToolCall(ctx context.Context, tc providers.ToolCall, opts processOptions) (string, error) {
+	if reason, required := al.approvals.Check(tc.Name, tc.Arguments); required {
+		logger.InfoCF("agent", "Tool call requires approval",
+			map[string]interface{}{
+				"tool":   tc.Name,
+				"reason": reason,
+			})
+
+		decision, err := al.approvals.Request(ctx, &approval.Request{
+			Tool:       tc.Name,
+			Args:       tc.Arguments,
+			Reason:     reason,
+			Channel:    opts.Channel,
+			ChatID:     opts.ChatID,
+			SessionKey: opts.SessionKey,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		switch decision {
+		case approval.Denied:
+			return fmt.Sprintf("Tool call '%s' was denied by the user. Do not retry it; explain what you wanted to do instead.", tc.Name), nil
+		case approval.TimedOut:
+			return fmt.Sprintf("Tool call '%s' was not executed: the approval request timed out.", tc.Name), nil
+		}
+	}
+
+	return al.tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, opts.Channel, opts.ChatID)
 }
 
 // updateToolContexts updates the context for tools that need channel/chatID info.
@@ -449,6 +774,21 @@ func (al *AgentLoop) updateToolContexts(channel, chatID string) {
 			st.SetContext(channel, chatID)
 		}
 	}
+	if tool, ok := al.tools.Get("parallel_tasks"); ok {
+		if pt, ok := tool.(*tools.ParallelTasksTool); ok {
+			pt.SetContext(channel, chatID)
+		}
+	}
+	if tool, ok := al.tools.Get("memory"); ok {
+		if mt, ok := tool.(*tools.MemoryTool); ok {
+			mt.SetContext(channel, chatID)
+		}
+	}
+	if tool, ok := al.tools.Get("alerts"); ok {
+		if at, ok := tool.(*tools.AlertsTool); ok {
+			at.SetContext(channel, chatID)
+		}
+	}
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
@@ -0,0 +1,284 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func newTestLoop(t *testing.T, provider providers.LLMProvider) (*AgentLoop, *bus.MessageBus, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = workspace
+	cfg.Agents.Defaults.MaxToolIterations = 5
+	msgBus := bus.NewMessageBus()
+	return NewAgentLoop(cfg, msgBus, provider), msgBus, workspace
+}
+
+func lastMessage(messages []providers.Message) providers.Message {
+	return messages[len(messages)-1]
+}
+
+func TestTurnWithToolCall(t *testing.T) {
+	provider := providers.NewScriptedProvider(
+		providers.CallTool("storage", map[string]interface{}{
+			"action": "write",
+			"path":   "regime/current_regime.json",
+			"data":   `{"assets": {}}`,
+		}),
+		providers.Reply("Saved the regime file.").Then(func(messages []providers.Message) error {
+			if last := lastMessage(messages); last.Role != "tool" || !strings.Contains(last.Content, "Written") {
+				return fmt.Errorf("expected the storage result, got %s: %q", last.Role, last.Content)
+			}
+			return nil
+		}),
+	)
+	al, _, workspace := newTestLoop(t, provider)
+
+	response, err := al.ProcessDirect(context.Background(), "update the regime file", "test:tool")
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if response != "Saved the regime file." {
+		t.Errorf("response = %q", response)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "data", "regime", "current_regime.json")); err != nil {
+		t.Errorf("storage tool did not write the file: %v", err)
+	}
+	if provider.Remaining() != 0 {
+		t.Errorf("%d scripted steps unused", provider.Remaining())
+	}
+}
+
+func TestProcessStructuredRepairsInvalidAnswer(t *testing.T) {
+	provider := providers.NewScriptedProvider(
+		providers.Reply(`{"confidence": "high"}`),
+		providers.Reply("```json\n{\"confidence\": 8}\n```").Then(func(messages []providers.Message) error {
+			if last := lastMessage(messages); !strings.Contains(last.Content, "$.confidence: expected integer") {
+				return fmt.Errorf("validation errors were not fed back: %q", last.Content)
+			}
+			return nil
+		}),
+	)
+	al, _, _ := newTestLoop(t, provider)
+
+	doc, err := al.ProcessStructured(context.Background(), "score BTC", "test:structured", "score", map[string]interface{}{
+		"type":     "object",
+		"required": []string{"confidence"},
+		"properties": map[string]interface{}{
+			"confidence": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProcessStructured: %v", err)
+	}
+	if string(doc) != `{"confidence": 8}` {
+		t.Errorf("doc = %s", doc)
+	}
+	if _, ok := provider.Calls()[0].Options["response_format"]; !ok {
+		t.Errorf("response_format option was not passed to the provider")
+	}
+}
+
+func TestCronJobRunsThroughAgent(t *testing.T) {
+	provider := providers.NewScriptedProvider(
+		providers.Reply("Scan complete.").Then(func(messages []providers.Message) error {
+			if last := lastMessage(messages); last.Content != "run scan_markets" {
+				return fmt.Errorf("unexpected cron prompt %q", last.Content)
+			}
+			return nil
+		}),
+	)
+	al, msgBus, workspace := newTestLoop(t, provider)
+
+	cronService := cron.NewCronService(filepath.Join(workspace, "cron", "jobs.json"), nil)
+	cronTool := tools.NewCronTool(cronService, al, msgBus)
+
+	result := cronTool.ExecuteJob(context.Background(), &cron.CronJob{
+		ID:      "scan",
+		Payload: cron.CronPayload{Message: "run scan_markets"},
+	})
+	if result != "ok" {
+		t.Fatalf("ExecuteJob = %q", result)
+	}
+	if history := al.sessions.GetHistory("cron-scan"); len(history) != 2 {
+		t.Errorf("cron session has %d messages, want 2", len(history))
+	}
+}
+
+func TestChannelMessageRoundTrip(t *testing.T) {
+	provider := providers.NewScriptedProvider(providers.Reply("BTC is at 97,500."))
+	al, msgBus, _ := newTestLoop(t, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go al.Run(ctx)
+
+	msgBus.PublishInbound(bus.InboundMessage{
+		Channel:    "telegram",
+		SenderID:   "42",
+		ChatID:     "100",
+		Content:    "price of BTC?",
+		SessionKey: "telegram:100",
+	})
+
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no outbound message")
+	}
+	if out.Channel != "telegram" || out.ChatID != "100" || out.Content != "BTC is at 97,500." {
+		t.Errorf("outbound = %+v", out)
+	}
+}
+
+// exhaustingSteps makes the model call a tool on every iteration of a turn and
+// then answer the progress summary request.
+func exhaustingSteps(iterations int, summary string) []providers.ScriptedStep {
+	var steps []providers.ScriptedStep
+	for i := 0; i < iterations; i++ {
+		steps = append(steps, providers.CallTool("storage", map[string]interface{}{
+			"action": "write",
+			"path":   fmt.Sprintf("scan/step_%d.json", i),
+			"data":   `{"ok": true}`,
+		}))
+	}
+	return append(steps, providers.Reply(summary).Then(func(messages []providers.Message) error {
+		if last := lastMessage(messages); !strings.Contains(last.Content, "maximum of 5 tool iterations") {
+			return fmt.Errorf("expected the progress summary request, got %q", last.Content)
+		}
+		return nil
+	}))
+}
+
+func TestTruncatedTurnResume(t *testing.T) {
+	provider := providers.NewScriptedProvider(exhaustingSteps(5, "Wrote 5 of 8 scan files.")...)
+	al, _, _ := newTestLoop(t, provider)
+	ctx := context.Background()
+	key := "test:truncated"
+
+	response, err := al.ProcessDirect(ctx, "scan all markets", key)
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if response != "Wrote 5 of 8 scan files." {
+		t.Errorf("response = %q", response)
+	}
+	truncated := al.sessions.GetTruncated(key)
+	if truncated == nil || truncated.Request != "scan all markets" || truncated.Iterations != 5 || len(truncated.Messages) != 10 {
+		t.Fatalf("unexpected truncated turn: %+v", truncated)
+	}
+	if history := al.sessions.GetHistory(key); len(history) != 2 || history[0].Content != "scan all markets" {
+		t.Errorf("tool messages should move out of the history: %+v", history)
+	}
+
+	// A system alert routed to the session leaves the truncated turn alone
+	provider.Add(providers.Reply("Noted the alert."))
+	if _, err := al.processSystemMessage(ctx, bus.InboundMessage{
+		Channel:  "system",
+		SenderID: "alert",
+		ChatID:   key,
+		Content:  "BTC volatility spike",
+	}); err != nil {
+		t.Fatalf("processSystemMessage: %v", err)
+	}
+	if al.sessions.GetTruncated(key) == nil {
+		t.Fatal("a system message should not clear the truncated turn")
+	}
+
+	// An unrelated message drops the truncated turn
+	provider.Add(providers.Reply("BTC is at 97,500."))
+	if _, err := al.ProcessDirect(ctx, "price of BTC?", key); err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if al.sessions.GetTruncated(key) != nil {
+		t.Fatal("an unrelated message should clear the truncated turn")
+	}
+
+	// "continue" replays the earlier tool calls and results
+	provider.Add(exhaustingSteps(5, "Wrote 5 more.")...)
+	if _, err := al.ProcessDirect(ctx, "scan the rest", key); err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	provider.Add(providers.Reply("All scan files written.").Then(func(messages []providers.Message) error {
+		results := 0
+		for _, m := range messages {
+			if m.Role == "tool" && strings.Contains(m.Content, "Written") {
+				results++
+			}
+		}
+		if results != 5 {
+			return fmt.Errorf("expected the 5 earlier tool results, got %d", results)
+		}
+		if last := lastMessage(messages); last.Role != "tool" {
+			return fmt.Errorf("the replayed tool results should come last, got %s", last.Role)
+		}
+		return nil
+	}))
+	response, err = al.ProcessDirect(ctx, "Continue!", key)
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if response != "All scan files written." {
+		t.Errorf("response = %q", response)
+	}
+	if al.sessions.GetTruncated(key) != nil {
+		t.Error("a finished resume should clear the truncated turn")
+	}
+	history := al.sessions.GetHistory(key)
+	if user := history[len(history)-2]; user.Role != "user" || user.Content != "Continue!" {
+		t.Errorf("the user's own message should be saved, got %+v", user)
+	}
+	if provider.Remaining() != 0 {
+		t.Errorf("%d scripted steps unused", provider.Remaining())
+	}
+}
+
+func TestTruncatedTurnSurvivesSummarization(t *testing.T) {
+	var al *AgentLoop
+	key := "test:summarized"
+	steps := exhaustingSteps(5, "Wrote 5 of 8 scan files.")
+	// Summarization of the session trims its history mid-turn
+	steps[3] = steps[3].Then(func([]providers.Message) error {
+		al.sessions.TruncateHistory(key, 2)
+		return nil
+	})
+	provider := providers.NewScriptedProvider(steps...)
+	al, _, _ = newTestLoop(t, provider)
+
+	if _, err := al.ProcessDirect(context.Background(), "scan all markets", key); err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if truncated := al.sessions.GetTruncated(key); truncated == nil || len(truncated.Messages) != 10 {
+		t.Fatalf("the truncated turn should keep all its tool messages: %+v", truncated)
+	}
+	for _, m := range al.sessions.GetHistory(key) {
+		if m.Role == "tool" || len(m.ToolCalls) > 0 {
+			t.Errorf("tool message left in the history: %+v", m)
+		}
+	}
+}
+
+func TestIsContinueRequest(t *testing.T) {
+	for content, want := range map[string]bool{
+		"continue":       true,
+		" Keep going! ":  true,
+		"/resume":        true,
+		"continue later": false,
+		"price of BTC?":  false,
+	} {
+		if got := isContinueRequest(content); got != want {
+			t.Errorf("isContinueRequest(%q) = %v", content, got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	defaultMemoryTokens = 1500
+	defaultMemoryTopK   = 8
+
+	// memoryRetrievalTimeout bounds query embedding in hybrid mode.
+	memoryRetrievalTimeout = 10 * time.Second
+)
+
+// buildMemoryContext returns the memory section of the system prompt. Without
+// a retrieval index it falls back to the full long-term memory and recent
+// notes. With one, it injects the snippets ranking highest for query, then
+// the tail of today's note, stopping at the token budget.
+func (cb *ContextBuilder) buildMemoryContext(query string) string {
+	if cb.memoryIndex == nil {
+		return cb.memory.GetMemoryContext()
+	}
+	if cb.memoryIndex.Len() == 0 {
+		return ""
+	}
+
+	budget := cb.memoryTokens
+	if budget <= 0 {
+		budget = defaultMemoryTokens
+	}
+	topK := cb.memoryTopK
+	if topK <= 0 {
+		topK = defaultMemoryTopK
+	}
+	remaining := budget * 4 // Same 4 chars per token heuristic as estimateTokens
+
+	var sb strings.Builder
+	sb.WriteString("Only the memories most relevant to the current message are shown. Use the memory_search tool to recall anything else.\n")
+
+	todayFile := ""
+	if rel, ok := cb.relativeToWorkspace(cb.memory.TodayFile()); ok {
+		todayFile = rel
+	}
+
+	retriever := cb.memoryRetriever
+	if retriever == nil {
+		retriever = cb.memoryIndex
+	}
+	var results []memory.Result
+	if strings.TrimSpace(query) != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), memoryRetrievalTimeout)
+		results = retriever.Retrieve(ctx, query, memory.SearchOptions{Limit: topK})
+		cancel()
+	}
+
+	var snippets []string
+	for _, r := range results {
+		if r.Path == todayFile {
+			continue // Covered by today's notes below
+		}
+		snippet := formatSnippet(r)
+		if len(snippet) > remaining {
+			if len(snippets) > 0 {
+				break
+			}
+			snippet = utils.Truncate(snippet, remaining)
+		}
+		snippets = append(snippets, snippet)
+		remaining -= len(snippet)
+	}
+	if len(snippets) > 0 {
+		sb.WriteString("\n## Relevant Memories\n\n")
+		sb.WriteString(strings.Join(snippets, "\n\n"))
+		sb.WriteString("\n")
+	}
+
+	if today := strings.TrimSpace(cb.memory.ReadToday()); today != "" && remaining > 200 {
+		if len(today) > remaining {
+			today = "..." + strings.ToValidUTF8(today[len(today)-remaining:], "")
+		}
+		sb.WriteString("\n## Today's Notes\n\n")
+		sb.WriteString(today)
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimSpace(sb.String())
+}
+
+func formatSnippet(r memory.Result) string {
+	source := r.Path
+	if r.Heading != "" {
+		source += " › " + r.Heading
+	}
+	return fmt.Sprintf("### %s (%s)\n%s", source, r.Date.Format("2006-01-02"), r.Text)
+}
+
+func (cb *ContextBuilder) relativeToWorkspace(path string) (string, bool) {
+	rel, err := filepath.Rel(cb.workspace, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,678 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/timeseries"
+)
+
+// Event is a fired alert, also appended to data/alerts/fired.jsonl.
+type Event struct {
+	RuleID  string    `json:"rule_id"`
+	Symbol  string    `json:"symbol"`
+	Kind    Kind      `json:"kind"`
+	Time    time.Time `json:"timestamp"`
+	Price   float64   `json:"price"`
+	Value   float64   `json:"value"` // Move in %, z-score or volume multiple
+	Message string    `json:"message"`
+}
+
+// RuleStatus is a rule with its evaluation state.
+type RuleStatus struct {
+	Rule
+	Description string     `json:"description"`
+	LastValue   float64    `json:"last_value"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastFired   *time.Time `json:"last_fired,omitempty"`
+	Fired       int        `json:"fired"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type ruleState struct {
+	Active      bool       `json:"active,omitempty"` // Condition held at the last check
+	Side        int        `json:"side,omitempty"`   // level_cross: 1 above the level, -1 below
+	LastValue   float64    `json:"last_value"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	LastFired   *time.Time `json:"last_fired,omitempty"`
+	Fired       int        `json:"fired,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// result is the outcome of evaluating one rule.
+type result struct {
+	hit    bool
+	value  float64
+	price  float64
+	detail string
+	side   int
+}
+
+// Engine evaluates alert rules against a price source and publishes the
+// ones that fire to the message bus.
+type Engine struct {
+	source      PriceSource
+	bus         *bus.MessageBus
+	rulesPath   string
+	statePath   string
+	noisePath   string
+	history     *timeseries.Series
+	configRules []Rule
+	interval    time.Duration
+	cooldown    int
+	channel     string
+	chatID      string
+	now         func() time.Time
+
+	mu    sync.Mutex
+	state map[string]*ruleState
+}
+
+// NewEngine returns nil when alerts are disabled. Invalid rules in cfg, and
+// rules with nowhere to send their alert, are logged and skipped.
+func NewEngine(cfg config.AlertsConfig, workspace string, source PriceSource, msgBus *bus.MessageBus) *Engine {
+	if !cfg.Enabled {
+		return nil
+	}
+	e := &Engine{
+		source:    source,
+		bus:       msgBus,
+		rulesPath: filepath.Join(workspace, "alerts", "rules.json"),
+		statePath: filepath.Join(workspace, "alerts", "state.json"),
+		noisePath: filepath.Join(workspace, "data", "volatility", "current_noise_profile.json"),
+		history:   timeseries.Open(filepath.Join(workspace, "data", "alerts", "fired")),
+		interval:  time.Duration(cfg.CheckSeconds) * time.Second,
+		cooldown:  cfg.CooldownMinutes,
+		channel:   cfg.Channel,
+		chatID:    cfg.ChatID,
+		now:       time.Now,
+		state:     make(map[string]*ruleState),
+	}
+	if e.interval <= 0 {
+		e.interval = 30 * time.Second
+	}
+	if e.cooldown <= 0 {
+		e.cooldown = 60
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range cfg.Rules {
+		rule := RuleFromConfig(c)
+		if err := rule.Normalize(e.cooldown); err != nil {
+			logger.WarnCF("alerts", "Skipping invalid alert rule in config",
+				map[string]interface{}{
+					"symbol": c.Symbol,
+					"kind":   c.Kind,
+					"error":  err.Error(),
+				})
+			continue
+		}
+		if channel, _ := e.target(rule); channel == "" {
+			logger.WarnCF("alerts", "Skipping alert rule in config without a channel and chat_id",
+				map[string]interface{}{
+					"symbol": c.Symbol,
+					"kind":   c.Kind,
+				})
+			continue
+		}
+		if seen[rule.key()] {
+			continue
+		}
+		seen[rule.key()] = true
+		rule.ID = rule.configID()
+		e.configRules = append(e.configRules, rule)
+	}
+	e.loadState()
+	return e
+}
+
+// Rules returns the config rules followed by the ones added at runtime.
+func (e *Engine) Rules() []Rule {
+	rules := append([]Rule(nil), e.configRules...)
+	return append(rules, e.loadRules()...)
+}
+
+// loadRules reads the runtime rules on every call, so rules added from
+// another process (e.g. `picoclaw agent`) are picked up by the gateway.
+func (e *Engine) loadRules() []Rule {
+	data, err := os.ReadFile(e.rulesPath)
+	if err != nil {
+		return nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		logger.WarnCF("alerts", "Failed to read alert rules",
+			map[string]interface{}{
+				"path":  e.rulesPath,
+				"error": err.Error(),
+			})
+		return nil
+	}
+	return rules
+}
+
+// Add validates and stores a rule, refusing duplicates of existing rules.
+func (e *Engine) Add(rule Rule) (Rule, error) {
+	if err := rule.Normalize(e.cooldown); err != nil {
+		return Rule{}, err
+	}
+	if channel, _ := e.target(rule); channel == "" {
+		return Rule{}, fmt.Errorf("the rule needs a channel and chat_id to send its alert to")
+	}
+	rule.Source = ""
+	rule.ID = generateID()
+	rule.CreatedAt = e.now().UTC().Truncate(time.Second)
+
+	for _, existing := range e.configRules {
+		if existing.key() == rule.key() {
+			return Rule{}, fmt.Errorf("the same rule already exists (id: %s)", existing.ID)
+		}
+	}
+	err := fileio.Update(e.rulesPath, 0644, func(current []byte) ([]byte, error) {
+		var rules []Rule
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &rules); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", e.rulesPath, err)
+			}
+		}
+		for _, existing := range rules {
+			if existing.key() == rule.key() {
+				return nil, fmt.Errorf("the same rule already exists (id: %s)", existing.ID)
+			}
+		}
+		return json.MarshalIndent(append(rules, rule), "", "  ")
+	})
+	if err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Remove deletes a runtime rule. Rules from the config file can only be
+// removed there.
+func (e *Engine) Remove(id string) error {
+	for _, rule := range e.configRules {
+		if rule.ID == id {
+			return fmt.Errorf("rule %s is defined in config (tools.alerts.rules); remove it there", id)
+		}
+	}
+	found := false
+	err := fileio.Update(e.rulesPath, 0644, func(current []byte) ([]byte, error) {
+		var rules []Rule
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &rules); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", e.rulesPath, err)
+			}
+		}
+		kept := rules[:0]
+		for _, rule := range rules {
+			if rule.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, rule)
+		}
+		return json.MarshalIndent(kept, "", "  ")
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("rule %s not found", id)
+	}
+	e.mu.Lock()
+	delete(e.state, id)
+	e.mu.Unlock()
+	return nil
+}
+
+// Status lists the rules with their evaluation state.
+func (e *Engine) Status() []RuleStatus {
+	rules := e.Rules()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]RuleStatus, 0, len(rules))
+	for _, rule := range rules {
+		status := RuleStatus{Rule: rule, Description: rule.Describe()}
+		if st, ok := e.state[rule.ID]; ok {
+			status.LastValue = st.LastValue
+			status.LastChecked = st.LastChecked
+			status.LastFired = st.LastFired
+			status.Fired = st.Fired
+			status.LastError = st.LastError
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// History returns the alerts fired since the given time.
+func (e *Engine) History(since time.Time) ([]Event, error) {
+	entries, err := e.history.Range(since, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(entries))
+	for _, entry := range entries {
+		var ev Event
+		if err := json.Unmarshal(entry.Data, &ev); err == nil {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+// Run checks the rules every interval until ctx is cancelled.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Interval is how often Run checks the rules.
+func (e *Engine) Interval() time.Duration {
+	return e.interval
+}
+
+// Check evaluates every rule once and publishes the ones that fire.
+func (e *Engine) Check(ctx context.Context) []Event {
+	rules := e.Rules()
+	var events []Event
+	live := make(map[string]bool, len(rules))
+
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			break
+		}
+		live[rule.ID] = true
+
+		e.mu.Lock()
+		st, ok := e.state[rule.ID]
+		if !ok {
+			st = &ruleState{}
+			e.state[rule.ID] = st
+		}
+		prevSide := st.Side
+		e.mu.Unlock()
+
+		res, err := e.evaluate(ctx, rule, prevSide)
+		now := e.now().UTC().Truncate(time.Second)
+
+		e.mu.Lock()
+		st.LastChecked = &now
+		if err != nil {
+			st.LastError = err.Error()
+			e.mu.Unlock()
+			continue
+		}
+		st.LastError = ""
+		st.LastValue = math.Round(res.value*100) / 100
+		if res.side != 0 {
+			st.Side = res.side
+		}
+
+		fire := false
+		switch {
+		case !res.hit:
+			st.Active = false
+		case st.Active:
+			// Still the move that already fired (or was suppressed)
+		default:
+			st.Active = true
+			cooldown := time.Duration(rule.CooldownMinutes) * time.Minute
+			if st.LastFired == nil || now.Sub(*st.LastFired) >= cooldown {
+				fire = true
+				st.LastFired = &now
+				st.Fired++
+			}
+		}
+		e.mu.Unlock()
+
+		if fire {
+			ev := Event{
+				RuleID:  rule.ID,
+				Symbol:  rule.Symbol,
+				Kind:    rule.Kind,
+				Time:    now,
+				Price:   res.price,
+				Value:   math.Round(res.value*100) / 100,
+				Message: res.detail,
+			}
+			e.publish(rule, ev)
+			events = append(events, ev)
+		}
+	}
+
+	e.mu.Lock()
+	for id := range e.state {
+		if !live[id] {
+			delete(e.state, id)
+		}
+	}
+	e.mu.Unlock()
+	e.saveState()
+	return events
+}
+
+func (e *Engine) evaluate(ctx context.Context, rule Rule, prevSide int) (result, error) {
+	window := time.Duration(rule.WindowMinutes) * time.Minute
+
+	switch rule.Kind {
+	case KindPercentMove:
+		ticks, err := e.source.Prices(ctx, rule.Symbol, window)
+		if err != nil {
+			return result{}, err
+		}
+		if len(ticks) < 2 {
+			return result{}, fmt.Errorf("not enough prices for %s", rule.Symbol)
+		}
+		last := ticks[len(ticks)-1].Price
+		low, high := last, last
+		for _, t := range ticks {
+			low = math.Min(low, t.Price)
+			high = math.Max(high, t.Price)
+		}
+		up, down := pct(last, low), pct(last, high)
+		value, from := up, low
+		if rule.Direction == "down" || (rule.Direction == "" && -down > up) {
+			value, from = down, high
+		}
+		return result{
+			hit:    matches(rule.Direction, value) && math.Abs(value) >= rule.Percent,
+			value:  value,
+			price:  last,
+			detail: fmt.Sprintf("%s %+.2f%% within %dm (%s → %s)", rule.Symbol, value, rule.WindowMinutes, price(from), price(last)),
+		}, nil
+
+	case KindZScore:
+		ticks, err := e.source.Prices(ctx, rule.Symbol, window)
+		if err != nil {
+			return result{}, err
+		}
+		if len(ticks) < 2 {
+			return result{}, fmt.Errorf("not enough prices for %s", rule.Symbol)
+		}
+		first, last := ticks[0].Price, ticks[len(ticks)-1].Price
+		vol, basis, err := e.volatility(ctx, rule.Symbol)
+		if err != nil {
+			return result{}, err
+		}
+		// Scale the hourly volatility to the window
+		vol *= math.Sqrt(window.Hours())
+		move := pct(last, first) / 100
+		z := move / vol
+		return result{
+			hit:   matches(rule.Direction, z) && math.Abs(z) >= rule.ZScore,
+			value: z,
+			price: last,
+			detail: fmt.Sprintf("%s %+.2f%% in %dm is a %+.1fσ move (%s → %s, %s)",
+				rule.Symbol, move*100, rule.WindowMinutes, z, price(first), price(last), basis),
+		}, nil
+
+	case KindLevelCross:
+		p, err := e.source.Price(ctx, rule.Symbol)
+		if err != nil {
+			return result{}, err
+		}
+		side := -1
+		if p >= rule.Level {
+			side = 1
+		}
+		crossed := prevSide != 0 && side != prevSide
+		word := "below"
+		if side == 1 {
+			word = "above"
+		}
+		return result{
+			hit:    crossed && matches(rule.Direction, float64(side)),
+			value:  p,
+			price:  p,
+			side:   side,
+			detail: fmt.Sprintf("%s crossed %s %s (now %s)", rule.Symbol, word, price(rule.Level), price(p)),
+		}, nil
+
+	case KindVolumeSpike:
+		candles, err := e.source.Candles(ctx, rule.Symbol, rule.Interval, volumeAverageCandles+1)
+		if err != nil {
+			return result{}, err
+		}
+		if len(candles) < 2 {
+			return result{}, fmt.Errorf("not enough candles for %s", rule.Symbol)
+		}
+		current := candles[len(candles)-1]
+		var sum float64
+		for _, c := range candles[:len(candles)-1] {
+			sum += c.Volume
+		}
+		avg := sum / float64(len(candles)-1)
+		if avg <= 0 {
+			return result{}, fmt.Errorf("no volume data for %s", rule.Symbol)
+		}
+		multiple := current.Volume / avg
+		dir := 1.0
+		if current.Close < current.Open {
+			dir = -1
+		}
+		return result{
+			hit:   matches(rule.Direction, dir) && multiple >= rule.Multiplier,
+			value: multiple,
+			price: current.Close,
+			detail: fmt.Sprintf("%s %s volume %.1f× the %d-candle average (%+.2f%% candle, now %s)",
+				rule.Symbol, rule.Interval, multiple, len(candles)-1, pct(current.Close, current.Open), price(current.Close)),
+		}, nil
+	}
+	return result{}, fmt.Errorf("unknown kind %q", rule.Kind)
+}
+
+// volatility returns the hourly return volatility of symbol, from the
+// volatility_noise_filter profile when it has the symbol and otherwise from
+// the last 48 hourly candles.
+func (e *Engine) volatility(ctx context.Context, symbol string) (float64, string, error) {
+	if vol := noiseProfileVol(e.noisePath, symbol); vol > 0 {
+		return vol, "noise profile", nil
+	}
+	candles, err := e.source.Candles(ctx, symbol, "1h", 49)
+	if err != nil {
+		return 0, "", err
+	}
+	var returns []float64
+	for i := 1; i < len(candles); i++ {
+		if prev := candles[i-1].Close; prev > 0 {
+			returns = append(returns, candles[i].Close/prev-1)
+		}
+	}
+	if len(returns) < 10 {
+		return 0, "", fmt.Errorf("not enough history to measure %s volatility", symbol)
+	}
+	var mean, sq float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	for _, r := range returns {
+		sq += (r - mean) * (r - mean)
+	}
+	vol := math.Sqrt(sq / float64(len(returns)-1))
+	if vol <= 0 {
+		return 0, "", fmt.Errorf("%s has no measurable volatility", symbol)
+	}
+	return vol, "48h realized volatility", nil
+}
+
+// noiseProfileVol reads vol_1h for symbol from the noise profile, which may
+// be a list of assets, {"assets": [...]} or a map keyed by symbol.
+func noiseProfileVol(path, symbol string) float64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	type asset struct {
+		Symbol string  `json:"symbol"`
+		Vol1h  float64 `json:"vol_1h"`
+	}
+	var list []asset
+	if json.Unmarshal(data, &list) != nil {
+		var wrapped struct {
+			Assets []asset `json:"assets"`
+		}
+		if json.Unmarshal(data, &wrapped) == nil && len(wrapped.Assets) > 0 {
+			list = wrapped.Assets
+		} else {
+			var byKey map[string]asset
+			if json.Unmarshal(data, &byKey) == nil {
+				for key, a := range byKey {
+					if a.Symbol == "" {
+						a.Symbol = key
+					}
+					list = append(list, a)
+				}
+			}
+		}
+	}
+	for _, a := range list {
+		if strings.EqualFold(a.Symbol, symbol) {
+			return a.Vol1h
+		}
+	}
+	return 0
+}
+
+// publish wakes the agent with the alert or sends it to the channel, and
+// logs it to the history.
+// target is the channel and chat a rule's alert goes to: the rule's own, or
+// else the engine's. Both are empty when neither is set.
+func (e *Engine) target(rule Rule) (string, string) {
+	if rule.Channel != "" && rule.ChatID != "" {
+		return rule.Channel, rule.ChatID
+	}
+	if e.channel != "" && e.chatID != "" {
+		return e.channel, e.chatID
+	}
+	return "", ""
+}
+
+func (e *Engine) publish(rule Rule, ev Event) {
+	channel, chatID := e.target(rule)
+
+	text := fmt.Sprintf("🚨 %s\nRule %s: %s", ev.Message, rule.ID, rule.Describe())
+	logger.InfoCF("alerts", "Alert fired",
+		map[string]interface{}{
+			"rule":    rule.ID,
+			"symbol":  rule.Symbol,
+			"value":   ev.Value,
+			"action":  rule.Action,
+			"channel": channel,
+		})
+
+	if e.bus != nil {
+		if rule.Action == ActionNotify {
+			e.bus.PublishOutbound(bus.OutboundMessage{
+				Channel: channel,
+				ChatID:  chatID,
+				Content: text,
+			})
+		} else {
+			content := "Price alert fired:\n" + text
+			if rule.Note != "" {
+				content += "\n\n" + rule.Note
+			}
+			e.bus.PublishInbound(bus.InboundMessage{
+				Channel:  "system",
+				SenderID: "alert:" + rule.ID,
+				// Format: "original_channel:original_chat_id" for routing back
+				ChatID:   channel + ":" + chatID,
+				Content:  content,
+				Metadata: map[string]string{"alert_rule": rule.ID},
+			})
+		}
+	}
+
+	data, _ := json.Marshal(ev)
+	if _, err := e.history.Append(data); err != nil {
+		logger.WarnCF("alerts", "Failed to log alert",
+			map[string]interface{}{
+				"error": err.Error(),
+			})
+	}
+}
+
+func (e *Engine) loadState() {
+	data, err := os.ReadFile(e.statePath)
+	if err != nil {
+		return
+	}
+	var state map[string]*ruleState
+	if json.Unmarshal(data, &state) == nil && state != nil {
+		e.state = state
+	}
+}
+
+func (e *Engine) saveState() {
+	e.mu.Lock()
+	data, err := json.MarshalIndent(e.state, "", "  ")
+	e.mu.Unlock()
+	if err == nil {
+		err = fileio.WriteFile(e.statePath, data, 0644)
+	}
+	if err != nil {
+		logger.WarnCF("alerts", "Failed to save alert state",
+			map[string]interface{}{
+				"path":  e.statePath,
+				"error": err.Error(),
+			})
+	}
+}
+
+// matches reports whether a signed value goes the rule's direction.
+func matches(direction string, value float64) bool {
+	switch direction {
+	case "up":
+		return value > 0
+	case "down":
+		return value < 0
+	}
+	return true
+}
+
+func pct(price, ref float64) float64 {
+	if ref == 0 {
+		return 0
+	}
+	return (price - ref) / ref * 100
+}
+
+// price formats a price with precision suited to its size.
+func price(p float64) string {
+	if p >= 1000 {
+		return fmt.Sprintf("%.2f", p)
+	}
+	return fmt.Sprintf("%.6g", p)
+}
+
+func generateID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
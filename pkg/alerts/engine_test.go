@@ -0,0 +1,278 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/marketdata"
+)
+
+// fakeSource serves fixed prices and candles per symbol.
+type fakeSource struct {
+	prices  map[string][]float64 // Oldest first over the requested window
+	candles map[string][]marketdata.Candle
+}
+
+func (f *fakeSource) Price(ctx context.Context, symbol string) (float64, error) {
+	p := f.prices[symbol]
+	if len(p) == 0 {
+		return 0, fmt.Errorf("no price for %s", symbol)
+	}
+	return p[len(p)-1], nil
+}
+
+func (f *fakeSource) Prices(ctx context.Context, symbol string, window time.Duration) ([]marketdata.Tick, error) {
+	p := f.prices[symbol]
+	if len(p) == 0 {
+		return nil, fmt.Errorf("no price for %s", symbol)
+	}
+	start := time.Now().Add(-window)
+	ticks := make([]marketdata.Tick, len(p))
+	for i, price := range p {
+		ticks[i] = marketdata.Tick{Time: start.Add(time.Duration(i) * window / time.Duration(len(p))), Price: price}
+	}
+	return ticks, nil
+}
+
+func (f *fakeSource) Candles(ctx context.Context, symbol, interval string, limit int) ([]marketdata.Candle, error) {
+	c := f.candles[symbol+"/"+interval]
+	if len(c) == 0 {
+		return nil, fmt.Errorf("no %s candles for %s", interval, symbol)
+	}
+	if len(c) > limit {
+		c = c[len(c)-limit:]
+	}
+	return c, nil
+}
+
+func newTestEngine(t *testing.T, source PriceSource, rules ...config.AlertRuleConfig) (*Engine, *bus.MessageBus, string) {
+	t.Helper()
+	workspace := t.TempDir()
+	msgBus := bus.NewMessageBus()
+	engine := NewEngine(config.AlertsConfig{
+		Enabled:         true,
+		CooldownMinutes: 60,
+		Channel:         "telegram",
+		ChatID:          "42",
+		Rules:           rules,
+	}, workspace, source, msgBus)
+	return engine, msgBus, workspace
+}
+
+func inbound(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("expected an inbound message")
+	}
+	return msg
+}
+
+func TestPercentMoveFiresOncePerMove(t *testing.T) {
+	source := &fakeSource{prices: map[string][]float64{"BTCUSDT": {100, 101, 100.5}}}
+	engine, msgBus, workspace := newTestEngine(t, source, config.AlertRuleConfig{
+		Symbol: "btc/usdt", Kind: "percent_move", Percent: 3, WindowMinutes: 5, Note: "Explain the move.",
+	})
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if events := engine.Check(ctx); len(events) != 0 {
+		t.Fatalf("a 1%% move should not fire: %+v", events)
+	}
+
+	// Falling 4% off the window's high fires
+	source.prices["BTCUSDT"] = []float64{100, 104, 99.8}
+	events := engine.Check(ctx)
+	if len(events) != 1 || events[0].Value != -4.04 || events[0].Price != 99.8 {
+		t.Fatalf("expected one -4.04%% alert: %+v", events)
+	}
+	msg := inbound(t, msgBus)
+	if msg.Channel != "system" || msg.ChatID != "telegram:42" || msg.SenderID != "alert:"+events[0].RuleID {
+		t.Fatalf("unexpected routing: %+v", msg)
+	}
+	if !strings.Contains(msg.Content, "BTCUSDT -4.04% within 5m (104 → 99.8)") || !strings.HasSuffix(msg.Content, "Explain the move.") {
+		t.Fatalf("unexpected content: %s", msg.Content)
+	}
+
+	// The same move is not reported again while it lasts
+	now = now.Add(2 * time.Hour)
+	if events := engine.Check(ctx); len(events) != 0 {
+		t.Fatalf("a continuing move should not fire again: %+v", events)
+	}
+
+	// Once it clears the rule re-arms, but the cooldown still applies
+	source.prices["BTCUSDT"] = []float64{100, 100, 100}
+	engine.Check(ctx)
+	now = now.Add(10 * time.Minute)
+	source.prices["BTCUSDT"] = []float64{100, 96, 99.5}
+	if events := engine.Check(ctx); len(events) != 1 {
+		t.Fatalf("a new move after the cooldown should fire: %+v", events)
+	}
+	inbound(t, msgBus)
+	source.prices["BTCUSDT"] = []float64{100, 100, 100}
+	engine.Check(ctx)
+	now = now.Add(10 * time.Minute)
+	source.prices["BTCUSDT"] = []float64{100, 95, 99}
+	if events := engine.Check(ctx); len(events) != 0 {
+		t.Fatalf("a move within the cooldown should not fire: %+v", events)
+	}
+
+	history, err := engine.History(time.Time{})
+	if err != nil || len(history) != 2 {
+		t.Fatalf("expected 2 logged alerts, got %d (%v)", len(history), err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "alerts", "state.json")); err != nil {
+		t.Fatalf("state should be saved: %v", err)
+	}
+
+	// A restarted engine remembers the cooldown
+	restarted, _, _ := newTestEngine(t, source)
+	restarted.statePath = engine.statePath
+	restarted.loadState()
+	if st := restarted.state[events[0].RuleID]; st == nil || st.Fired != 2 {
+		t.Fatalf("state was not restored: %+v", st)
+	}
+}
+
+func TestLevelCrossAndVolumeSpike(t *testing.T) {
+	volumes := make([]marketdata.Candle, 21)
+	for i := range volumes {
+		volumes[i] = marketdata.Candle{Open: 10, Close: 10, Volume: 100}
+	}
+	source := &fakeSource{
+		prices:  map[string][]float64{"XAUUSD": {2390}},
+		candles: map[string][]marketdata.Candle{"ETHUSDT/5m": volumes},
+	}
+	engine, msgBus, _ := newTestEngine(t, source,
+		config.AlertRuleConfig{Symbol: "XAUUSD", Kind: "level_cross", Level: 2400, Direction: "above", Action: "notify"},
+		config.AlertRuleConfig{Symbol: "ETHUSDT", Kind: "volume_spike", Multiplier: 4, Direction: "down"},
+	)
+	ctx := context.Background()
+
+	// The first check only learns which side of the level the price is on
+	if events := engine.Check(ctx); len(events) != 0 {
+		t.Fatalf("nothing should fire yet: %+v", events)
+	}
+
+	source.prices["XAUUSD"] = []float64{2405}
+	volumes[20] = marketdata.Candle{Open: 10, Close: 9, Volume: 500}
+	events := engine.Check(ctx)
+	if len(events) != 2 || events[0].Symbol != "XAUUSD" || events[1].Value != 5 {
+		t.Fatalf("expected the cross and the spike: %+v", events)
+	}
+	ctxOut, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctxOut)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || !strings.Contains(out.Content, "XAUUSD crossed above 2400.00") {
+		t.Fatalf("notify rules should message the channel: %+v", out)
+	}
+	if msg := inbound(t, msgBus); !strings.Contains(msg.Content, "ETHUSDT 5m volume 5.0×") {
+		t.Fatalf("unexpected alert: %s", msg.Content)
+	}
+
+	// Falling back under the level is a cross the wrong way
+	source.prices["XAUUSD"] = []float64{2395}
+	volumes[20] = marketdata.Candle{Open: 10, Close: 11, Volume: 500}
+	if events := engine.Check(ctx); len(events) != 0 {
+		t.Fatalf("moves against the rule's direction should not fire: %+v", events)
+	}
+}
+
+func TestZScoreUsesNoiseProfile(t *testing.T) {
+	source := &fakeSource{prices: map[string][]float64{"BTCUSDT": {100, 101, 102.5}}}
+	engine, msgBus, workspace := newTestEngine(t, source, config.AlertRuleConfig{
+		Symbol: "BTCUSDT", Kind: "zscore", ZScore: 2, WindowMinutes: 60,
+	})
+	ctx := context.Background()
+
+	// Without a profile or candles there's nothing to measure against
+	engine.Check(ctx)
+	if status := engine.Status(); status[0].LastError == "" {
+		t.Fatalf("expected a volatility error: %+v", status[0])
+	}
+
+	profile := filepath.Join(workspace, "data", "volatility", "current_noise_profile.json")
+	os.MkdirAll(filepath.Dir(profile), 0755)
+	os.WriteFile(profile, []byte(`[{"symbol":"BTCUSDT","vol_1h":0.01}]`), 0644)
+	events := engine.Check(ctx)
+	if len(events) != 1 || events[0].Value != 2.5 {
+		t.Fatalf("a 2.5%% hourly move against 1%% volatility is 2.5σ: %+v", events)
+	}
+	if msg := inbound(t, msgBus); !strings.Contains(msg.Content, "+2.5σ move") || !strings.Contains(msg.Content, "noise profile") {
+		t.Fatalf("unexpected alert: %s", msg.Content)
+	}
+	if vol := noiseProfileVol(profile, "ethusdt"); vol != 0 {
+		t.Fatalf("unknown symbols should have no volatility, got %g", vol)
+	}
+	os.WriteFile(profile, []byte(`{"ETHUSDT":{"vol_1h":0.02}}`), 0644)
+	if vol := noiseProfileVol(profile, "ETHUSDT"); vol != 0.02 {
+		t.Fatalf("profiles keyed by symbol should be read, got %g", vol)
+	}
+}
+
+func TestAddAndRemoveRules(t *testing.T) {
+	engine, _, _ := newTestEngine(t, &fakeSource{}, config.AlertRuleConfig{
+		Symbol: "BTCUSDT", Kind: "percent_move", Percent: 3,
+	})
+
+	rule, err := engine.Add(Rule{Symbol: "ethusdt", Kind: KindLevelCross, Level: 4000, Direction: "below"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Symbol != "ETHUSDT" || rule.Direction != "down" || rule.Action != ActionAgent || rule.CooldownMinutes != 60 {
+		t.Fatalf("defaults not applied: %+v", rule)
+	}
+	if _, err := engine.Add(Rule{Symbol: "ETHUSDT", Kind: KindLevelCross, Level: 4000, Direction: "down"}); err == nil {
+		t.Fatal("duplicate rules should be refused")
+	}
+	if _, err := engine.Add(Rule{Symbol: "BTCUSDT", Kind: KindPercentMove, Percent: 3, WindowMinutes: 5}); err == nil {
+		t.Fatal("duplicates of config rules should be refused")
+	}
+	if _, err := engine.Add(Rule{Symbol: "BTCUSDT", Kind: "moon"}); err == nil {
+		t.Fatal("unknown kinds should be refused")
+	}
+
+	rules := engine.Rules()
+	if len(rules) != 2 || rules[0].Source != "config" || !strings.HasPrefix(rules[0].ID, "cfg-") {
+		t.Fatalf("unexpected rules: %+v", rules)
+	}
+	if err := engine.Remove(rules[0].ID); err == nil {
+		t.Fatal("config rules can't be removed at runtime")
+	}
+	if err := engine.Remove(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Remove(rule.ID); err == nil {
+		t.Fatal("removing a missing rule should fail")
+	}
+	if len(engine.Rules()) != 1 {
+		t.Fatal("rule was not removed")
+	}
+}
+
+func TestRulesWithoutTargetRefused(t *testing.T) {
+	engine := NewEngine(config.AlertsConfig{
+		Enabled: true,
+		Rules: []config.AlertRuleConfig{
+			{Symbol: "BTCUSDT", Kind: "percent_move", Percent: 3},
+			{Symbol: "ETHUSDT", Kind: "percent_move", Percent: 3, Action: "notify", Channel: "telegram", ChatID: "42"},
+		},
+	}, t.TempDir(), &fakeSource{}, bus.NewMessageBus())
+
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].Symbol != "ETHUSDT" {
+		t.Fatalf("only the rule with a channel should be kept: %+v", rules)
+	}
+	if _, err := engine.Add(Rule{Symbol: "SOLUSDT", Kind: KindLevelCross, Level: 200, Direction: "up"}); err == nil {
+		t.Fatal("a rule with nowhere to send its alert should be refused")
+	}
+}
@@ -0,0 +1,207 @@
+// Package alerts evaluates declarative price alert rules in Go and only
+// involves the agent, or messages a channel, when a rule fires. Rules are
+// edge-triggered: once fired, a rule stays quiet until its condition has
+// cleared and its cooldown has passed, so a move is reported once.
+package alerts
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/marketdata"
+)
+
+// Kind is the condition a rule checks.
+type Kind string
+
+const (
+	KindPercentMove Kind = "percent_move" // Price moved Percent off the window's low or high
+	KindZScore      Kind = "zscore"       // Move over the window is ZScore standard deviations
+	KindLevelCross  Kind = "level_cross"  // Price crossed Level
+	KindVolumeSpike Kind = "volume_spike" // Current candle volume is Multiplier times the average
+)
+
+// Kinds lists the supported rule kinds.
+var Kinds = []Kind{KindPercentMove, KindZScore, KindLevelCross, KindVolumeSpike}
+
+const (
+	ActionAgent  = "agent"  // Wake the agent with the alert
+	ActionNotify = "notify" // Send the alert text to the channel
+)
+
+// volumeAverageCandles is how many earlier candles a volume spike is
+// measured against.
+const volumeAverageCandles = 20
+
+// Rule is a declarative alert rule. Direction is "up", "down" or empty for
+// either; for level_cross "up" means crossing above the level.
+type Rule struct {
+	ID              string    `json:"id"`
+	Symbol          string    `json:"symbol"`
+	Kind            Kind      `json:"kind"`
+	Percent         float64   `json:"percent,omitempty"`
+	WindowMinutes   int       `json:"window_minutes,omitempty"`
+	ZScore          float64   `json:"z_score,omitempty"`
+	Level           float64   `json:"level,omitempty"`
+	Direction       string    `json:"direction,omitempty"`
+	Multiplier      float64   `json:"multiplier,omitempty"`
+	Interval        string    `json:"interval,omitempty"`
+	CooldownMinutes int       `json:"cooldown_minutes"`
+	Action          string    `json:"action"`
+	Channel         string    `json:"channel,omitempty"`
+	ChatID          string    `json:"chat_id,omitempty"`
+	Note            string    `json:"note,omitempty"` // Instructions for the agent when the rule fires
+	Source          string    `json:"source,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+}
+
+// RuleFromConfig converts a rule from the config file.
+func RuleFromConfig(c config.AlertRuleConfig) Rule {
+	return Rule{
+		Symbol:          c.Symbol,
+		Kind:            Kind(c.Kind),
+		Percent:         c.Percent,
+		WindowMinutes:   c.WindowMinutes,
+		ZScore:          c.ZScore,
+		Level:           c.Level,
+		Direction:       c.Direction,
+		Multiplier:      c.Multiplier,
+		Interval:        c.Interval,
+		CooldownMinutes: c.CooldownMinutes,
+		Action:          c.Action,
+		Channel:         c.Channel,
+		ChatID:          c.ChatID,
+		Note:            c.Note,
+		Source:          "config",
+	}
+}
+
+// Normalize fills in defaults and checks the rule's parameters.
+func (r *Rule) Normalize(defaultCooldown int) error {
+	r.Symbol = marketdata.Canonical(r.Symbol)
+	if r.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+
+	switch strings.ToLower(r.Direction) {
+	case "", "any", "either", "both":
+		r.Direction = ""
+	case "up", "above", "rise":
+		r.Direction = "up"
+	case "down", "below", "fall":
+		r.Direction = "down"
+	default:
+		return fmt.Errorf("direction must be up, down or empty, got %q", r.Direction)
+	}
+
+	switch r.Kind {
+	case KindPercentMove:
+		if r.Percent <= 0 {
+			return fmt.Errorf("percent_move needs a positive percent")
+		}
+		if r.WindowMinutes <= 0 {
+			r.WindowMinutes = 5
+		}
+	case KindZScore:
+		if r.ZScore <= 0 {
+			r.ZScore = 2
+		}
+		if r.WindowMinutes <= 0 {
+			r.WindowMinutes = 60
+		}
+	case KindLevelCross:
+		if r.Level <= 0 {
+			return fmt.Errorf("level_cross needs a positive level")
+		}
+	case KindVolumeSpike:
+		if r.Multiplier <= 1 {
+			r.Multiplier = 3
+		}
+		if r.Interval == "" {
+			r.Interval = "5m"
+		}
+		if _, ok := intervals[r.Interval]; !ok {
+			return fmt.Errorf("unsupported interval %q", r.Interval)
+		}
+	default:
+		return fmt.Errorf("unknown kind %q (use %s)", r.Kind, kindList())
+	}
+
+	if r.CooldownMinutes <= 0 {
+		r.CooldownMinutes = defaultCooldown
+	}
+	switch r.Action {
+	case "":
+		r.Action = ActionAgent
+	case ActionAgent, ActionNotify:
+	default:
+		return fmt.Errorf("action must be %q or %q", ActionAgent, ActionNotify)
+	}
+	return nil
+}
+
+// key identifies what a rule checks, so duplicates can be refused.
+func (r *Rule) key() string {
+	return fmt.Sprintf("%s|%s|%g|%d|%g|%g|%s|%g|%s|%s|%s|%s",
+		r.Symbol, r.Kind, r.Percent, r.WindowMinutes, r.ZScore, r.Level,
+		r.Direction, r.Multiplier, r.Interval, r.Action, r.Channel, r.ChatID)
+}
+
+// configID gives config rules an ID that stays the same across restarts.
+func (r *Rule) configID() string {
+	sum := sha1.Sum([]byte(r.key()))
+	return "cfg-" + hex.EncodeToString(sum[:4])
+}
+
+// Describe says in words what the rule watches for.
+func (r *Rule) Describe() string {
+	dir := ""
+	switch r.Direction {
+	case "up":
+		dir = " up"
+	case "down":
+		dir = " down"
+	}
+	switch r.Kind {
+	case KindPercentMove:
+		return fmt.Sprintf("%s moves%s %g%% within %dm", r.Symbol, dir, r.Percent, r.WindowMinutes)
+	case KindZScore:
+		return fmt.Sprintf("%s %dm move%s of %gσ or more", r.Symbol, r.WindowMinutes, dir, r.ZScore)
+	case KindLevelCross:
+		switch r.Direction {
+		case "up":
+			return fmt.Sprintf("%s crosses above %g", r.Symbol, r.Level)
+		case "down":
+			return fmt.Sprintf("%s crosses below %g", r.Symbol, r.Level)
+		}
+		return fmt.Sprintf("%s crosses %g", r.Symbol, r.Level)
+	case KindVolumeSpike:
+		return fmt.Sprintf("%s %s volume %g× the %d-candle average", r.Symbol, r.Interval, r.Multiplier, volumeAverageCandles)
+	}
+	return string(r.Kind)
+}
+
+func kindList() string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
+
+// intervals are the candle intervals rules can use.
+var intervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"4h":  4 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// intervalOrder lists intervals shortest first.
+var intervalOrder = []string{"1m", "5m", "15m", "1h", "4h", "1d"}
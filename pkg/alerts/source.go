@@ -0,0 +1,98 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/marketdata"
+)
+
+// PriceSource supplies the prices and candles rules are evaluated on.
+type PriceSource interface {
+	// Price returns the current price of symbol.
+	Price(ctx context.Context, symbol string) (float64, error)
+	// Prices returns symbol's prices over the last window, oldest first,
+	// ending with the current price.
+	Prices(ctx context.Context, symbol string, window time.Duration) ([]marketdata.Tick, error)
+	// Candles returns symbol's last limit candles, oldest first.
+	Candles(ctx context.Context, symbol, interval string, limit int) ([]marketdata.Candle, error)
+}
+
+// maxCandles is the most candles a price window is built from.
+const maxCandles = 100
+
+// MarketSource reads streamed prices where the stream has them and polls
+// the market data router for everything else.
+type MarketSource struct {
+	router *marketdata.Router
+	stream *marketdata.Stream // nil when streaming is disabled
+}
+
+func NewMarketSource(router *marketdata.Router, stream *marketdata.Stream) *MarketSource {
+	return &MarketSource{router: router, stream: stream}
+}
+
+func (s *MarketSource) Price(ctx context.Context, symbol string) (float64, error) {
+	if s.stream != nil {
+		if q, ok := s.stream.Quote(symbol, 0); ok && !q.Stale {
+			return q.Price, nil
+		}
+	}
+	ticker, err := s.router.Ticker(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	return ticker.Price, nil
+}
+
+func (s *MarketSource) Prices(ctx context.Context, symbol string, window time.Duration) ([]marketdata.Tick, error) {
+	now := time.Now()
+	if s.stream != nil {
+		// Use the stream only once it covers the window, e.g. not right
+		// after a restart
+		if q, ok := s.stream.Quote(symbol, 0); ok && !q.Stale {
+			ticks := s.stream.Ticks(symbol, now.Add(-window))
+			if len(ticks) > 1 && ticks[0].Time.Before(now.Add(-window*9/10)) {
+				return ticks, nil
+			}
+		}
+	}
+
+	interval := intervalOrder[len(intervalOrder)-1]
+	for _, name := range intervalOrder {
+		if window/intervals[name] <= maxCandles {
+			interval = name
+			break
+		}
+	}
+	step := intervals[interval]
+	limit := int(window/step) + 1
+	candles, err := s.Candles(ctx, symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("no candles for %s", symbol)
+	}
+
+	// The first candle's open starts the window; closes follow
+	ticks := make([]marketdata.Tick, 0, len(candles)+1)
+	ticks = append(ticks, marketdata.Tick{Time: candles[0].Time, Price: candles[0].Open})
+	for _, c := range candles {
+		at := c.Time.Add(step)
+		if at.After(now) {
+			at = now
+		}
+		ticks = append(ticks, marketdata.Tick{Time: at, Price: c.Close})
+	}
+	return ticks, nil
+}
+
+func (s *MarketSource) Candles(ctx context.Context, symbol, interval string, limit int) ([]marketdata.Candle, error) {
+	series, err := s.router.Candles(ctx, symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	return series.Candles, nil
+}
@@ -0,0 +1,399 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Decision is the outcome of an approval request.
+type Decision string
+
+const (
+	Approved Decision = "approved"
+	Denied   Decision = "denied"
+	TimedOut Decision = "timeout"
+)
+
+// Request describes a tool call waiting for a human decision.
+type Request struct {
+	ID         string                 `json:"id"`
+	Tool       string                 `json:"tool"`
+	Args       map[string]interface{} `json:"args"`
+	Reason     string                 `json:"reason"`
+	Channel    string                 `json:"channel"`
+	ChatID     string                 `json:"chat_id"`
+	SessionKey string                 `json:"session_key"`
+	Created    time.Time              `json:"created"`
+}
+
+// Prompter asks for a decision synchronously, e.g. on the CLI terminal.
+type Prompter func(ctx context.Context, req *Request) (bool, error)
+
+type rule struct {
+	tool    string
+	arg     string
+	pattern *regexp.Regexp
+}
+
+type pendingRequest struct {
+	req    *Request
+	result chan resolution
+}
+
+type resolution struct {
+	approved bool
+	by       string
+}
+
+// auditRecord is one line of workspace/audit/approvals.jsonl.
+type auditRecord struct {
+	Time      string                 `json:"time"`
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"`
+	Args      map[string]interface{} `json:"args"`
+	Reason    string                 `json:"reason"`
+	Channel   string                 `json:"channel"`
+	ChatID    string                 `json:"chat_id"`
+	Session   string                 `json:"session_key"`
+	Decision  Decision               `json:"decision"`
+	DecidedBy string                 `json:"decided_by,omitempty"`
+	WaitedMS  int64                  `json:"waited_ms"`
+}
+
+// Manager decides which tool calls need approval, routes approval requests to
+// the originating channel and waits for the user's reply.
+type Manager struct {
+	enabled    bool
+	rules      []rule
+	timeout    time.Duration
+	unattended Decision
+	bus        *bus.MessageBus
+	auditPath  string
+	prompter   Prompter
+	pending    map[string]*pendingRequest
+	mu         sync.Mutex
+}
+
+func NewManager(cfg config.ApprovalConfig, workspace string, msgBus *bus.MessageBus) (*Manager, error) {
+	m := &Manager{
+		enabled:    cfg.Enabled,
+		timeout:    time.Duration(cfg.TimeoutSeconds) * time.Second,
+		unattended: Denied,
+		bus:        msgBus,
+		auditPath:  filepath.Join(workspace, "audit", "approvals.jsonl"),
+		pending:    make(map[string]*pendingRequest),
+	}
+
+	if m.timeout <= 0 {
+		m.timeout = 5 * time.Minute
+	}
+	if strings.EqualFold(cfg.Unattended, "allow") {
+		m.unattended = Approved
+	}
+
+	for _, rc := range cfg.Rules {
+		if rc.Tool == "" {
+			return nil, fmt.Errorf("approval rule is missing tool name")
+		}
+		r := rule{tool: rc.Tool, arg: rc.Arg}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid approval pattern %q: %w", rc.Pattern, err)
+			}
+			r.pattern = re
+		}
+		m.rules = append(m.rules, r)
+	}
+
+	if msgBus != nil {
+		msgBus.AddInboundFilter(m.filterReply)
+	}
+
+	return m, nil
+}
+
+// SetPrompter sets the prompter used for requests originating from the CLI.
+func (m *Manager) SetPrompter(prompter Prompter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prompter = prompter
+}
+
+// Check reports whether a tool call requires approval and which rule matched.
+func (m *Manager) Check(tool string, args map[string]interface{}) (string, bool) {
+	if !m.enabled {
+		return "", false
+	}
+
+	for _, r := range m.rules {
+		if r.tool != tool && r.tool != "*" {
+			continue
+		}
+		if r.pattern == nil {
+			return fmt.Sprintf("tool '%s' always requires approval", tool), true
+		}
+
+		var subject string
+		if r.arg != "" {
+			v, ok := args[r.arg]
+			if !ok {
+				continue
+			}
+			subject = fmt.Sprintf("%v", v)
+		} else {
+			data, _ := json.Marshal(args)
+			subject = string(data)
+		}
+
+		if r.pattern.MatchString(subject) {
+			if r.arg != "" {
+				return fmt.Sprintf("argument '%s' matches %s", r.arg, r.pattern.String()), true
+			}
+			return fmt.Sprintf("arguments match %s", r.pattern.String()), true
+		}
+	}
+
+	return "", false
+}
+
+// Request blocks until the request is approved, denied or times out.
+// The decision is always written to the audit log.
+func (m *Manager) Request(ctx context.Context, req *Request) (Decision, error) {
+	if req.ID == "" {
+		req.ID = generateID()
+	}
+	if req.Created.IsZero() {
+		req.Created = time.Now()
+	}
+
+	decision, by, err := m.wait(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	m.audit(req, decision, by)
+
+	logger.InfoCF("approval", "Approval request resolved",
+		map[string]interface{}{
+			"id":       req.ID,
+			"tool":     req.Tool,
+			"decision": string(decision),
+			"by":       by,
+		})
+
+	return decision, nil
+}
+
+func (m *Manager) wait(ctx context.Context, req *Request) (Decision, string, error) {
+	m.mu.Lock()
+	prompter := m.prompter
+	m.mu.Unlock()
+
+	if req.Channel == "cli" && prompter != nil {
+		approved, err := prompter(ctx, req)
+		if err != nil {
+			return "", "", fmt.Errorf("approval prompt failed: %w", err)
+		}
+		if approved {
+			return Approved, "cli", nil
+		}
+		return Denied, "cli", nil
+	}
+
+	if !isInteractive(req.Channel, req.ChatID) || m.bus == nil {
+		return m.unattended, "policy", nil
+	}
+
+	p := &pendingRequest{req: req, result: make(chan resolution, 1)}
+	m.mu.Lock()
+	m.pending[req.ID] = p
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, req.ID)
+		m.mu.Unlock()
+	}()
+
+	m.bus.PublishOutbound(bus.OutboundMessage{
+		Channel: req.Channel,
+		ChatID:  req.ChatID,
+		Content: m.formatRequest(req),
+		Actions: []bus.MessageAction{
+			{Label: "Approve", Data: "/approve " + req.ID},
+			{Label: "Deny", Data: "/deny " + req.ID},
+		},
+	})
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-p.result:
+		if res.approved {
+			return Approved, res.by, nil
+		}
+		return Denied, res.by, nil
+	case <-timer.C:
+		m.bus.PublishOutbound(bus.OutboundMessage{
+			Channel: req.Channel,
+			ChatID:  req.ChatID,
+			Content: fmt.Sprintf("Approval request %s for `%s` timed out and was denied.", req.ID, req.Tool),
+		})
+		return TimedOut, "", nil
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+// Resolve records a decision for a pending request. It returns false if the
+// request does not exist (already resolved, timed out or unknown).
+func (m *Manager) Resolve(id string, approved bool, by string) bool {
+	m.mu.Lock()
+	p, ok := m.pending[id]
+	if ok {
+		delete(m.pending, id)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	p.result <- resolution{approved: approved, by: by}
+	return true
+}
+
+// Pending returns the requests currently waiting for a decision.
+func (m *Manager) Pending() []*Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reqs := make([]*Request, 0, len(m.pending))
+	for _, p := range m.pending {
+		reqs = append(reqs, p.req)
+	}
+	return reqs
+}
+
+// filterReply consumes inbound approval replies so they never reach the agent
+// loop, which is blocked waiting for the decision.
+func (m *Manager) filterReply(msg bus.InboundMessage) bool {
+	approved, id, ok := parseReply(msg.Content)
+	if !ok {
+		return false
+	}
+
+	m.mu.Lock()
+	var match *pendingRequest
+	matches := 0
+	for _, p := range m.pending {
+		if p.req.Channel != msg.Channel || p.req.ChatID != msg.ChatID {
+			continue
+		}
+		if id != "" && p.req.ID != id {
+			continue
+		}
+		match = p
+		matches++
+	}
+	m.mu.Unlock()
+
+	// A bare "yes"/"no" is only unambiguous with exactly one request pending
+	if matches != 1 {
+		return false
+	}
+
+	return m.Resolve(match.req.ID, approved, msg.SenderID)
+}
+
+func (m *Manager) formatRequest(req *Request) string {
+	argsJSON, _ := json.MarshalIndent(req.Args, "", "  ")
+
+	var sb strings.Builder
+	sb.WriteString("⚠️ Approval required\n\n")
+	sb.WriteString(fmt.Sprintf("Tool: %s\n", req.Tool))
+	sb.WriteString(fmt.Sprintf("Reason: %s\n", req.Reason))
+	sb.WriteString(fmt.Sprintf("Arguments:\n```\n%s\n```\n\n", utils.Truncate(string(argsJSON), 1500)))
+	sb.WriteString(fmt.Sprintf("Reply \"approve %s\" or \"deny %s\" within %s.", req.ID, req.ID, m.timeout))
+	return sb.String()
+}
+
+func (m *Manager) audit(req *Request, decision Decision, by string) {
+	record := auditRecord{
+		Time:      time.Now().UTC().Format(time.RFC3339),
+		ID:        req.ID,
+		Tool:      req.Tool,
+		Args:      req.Args,
+		Reason:    req.Reason,
+		Channel:   req.Channel,
+		ChatID:    req.ChatID,
+		Session:   req.SessionKey,
+		Decision:  decision,
+		DecidedBy: by,
+		WaitedMS:  time.Since(req.Created).Milliseconds(),
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	if err := fileio.AppendFile(m.auditPath, append(data, '\n'), 0644); err != nil {
+		logger.ErrorCF("approval", "Failed to write audit log", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// parseReply recognises "approve", "/approve <id>", "yes", "deny <id>", "no", ...
+func parseReply(content string) (approved bool, id string, ok bool) {
+	fields := strings.Fields(strings.ToLower(strings.TrimSpace(content)))
+	if len(fields) == 0 || len(fields) > 2 {
+		return false, "", false
+	}
+
+	switch strings.TrimPrefix(fields[0], "/") {
+	case "approve", "approved", "yes", "y", "allow":
+		approved = true
+	case "deny", "denied", "no", "n", "reject":
+		approved = false
+	default:
+		return false, "", false
+	}
+
+	if len(fields) == 2 {
+		id = fields[1]
+	}
+	return approved, id, true
+}
+
+// isInteractive reports whether a channel/chat pair can receive and answer an
+// approval request. Cron jobs without a recipient run on "direct".
+func isInteractive(channel, chatID string) bool {
+	if channel == "" || channel == "cli" || channel == "system" {
+		return false
+	}
+	return chatID != "" && chatID != "direct"
+}
+
+func generateID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano()%100000000)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestCheckRules(t *testing.T) {
+	m, err := NewManager(config.ApprovalConfig{
+		Enabled: true,
+		Rules: []config.ApprovalRuleConfig{
+			{Tool: "write_file"},
+			{Tool: "exec", Arg: "command", Pattern: `\bgit\s+push\b`},
+		},
+	}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		args     map[string]interface{}
+		required bool
+	}{
+		{"always", "write_file", map[string]interface{}{"path": "a.txt"}, true},
+		{"pattern match", "exec", map[string]interface{}{"command": "git push origin"}, true},
+		{"pattern miss", "exec", map[string]interface{}{"command": "ls -la"}, false},
+		{"unlisted tool", "read_file", map[string]interface{}{"path": "a.txt"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, required := m.Check(tt.tool, tt.args); required != tt.required {
+				t.Errorf("Check(%s) required = %v, want %v", tt.tool, required, tt.required)
+			}
+		})
+	}
+}
+
+func TestRequestResolvedByReply(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	m, err := NewManager(config.ApprovalConfig{
+		Enabled:        true,
+		TimeoutSeconds: 5,
+		Rules:          []config.ApprovalRuleConfig{{Tool: "exec"}},
+	}, t.TempDir(), msgBus)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		out, ok := msgBus.SubscribeOutbound(ctx)
+		if !ok || len(out.Actions) != 2 {
+			return
+		}
+		msgBus.PublishInbound(bus.InboundMessage{
+			Channel:  "telegram",
+			SenderID: "42",
+			ChatID:   "100",
+			Content:  out.Actions[0].Data,
+		})
+	}()
+
+	decision, err := m.Request(context.Background(), &Request{
+		Tool:    "exec",
+		Args:    map[string]interface{}{"command": "ls"},
+		Channel: "telegram",
+		ChatID:  "100",
+	})
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if decision != Approved {
+		t.Errorf("decision = %s, want %s", decision, Approved)
+	}
+	if len(m.Pending()) != 0 {
+		t.Errorf("pending requests left after resolution")
+	}
+}
+
+func TestUnattendedPolicy(t *testing.T) {
+	m, _ := NewManager(config.ApprovalConfig{Enabled: true}, t.TempDir(), bus.NewMessageBus())
+
+	decision, err := m.Request(context.Background(), &Request{Tool: "exec", Channel: "telegram", ChatID: "direct"})
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if decision != Denied {
+		t.Errorf("decision = %s, want %s", decision, Denied)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	tests := []struct {
+		in       string
+		approved bool
+		id       string
+		ok       bool
+	}{
+		{"/approve ab12", true, "ab12", true},
+		{"yes", true, "", true},
+		{"Deny ab12", false, "ab12", true},
+		{"no thanks, do something else", false, "", false},
+		{"what is the price of gold", false, "", false},
+	}
+
+	for _, tt := range tests {
+		approved, id, ok := parseReply(tt.in)
+		if approved != tt.approved || id != tt.id || ok != tt.ok {
+			t.Errorf("parseReply(%q) = (%v, %q, %v), want (%v, %q, %v)",
+				tt.in, approved, id, ok, tt.approved, tt.id, tt.ok)
+		}
+	}
+}
@@ -9,6 +9,7 @@ type MessageBus struct {
 	inbound  chan InboundMessage
 	outbound chan OutboundMessage
 	handlers map[string]MessageHandler
+	filters  []InboundFilter
 	mu       sync.RWMutex
 }
 
@@ -21,9 +22,26 @@ func NewMessageBus() *MessageBus {
 }
 
 func (mb *MessageBus) PublishInbound(msg InboundMessage) {
+	mb.mu.RLock()
+	filters := mb.filters
+	mb.mu.RUnlock()
+
+	for _, filter := range filters {
+		if filter(msg) {
+			return
+		}
+	}
+
 	mb.inbound <- msg
 }
 
+// AddInboundFilter registers a filter that runs on every published inbound message.
+func (mb *MessageBus) AddInboundFilter(filter InboundFilter) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.filters = append(mb.filters, filter)
+}
+
 func (mb *MessageBus) ConsumeInbound(ctx context.Context) (InboundMessage, bool) {
 	select {
 	case msg := <-mb.inbound:
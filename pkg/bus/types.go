@@ -11,9 +11,22 @@ type InboundMessage struct {
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string          `json:"channel"`
+	ChatID  string          `json:"chat_id"`
+	Content string          `json:"content"`
+	Actions []MessageAction `json:"actions,omitempty"`
+}
+
+// MessageAction is an interactive reply option attached to an outbound message.
+// Channels that support buttons render it as one; when pressed, Data is delivered
+// back as the content of an inbound message from the pressing user.
+type MessageAction struct {
+	Label string `json:"label"`
+	Data  string `json:"data"`
 }
 
 type MessageHandler func(InboundMessage) error
+
+// InboundFilter inspects an inbound message before it is queued for the agent.
+// Returning true consumes the message so it never reaches the agent loop.
+type InboundFilter func(InboundMessage) bool
@@ -0,0 +1,410 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const userAgent = "Mozilla/5.0 (compatible; picoclaw/1.0)"
+
+// keepDays is how long events that dropped out of a feed (e.g. last week's
+// releases in a this-week export) are kept in the feed cache.
+const keepDays = 14
+
+// feedState is a feed's last good events, kept in calendar/feed_cache.json
+// so restarts and feed outages don't lose the calendar.
+type feedState struct {
+	Fetched   time.Time `json:"fetched"`
+	Events    []Event   `json:"events"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Calendar merges the configured feeds with the maintained events file.
+// Feeds are fetched on demand, at most every refresh interval.
+type Calendar struct {
+	feeds     []config.CalendarFeedConfig
+	file      string
+	cachePath string
+	workspace string
+	refresh   time.Duration
+	countries map[string]bool
+	client    *http.Client
+	now       func() time.Time
+
+	mu    sync.Mutex
+	state map[string]*feedState
+}
+
+// NewFromConfig returns nil when the calendar is disabled. Feed requests
+// go through transport (http.DefaultTransport when nil).
+func NewFromConfig(cfg config.CalendarConfig, workspace string, transport http.RoundTripper) *Calendar {
+	if !cfg.Enabled {
+		return nil
+	}
+	c := &Calendar{
+		feeds:     cfg.Feeds,
+		file:      workspacePath(workspace, cfg.File),
+		cachePath: filepath.Join(workspace, "calendar", "feed_cache.json"),
+		workspace: workspace,
+		refresh:   time.Duration(cfg.RefreshMinutes) * time.Minute,
+		client:    &http.Client{Timeout: 30 * time.Second, Transport: transport},
+		now:       time.Now,
+		state:     make(map[string]*feedState),
+	}
+	if cfg.File == "" {
+		c.file = filepath.Join(workspace, "calendar", "events.json")
+	}
+	if c.refresh <= 0 {
+		c.refresh = time.Hour
+	}
+	if len(cfg.Countries) > 0 {
+		c.countries = make(map[string]bool)
+		for _, country := range cfg.Countries {
+			c.countries[NormalizeCountry(country)] = true
+		}
+	}
+	if data, err := os.ReadFile(c.cachePath); err == nil {
+		json.Unmarshal(data, &c.state)
+	}
+	return c
+}
+
+func workspacePath(workspace, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(workspace, p)
+}
+
+// Refresh is how often feeds are fetched again.
+func (c *Calendar) Refresh() time.Duration {
+	return c.refresh
+}
+
+// File is the maintained events file.
+func (c *Calendar) File() string {
+	return c.file
+}
+
+// Events returns every known event, oldest first: the feeds' events,
+// replaced by entries of the maintained file with the same ID. A feed that
+// fails keeps serving its last good events; the error is only returned
+// when there is nothing to serve.
+func (c *Calendar) Events(ctx context.Context) ([]Event, error) {
+	feedErrs := c.update(ctx)
+
+	byID := make(map[string]Event)
+	c.mu.Lock()
+	for _, feed := range c.feeds {
+		if st := c.state[feed.Name]; st != nil {
+			for _, e := range st.Events {
+				byID[e.ID] = e
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	local, err := c.local()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range local {
+		byID[e.ID] = e
+	}
+	if len(byID) == 0 && len(feedErrs) > 0 {
+		return nil, fmt.Errorf("no calendar events: %s", strings.Join(feedErrs, "; "))
+	}
+
+	events := make([]Event, 0, len(byID))
+	for _, e := range byID {
+		if c.countries == nil || c.countries[e.Country] {
+			events = append(events, e)
+		}
+	}
+	sortEvents(events)
+	return events, nil
+}
+
+// Upcoming returns the events of at least importance min in the next
+// within, limited to countries when given.
+func (c *Calendar) Upcoming(ctx context.Context, within time.Duration, min string, countries []string) ([]Event, error) {
+	events, err := c.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	today := day(now)
+	return filter(events, min, countries, func(e Event) bool {
+		if e.AllDay {
+			return !e.Time.Before(today) && e.Time.Before(now.Add(within))
+		}
+		return !e.Time.Before(now) && e.Time.Before(now.Add(within))
+	}), nil
+}
+
+// Released returns the events of at least importance min on the UTC day of
+// date that have already happened, limited to countries when given.
+func (c *Calendar) Released(ctx context.Context, date time.Time, min string, countries []string) ([]Event, error) {
+	events, err := c.Events(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	from := day(date)
+	to := from.AddDate(0, 0, 1)
+	return filter(events, min, countries, func(e Event) bool {
+		return !e.Time.Before(from) && e.Time.Before(to) && !e.Time.After(now)
+	}), nil
+}
+
+// Record adds an event to the maintained file, or updates the entry with
+// the same ID, e.g. to fill in the actual value after a release. Fields
+// left empty keep the known event's values.
+func (c *Calendar) Record(ctx context.Context, e Event) (Event, error) {
+	if e.ID != "" {
+		events, err := c.Events(ctx)
+		if err != nil {
+			return Event{}, err
+		}
+		for _, known := range events {
+			if known.ID == e.ID {
+				e = merge(known, e)
+				break
+			}
+		}
+	}
+	if e.Title == "" || e.Time.IsZero() {
+		return Event{}, fmt.Errorf("an event needs a title and a time (or the id of a known event)")
+	}
+	e.Source = "local"
+	e.normalize("local")
+
+	err := fileio.Update(c.file, 0644, func(current []byte) ([]byte, error) {
+		var events []Event
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &events); err != nil {
+				return nil, fmt.Errorf("reading %s: %w", c.file, err)
+			}
+		}
+		replaced := false
+		for i := range events {
+			if events[i].ID == e.ID {
+				events[i] = e
+				replaced = true
+			}
+		}
+		if !replaced {
+			events = append(events, e)
+		}
+		sortEvents(events)
+		return json.MarshalIndent(events, "", "  ")
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
+
+// merge overlays the fields set in update on known.
+func merge(known, update Event) Event {
+	if update.Title != "" {
+		known.Title = update.Title
+	}
+	if !update.Time.IsZero() {
+		known.Time, known.AllDay = update.Time, update.AllDay
+	}
+	for _, f := range []struct{ dst, src *string }{
+		{&known.Country, &update.Country},
+		{&known.Type, &update.Type},
+		{&known.Importance, &update.Importance},
+		{&known.Consensus, &update.Consensus},
+		{&known.Previous, &update.Previous},
+		{&known.Actual, &update.Actual},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	return known
+}
+
+// local reads the maintained file. It is hand-edited, so it accepts the
+// same loose field names as JSON feeds.
+func (c *Calendar) local() ([]Event, error) {
+	data, err := os.ReadFile(c.file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	events, err := Parse(data, "json", "local")
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", c.file, err)
+	}
+	return events, nil
+}
+
+// update fetches the feeds that are due and returns the errors of those
+// that failed.
+func (c *Calendar) update(ctx context.Context) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var errs []string
+	changed := false
+	for _, feed := range c.feeds {
+		st := c.state[feed.Name]
+		if st == nil {
+			st = &feedState{}
+			c.state[feed.Name] = st
+		}
+		if now.Sub(st.Fetched) < c.refresh {
+			if st.LastError != "" {
+				errs = append(errs, fmt.Sprintf("%s: %s", feed.Name, st.LastError))
+			}
+			continue
+		}
+		events, err := c.fetch(ctx, feed)
+		st.Fetched = now
+		changed = true
+		if err != nil {
+			st.LastError = err.Error()
+			errs = append(errs, fmt.Sprintf("%s: %v", feed.Name, err))
+			logger.WarnCF("calendar", "Calendar feed failed, keeping last events",
+				map[string]interface{}{
+					"feed":   feed.Name,
+					"events": len(st.Events),
+					"error":  err.Error(),
+				})
+			continue
+		}
+		st.LastError = ""
+		st.Events = keepOlder(st.Events, events, now.AddDate(0, 0, -keepDays))
+		logger.InfoCF("calendar", "Calendar feed refreshed",
+			map[string]interface{}{
+				"feed":   feed.Name,
+				"events": len(events),
+			})
+	}
+	if changed {
+		if data, err := json.MarshalIndent(c.state, "", "  "); err == nil {
+			if err := fileio.WriteFile(c.cachePath, data, 0644); err != nil {
+				logger.WarnCF("calendar", "Failed to save calendar feed cache",
+					map[string]interface{}{
+						"error": err.Error(),
+					})
+			}
+		}
+	}
+	return errs
+}
+
+// keepOlder returns fresh plus the previous events from before the first
+// fresh one and after cutoff, so a feed covering only this week doesn't
+// lose the releases of the last few days when the week turns.
+func keepOlder(previous, fresh []Event, cutoff time.Time) []Event {
+	if len(fresh) == 0 {
+		return previous
+	}
+	first := fresh[0].Time
+	for _, e := range fresh {
+		if e.Time.Before(first) {
+			first = e.Time
+		}
+	}
+	var kept []Event
+	for _, e := range previous {
+		if e.Time.Before(first) && e.Time.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	return append(kept, fresh...)
+}
+
+// fetch reads a feed from a URL, or from a path relative to the workspace.
+func (c *Calendar) fetch(ctx context.Context, feed config.CalendarFeedConfig) ([]Event, error) {
+	var data []byte
+	if strings.HasPrefix(feed.URL, "http://") || strings.HasPrefix(feed.URL, "https://") {
+		req, err := http.NewRequestWithContext(ctx, "GET", feed.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		data, err = io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(workspacePath(c.workspace, strings.TrimPrefix(feed.URL, "file://"))); err != nil {
+			return nil, err
+		}
+	}
+
+	format := feed.Format
+	if format == "" {
+		switch ext := strings.ToLower(path.Ext(strings.SplitN(feed.URL, "?", 2)[0])); ext {
+		case ".ics", ".json", ".csv":
+			format = ext[1:]
+		}
+	}
+	return Parse(data, format, feed.Name)
+}
+
+// filter keeps the events matching keep, of at least importance min and,
+// when countries is not empty, of one of countries.
+func filter(events []Event, min string, countries []string, keep func(Event) bool) []Event {
+	var wanted map[string]bool
+	if len(countries) > 0 {
+		wanted = make(map[string]bool)
+		for _, country := range countries {
+			wanted[NormalizeCountry(country)] = true
+		}
+	}
+	var out []Event
+	for _, e := range events {
+		if keep(e) && e.AtLeast(min) && (wanted == nil || wanted[e.Country]) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func sortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return events[i].Country+events[i].Title < events[j].Country+events[j].Title
+	})
+}
+
+// day truncates t to the start of its UTC day.
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
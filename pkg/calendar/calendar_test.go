@@ -0,0 +1,236 @@
+package calendar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+const forexFactory = `[
+{"title":"CPI m/m","country":"USD","date":"2025-01-15T08:30:00-05:00","impact":"High","forecast":"0.3%","previous":"0.3%"},
+{"title":"Flash Manufacturing PMI","country":"EUR","date":"2025-01-15T04:00:00-05:00","impact":"Medium","forecast":"45.3","previous":"45.1"},
+{"title":"Bank Holiday","country":"JPY","date":"2025-01-13T00:00:00-05:00","impact":"Holiday","forecast":"","previous":""},
+{"title":"Federal Funds Rate","country":"USD","date":"2025-01-29T14:00:00-05:00","impact":"High","forecast":"4.50%","previous":"4.50%"}
+]`
+
+func TestParseFormats(t *testing.T) {
+	events, err := Parse([]byte(forexFactory), "", "ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+	cpi := events[0]
+	if !cpi.Time.Equal(time.Date(2025, 1, 15, 13, 30, 0, 0, time.UTC)) || cpi.Type != TypeInflation ||
+		cpi.Importance != ImportanceHigh || cpi.Consensus != "0.3%" || cpi.Source != "ff" || cpi.ID == "" {
+		t.Fatalf("unexpected CPI event: %+v", cpi)
+	}
+	if events[1].Type != TypeGrowth || events[1].Importance != ImportanceMedium {
+		t.Fatalf("unexpected PMI event: %+v", events[1])
+	}
+	if events[2].Importance != ImportanceLow || events[2].Type != TypeOther {
+		t.Fatalf("unexpected holiday: %+v", events[2])
+	}
+	if events[3].Type != TypeCentralBank {
+		t.Fatalf("unexpected rate decision: %+v", events[3])
+	}
+
+	ics := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20250110T133000Z\r\nSUMMARY:USD - Non-Farm Employment Chan\r\n ge\r\n" +
+		"DESCRIPTION:Forecast: 160K\\nPrevious: 227K\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;TZID=Europe/Berlin:20250130T141500\r\nSUMMARY:Main Refinancing Rate\r\nLOCATION:Euro Area\r\nPRIORITY:1\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20250120\r\nSUMMARY:[USD] Bank Holiday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	events, err = Parse([]byte(ics), "", "ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 ICS events, got %d", len(events))
+	}
+	nfp := events[0]
+	if nfp.Country != "USD" || nfp.Title != "Non-Farm Employment Change" || nfp.Type != TypeEmployment ||
+		nfp.Importance != ImportanceHigh || nfp.Consensus != "160K" || nfp.Previous != "227K" {
+		t.Fatalf("unexpected NFP event: %+v", nfp)
+	}
+	ecb := events[1]
+	if ecb.Country != "EUR" || !ecb.Time.Equal(time.Date(2025, 1, 30, 13, 15, 0, 0, time.UTC)) || ecb.Importance != ImportanceHigh {
+		t.Fatalf("unexpected ECB event: %+v", ecb)
+	}
+	if !events[2].AllDay || events[2].Country != "USD" {
+		t.Fatalf("unexpected all-day event: %+v", events[2])
+	}
+
+	csv := "Date,Time,Currency,Event,Impact,Actual,Forecast,Previous\n" +
+		"2025-01-30,8:30am,USD,Advance GDP q/q,High,2.3%,2.6%,3.1%\n" +
+		"2025-01-31,Tentative,CNY,Manufacturing PMI,,,,\n"
+	events, err = Parse([]byte(csv), "csv", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || !events[0].Time.Equal(time.Date(2025, 1, 30, 8, 30, 0, 0, time.UTC)) ||
+		events[0].Actual != "2.3%" || events[0].Type != TypeGrowth || !events[1].AllDay || events[1].Importance != ImportanceMedium {
+		t.Fatalf("unexpected CSV events: %+v", events)
+	}
+}
+
+func newTestCalendar(t *testing.T, workspace, url string, now time.Time) *Calendar {
+	t.Helper()
+	c := NewFromConfig(config.CalendarConfig{
+		Enabled:        true,
+		File:           "calendar/events.json",
+		Feeds:          []config.CalendarFeedConfig{{Name: "ff", URL: url, Format: "json"}},
+		RefreshMinutes: 60,
+		Countries:      []string{"USD", "Euro Area"},
+	}, workspace, nil)
+	c.now = func() time.Time { return now }
+	return c
+}
+
+func TestCalendarQueriesAndRecord(t *testing.T) {
+	var requests int32
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(forexFactory))
+	}))
+	defer server.Close()
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	workspace := t.TempDir()
+	c := newTestCalendar(t, workspace, server.URL, now)
+	ctx := context.Background()
+
+	// The JPY holiday is filtered out by the configured countries
+	upcoming, err := c.Upcoming(ctx, 6*time.Hour, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 1 || upcoming[0].Title != "CPI m/m" {
+		t.Fatalf("expected CPI in the next 6h, got %+v", upcoming)
+	}
+	if upcoming, _ := c.Upcoming(ctx, 30*24*time.Hour, "high", []string{"usd"}); len(upcoming) != 2 {
+		t.Fatalf("expected 2 high-importance USD events, got %+v", upcoming)
+	}
+	released, err := c.Released(ctx, now, "medium", nil)
+	if err != nil || len(released) != 1 || released[0].Country != "EUR" {
+		t.Fatalf("expected the PMI released this morning, got %+v (%v)", released, err)
+	}
+	if requests != 1 {
+		t.Fatalf("feed should be fetched once per refresh interval, got %d requests", requests)
+	}
+
+	// Recording the actual value overrides the feed entry
+	recorded, err := c.Record(ctx, Event{ID: upcoming[0].ID, Actual: "0.4%"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorded.Title != "CPI m/m" || recorded.Consensus != "0.3%" || recorded.Actual != "0.4%" || recorded.Source != "local" {
+		t.Fatalf("unexpected recorded event: %+v", recorded)
+	}
+	if _, err := c.Record(ctx, Event{Title: "Powell Speaks", Country: "US", Time: now.Add(2 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Record(ctx, Event{Actual: "1"}); err == nil {
+		t.Fatal("an event without title or time should be refused")
+	}
+
+	// A failing feed keeps its last events, also across restarts
+	fail.Store(true)
+	c = newTestCalendar(t, workspace, server.URL, now.Add(2*time.Hour))
+	events, err := c.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 || requests != 2 {
+		t.Fatalf("expected the cached feed and recorded events after a failed refresh, got %d events after %d requests", len(events), requests)
+	}
+	for _, e := range events {
+		if e.Title == "CPI m/m" && e.Actual != "0.4%" {
+			t.Fatalf("recorded actual lost: %+v", e)
+		}
+	}
+}
+
+func TestSchedulerSync(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	feed := filepath.Join(dir, "feed.json")
+	os.WriteFile(feed, []byte(forexFactory), 0644)
+
+	c := NewFromConfig(config.CalendarConfig{
+		Enabled: true,
+		Feeds:   []config.CalendarFeedConfig{{Name: "local", URL: feed}},
+	}, dir, nil)
+	c.now = func() time.Time { return now }
+	cronService := cron.NewCronService(filepath.Join(dir, "cron", "jobs.json"), nil)
+	cronService.AddJob("econ:daily_outlook", cron.CronSchedule{Kind: "cron", Expr: "0 7 * * *"}, "x", false, "telegram", "")
+
+	schedule := config.CalendarScheduleConfig{Enabled: true, MinImportance: "high", PreMinutes: 30, PostMinutes: 15, LookaheadHours: 24, Channel: "telegram"}
+	if _, err := NewScheduler(c, cronService, schedule); err == nil {
+		t.Fatal("a channel without a chat id should be refused")
+	}
+	schedule.ChatID = "42"
+	s, err := NewScheduler(c, cronService, schedule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = c.now
+	added, removed, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || removed != 0 {
+		t.Fatalf("expected pre and post jobs for CPI, got %d added, %d removed", added, removed)
+	}
+	jobs := map[string]cron.CronJob{}
+	for _, j := range cronService.ListJobs(true) {
+		jobs[j.Name] = j
+	}
+	cpi := time.Date(2025, 1, 15, 13, 30, 0, 0, time.UTC)
+	var pre, post cron.CronJob
+	for name, j := range jobs {
+		switch {
+		case strings.HasPrefix(name, "calendar:pre:"):
+			pre = j
+		case strings.HasPrefix(name, "calendar:post:"):
+			post = j
+		}
+	}
+	if pre.Schedule.AtMS == nil || *pre.Schedule.AtMS != cpi.Add(-30*time.Minute).UnixMilli() ||
+		post.Schedule.AtMS == nil || *post.Schedule.AtMS != cpi.Add(15*time.Minute).UnixMilli() {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+	if !strings.Contains(pre.Payload.Message, "event_macro_trigger") || !strings.Contains(post.Payload.Message, "post-event") ||
+		pre.Payload.Channel != "telegram" || pre.Payload.To != "42" || pre.Payload.Deliver {
+		t.Fatalf("unexpected job payloads: %+v / %+v", pre.Payload, post.Payload)
+	}
+
+	// Nothing changes on a second sync
+	if added, removed, _ := s.Sync(context.Background()); added != 0 || removed != 0 {
+		t.Fatalf("second sync changed %d/%d jobs", added, removed)
+	}
+
+	// A rescheduled release moves its jobs
+	os.WriteFile(feed, []byte(strings.Replace(forexFactory, "2025-01-15T08:30:00-05:00", "2025-01-15T09:00:00-05:00", 1)), 0644)
+	c.state = map[string]*feedState{}
+	added, removed, err = s.Sync(context.Background())
+	if err != nil || added != 2 || removed != 2 {
+		t.Fatalf("expected the jobs to move, got %d added, %d removed (%v)", added, removed, err)
+	}
+	if len(cronService.ListJobs(true)) != 3 {
+		t.Fatalf("other cron jobs should be left alone: %+v", cronService.ListJobs(true))
+	}
+}
@@ -0,0 +1,164 @@
+// Package calendar loads scheduled economic releases (CPI, NFP, FOMC, PMI,
+// GDP, ...) from ICS, JSON or CSV feeds and a hand-maintained file, and
+// schedules agent runs around the important ones.
+package calendar
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Event types, matching the classification of the event_macro_trigger skill.
+const (
+	TypeInflation   = "inflation"
+	TypeEmployment  = "employment"
+	TypeGrowth      = "growth"
+	TypeCentralBank = "central_bank"
+	TypeOther       = "other"
+)
+
+// Importance levels, lowest first.
+const (
+	ImportanceLow    = "low"
+	ImportanceMedium = "medium"
+	ImportanceHigh   = "high"
+)
+
+// Event is one scheduled release. Consensus, Previous and Actual are kept as
+// published (e.g. "0.3%", "256K") since sources format them differently.
+type Event struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	AllDay     bool      `json:"all_day,omitempty"` // Only the date is known, e.g. holidays
+	Country    string    `json:"country"`           // Currency code where known, e.g. USD
+	Title      string    `json:"title"`
+	Type       string    `json:"type"`
+	Importance string    `json:"importance"`
+	Consensus  string    `json:"consensus,omitempty"`
+	Previous   string    `json:"previous,omitempty"`
+	Actual     string    `json:"actual,omitempty"`
+	Source     string    `json:"source"`
+}
+
+// keywords classify an event by its title, first match wins. Matching is
+// on whole words so "ism" doesn't match "optimism".
+var keywords = []struct {
+	words      []string
+	typ        string
+	importance string
+}{
+	{[]string{"cpi", "consumer price index"}, TypeInflation, ImportanceHigh},
+	{[]string{"ppi", "pce", "producer price", "inflation", "price index"}, TypeInflation, ImportanceMedium},
+	{[]string{"non farm", "nonfarm", "nfp", "payrolls"}, TypeEmployment, ImportanceHigh},
+	{[]string{"unemployment", "jobless", "employment", "adp", "claims", "jolts", "hourly earnings", "wage"}, TypeEmployment, ImportanceMedium},
+	{[]string{"fomc", "federal funds", "rate decision", "interest rate", "cash rate", "main refinancing", "bank rate", "policy rate"}, TypeCentralBank, ImportanceHigh},
+	{[]string{"monetary policy", "press conference", "minutes", "fed chair", "powell", "lagarde", "ecb", "boe", "boj", "pboc"}, TypeCentralBank, ImportanceMedium},
+	{[]string{"gdp", "gross domestic product"}, TypeGrowth, ImportanceHigh},
+	{[]string{"pmi", "ism", "retail sales", "industrial production", "durable goods", "trade balance", "consumer confidence", "sentiment"}, TypeGrowth, ImportanceMedium},
+}
+
+// classify returns the type and default importance for a title.
+func classify(title string) (string, string) {
+	text := " " + words(title) + " "
+	for _, k := range keywords {
+		for _, w := range k.words {
+			if strings.Contains(text, " "+w+" ") {
+				return k.typ, k.importance
+			}
+		}
+	}
+	return TypeOther, ImportanceLow
+}
+
+// words lower-cases s and replaces everything but letters and digits with
+// single spaces.
+func words(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ")
+}
+
+// ParseImportance maps the labels sources use (High/Medium/Low, 1-3 stars,
+// red/orange/yellow) to an importance level, or "" if s isn't one.
+func ParseImportance(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "high", "3", "***", "red":
+		return ImportanceHigh
+	case "medium", "moderate", "med", "2", "**", "orange":
+		return ImportanceMedium
+	case "low", "1", "*", "yellow", "holiday", "non-economic", "none":
+		return ImportanceLow
+	}
+	return ""
+}
+
+// rank orders importance levels; unknown levels rank with low.
+func rank(importance string) int {
+	switch importance {
+	case ImportanceHigh:
+		return 3
+	case ImportanceMedium:
+		return 2
+	}
+	return 1
+}
+
+// AtLeast reports whether the event is at least as important as min.
+func (e Event) AtLeast(min string) bool {
+	return rank(e.Importance) >= rank(ParseImportance(min))
+}
+
+// countries maps country and region names to the currency code feeds like
+// Forex Factory use, so filters and IDs agree across sources.
+var countries = map[string]string{
+	"US": "USD", "USA": "USD", "UNITED STATES": "USD",
+	"EU": "EUR", "EMU": "EUR", "EURO AREA": "EUR", "EUROZONE": "EUR", "EUROPEAN UNION": "EUR",
+	"GERMANY": "EUR", "FRANCE": "EUR", "ITALY": "EUR", "SPAIN": "EUR",
+	"UK": "GBP", "GB": "GBP", "UNITED KINGDOM": "GBP",
+	"JP": "JPY", "JAPAN": "JPY",
+	"CN": "CNY", "CHINA": "CNY",
+	"CA": "CAD", "CANADA": "CAD",
+	"AU": "AUD", "AUSTRALIA": "AUD",
+	"NZ": "NZD", "NEW ZEALAND": "NZD",
+	"CH": "CHF", "SWITZERLAND": "CHF",
+}
+
+// NormalizeCountry returns the currency code for a country name or code.
+func NormalizeCountry(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if code, ok := countries[s]; ok {
+		return code
+	}
+	return s
+}
+
+// normalize fills in the fields a source may leave out: the country code,
+// type and importance from the title, and the ID.
+func (e *Event) normalize(source string) {
+	e.Title = strings.TrimSpace(e.Title)
+	e.Country = NormalizeCountry(e.Country)
+	typ, importance := classify(e.Title)
+	if e.Type == "" {
+		e.Type = typ
+	}
+	if level := ParseImportance(e.Importance); level != "" {
+		e.Importance = level
+	} else {
+		e.Importance = importance
+	}
+	if e.Source == "" {
+		e.Source = source
+	}
+	if e.ID == "" {
+		e.ID = eventID(e.Country, e.Title, e.Time)
+	}
+}
+
+// eventID identifies an event by what it is and when, so the same release
+// from different feeds or refreshes gets the same ID.
+func eventID(country, title string, at time.Time) string {
+	sum := sha1.Sum([]byte(country + "|" + words(title) + "|" + at.UTC().Format(time.RFC3339)))
+	return hex.EncodeToString(sum[:6])
+}
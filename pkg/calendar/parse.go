@@ -0,0 +1,336 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse reads events in format "ics", "json" or "csv"; an empty format is
+// guessed from the content. Events without a title or time are skipped.
+func Parse(data []byte, format, source string) ([]Event, error) {
+	if format == "" {
+		format = guessFormat(data)
+	}
+	var events []Event
+	var err error
+	switch strings.ToLower(format) {
+	case "ics", "ical":
+		events, err = parseICS(data)
+	case "json":
+		events, err = parseJSON(data)
+	case "csv":
+		events, err = parseCSV(data)
+	default:
+		return nil, fmt.Errorf("unknown calendar format %q (use ics, json or csv)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].normalize(source)
+	}
+	return events, nil
+}
+
+func guessFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("BEGIN:VCALENDAR")):
+		return "ics"
+	case bytes.HasPrefix(trimmed, []byte("[")), bytes.HasPrefix(trimmed, []byte("{")):
+		return "json"
+	}
+	return "csv"
+}
+
+// parseICS reads the VEVENTs of an iCalendar file. Consensus, previous and
+// actual values and the country are read from "Forecast: ..." style lines
+// in the description; a currency code before the title ("USD - CPI m/m" or
+// "[USD] CPI m/m") also gives the country.
+func parseICS(data []byte) ([]Event, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	// Unfold continuation lines
+	text = strings.NewReplacer("\n ", "", "\n\t", "").Replace(text)
+
+	var events []Event
+	var e *Event
+	for _, line := range strings.Split(text, "\n") {
+		name, params, value := icsLine(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			e = &Event{}
+		case name == "END" && value == "VEVENT" && e != nil:
+			if e.Title != "" && !e.Time.IsZero() {
+				code, title := splitCountry(e.Title)
+				if e.Country == "" {
+					e.Country = code
+				}
+				e.Title = title
+				events = append(events, *e)
+			}
+			e = nil
+		case e == nil:
+		case name == "DTSTART":
+			t, allDay, err := icsTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %w", e.Title, err)
+			}
+			e.Time, e.AllDay = t, allDay
+		case name == "SUMMARY":
+			e.Title = icsText(value)
+		case name == "LOCATION":
+			if e.Country == "" {
+				e.Country = icsText(value)
+			}
+		case name == "PRIORITY":
+			// RFC 5545: 1-4 high, 5 medium, 6-9 low, 0 undefined
+			if p, err := strconv.Atoi(value); err == nil && p > 0 && e.Importance == "" {
+				e.Importance = ImportanceLow
+				if p <= 4 {
+					e.Importance = ImportanceHigh
+				} else if p == 5 {
+					e.Importance = ImportanceMedium
+				}
+			}
+		case name == "DESCRIPTION":
+			for _, l := range strings.Split(icsText(value), "\n") {
+				key, val, ok := strings.Cut(l, ":")
+				if !ok {
+					continue
+				}
+				setField(e, key, strings.TrimSpace(val))
+			}
+		}
+	}
+	return events, nil
+}
+
+// icsLine splits "NAME;PARAM=X:VALUE".
+func icsLine(line string) (string, map[string]string, string) {
+	head, value, ok := strings.Cut(strings.TrimRight(line, "\r"), ":")
+	if !ok {
+		return "", nil, ""
+	}
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value)
+}
+
+// icsTime parses a DTSTART value: a date, a UTC time, or a local time in
+// the TZID zone (UTC when the zone is unknown or missing).
+func icsTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	loc := time.UTC
+	if tz := params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.UTC(), false, err
+}
+
+func icsText(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// splitCountry takes a leading currency code off a title.
+func splitCountry(title string) (string, string) {
+	if strings.HasPrefix(title, "[") {
+		if code, rest, ok := strings.Cut(title[1:], "]"); ok && isCode(code) {
+			return code, strings.TrimSpace(rest)
+		}
+	}
+	for _, sep := range []string{" - ", ": "} {
+		if code, rest, ok := strings.Cut(title, sep); ok && isCode(code) {
+			return code, strings.TrimSpace(rest)
+		}
+	}
+	return "", title
+}
+
+func isCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// setField sets the event field a feed's column or key names. Names are
+// matched loosely so "Forecast", "consensus" and "Impact" all work.
+func setField(e *Event, key, value string) {
+	if value == "" {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(strings.ReplaceAll(key, "_", " "))) {
+	case "id":
+		e.ID = value
+	case "title", "event", "name", "summary":
+		e.Title = value
+	case "country", "currency", "region", "ccy":
+		e.Country = value
+	case "impact", "importance", "priority", "volatility":
+		e.Importance = value
+	case "type", "category":
+		e.Type = strings.ToLower(value)
+	case "forecast", "consensus", "expected", "estimate":
+		e.Consensus = value
+	case "previous", "prior", "prev":
+		e.Previous = value
+	case "actual":
+		e.Actual = value
+	case "source":
+		e.Source = value
+	case "all day":
+		e.AllDay = value == "true"
+	}
+}
+
+// timeKeys are the keys and columns a feed may give the time in. A "date"
+// column may be followed by a separate "time" column.
+var timeKeys = []string{"time", "date", "datetime", "timestamp", "start"}
+
+// parseTime reads a date and an optional time of day. Times without an
+// offset are UTC; a missing, "All Day" or "Tentative" time gives an all-day
+// event.
+func parseTime(date, clock string) (time.Time, bool, error) {
+	date = strings.TrimSpace(date)
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.UTC(), false, nil
+		}
+	}
+	if ms, err := strconv.ParseInt(date, 10, 64); err == nil && ms > 1e11 {
+		return time.UnixMilli(ms).UTC(), false, nil
+	}
+	var day time.Time
+	var err error
+	for _, layout := range []string{"2006-01-02", "01-02-2006", "01/02/2006", "Jan 2, 2006", "Mon Jan 2 2006"} {
+		if day, err = time.Parse(layout, date); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("unrecognized date %q", date)
+	}
+	clock = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(clock), " ", ""))
+	if clock == "" || clock == "allday" || clock == "tentative" || clock == "dayone" {
+		return day, true, nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05", "3:04pm", "3pm"} {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return day.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("unrecognized time %q", clock)
+}
+
+// parseJSON reads an array of event objects, or an object with an "events"
+// array, e.g. the Forex Factory weekly export.
+func parseJSON(data []byte) ([]Event, error) {
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var wrapped struct {
+			Events []map[string]interface{} `json:"events"`
+		}
+		if err2 := json.Unmarshal(data, &wrapped); err2 != nil {
+			return nil, err
+		}
+		rows = wrapped.Events
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		fields := make(map[string]string, len(row))
+		for k, v := range row {
+			switch v := v.(type) {
+			case string:
+				fields[strings.ToLower(k)] = v
+			case float64:
+				fields[strings.ToLower(k)] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				fields[strings.ToLower(k)] = strconv.FormatBool(v)
+			}
+		}
+		if e, ok := fromFields(fields); ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// parseCSV reads a CSV file with a header row.
+func parseCSV(data []byte) ([]Event, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	events := make([]Event, 0, len(records)-1)
+	for _, record := range records[1:] {
+		fields := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				fields[strings.ToLower(strings.TrimSpace(name))] = record[i]
+			}
+		}
+		if e, ok := fromFields(fields); ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// fromFields builds an event from a JSON object or CSV row.
+func fromFields(fields map[string]string) (Event, bool) {
+	var e Event
+	for k, v := range fields {
+		setField(&e, k, strings.TrimSpace(v))
+	}
+	date, clock := "", ""
+	for _, k := range timeKeys {
+		if v := strings.TrimSpace(fields[k]); v != "" && date == "" {
+			date = v
+		}
+	}
+	// A separate time-of-day column next to a date column
+	if d := strings.TrimSpace(fields["date"]); d != "" && fields["time"] != "" && !strings.Contains(d, "T") {
+		date, clock = d, fields["time"]
+	}
+	if e.Title == "" || date == "" {
+		return Event{}, false
+	}
+	t, allDay, err := parseTime(date, clock)
+	if err != nil {
+		return Event{}, false
+	}
+	e.Time, e.AllDay = t, allDay || e.AllDay
+	return e, true
+}
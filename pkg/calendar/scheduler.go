@@ -0,0 +1,174 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// jobPrefix names the cron jobs the scheduler owns.
+const jobPrefix = "calendar:"
+
+// Scheduler keeps one-time cron jobs that run the agent before and after
+// each important upcoming event, moving them when an event is rescheduled
+// and removing them when it disappears from the calendar.
+type Scheduler struct {
+	calendar  *Calendar
+	cron      *cron.CronService
+	min       string
+	pre       time.Duration
+	post      time.Duration
+	lookahead time.Duration
+	channel   string
+	chatID    string
+	now       func() time.Time
+}
+
+// NewScheduler returns nil when scheduling is disabled or there is no
+// calendar, and an error when a channel is configured without a chat to
+// send to.
+func NewScheduler(cal *Calendar, cronService *cron.CronService, cfg config.CalendarScheduleConfig) (*Scheduler, error) {
+	if cal == nil || cronService == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.Channel != "" && cfg.ChatID == "" {
+		return nil, fmt.Errorf("schedule channel %q has no chat_id", cfg.Channel)
+	}
+	s := &Scheduler{
+		calendar:  cal,
+		cron:      cronService,
+		min:       ParseImportance(cfg.MinImportance),
+		pre:       time.Duration(cfg.PreMinutes) * time.Minute,
+		post:      time.Duration(cfg.PostMinutes) * time.Minute,
+		lookahead: time.Duration(cfg.LookaheadHours) * time.Hour,
+		channel:   cfg.Channel,
+		chatID:    cfg.ChatID,
+		now:       time.Now,
+	}
+	if s.min == "" {
+		s.min = ImportanceHigh
+	}
+	if s.post <= 0 {
+		s.post = 15 * time.Minute
+	}
+	if s.lookahead <= 0 {
+		s.lookahead = 24 * time.Hour
+	}
+	return s, nil
+}
+
+// Run syncs the jobs every calendar refresh interval until ctx is
+// cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.calendar.Refresh())
+	defer ticker.Stop()
+	for {
+		if _, _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+			logger.WarnCF("calendar", "Failed to schedule calendar events",
+				map[string]interface{}{
+					"error": err.Error(),
+				})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync makes the cron jobs match the calendar: a pre-event job for events
+// starting after PreMinutes from now and a post-event job for events whose
+// post-event run is still ahead. It returns how many jobs it added and
+// removed.
+func (s *Scheduler) Sync(ctx context.Context) (int, int, error) {
+	events, err := s.calendar.Events(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := s.now()
+
+	type job struct {
+		at      time.Time
+		message string
+	}
+	want := make(map[string]job)
+	for _, e := range events {
+		if e.AllDay || !e.AtLeast(s.min) || e.Time.After(now.Add(s.lookahead)) {
+			continue
+		}
+		if s.pre > 0 && e.Time.Add(-s.pre).After(now) {
+			want[jobPrefix+"pre:"+e.ID] = job{e.Time.Add(-s.pre), preMessage(e, s.pre)}
+		}
+		if e.Time.Add(s.post).After(now) {
+			want[jobPrefix+"post:"+e.ID] = job{e.Time.Add(s.post), postMessage(e)}
+		}
+	}
+
+	added, removed := 0, 0
+	for _, existing := range s.cron.ListJobs(true) {
+		if !strings.HasPrefix(existing.Name, jobPrefix) {
+			continue
+		}
+		j, ok := want[existing.Name]
+		if ok && existing.Schedule.AtMS != nil && *existing.Schedule.AtMS == j.at.UnixMilli() {
+			delete(want, existing.Name)
+			continue
+		}
+		if !ok && existing.Schedule.AtMS != nil && *existing.Schedule.AtMS <= now.UnixMilli() {
+			continue // Due, cron runs and deletes it
+		}
+		// Rescheduled or no longer in the calendar
+		s.cron.RemoveJob(existing.ID)
+		removed++
+	}
+	for name, j := range want {
+		atMS := j.at.UnixMilli()
+		if _, err := s.cron.AddJob(name, cron.CronSchedule{Kind: "at", AtMS: &atMS}, j.message, false, s.channel, s.chatID); err != nil {
+			return added, removed, fmt.Errorf("adding %s: %w", name, err)
+		}
+		added++
+	}
+	if added > 0 || removed > 0 {
+		logger.InfoCF("calendar", "Scheduled calendar event runs",
+			map[string]interface{}{
+				"added":   added,
+				"removed": removed,
+			})
+	}
+	return added, removed, nil
+}
+
+// describe summarizes an event for a job message.
+func describe(e Event) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s (%s importance, %s) at %s", e.Country, e.Title, e.Importance, e.Type, e.Time.UTC().Format("2006-01-02 15:04 UTC"))
+	if e.Consensus != "" {
+		fmt.Fprintf(&sb, ", consensus %s", e.Consensus)
+	}
+	if e.Previous != "" {
+		fmt.Fprintf(&sb, ", previous %s", e.Previous)
+	}
+	fmt.Fprintf(&sb, " [event id %s]", e.ID)
+	return sb.String()
+}
+
+func preMessage(e Event, lead time.Duration) string {
+	return fmt.Sprintf("Economic calendar: %s is released in %d minutes. Execute the event_macro_trigger skill in pre-event mode: "+
+		"record the market baseline (BTC, ETH, gold, DXY proxy, SPX) and the expected reaction for a beat or a miss against consensus, "+
+		"and save it with the upcoming events. Do NOT send a Telegram message unless positioning into the release looks unusually risky.",
+		describe(e), int(lead.Minutes()))
+}
+
+func postMessage(e Event) string {
+	return fmt.Sprintf("Economic calendar: %s has been released. Execute the event_macro_trigger skill in post-event mode: "+
+		"get the actual value with economic_calendar action \"released\" (if it is missing, find it in the news and save it with action \"record\" and this event id), "+
+		"compare it with consensus, measure the market reaction against the pre-event baseline, and update the active triggers and macro patterns. "+
+		"Only send a Telegram message if the surprise or the market reaction is significant.",
+		describe(e))
+}
@@ -49,6 +49,7 @@ func (c *DiscordChannel) Start(ctx context.Context) error {
 	logger.InfoC("discord", "Starting Discord bot")
 
 	c.session.AddHandler(c.handleMessage)
+	c.session.AddHandler(c.handleInteraction)
 
 	if err := c.session.Open(); err != nil {
 		return fmt.Errorf("failed to open discord session: %w", err)
@@ -91,6 +92,29 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 
 	message := msg.Content
 
+	if len(msg.Actions) > 0 {
+		buttons := make([]discordgo.MessageComponent, 0, len(msg.Actions))
+		for i, action := range msg.Actions {
+			style := discordgo.SecondaryButton
+			if i == 0 {
+				style = discordgo.PrimaryButton
+			}
+			buttons = append(buttons, discordgo.Button{
+				Label:    action.Label,
+				Style:    style,
+				CustomID: action.Data,
+			})
+		}
+
+		if _, err := c.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:    message,
+			Components: []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}},
+		}); err != nil {
+			return fmt.Errorf("failed to send discord message: %w", err)
+		}
+		return nil
+	}
+
 	if _, err := c.session.ChannelMessageSend(channelID, message); err != nil {
 		return fmt.Errorf("failed to send discord message: %w", err)
 	}
@@ -98,6 +122,51 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 	return nil
 }
 
+// handleInteraction turns a button press into an inbound message carrying the
+// button's custom ID, then removes the buttons from the original message.
+func (c *DiscordChannel) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i == nil || i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return
+	}
+
+	data := i.MessageComponentData()
+
+	content := ""
+	if i.Message != nil {
+		content = i.Message.Content
+	}
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	}); err != nil {
+		logger.ErrorCF("discord", "Failed to respond to interaction", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	metadata := map[string]string{
+		"user_id":    user.ID,
+		"username":   user.Username,
+		"guild_id":   i.GuildID,
+		"channel_id": i.ChannelID,
+		"is_dm":      fmt.Sprintf("%t", i.GuildID == ""),
+		"callback":   "true",
+	}
+
+	c.HandleMessage(user.ID, i.ChannelID, data.CustomID, nil, metadata)
+}
+
 func (c *DiscordChannel) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
 	if m == nil || m.Author == nil {
 		return
@@ -83,6 +83,8 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 				}
 				if update.Message != nil {
 					c.handleMessage(update)
+				} else if update.CallbackQuery != nil {
+					c.handleCallback(update.CallbackQuery)
 				}
 			}
 		}
@@ -121,6 +123,10 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 
 	htmlContent := markdownToTelegramHTML(msg.Content)
 
+	if len(msg.Actions) > 0 {
+		return c.sendWithActions(chatID, htmlContent, msg)
+	}
+
 	// Try to edit placeholder
 	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
 		c.placeholders.Delete(msg.ChatID)
@@ -147,6 +153,61 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// sendWithActions sends a message with an inline keyboard, one button per action.
+func (c *TelegramChannel) sendWithActions(chatID int64, htmlContent string, msg bus.OutboundMessage) error {
+	buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(msg.Actions))
+	for _, action := range msg.Actions {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(action.Label, action.Data))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
+
+	tgMsg := tgbotapi.NewMessage(chatID, htmlContent)
+	tgMsg.ParseMode = tgbotapi.ModeHTML
+	tgMsg.ReplyMarkup = keyboard
+
+	if _, err := c.bot.Send(tgMsg); err != nil {
+		log.Printf("HTML parse failed, falling back to plain text: %v", err)
+		tgMsg = tgbotapi.NewMessage(chatID, msg.Content)
+		tgMsg.ReplyMarkup = keyboard
+		_, err = c.bot.Send(tgMsg)
+		return err
+	}
+
+	return nil
+}
+
+// handleCallback turns an inline keyboard press into an inbound message
+// carrying the button's data, then removes the keyboard.
+func (c *TelegramChannel) handleCallback(query *tgbotapi.CallbackQuery) {
+	if query.From == nil || query.Message == nil {
+		return
+	}
+
+	c.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+
+	senderID := fmt.Sprintf("%d", query.From.ID)
+	if query.From.UserName != "" {
+		senderID = fmt.Sprintf("%d|%s", query.From.ID, query.From.UserName)
+	}
+	if !c.IsAllowed(senderID) {
+		return
+	}
+
+	chatID := query.Message.Chat.ID
+	c.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+
+	metadata := map[string]string{
+		"message_id": fmt.Sprintf("%d", query.Message.MessageID),
+		"user_id":    fmt.Sprintf("%d", query.From.ID),
+		"username":   query.From.UserName,
+		"first_name": query.From.FirstName,
+		"callback":   "true",
+	}
+
+	c.HandleMessage(senderID, fmt.Sprintf("%d", chatID), query.Data, nil, metadata)
+}
+
 func (c *TelegramChannel) handleMessage(update tgbotapi.Update) {
 	message := update.Message
 	if message == nil {
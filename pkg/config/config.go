@@ -3,10 +3,12 @@ package config
 import (
 	"encoding/json"
 	"os"
-	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/caarlos0/env/v11"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
 )
 
 type Config struct {
@@ -19,8 +21,76 @@ type Config struct {
 }
 
 type AgentsConfig struct {
-	Defaults AgentDefaults            `json:"defaults"`
-	Named    map[string]AgentDefaults `json:"named,omitempty"`
+	Defaults  AgentDefaults            `json:"defaults"`
+	Named     map[string]AgentDefaults `json:"named,omitempty"`
+	Vision    VisionConfig             `json:"vision"`
+	Subagents SubagentsConfig          `json:"subagents"`
+	Memory    MemoryConfig             `json:"memory"`
+}
+
+// MemoryConfig controls how memory reaches the system prompt. In "search"
+// mode only the snippets most relevant to the current message are injected,
+// within ContextTokens; "hybrid" ranks them with both keyword search and
+// embeddings from providers.embeddings; "full" injects MEMORY.md and the last
+// three days of notes. DataFiles are glob patterns under workspace/data that are indexed
+// alongside the memory files.
+type MemoryConfig struct {
+	Retrieval     string              `json:"retrieval" env:"PICOCLAW_AGENTS_MEMORY_RETRIEVAL"`
+	ContextTokens int                 `json:"context_tokens" env:"PICOCLAW_AGENTS_MEMORY_CONTEXT_TOKENS"`
+	TopK          int                 `json:"top_k" env:"PICOCLAW_AGENTS_MEMORY_TOP_K"`
+	DataFiles     []string            `json:"data_files" env:"PICOCLAW_AGENTS_MEMORY_DATA_FILES"`
+	Consolidation ConsolidationConfig `json:"consolidation"`
+}
+
+// ConsolidationConfig controls the background job that rolls daily notes into
+// weekly and monthly digests under memory/digests. Raw notes older than
+// RetentionDays are archived to memory/archive (deleted when Archive is
+// false) once their week is digested, and learnings that recur in
+// PromoteAfter weekly digests become long-term facts. DryRun keeps the job
+// from running; "picoclaw memory consolidate --dry-run" previews it once.
+type ConsolidationConfig struct {
+	Enabled       bool `json:"enabled" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_ENABLED"`
+	IntervalHours int  `json:"interval_hours" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_INTERVAL_HOURS"`
+	RetentionDays int  `json:"retention_days" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_RETENTION_DAYS"`
+	Archive       bool `json:"archive" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_ARCHIVE"`
+	PromoteAfter  int  `json:"promote_after" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_PROMOTE_AFTER"`
+	DryRun        bool `json:"dry_run" env:"PICOCLAW_AGENTS_MEMORY_CONSOLIDATION_DRY_RUN"`
+}
+
+// SubagentsConfig controls background subagents started with the spawn tool.
+// Tools is the most a subagent may use; a spawn call can narrow it further.
+// An empty Model means the main agent's model.
+type SubagentsConfig struct {
+	Model                  string   `json:"model" env:"PICOCLAW_AGENTS_SUBAGENTS_MODEL"`
+	MaxIterations          int      `json:"max_iterations" env:"PICOCLAW_AGENTS_SUBAGENTS_MAX_ITERATIONS"`
+	MaxTokens              int      `json:"max_tokens" env:"PICOCLAW_AGENTS_SUBAGENTS_MAX_TOKENS"`
+	TimeoutSeconds         int      `json:"timeout_seconds" env:"PICOCLAW_AGENTS_SUBAGENTS_TIMEOUT_SECONDS"`
+	MaxConcurrentPerOrigin int      `json:"max_concurrent_per_origin" env:"PICOCLAW_AGENTS_SUBAGENTS_MAX_CONCURRENT_PER_ORIGIN"`
+	Tools                  []string `json:"tools" env:"PICOCLAW_AGENTS_SUBAGENTS_TOOLS"`
+}
+
+// VisionConfig controls whether channel images are passed to the model.
+// Models lists case-insensitive substrings of vision-capable model names.
+type VisionConfig struct {
+	Enabled      bool     `json:"enabled" env:"PICOCLAW_AGENTS_VISION_ENABLED"`
+	Models       []string `json:"models" env:"PICOCLAW_AGENTS_VISION_MODELS"`
+	MaxDimension int      `json:"max_dimension" env:"PICOCLAW_AGENTS_VISION_MAX_DIMENSION"`
+	MaxBytes     int      `json:"max_bytes" env:"PICOCLAW_AGENTS_VISION_MAX_BYTES"`
+	MaxImages    int      `json:"max_images" env:"PICOCLAW_AGENTS_VISION_MAX_IMAGES"`
+}
+
+// SupportsModel reports whether images should be sent to the given model.
+func (v VisionConfig) SupportsModel(model string) bool {
+	if !v.Enabled {
+		return false
+	}
+	lowerModel := strings.ToLower(model)
+	for _, m := range v.Models {
+		if m != "" && strings.Contains(lowerModel, strings.ToLower(m)) {
+			return true
+		}
+	}
+	return false
 }
 
 type AgentDefaults struct {
@@ -90,14 +160,16 @@ type DingTalkConfig struct {
 }
 
 type ProvidersConfig struct {
-	Anthropic  ProviderConfig `json:"anthropic"`
-	OpenAI     ProviderConfig `json:"openai"`
-	OpenRouter ProviderConfig `json:"openrouter"`
-	Groq       ProviderConfig `json:"groq"`
-	Zhipu      ProviderConfig `json:"zhipu"`
-	VLLM       ProviderConfig `json:"vllm"`
-	Gemini     ProviderConfig `json:"gemini"`
-	DeepSeek   ProviderConfig `json:"deepseek"`
+	Anthropic  ProviderConfig   `json:"anthropic"`
+	OpenAI     ProviderConfig   `json:"openai"`
+	OpenRouter ProviderConfig   `json:"openrouter"`
+	Groq       ProviderConfig   `json:"groq"`
+	Zhipu      ProviderConfig   `json:"zhipu"`
+	VLLM       ProviderConfig   `json:"vllm"`
+	Gemini     ProviderConfig   `json:"gemini"`
+	DeepSeek   ProviderConfig   `json:"deepseek"`
+	Cassette   CassetteConfig   `json:"cassette"`
+	Embeddings EmbeddingsConfig `json:"embeddings"`
 }
 
 type ProviderConfig struct {
@@ -105,6 +177,25 @@ type ProviderConfig struct {
 	APIBase string `json:"api_base" env:"PICOCLAW_PROVIDERS_{{.Name}}_API_BASE"`
 }
 
+// CassetteConfig records LLM exchanges to a file or replays them, for
+// deterministic end-to-end runs without network access.
+// Mode is "record", "replay" or "auto"; an empty Path disables it.
+type CassetteConfig struct {
+	Path string `json:"path" env:"PICOCLAW_PROVIDERS_CASSETTE_PATH"`
+	Mode string `json:"mode" env:"PICOCLAW_PROVIDERS_CASSETTE_MODE"`
+}
+
+// EmbeddingsConfig points at an OpenAI-compatible /embeddings endpoint, such
+// as OpenAI itself or a local Ollama or llama.cpp server. When APIBase is
+// empty the OpenAI provider's key and base are used. Dimensions is optional
+// and only sent when set.
+type EmbeddingsConfig struct {
+	APIKey     string `json:"api_key" env:"PICOCLAW_PROVIDERS_EMBEDDINGS_API_KEY"`
+	APIBase    string `json:"api_base" env:"PICOCLAW_PROVIDERS_EMBEDDINGS_API_BASE"`
+	Model      string `json:"model" env:"PICOCLAW_PROVIDERS_EMBEDDINGS_MODEL"`
+	Dimensions int    `json:"dimensions" env:"PICOCLAW_PROVIDERS_EMBEDDINGS_DIMENSIONS"`
+}
+
 type GatewayConfig struct {
 	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
 	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
@@ -119,10 +210,216 @@ type WebToolsConfig struct {
 	Search WebSearchConfig `json:"search"`
 }
 
+type ApprovalRuleConfig struct {
+	Tool    string `json:"tool"`
+	Arg     string `json:"arg,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+type ApprovalConfig struct {
+	Enabled        bool                 `json:"enabled" env:"PICOCLAW_TOOLS_APPROVAL_ENABLED"`
+	TimeoutSeconds int                  `json:"timeout_seconds" env:"PICOCLAW_TOOLS_APPROVAL_TIMEOUT_SECONDS"`
+	Unattended     string               `json:"unattended" env:"PICOCLAW_TOOLS_APPROVAL_UNATTENDED"`
+	Rules          []ApprovalRuleConfig `json:"rules"`
+}
+
+// RetentionPolicyConfig bounds the files matching Pattern directly inside
+// data/<Path>. Files are removed when older than MaxAgeDays, beyond the
+// newest MaxCount, or beyond MaxSizeMB in total; files older than
+// DownsampleAfterDays are first thinned to the newest one per
+// DownsampleInterval (e.g. "1h"). With Archive, removed files are bundled into
+// data/archive/<Path>/<time>.tar.gz instead of deleted. Zero disables a limit.
+type RetentionPolicyConfig struct {
+	Path                string `json:"path"`
+	Pattern             string `json:"pattern,omitempty"`
+	MaxAgeDays          int    `json:"max_age_days,omitempty"`
+	MaxCount            int    `json:"max_count,omitempty"`
+	MaxSizeMB           int    `json:"max_size_mb,omitempty"`
+	DownsampleAfterDays int    `json:"downsample_after_days,omitempty"`
+	DownsampleInterval  string `json:"downsample_interval,omitempty"`
+	Archive             bool   `json:"archive,omitempty"`
+}
+
+// RetentionConfig controls the gateway job that applies the retention
+// policies every IntervalHours. DryRun only logs what would be removed.
+type RetentionConfig struct {
+	Enabled       bool                    `json:"enabled" env:"PICOCLAW_TOOLS_STORAGE_RETENTION_ENABLED"`
+	IntervalHours int                     `json:"interval_hours" env:"PICOCLAW_TOOLS_STORAGE_RETENTION_INTERVAL_HOURS"`
+	DryRun        bool                    `json:"dry_run" env:"PICOCLAW_TOOLS_STORAGE_RETENTION_DRY_RUN"`
+	Policies      []RetentionPolicyConfig `json:"policies"`
+}
+
+type StorageConfig struct {
+	Retention RetentionConfig `json:"retention"`
+}
+
+// MarketDataCacheConfig keeps market_data results for the given number of
+// seconds, so cron jobs asking for the same tickers and candles minutes apart
+// share one request. Candles are never kept longer than their interval. With
+// Persist the cache is saved to workspace/cache/market_data.json and survives
+// restarts.
+type MarketDataCacheConfig struct {
+	Enabled            bool `json:"enabled" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_ENABLED"`
+	TickerSeconds      int  `json:"ticker_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_TICKER_SECONDS"`
+	CandlesSeconds     int  `json:"candles_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_CANDLES_SECONDS"`
+	OrderBookSeconds   int  `json:"orderbook_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_ORDERBOOK_SECONDS"`
+	ForexSeconds       int  `json:"forex_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_FOREX_SECONDS"`
+	DerivativesSeconds int  `json:"derivatives_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_DERIVATIVES_SECONDS"`
+	Persist            bool `json:"persist" env:"PICOCLAW_TOOLS_MARKET_DATA_CACHE_PERSIST"`
+}
+
+// MarketStreamConfig has the gateway subscribe to Binance's websocket
+// ticker and kline streams for Symbols and keep the last WindowMinutes of
+// prices in memory, read by the market_stream tool. URL overrides the
+// endpoint, e.g. wss://data-stream.binance.vision where stream.binance.com is
+// blocked. Dropped connections are retried with exponential backoff up to
+// MaxBackoffSeconds. The stream is off by default, so enabling it is an
+// explicit choice to hold a connection open to Binance.
+type MarketStreamConfig struct {
+	Enabled           bool     `json:"enabled" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_ENABLED"`
+	URL               string   `json:"url" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_URL"`
+	Symbols           []string `json:"symbols" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_SYMBOLS"`
+	Intervals         []string `json:"intervals" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_INTERVALS"`
+	WindowMinutes     int      `json:"window_minutes" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_WINDOW_MINUTES"`
+	MaxBackoffSeconds int      `json:"max_backoff_seconds" env:"PICOCLAW_TOOLS_MARKET_DATA_STREAM_MAX_BACKOFF_SECONDS"`
+}
+
+// MarketHistoryConfig keeps the candles of market_data's history action
+// under workspace/data/candles, so long lookbacks are downloaded once and
+// afterwards only extended. MaxFetchCandles bounds what one request
+// downloads; MaxCandles bounds what it returns.
+type MarketHistoryConfig struct {
+	Enabled         bool `json:"enabled" env:"PICOCLAW_TOOLS_MARKET_DATA_HISTORY_ENABLED"`
+	MaxFetchCandles int  `json:"max_fetch_candles" env:"PICOCLAW_TOOLS_MARKET_DATA_HISTORY_MAX_FETCH_CANDLES"`
+	MaxCandles      int  `json:"max_candles" env:"PICOCLAW_TOOLS_MARKET_DATA_HISTORY_MAX_CANDLES"`
+}
+
+// MarketDataConfig lists the sources market_data tries for each asset class,
+// in order: "binance", "okx", "yahoo", "erapi" or "frankfurter". Derivatives
+// lists the sources of perpetual futures data ("binance", "okx"). BaseURLs
+// overrides a source's API root, e.g. {"binance": "https://data-api.binance.vision"}
+// where api.binance.com is blocked; "binance_futures" overrides Binance's
+// futures API root. Aliases adds symbol aliases to the built-in registry,
+// e.g. {"NAS": "NDX", "GOLDSPOT": "XAUUSD"}.
+type MarketDataConfig struct {
+	Crypto      []string              `json:"crypto" env:"PICOCLAW_TOOLS_MARKET_DATA_CRYPTO"`
+	FX          []string              `json:"fx" env:"PICOCLAW_TOOLS_MARKET_DATA_FX"`
+	Metals      []string              `json:"metals" env:"PICOCLAW_TOOLS_MARKET_DATA_METALS"`
+	Equity      []string              `json:"equity" env:"PICOCLAW_TOOLS_MARKET_DATA_EQUITY"`
+	Derivatives []string              `json:"derivatives" env:"PICOCLAW_TOOLS_MARKET_DATA_DERIVATIVES"`
+	BaseURLs    map[string]string     `json:"base_urls,omitempty"`
+	Aliases     map[string]string     `json:"aliases,omitempty"`
+	Cache       MarketDataCacheConfig `json:"cache"`
+	Stream      MarketStreamConfig    `json:"stream"`
+	History     MarketHistoryConfig   `json:"history"`
+}
+
+// RateLimitHostConfig is the budget of one upstream. Host is a name or a
+// glob such as "*.binance.com". WeightPerMinute is the request weight budget
+// for hosts that report their used weight (Binance's X-MBX-USED-WEIGHT-1M).
+type RateLimitHostConfig struct {
+	Host              string `json:"host"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	Burst             int    `json:"burst,omitempty"`
+	WeightPerMinute   int    `json:"weight_per_minute,omitempty"`
+}
+
+// RateLimitConfig throttles the requests of market_data, news_feed and
+// web_fetch per host. Hosts not listed get DefaultRequestsPerMinute. A
+// request that would wait longer than MaxWaitSeconds (for a token or for a
+// host's 429/418 backoff to end) fails instead, so fallbacks kick in.
+type RateLimitConfig struct {
+	Enabled                  bool                  `json:"enabled" env:"PICOCLAW_TOOLS_RATE_LIMIT_ENABLED"`
+	DefaultRequestsPerMinute int                   `json:"default_requests_per_minute" env:"PICOCLAW_TOOLS_RATE_LIMIT_DEFAULT_REQUESTS_PER_MINUTE"`
+	MaxWaitSeconds           int                   `json:"max_wait_seconds" env:"PICOCLAW_TOOLS_RATE_LIMIT_MAX_WAIT_SECONDS"`
+	Hosts                    []RateLimitHostConfig `json:"hosts"`
+}
+
+// AlertRuleConfig is a price alert rule; see AlertsConfig.
+type AlertRuleConfig struct {
+	Symbol          string  `json:"symbol"`
+	Kind            string  `json:"kind"`
+	Percent         float64 `json:"percent,omitempty"`
+	WindowMinutes   int     `json:"window_minutes,omitempty"`
+	ZScore          float64 `json:"z_score,omitempty"`
+	Level           float64 `json:"level,omitempty"`
+	Direction       string  `json:"direction,omitempty"`
+	Multiplier      float64 `json:"multiplier,omitempty"`
+	Interval        string  `json:"interval,omitempty"`
+	CooldownMinutes int     `json:"cooldown_minutes,omitempty"`
+	Action          string  `json:"action,omitempty"`
+	Channel         string  `json:"channel,omitempty"`
+	ChatID          string  `json:"chat_id,omitempty"`
+	Note            string  `json:"note,omitempty"`
+}
+
+// AlertsConfig runs price alert rules in the gateway every CheckSeconds.
+// Kinds are "percent_move" (Percent within WindowMinutes), "zscore" (move
+// over WindowMinutes against the volatility noise profile), "level_cross"
+// and "volume_spike" (candle volume Multiplier times the average). A rule
+// fires again only after the condition clears and CooldownMinutes pass.
+// Action "agent" wakes the agent with the alert and Note; "notify" sends
+// the alert text straight to Channel/ChatID, which default to the ones here.
+// Alerts are off by default; rules with no channel and chat_id are skipped.
+// Rules added with the alerts tool are kept in workspace/alerts/rules.json.
+type AlertsConfig struct {
+	Enabled         bool              `json:"enabled" env:"PICOCLAW_TOOLS_ALERTS_ENABLED"`
+	CheckSeconds    int               `json:"check_seconds" env:"PICOCLAW_TOOLS_ALERTS_CHECK_SECONDS"`
+	CooldownMinutes int               `json:"cooldown_minutes" env:"PICOCLAW_TOOLS_ALERTS_COOLDOWN_MINUTES"`
+	Channel         string            `json:"channel" env:"PICOCLAW_TOOLS_ALERTS_CHANNEL"`
+	ChatID          string            `json:"chat_id" env:"PICOCLAW_TOOLS_ALERTS_CHAT_ID"`
+	Rules           []AlertRuleConfig `json:"rules"`
+}
+
+// CalendarFeedConfig is one economic calendar feed. Format is "ics", "json"
+// or "csv"; empty guesses it from the URL and the content.
+type CalendarFeedConfig struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Format string `json:"format,omitempty"`
+}
+
+// CalendarScheduleConfig has the gateway add one-time cron jobs that run
+// the agent PreMinutes before and PostMinutes after each event of at least
+// MinImportance in the next LookaheadHours. The runs speak in Channel/ChatID;
+// with no channel they run without a chat to message.
+type CalendarScheduleConfig struct {
+	Enabled        bool   `json:"enabled" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_ENABLED"`
+	MinImportance  string `json:"min_importance" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_MIN_IMPORTANCE"`
+	PreMinutes     int    `json:"pre_minutes" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_PRE_MINUTES"`
+	PostMinutes    int    `json:"post_minutes" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_POST_MINUTES"`
+	LookaheadHours int    `json:"lookahead_hours" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_LOOKAHEAD_HOURS"`
+	Channel        string `json:"channel" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_CHANNEL"`
+	ChatID         string `json:"chat_id" env:"PICOCLAW_TOOLS_CALENDAR_SCHEDULE_CHAT_ID"`
+}
+
+// CalendarConfig is the economic_calendar tool's data: Feeds are fetched at
+// most every RefreshMinutes, and File (relative to the workspace) holds
+// events added or corrected by hand or by the agent, which win over feed
+// entries for the same event. Countries limits events to those countries or
+// currencies; empty keeps all.
+type CalendarConfig struct {
+	Enabled        bool                   `json:"enabled" env:"PICOCLAW_TOOLS_CALENDAR_ENABLED"`
+	File           string                 `json:"file" env:"PICOCLAW_TOOLS_CALENDAR_FILE"`
+	Feeds          []CalendarFeedConfig   `json:"feeds"`
+	RefreshMinutes int                    `json:"refresh_minutes" env:"PICOCLAW_TOOLS_CALENDAR_REFRESH_MINUTES"`
+	Countries      []string               `json:"countries" env:"PICOCLAW_TOOLS_CALENDAR_COUNTRIES"`
+	Schedule       CalendarScheduleConfig `json:"schedule"`
+}
+
 type ToolsConfig struct {
-	Web WebToolsConfig `json:"web"`
+	Web        WebToolsConfig   `json:"web"`
+	Approval   ApprovalConfig   `json:"approval"`
+	Storage    StorageConfig    `json:"storage"`
+	MarketData MarketDataConfig `json:"market_data"`
+	RateLimit  RateLimitConfig  `json:"rate_limit"`
+	Alerts     AlertsConfig     `json:"alerts"`
+	Calendar   CalendarConfig   `json:"calendar"`
 }
 
+// volatilityAlertNote is what the default alert rules ask the agent to do.
+const volatilityAlertNote = "Execute the volatility_alert skill for this move: check the news for a cause and send the alert if it is significant."
+
 func DefaultConfig() *Config {
 	return &Config{
 		Agents: AgentsConfig{
@@ -133,6 +430,35 @@ func DefaultConfig() *Config {
 				Temperature:       0.7,
 				MaxToolIterations: 20,
 			},
+			Vision: VisionConfig{
+				Enabled:      true,
+				Models:       []string{"gpt-4o", "gpt-4.1", "gpt-5", "claude", "gemini", "glm-4v", "glm-4.5v", "qwen-vl", "pixtral", "llava", "vision"},
+				MaxDimension: 1568,
+				MaxBytes:     1 << 20,
+				MaxImages:    4,
+			},
+			Subagents: SubagentsConfig{
+				Model:                  "",
+				MaxIterations:          10,
+				MaxTokens:              4096,
+				TimeoutSeconds:         600,
+				MaxConcurrentPerOrigin: 3,
+				Tools:                  []string{"read_file", "list_dir", "web_search", "web_fetch", "market_data", "market_stream", "news_feed", "economic_calendar", "storage", "structured_output", "memory_search"},
+			},
+			Memory: MemoryConfig{
+				Retrieval:     "search",
+				ContextTokens: 1500,
+				TopK:          8,
+				DataFiles:     []string{"patterns/*.json", "learning/*.json", "postmortem/*.json", "macro/reasoning_log.json", "reports/*.md"},
+				Consolidation: ConsolidationConfig{
+					Enabled:       true,
+					IntervalHours: 24,
+					RetentionDays: 30,
+					Archive:       true,
+					PromoteAfter:  3,
+					DryRun:        false,
+				},
+			},
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
@@ -198,6 +524,99 @@ func DefaultConfig() *Config {
 					MaxResults: 5,
 				},
 			},
+			Approval: ApprovalConfig{
+				Enabled:        false,
+				TimeoutSeconds: 300,
+				Unattended:     "deny",
+				Rules: []ApprovalRuleConfig{
+					{Tool: "exec"},
+					{Tool: "write_file"},
+					{Tool: "edit_file"},
+				},
+			},
+			Storage: StorageConfig{
+				Retention: RetentionConfig{
+					Enabled:       true,
+					IntervalHours: 6,
+					DryRun:        false,
+					Policies: []RetentionPolicyConfig{
+						{Path: "scans", Pattern: "*.json", MaxAgeDays: 30, DownsampleAfterDays: 2, DownsampleInterval: "1h", Archive: true},
+						{Path: "reports", MaxAgeDays: 90, Archive: true},
+						{Path: "archive/scans", Pattern: "*.tar.gz", MaxSizeMB: 200},
+						{Path: "archive/reports", Pattern: "*.tar.gz", MaxSizeMB: 50},
+					},
+				},
+			},
+			MarketData: MarketDataConfig{
+				Crypto:      []string{"binance", "okx", "yahoo"},
+				FX:          []string{"erapi", "frankfurter", "yahoo"},
+				Metals:      []string{"erapi", "yahoo"},
+				Equity:      []string{"yahoo"},
+				Derivatives: []string{"binance", "okx"},
+				Cache: MarketDataCacheConfig{
+					Enabled:            true,
+					TickerSeconds:      30,
+					CandlesSeconds:     300,
+					OrderBookSeconds:   5,
+					ForexSeconds:       900,
+					DerivativesSeconds: 60,
+					Persist:            true,
+				},
+				Stream: MarketStreamConfig{
+					Enabled:           false,
+					URL:               "wss://stream.binance.com:9443",
+					Symbols:           []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "BNBUSDT", "XRPUSDT"},
+					Intervals:         []string{"1m", "15m"},
+					WindowMinutes:     60,
+					MaxBackoffSeconds: 300,
+				},
+				History: MarketHistoryConfig{
+					Enabled:         true,
+					MaxFetchCandles: 50000,
+					MaxCandles:      1000,
+				},
+			},
+			RateLimit: RateLimitConfig{
+				Enabled:                  true,
+				DefaultRequestsPerMinute: 120,
+				MaxWaitSeconds:           15,
+				Hosts: []RateLimitHostConfig{
+					{Host: "*.binance.com", RequestsPerMinute: 600, Burst: 20, WeightPerMinute: 6000},
+					{Host: "*.binance.vision", RequestsPerMinute: 600, Burst: 20, WeightPerMinute: 6000},
+					{Host: "api.binance.us", RequestsPerMinute: 300, Burst: 10, WeightPerMinute: 1200},
+					{Host: "www.okx.com", RequestsPerMinute: 600, Burst: 20},
+					{Host: "*.finance.yahoo.com", RequestsPerMinute: 60, Burst: 5},
+					{Host: "open.er-api.com", RequestsPerMinute: 30, Burst: 5},
+					{Host: "api.frankfurter.app", RequestsPerMinute: 60, Burst: 5},
+				},
+			},
+			Alerts: AlertsConfig{
+				Enabled:         false,
+				CheckSeconds:    30,
+				CooldownMinutes: 60,
+				Rules: []AlertRuleConfig{
+					{Symbol: "BTCUSDT", Kind: "percent_move", Percent: 2, WindowMinutes: 5, Note: volatilityAlertNote},
+					{Symbol: "ETHUSDT", Kind: "percent_move", Percent: 3, WindowMinutes: 5, Note: volatilityAlertNote},
+					{Symbol: "SOLUSDT", Kind: "percent_move", Percent: 3, WindowMinutes: 5, Note: volatilityAlertNote},
+					{Symbol: "BTCUSDT", Kind: "zscore", ZScore: 3, WindowMinutes: 60, Note: volatilityAlertNote},
+				},
+			},
+			Calendar: CalendarConfig{
+				Enabled: true,
+				File:    "calendar/events.json",
+				Feeds: []CalendarFeedConfig{
+					{Name: "forexfactory", URL: "https://nfs.faireconomy.media/ff_calendar_thisweek.json", Format: "json"},
+				},
+				RefreshMinutes: 60,
+				Countries:      []string{"USD", "EUR", "GBP", "JPY", "CNY"},
+				Schedule: CalendarScheduleConfig{
+					Enabled:        true,
+					MinImportance:  "high",
+					PreMinutes:     30,
+					PostMinutes:    15,
+					LookaheadHours: 24,
+				},
+			},
 		},
 	}
 }
@@ -233,12 +652,7 @@ func SaveConfig(path string, cfg *Config) error {
 		return err
 	}
 
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	return os.WriteFile(path, data, 0644)
+	return fileio.WriteFile(path, data, 0644)
 }
 
 func (c *Config) WorkspacePath() string {
@@ -0,0 +1,201 @@
+// Package contracts enforces the shape of the files skills exchange through
+// the data/ directory. A contract binds a storage path, or a glob such as
+// "opportunities/scored_*.json", to a JSON Schema. Contracts are declared in
+// workspace/schemas.json:
+//
+//	{
+//	  "scoring/weights.json": "skills/update_scorer_weights/weights.schema.json",
+//	  "alerts/history.jsonl": {"type": "object", "required": ["asset", "reason"]}
+//	}
+//
+// where a value is a schema path relative to the workspace or an inline
+// schema, or by skills under "storage_schemas" in their frontmatter metadata,
+// with schema paths relative to the skill directory.
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// DeclarationFile is the workspace file that declares contracts.
+const DeclarationFile = "schemas.json"
+
+// Contract binds storage paths to a schema.
+type Contract struct {
+	Pattern string // Path or glob relative to data/
+	Schema  string // Where the schema comes from, for messages
+	Source  string // "schemas.json" or "skill <name>"
+	Err     error  // Set when the schema could not be loaded
+
+	schema *jsonschema.Schema
+}
+
+// Registry loads the contracts of a workspace. Declarations are re-read on
+// every lookup, so edits take effect without a restart.
+type Registry struct {
+	workspace string
+	skills    *skills.SkillsLoader
+}
+
+func NewRegistry(workspace string) *Registry {
+	return &Registry{
+		workspace: workspace,
+		skills:    skills.NewSkillsLoader(workspace, "", ""),
+	}
+}
+
+// Contracts returns every declared contract, workspace declarations before
+// skill ones. A contract whose schema can't be loaded has Err set; an
+// unreadable declaration file is returned as the error.
+func (r *Registry) Contracts() ([]Contract, error) {
+	var contracts []Contract
+
+	declared, err := r.workspaceDeclarations()
+	patterns := make([]string, 0, len(declared))
+	for pattern := range declared {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	for _, pattern := range patterns {
+		c := Contract{Pattern: cleanPattern(pattern), Source: DeclarationFile}
+		var loadErr error
+		switch v := declared[pattern].(type) {
+		case string:
+			c.Schema = v
+			c.schema, loadErr = r.loadSchema(filepath.Join(r.workspace, filepath.FromSlash(v)))
+		case map[string]interface{}:
+			c.Schema = "inline schema"
+			c.schema = jsonschema.New(v)
+		default:
+			loadErr = fmt.Errorf("must be a schema path or an inline schema object")
+		}
+		c.Err = loadErr
+		contracts = append(contracts, c)
+	}
+
+	for _, decl := range r.skills.StorageSchemas() {
+		c := Contract{
+			Pattern: cleanPattern(decl.Pattern),
+			Schema:  r.relative(decl.SchemaPath),
+			Source:  "skill " + decl.Skill,
+		}
+		c.schema, c.Err = r.loadSchema(decl.SchemaPath)
+		contracts = append(contracts, c)
+	}
+	return contracts, err
+}
+
+// Lookup returns the contract for a path relative to data/, or nil. An
+// exact pattern wins over a glob; otherwise the first declaration wins. It
+// fails when the declaration file or the matching contract's schema is
+// broken, rather than letting writes through unchecked.
+func (r *Registry) Lookup(relPath string) (*Contract, error) {
+	contracts, err := r.Contracts()
+	if err != nil {
+		return nil, err
+	}
+	match := find(contracts, relPath)
+	if match != nil && match.Err != nil {
+		return nil, fmt.Errorf("contract for %s (from %s): %w", match.Pattern, match.Source, match.Err)
+	}
+	return match, nil
+}
+
+func find(contracts []Contract, relPath string) *Contract {
+	relPath = cleanPattern(relPath)
+	var match *Contract
+	for i := range contracts {
+		c := &contracts[i]
+		if c.Pattern == relPath {
+			return c
+		}
+		if match == nil && c.Matches(relPath) {
+			match = c
+		}
+	}
+	return match
+}
+
+// Matches reports whether relPath is covered by the contract.
+func (c *Contract) Matches(relPath string) bool {
+	ok, err := path.Match(c.Pattern, cleanPattern(relPath))
+	return err == nil && ok
+}
+
+// ValidateDocument checks a whole file's content.
+func (c *Contract) ValidateDocument(data []byte) []jsonschema.ValidationError {
+	return c.schema.ValidateJSON(data)
+}
+
+// ValidateEntry checks one entry appended to a log. A contract whose schema
+// describes an array of entries is applied to its "items"; any other schema
+// describes a single entry.
+func (c *Contract) ValidateEntry(data []byte) []jsonschema.ValidationError {
+	root := c.schema.Map()
+	if root["type"] == "array" {
+		if items, ok := root["items"].(map[string]interface{}); ok {
+			// Keep $defs so local references still resolve
+			entry := make(map[string]interface{}, len(items)+2)
+			for k, v := range items {
+				entry[k] = v
+			}
+			for _, key := range []string{"$defs", "definitions"} {
+				if defs, ok := root[key]; ok {
+					entry[key] = defs
+				}
+			}
+			return jsonschema.New(entry).ValidateJSON(data)
+		}
+	}
+	return c.schema.ValidateJSON(data)
+}
+
+// Describe renders a validation failure for the model or the CLI.
+func (c *Contract) Describe(errs []jsonschema.ValidationError) string {
+	return fmt.Sprintf("does not match its schema (%s, from %s):\n%s",
+		c.Schema, c.Source, jsonschema.FormatErrors(errs, 20))
+}
+
+func (r *Registry) workspaceDeclarations() (map[string]interface{}, error) {
+	data, err := os.ReadFile(filepath.Join(r.workspace, DeclarationFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var declared map[string]interface{}
+	if err := json.Unmarshal(data, &declared); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", DeclarationFile, err)
+	}
+	return declared, nil
+}
+
+func (r *Registry) loadSchema(path string) (*jsonschema.Schema, error) {
+	schema, err := jsonschema.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema %s: %w", r.relative(path), err)
+	}
+	return schema, nil
+}
+
+func (r *Registry) relative(p string) string {
+	if rel, err := filepath.Rel(r.workspace, p); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+func cleanPattern(p string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(p)), "./")
+}
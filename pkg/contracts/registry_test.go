@@ -0,0 +1,125 @@
+package contracts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/timeseries"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWorkspace(t *testing.T) string {
+	workspace := t.TempDir()
+	writeFile(t, filepath.Join(workspace, "skills", "regime", "SKILL.md"), `---
+name: regime
+description: Regime filter
+metadata: {"droidclaw":{"emoji":"📈","storage_schemas":{"regime/current_regime.json":"output.schema.json","regime/*.json":"output.schema.json"}}}
+---
+
+# Regime
+`)
+	writeFile(t, filepath.Join(workspace, "skills", "regime", "output.schema.json"),
+		`{"type": "object", "required": ["timestamp", "assets"], "properties": {"assets": {"type": "object", "minProperties": 1}}}`)
+	writeFile(t, filepath.Join(workspace, DeclarationFile), `{
+  "alerts/history.jsonl": {"type": "object", "required": ["asset", "reason"]},
+  "scoring/log.json": {"type": "array", "items": {"$ref": "#/$defs/change"}, "$defs": {"change": {"type": "object", "required": ["reasoning"]}}}
+}`)
+	return workspace
+}
+
+func TestLookupSkillAndWorkspaceContracts(t *testing.T) {
+	r := NewRegistry(testWorkspace(t))
+
+	c, err := r.Lookup("regime/current_regime.json")
+	if err != nil || c == nil {
+		t.Fatalf("Lookup = %v, %v", c, err)
+	}
+	if c.Source != "skill regime" || c.Schema != "skills/regime/output.schema.json" {
+		t.Errorf("contract = %+v", c)
+	}
+	if errs := c.ValidateDocument([]byte(`{"timestamp": "now", "assets": {}}`)); len(errs) != 1 || errs[0].Path != "$.assets" {
+		t.Errorf("errors = %v, want one at $.assets", errs)
+	}
+
+	if c, _ := r.Lookup("regime/history_2025.json"); c == nil || c.Pattern != "regime/*.json" {
+		t.Errorf("glob contract not found: %+v", c)
+	}
+	if c, _ := r.Lookup("scans/latest.json"); c != nil {
+		t.Errorf("unexpected contract for an undeclared path: %+v", c)
+	}
+
+	c, _ = r.Lookup("alerts/history.jsonl")
+	if errs := c.ValidateEntry([]byte(`{"asset": "BTC"}`)); len(errs) != 1 {
+		t.Errorf("entry errors = %v, want missing reason", errs)
+	}
+
+	// Entries appended to an array log are checked against its items
+	c, _ = r.Lookup("scoring/log.json")
+	if errs := c.ValidateEntry([]byte(`{"reasoning": "macro hit rate 72%"}`)); len(errs) != 0 {
+		t.Errorf("valid entry rejected: %v", errs)
+	}
+	if errs := c.ValidateEntry([]byte(`{}`)); len(errs) != 1 {
+		t.Errorf("invalid entry accepted")
+	}
+}
+
+func TestLookupReportsBrokenSchema(t *testing.T) {
+	workspace := testWorkspace(t)
+	os.Remove(filepath.Join(workspace, "skills", "regime", "output.schema.json"))
+
+	r := NewRegistry(workspace)
+	if _, err := r.Lookup("regime/current_regime.json"); err == nil {
+		t.Error("expected an error for a contract whose schema is missing")
+	}
+	if c, err := r.Lookup("alerts/history.jsonl"); err != nil || c == nil {
+		t.Errorf("unrelated contract affected: %v, %v", c, err)
+	}
+}
+
+func TestValidateData(t *testing.T) {
+	workspace := testWorkspace(t)
+	dataDir := filepath.Join(workspace, "data")
+	writeFile(t, filepath.Join(dataDir, "regime", "current_regime.json"), `{"timestamp": "2025-01-15T10:00:00Z", "assets": {"BTC": {}}}`)
+	writeFile(t, filepath.Join(dataDir, "regime", "old.json"), `{"assets": {}}`)
+	writeFile(t, filepath.Join(dataDir, "scans", "latest.json"), `{"anything": true}`)
+	series := timeseries.Open(filepath.Join(dataDir, "alerts", "history"))
+	series.Append([]byte(`{"asset": "BTC", "reason": "spike"}`))
+	series.Append([]byte(`{"asset": "ETH"}`))
+
+	reports, err := NewRegistry(workspace).ValidateData(dataDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]FileReport)
+	for _, r := range reports {
+		byPath[r.Path] = r
+	}
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3: %+v", len(reports), reports)
+	}
+	if len(byPath["regime/current_regime.json"].Problems) != 0 {
+		t.Errorf("valid file reported: %v", byPath["regime/current_regime.json"].Problems)
+	}
+	if len(byPath["regime/old.json"].Problems) != 2 {
+		t.Errorf("old.json problems = %v, want missing timestamp and empty assets", byPath["regime/old.json"].Problems)
+	}
+	alerts := byPath["alerts/history.jsonl"]
+	if alerts.Entries != 2 || len(alerts.Problems) != 1 || !strings.Contains(alerts.Problems[0], "reason") {
+		t.Errorf("series report = %+v", alerts)
+	}
+
+	if _, err := NewRegistry(workspace).ValidateData(dataDir, "scans/latest.json"); err == nil {
+		t.Error("expected an error for a path without a contract")
+	}
+}
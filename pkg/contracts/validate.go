@@ -0,0 +1,139 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+	"github.com/sipeed/picoclaw/pkg/timeseries"
+)
+
+// maxProblems caps the problems reported per file.
+const maxProblems = 20
+
+// FileReport is the result of checking one stored file against its contract.
+type FileReport struct {
+	Path     string // Relative to data/
+	Contract *Contract
+	Entries  int // Entries checked for logs; 0 for documents
+	Problems []string
+}
+
+// ValidateData checks every file under dataDir that a contract covers, or
+// only the file at relPath when it is set. Contracts whose schema can't be
+// loaded are reported as problems of the files they cover.
+func (r *Registry) ValidateData(dataDir, relPath string) ([]FileReport, error) {
+	contracts, err := r.Contracts()
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []FileReport
+	check := func(rel, abs string, series bool) {
+		c := find(contracts, rel)
+		if c == nil {
+			return
+		}
+		report := FileReport{Path: rel, Contract: c}
+		switch {
+		case c.Err != nil:
+			report.Problems = []string{c.Err.Error()}
+		case series:
+			report.Entries, report.Problems = c.validateSeries(abs)
+		default:
+			report.Entries, report.Problems = c.validateFile(abs)
+		}
+		reports = append(reports, report)
+	}
+
+	if relPath != "" {
+		rel := cleanPattern(relPath)
+		abs := filepath.Join(dataDir, filepath.FromSlash(rel))
+		stem := strings.TrimSuffix(strings.TrimSuffix(abs, timeseries.SegmentExt), ".json")
+		if timeseries.Exists(stem) {
+			check(rel, stem, true)
+		} else if _, err := os.Stat(abs); err != nil {
+			return nil, err
+		} else {
+			check(rel, abs, false)
+		}
+		if len(reports) == 0 {
+			return nil, fmt.Errorf("no schema contract covers %s", rel)
+		}
+		return reports, nil
+	}
+
+	err = filepath.WalkDir(dataDir, func(abs string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && abs != dataDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(dataDir, abs)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if abs != dataDir && timeseries.Exists(abs) {
+				check(rel+timeseries.SegmentExt, abs, true)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		check(rel, abs, false)
+		return nil
+	})
+	return reports, err
+}
+
+func (c *Contract) validateFile(path string) (int, []string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, []string{err.Error()}
+	}
+
+	// A log of entries checked against an entry schema
+	var entries []json.RawMessage
+	if c.schema.Map()["type"] != "array" && json.Unmarshal(data, &entries) == nil {
+		var problems []string
+		for i, entry := range entries {
+			problems = appendProblems(problems, fmt.Sprintf("entry %d: ", i), c.ValidateEntry(entry))
+		}
+		return len(entries), problems
+	}
+
+	return 0, appendProblems(nil, "", c.ValidateDocument(data))
+}
+
+func (c *Contract) validateSeries(dir string) (int, []string) {
+	entries, err := timeseries.Open(dir).Range(time.Time{}, time.Time{})
+	if err != nil {
+		return 0, []string{err.Error()}
+	}
+	var problems []string
+	for _, e := range entries {
+		prefix := fmt.Sprintf("entry at %s: ", e.Time.UTC().Format(time.RFC3339))
+		problems = appendProblems(problems, prefix, c.ValidateEntry(e.Data))
+	}
+	return len(entries), problems
+}
+
+func appendProblems(problems []string, prefix string, errs []jsonschema.ValidationError) []string {
+	for _, e := range errs {
+		if len(problems) == maxProblems {
+			return append(problems, "... (more problems omitted)")
+		}
+		if len(problems) > maxProblems {
+			return problems
+		}
+		problems = append(problems, prefix+e.Error())
+	}
+	return problems
+}
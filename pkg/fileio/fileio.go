@@ -0,0 +1,166 @@
+// Package fileio is the workspace file layer: atomic writes that never leave
+// a truncated file behind, advisory locks that serialize read-modify-write
+// cycles across goroutines, cron jobs and processes, and content versions for
+// optimistic concurrency.
+package fileio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrConflict is returned by WriteFileIf when the file changed since the
+// expected version was read.
+var ErrConflict = errors.New("file was modified since it was read")
+
+// Version identifies a file's content. The empty version means the file does
+// not exist.
+type Version string
+
+// VersionOf returns the version of data.
+func VersionOf(data []byte) Version {
+	sum := sha256.Sum256(data)
+	return Version(hex.EncodeToString(sum[:8]))
+}
+
+// ReadFile reads path and returns its content with its version. A missing
+// file returns the os.ErrNotExist error and an empty version.
+func ReadFile(path string) ([]byte, Version, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, VersionOf(data), nil
+}
+
+// WriteFile atomically replaces path with data: it writes a temp file in the
+// same directory, fsyncs it, renames it over path and fsyncs the directory.
+// Parent directories are created and an existing file keeps its mode.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return WriteStream(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteStream is WriteFile for content too large to hold in memory: write
+// streams it into the temp file, and an error from it leaves path untouched.
+func WriteStream(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	// Replace the target of a symlink rather than the link itself
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// WriteFileIf atomically writes data to path only if the file is still at
+// version expected, returning ErrConflict otherwise. An empty expected
+// version requires that the file does not exist yet.
+func WriteFileIf(path string, data []byte, perm os.FileMode, expected Version) (Version, error) {
+	var written Version
+	err := WithLock(path, func() error {
+		current := Version("")
+		if existing, err := os.ReadFile(path); err == nil {
+			current = VersionOf(existing)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if current != expected {
+			return fmt.Errorf("%w (expected version %q, found %q)", ErrConflict, expected, current)
+		}
+		if err := WriteFile(path, data, perm); err != nil {
+			return err
+		}
+		written = VersionOf(data)
+		return nil
+	})
+	return written, err
+}
+
+// Update runs a locked read-modify-write cycle on path. fn receives the
+// current content (nil if the file does not exist) and returns the new
+// content, which is written atomically.
+func Update(path string, perm os.FileMode, fn func(current []byte) ([]byte, error)) error {
+	return WithLock(path, func() error {
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		next, err := fn(current)
+		if err != nil {
+			return err
+		}
+		return WriteFile(path, next, perm)
+	})
+}
+
+// AppendFile appends data to path under its lock and fsyncs it, creating
+// the file and its parent directories if needed.
+func AppendFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return WithLock(path, func() error {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func syncDir(dir string) {
+	// Persist the rename; not every platform can fsync a directory
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
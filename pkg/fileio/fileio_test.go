@@ -0,0 +1,125 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileReplacesAtomically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	if err := WriteFile(path, []byte(`{"v": 1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte(`{"v": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != `{"v": 2}` {
+		t.Errorf("content = %s", data)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want the existing 0600 kept", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestWriteStreamKeepsFileOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := WriteStream(path, 0644, func(w io.Writer) error {
+		_, err := io.WriteString(w, "complete")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("source vanished")
+	err := WriteStream(path, 0644, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("err = %v, want the write error", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "complete" {
+		t.Errorf("content = %s, want the earlier file kept", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestWriteFileIfDetectsConflicts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weights.json")
+
+	v1, err := WriteFileIf(path, []byte("a"), 0644, "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := WriteFileIf(path, []byte("b"), 0644, ""); !errors.Is(err, ErrConflict) {
+		t.Errorf("create over existing file: err = %v, want ErrConflict", err)
+	}
+
+	_, read, _ := ReadFile(path)
+	if read != v1 {
+		t.Errorf("ReadFile version %q, WriteFileIf returned %q", read, v1)
+	}
+
+	// Another writer gets in first
+	if err := WriteFile(path, []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteFileIf(path, []byte("d"), 0644, read); !errors.Is(err, ErrConflict) {
+		t.Errorf("stale write: err = %v, want ErrConflict", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "c" {
+		t.Errorf("stale write changed the file to %q", data)
+	}
+}
+
+func TestUpdateSerializesWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update(path, 0644, func(current []byte) ([]byte, error) {
+				n, _ := strconv.Atoi(string(current))
+				return []byte(strconv.Itoa(n + 1)), nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "20" {
+		t.Errorf("counter = %s after 20 concurrent updates", data)
+	}
+}
+
+func TestAppendFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "events.log")
+	for _, line := range []string{"one\n", "two\n"} {
+		if err := AppendFile(path, []byte(line), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "one\ntwo\n" {
+		t.Errorf("content = %q", data)
+	}
+}
@@ -0,0 +1,60 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// processLocks serializes lock holders within the process; the OS lock on
+// the lock file covers other processes.
+var processLocks sync.Map
+
+// LockPath returns the hidden lock file used for path.
+func LockPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+}
+
+// Lock takes the exclusive advisory lock for path, waiting for other holders
+// in this or another process, and returns the function that releases it.
+// The lock is a sidecar file, so it survives atomic replacement of path.
+func Lock(path string) (unlock func(), err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	mu, _ := processLocks.LoadOrStore(abs, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+
+	lockPath := LockPath(abs)
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		mu.(*sync.Mutex).Unlock()
+		return nil, err
+	}
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		mu.(*sync.Mutex).Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		mu.(*sync.Mutex).Unlock()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+		mu.(*sync.Mutex).Unlock()
+	}, nil
+}
+
+// WithLock runs fn while holding the lock for path.
+func WithLock(path string, fn func() error) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
@@ -0,0 +1,441 @@
+// Package jsonschema validates decoded JSON values against a practical subset
+// of JSON Schema (draft 2020-12): type, enum, const, properties, required,
+// additionalProperties, items, min/maxItems, uniqueItems, min/maxLength,
+// pattern, format (date-time, date), minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, allOf, anyOf, oneOf, not and local $ref.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError describes one place where a value does not match its schema.
+type ValidationError struct {
+	Path    string // JSONPath-style location, e.g. "$.assets[0].price"
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a parsed JSON Schema document.
+type Schema struct {
+	root map[string]interface{}
+}
+
+// New wraps a schema document. Schemas built in Go (e.g. with []string for
+// "required") are normalized through a JSON round trip first.
+func New(schema map[string]interface{}) *Schema {
+	if data, err := json.Marshal(schema); err == nil {
+		var normalized map[string]interface{}
+		if json.Unmarshal(data, &normalized) == nil {
+			schema = normalized
+		}
+	}
+	return &Schema{root: schema}
+}
+
+// Parse decodes a schema from JSON.
+func Parse(data []byte) (*Schema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// Load reads and parses a schema file.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Map returns the underlying schema document.
+func (s *Schema) Map() map[string]interface{} {
+	return s.root
+}
+
+// Validate checks a value produced by encoding/json and returns every
+// violation found, or nil when the value matches.
+func (s *Schema) Validate(value interface{}) []ValidationError {
+	v := &validator{root: s.root}
+	v.validate(s.root, value, "$")
+	return v.errs
+}
+
+// ValidateJSON decodes data and validates it.
+func (s *Schema) ValidateJSON(data []byte) []ValidationError {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []ValidationError{{Path: "$", Message: "invalid JSON: " + err.Error()}}
+	}
+	return s.Validate(value)
+}
+
+// FormatErrors renders validation errors as a bullet list, capped at limit
+// entries (0 means no cap).
+func FormatErrors(errs []ValidationError, limit int) string {
+	var sb strings.Builder
+	for i, e := range errs {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(&sb, "- ... and %d more\n", len(errs)-limit)
+			break
+		}
+		fmt.Fprintf(&sb, "- %s\n", e.Error())
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+type validator struct {
+	root  map[string]interface{}
+	errs  []ValidationError
+	depth int
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether value satisfies schema without recording errors.
+func (v *validator) matches(schema interface{}, value interface{}, path string) bool {
+	sub := &validator{root: v.root, depth: v.depth}
+	sub.validate(schema, value, path)
+	return len(sub.errs) == 0
+}
+
+func (v *validator) validate(schemaValue interface{}, value interface{}, path string) {
+	switch s := schemaValue.(type) {
+	case bool:
+		if !s {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		v.validateObjectSchema(s, value, path)
+	}
+}
+
+func (v *validator) validateObjectSchema(s map[string]interface{}, value interface{}, path string) {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolveRef(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		v.depth++
+		if v.depth > 64 {
+			v.fail(path, "$ref nesting too deep")
+			return
+		}
+		v.validate(target, value, path)
+		v.depth--
+	}
+
+	if t, ok := s["type"]; ok && !matchesType(t, value) {
+		v.fail(path, "expected %s, got %s", describeType(t), typeName(value))
+		return
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compactJSON(enum))
+		}
+	}
+	if c, ok := s["const"]; ok && !equal(c, value) {
+		v.fail(path, "must equal %s", compactJSON(c))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(s, val, path)
+	case []interface{}:
+		v.validateArray(s, val, path)
+	case string:
+		v.validateString(s, val, path)
+	case float64:
+		v.validateNumber(s, val, path)
+	}
+
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, value, path)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.matches(sub, value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "does not match any of the allowed schemas (anyOf)")
+		}
+	}
+	if one, ok := s["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, sub := range one {
+			if v.matches(sub, value, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "must match exactly one schema in oneOf, matched %d", count)
+		}
+	}
+	if not, ok := s["not"]; ok && v.matches(not, value, path) {
+		v.fail(path, "must not match the schema in \"not\"")
+	}
+}
+
+func (v *validator) validateObject(s map[string]interface{}, obj map[string]interface{}, path string) {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				v.fail(path, "missing required property %q", name)
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		childPath := path + "." + k
+		if propSchema, ok := properties[k]; ok {
+			v.validate(propSchema, obj[k], childPath)
+			continue
+		}
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unexpected property %q", k)
+			}
+		case map[string]interface{}:
+			v.validate(additional, obj[k], childPath)
+		}
+	}
+
+	if n, ok := number(s["minProperties"]); ok && float64(len(obj)) < n {
+		v.fail(path, "must have at least %v properties", n)
+	}
+	if n, ok := number(s["maxProperties"]); ok && float64(len(obj)) > n {
+		v.fail(path, "must have at most %v properties", n)
+	}
+}
+
+func (v *validator) validateArray(s map[string]interface{}, arr []interface{}, path string) {
+	if n, ok := number(s["minItems"]); ok && float64(len(arr)) < n {
+		v.fail(path, "must have at least %v items, got %d", n, len(arr))
+	}
+	if n, ok := number(s["maxItems"]); ok && float64(len(arr)) > n {
+		v.fail(path, "must have at most %v items, got %d", n, len(arr))
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					v.fail(path, "items %d and %d are identical", i, j)
+				}
+			}
+		}
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range arr {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) validateString(s map[string]interface{}, str string, path string) {
+	length := float64(len([]rune(str)))
+	if n, ok := number(s["minLength"]); ok && length < n {
+		v.fail(path, "must be at least %v characters", n)
+	}
+	if n, ok := number(s["maxLength"]); ok && length > n {
+		v.fail(path, "must be at most %v characters", n)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			v.fail(path, "schema pattern %q is invalid: %v", pattern, err)
+		} else if !re.MatchString(str) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+	switch s["format"] {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			v.fail(path, "must be an RFC 3339 date-time, got %q", str)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", str); err != nil {
+			v.fail(path, "must be a YYYY-MM-DD date, got %q", str)
+		}
+	}
+}
+
+func (v *validator) validateNumber(s map[string]interface{}, n float64, path string) {
+	if min, ok := number(s["minimum"]); ok && n < min {
+		v.fail(path, "must be >= %v, got %v", min, n)
+	}
+	if max, ok := number(s["maximum"]); ok && n > max {
+		v.fail(path, "must be <= %v, got %v", max, n)
+	}
+	if min, ok := number(s["exclusiveMinimum"]); ok && n <= min {
+		v.fail(path, "must be > %v, got %v", min, n)
+	}
+	if max, ok := number(s["exclusiveMaximum"]); ok && n >= max {
+		v.fail(path, "must be < %v, got %v", max, n)
+	}
+	if m, ok := number(s["multipleOf"]); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "must be a multiple of %v", m)
+		}
+	}
+}
+
+// resolveRef resolves a local JSON pointer such as "#/$defs/asset".
+func (v *validator) resolveRef(ref string) (interface{}, error) {
+	if ref == "#" {
+		return v.root, nil
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references are supported)", ref)
+	}
+
+	var current interface{} = v.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if current, ok = obj[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return current, nil
+}
+
+func matchesType(t interface{}, value interface{}) bool {
+	switch tt := t.(type) {
+	case string:
+		return matchesSingleType(tt, value)
+	case []interface{}:
+		for _, candidate := range tt {
+			if name, ok := candidate.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(t string, value interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+func describeType(t interface{}) string {
+	if list, ok := t.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, n := range list {
+			names = append(names, fmt.Sprint(n))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func equal(a, b interface{}) bool {
+	if na, ok := number(a); ok {
+		nb, ok := number(b)
+		return ok && na == nb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compactJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,78 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	schema, err := Parse([]byte(`{
+		"type": "object",
+		"required": ["timestamp", "assets"],
+		"properties": {
+			"timestamp": {"type": "string", "format": "date-time"},
+			"assets": {"type": "array", "maxItems": 2, "items": {"$ref": "#/$defs/asset"}}
+		},
+		"$defs": {
+			"asset": {
+				"type": "object",
+				"required": ["symbol"],
+				"additionalProperties": false,
+				"properties": {
+					"symbol": {"type": "string", "pattern": "^[A-Z]+$"},
+					"confidence": {"type": "integer", "minimum": 1, "maximum": 10},
+					"direction": {"enum": ["up", "down"]}
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string // substrings expected in the errors, in order
+	}{
+		{"valid", `{"timestamp": "2026-02-11T10:00:00Z", "assets": [{"symbol": "BTCUSDT", "confidence": 7, "direction": "up"}]}`, nil},
+		{"missing required", `{"assets": []}`, []string{`$: missing required property "timestamp"`}},
+		{"bad format", `{"timestamp": "yesterday", "assets": []}`, []string{"$.timestamp: must be an RFC 3339 date-time"}},
+		{"nested errors", `{"timestamp": "2026-02-11T10:00:00Z", "assets": [{"symbol": "btc", "confidence": 7.5, "direction": "sideways", "extra": 1}]}`, []string{
+			"$.assets[0].confidence: expected integer, got number",
+			"$.assets[0].direction: must be one of",
+			`$.assets[0]: unexpected property "extra"`,
+			"$.assets[0].symbol: must match pattern",
+		}},
+		{"too many items", `{"timestamp": "2026-02-11T10:00:00Z", "assets": [{"symbol": "A"}, {"symbol": "B"}, {"symbol": "C"}]}`, []string{"$.assets: must have at most 2 items"}},
+		{"invalid json", `{"timestamp": `, []string{"$: invalid JSON"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := schema.ValidateJSON([]byte(tt.doc))
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d:\n%s", len(errs), len(tt.want), FormatErrors(errs, 0))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(errs[i].Error(), want) {
+					t.Errorf("error %d = %q, want it to contain %q", i, errs[i].Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewNormalizesGoSchemas(t *testing.T) {
+	schema := New(map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "minLength": 1},
+		},
+	})
+
+	if errs := schema.Validate(map[string]interface{}{}); len(errs) != 1 {
+		t.Errorf("expected the missing required property to be reported, got %v", errs)
+	}
+}
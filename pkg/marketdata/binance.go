@@ -0,0 +1,263 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Binance serves crypto pairs from the Binance spot API, and perpetual
+// futures data from the USD-M futures API. Regions where api.binance.com is
+// blocked can point BaseURL at a mirror such as
+// https://data-api.binance.vision or https://api.binance.us.
+type Binance struct {
+	baseURL    string
+	futuresURL string
+	client     *http.Client
+}
+
+func NewBinance(baseURL string, client *http.Client) *Binance {
+	if baseURL == "" {
+		baseURL = "https://api.binance.com"
+	}
+	return &Binance{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		futuresURL: "https://fapi.binance.com",
+		client:     client,
+	}
+}
+
+// SetFuturesURL overrides the USD-M futures API root.
+func (b *Binance) SetFuturesURL(u string) {
+	b.futuresURL = strings.TrimRight(u, "/")
+}
+
+func (b *Binance) Name() string {
+	return "binance"
+}
+
+func (b *Binance) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	if Classify(symbol) != ClassCrypto {
+		return nil, ErrUnsupported
+	}
+	var data map[string]interface{}
+	if err := b.get(ctx, "/api/v3/ticker/24hr", url.Values{"symbol": {symbol}}, &data); err != nil {
+		return nil, err
+	}
+	ts := nowUTC()
+	if ms := number(data["closeTime"]); ms > 0 {
+		ts = time.UnixMilli(int64(ms)).UTC()
+	}
+	return &Ticker{
+		Symbol:      symbol,
+		Price:       number(data["lastPrice"]),
+		Change:      number(data["priceChange"]),
+		ChangePct:   number(data["priceChangePercent"]),
+		Open:        number(data["openPrice"]),
+		High:        number(data["highPrice"]),
+		Low:         number(data["lowPrice"]),
+		Volume:      number(data["volume"]),
+		QuoteVolume: number(data["quoteVolume"]),
+		Time:        ts,
+	}, nil
+}
+
+func (b *Binance) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	if Classify(symbol) != ClassCrypto {
+		return nil, ErrUnsupported
+	}
+	params := url.Values{
+		"symbol":   {symbol},
+		"interval": {interval},
+		"limit":    {fmt.Sprint(limit)},
+	}
+	var raw [][]interface{}
+	if err := b.get(ctx, "/api/v3/klines", params, &raw); err != nil {
+		return nil, err
+	}
+	return &CandleSeries{Symbol: symbol, Interval: interval, Candles: klines(raw)}, nil
+}
+
+// binancePage is the most klines Binance returns per request.
+const binancePage = 1000
+
+func (b *Binance) CandlesBefore(ctx context.Context, symbol, interval string, start, end time.Time) (*CandleSeries, error) {
+	if Classify(symbol) != ClassCrypto {
+		return nil, ErrUnsupported
+	}
+	// With only endTime Binance returns the klines just before it; a range
+	// that fits one page is asked for exactly
+	params := url.Values{
+		"symbol":   {symbol},
+		"interval": {interval},
+		"endTime":  {fmt.Sprint(end.UnixMilli() - 1)},
+		"limit":    {fmt.Sprint(binancePage)},
+	}
+	if step, err := intervalDuration(interval); err == nil && end.Sub(start) <= step*binancePage {
+		params.Set("startTime", fmt.Sprint(start.UnixMilli()))
+	}
+	var raw [][]interface{}
+	if err := b.get(ctx, "/api/v3/klines", params, &raw); err != nil {
+		return nil, err
+	}
+	return &CandleSeries{Symbol: symbol, Interval: interval, Candles: since(klines(raw), start)}, nil
+}
+
+func (b *Binance) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if Classify(symbol) != ClassCrypto {
+		return nil, ErrUnsupported
+	}
+	var data struct {
+		Bids [][]interface{} `json:"bids"`
+		Asks [][]interface{} `json:"asks"`
+	}
+	params := url.Values{"symbol": {symbol}, "limit": {fmt.Sprint(limit)}}
+	if err := b.get(ctx, "/api/v3/depth", params, &data); err != nil {
+		return nil, err
+	}
+	return &OrderBook{Symbol: symbol, Bids: levels(data.Bids), Asks: levels(data.Asks), Time: nowUTC()}, nil
+}
+
+func (b *Binance) FX(ctx context.Context, base string) (*FXRates, error) {
+	return nil, ErrUnsupported
+}
+
+func (b *Binance) FundingRates(ctx context.Context, symbol string, limit int) (*FundingSeries, error) {
+	perp, err := binancePerp(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		FundingTime int64  `json:"fundingTime"`
+		FundingRate string `json:"fundingRate"`
+		MarkPrice   string `json:"markPrice"`
+	}
+	params := url.Values{"symbol": {perp}, "limit": {fmt.Sprint(min(limit, 1000))}}
+	if err := b.getFutures(ctx, "/fapi/v1/fundingRate", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &FundingSeries{Symbol: symbol, Instrument: perp, Rates: make([]FundingRate, 0, len(raw))}
+	for _, r := range raw {
+		series.Rates = append(series.Rates, FundingRate{
+			Time:      time.UnixMilli(r.FundingTime).UTC(),
+			Rate:      number(r.FundingRate),
+			MarkPrice: number(r.MarkPrice),
+		})
+	}
+	return series, nil
+}
+
+func (b *Binance) OpenInterest(ctx context.Context, symbol, interval string, limit int) (*OpenInterestSeries, error) {
+	perp, err := binancePerp(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(derivativesIntervals, interval) {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	var raw []struct {
+		SumOpenInterest      string `json:"sumOpenInterest"`
+		SumOpenInterestValue string `json:"sumOpenInterestValue"`
+		Timestamp            int64  `json:"timestamp"`
+	}
+	params := url.Values{"symbol": {perp}, "period": {interval}, "limit": {fmt.Sprint(min(limit, 500))}}
+	if err := b.getFutures(ctx, "/futures/data/openInterestHist", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &OpenInterestSeries{Symbol: symbol, Instrument: perp, Interval: interval, Points: make([]OpenInterestPoint, 0, len(raw))}
+	for _, r := range raw {
+		series.Points = append(series.Points, OpenInterestPoint{
+			Time:      time.UnixMilli(r.Timestamp).UTC(),
+			Contracts: number(r.SumOpenInterest),
+			ValueUSD:  number(r.SumOpenInterestValue),
+		})
+	}
+	return series, nil
+}
+
+func (b *Binance) LongShortRatio(ctx context.Context, symbol, interval string, limit int) (*LongShortSeries, error) {
+	perp, err := binancePerp(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(derivativesIntervals, interval) {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	var raw []struct {
+		LongShortRatio string `json:"longShortRatio"`
+		LongAccount    string `json:"longAccount"`
+		ShortAccount   string `json:"shortAccount"`
+		Timestamp      int64  `json:"timestamp"`
+	}
+	params := url.Values{"symbol": {perp}, "period": {interval}, "limit": {fmt.Sprint(min(limit, 500))}}
+	if err := b.getFutures(ctx, "/futures/data/globalLongShortAccountRatio", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &LongShortSeries{Symbol: symbol, Instrument: perp, Interval: interval, Points: make([]LongShortPoint, 0, len(raw))}
+	for _, r := range raw {
+		series.Points = append(series.Points, LongShortPoint{
+			Time:     time.UnixMilli(r.Timestamp).UTC(),
+			Ratio:    number(r.LongShortRatio),
+			LongPct:  number(r.LongAccount) * 100,
+			ShortPct: number(r.ShortAccount) * 100,
+		})
+	}
+	return series, nil
+}
+
+func (b *Binance) Basis(ctx context.Context, symbol string) (*Basis, error) {
+	perp, err := binancePerp(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := b.getFutures(ctx, "/fapi/v1/premiumIndex", url.Values{"symbol": {perp}}, &data); err != nil {
+		return nil, err
+	}
+	ts := nowUTC()
+	if ms := number(data["time"]); ms > 0 {
+		ts = time.UnixMilli(int64(ms)).UTC()
+	}
+	return &Basis{
+		Symbol:      symbol,
+		Instrument:  perp,
+		IndexPrice:  number(data["indexPrice"]),
+		MarkPrice:   number(data["markPrice"]),
+		FundingRate: number(data["lastFundingRate"]),
+		NextFunding: time.UnixMilli(int64(number(data["nextFundingTime"]))).UTC(),
+		Time:        ts,
+	}, nil
+}
+
+// binancePerp returns the USD-M perpetual tracking a pair, e.g. BTCUSDT.
+func binancePerp(symbol string) (string, error) {
+	base, quote, err := perpPair(symbol)
+	if err != nil {
+		return "", err
+	}
+	return base + quote, nil
+}
+
+// getFutures calls the USD-M futures API.
+func (b *Binance) getFutures(ctx context.Context, path string, params url.Values, v interface{}) error {
+	return b.fetch(ctx, b.futuresURL+path, params, v)
+}
+
+func (b *Binance) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	return b.fetch(ctx, b.baseURL+path, params, v)
+}
+
+func (b *Binance) fetch(ctx context.Context, endpoint string, params url.Values, v interface{}) error {
+	err := getJSON(ctx, b.client, endpoint+"?"+params.Encode(), v)
+	// -1121 is "Invalid symbol"
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusBadRequest && strings.Contains(httpErr.Body, "-1121") {
+		return fmt.Errorf("%w: %s", ErrNotFound, params.Get("symbol"))
+	}
+	return err
+}
@@ -0,0 +1,256 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// CacheInfo tells the model how fresh a result is.
+type CacheInfo struct {
+	Hit        bool      `json:"hit"`
+	Coalesced  bool      `json:"coalesced,omitempty"` // Shared with a concurrent identical request
+	FetchedAt  time.Time `json:"fetched_at"`
+	AgeSeconds int       `json:"age_seconds"`
+}
+
+// TTLs are how long each kind of result is served from the cache. Zero
+// disables caching for that kind.
+type TTLs struct {
+	Ticker    time.Duration
+	Candles   time.Duration // Capped at the candle interval
+	OrderBook time.Duration
+	FX        time.Duration
+	// Derivatives covers funding, open interest, long/short ratios and
+	// basis; series are capped at their interval like candles
+	Derivatives time.Duration
+}
+
+// saveDelay batches the disk writes of bursts of fetches into one.
+const saveDelay = 5 * time.Second
+
+// sweepEvery is how often stores drop expired entries, so keys that are
+// never requested again don't stay in memory.
+const sweepEvery = time.Minute
+
+type cacheEntry struct {
+	Value     json.RawMessage `json:"value"`
+	Limit     int             `json:"limit,omitempty"` // Candles requested, for serving smaller requests
+	FetchedAt time.Time       `json:"fetched_at"`
+	Expires   time.Time       `json:"expires"`
+}
+
+// Cache keeps recent results in memory, optionally persisted to a file so
+// they survive restarts, and coalesces concurrent identical requests into
+// one fetch. Values are stored as JSON, so every caller gets its own copy.
+type Cache struct {
+	ttls TTLs
+	path string
+	now  func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	swept     time.Time
+	saveTimer *time.Timer
+	group     singleflight.Group
+}
+
+// NewCache creates a cache; with a non-empty path entries are loaded from
+// and saved to that file.
+func NewCache(ttls TTLs, path string) *Cache {
+	c := &Cache{
+		ttls:    ttls,
+		path:    path,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+	c.load()
+	return c
+}
+
+// NewCacheFromConfig returns nil when caching is disabled.
+func NewCacheFromConfig(cfg config.MarketDataCacheConfig, workspace string) *Cache {
+	if !cfg.Enabled {
+		return nil
+	}
+	ttls := TTLs{
+		Ticker:    time.Duration(cfg.TickerSeconds) * time.Second,
+		Candles:   time.Duration(cfg.CandlesSeconds) * time.Second,
+		OrderBook: time.Duration(cfg.OrderBookSeconds) * time.Second,
+		FX:        time.Duration(cfg.ForexSeconds) * time.Second,
+
+		Derivatives: time.Duration(cfg.DerivativesSeconds) * time.Second,
+	}
+	path := ""
+	if cfg.Persist {
+		path = filepath.Join(workspace, "cache", "market_data.json")
+	}
+	return NewCache(ttls, path)
+}
+
+// cached returns the value stored under key if it's fresh, and otherwise
+// calls fetch, sharing its result with concurrent callers of the same key.
+// limit lets candle requests be served from an entry holding at least as
+// many candles; trim cuts such an entry down to the request.
+func cached[T any](ctx context.Context, c *Cache, key string, limit int, ttl time.Duration,
+	fetch func() (*T, error), trim func(*T)) (*T, *CacheInfo, error) {
+	if c == nil || ttl <= 0 {
+		v, err := fetch()
+		return v, nil, err
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.Expires) && entry.Limit >= limit {
+		var v T
+		if err := json.Unmarshal(entry.Value, &v); err == nil {
+			if trim != nil {
+				trim(&v)
+			}
+			return &v, &CacheInfo{Hit: true, FetchedAt: entry.FetchedAt, AgeSeconds: int(now.Sub(entry.FetchedAt).Seconds())}, nil
+		}
+	}
+
+	flightKey := key
+	if limit > 0 {
+		flightKey = key + "#" + strconv.Itoa(limit)
+	}
+	res, err, shared := c.group.Do(flightKey, func() (interface{}, error) {
+		v, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		fetched := c.now()
+		c.store(key, cacheEntry{Value: data, Limit: limit, FetchedAt: fetched, Expires: fetched.Add(ttl)})
+		return data, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	var v T
+	if err := json.Unmarshal(res.([]byte), &v); err != nil {
+		return nil, nil, err
+	}
+	return &v, &CacheInfo{Hit: false, Coalesced: shared, FetchedAt: c.now()}, nil
+}
+
+// ttl returns how long a kind of result is kept; candles are kept no
+// longer than their interval.
+func (c *Cache) ttl(kind, interval string) time.Duration {
+	if c == nil {
+		return 0
+	}
+	switch kind {
+	case "ticker":
+		return c.ttls.Ticker
+	case "candles":
+		if step, err := intervalDuration(interval); err == nil && step < c.ttls.Candles {
+			return step
+		}
+		return c.ttls.Candles
+	case "orderbook":
+		return c.ttls.OrderBook
+	case "fx":
+		return c.ttls.FX
+	case "derivatives":
+		if step, err := intervalDuration(interval); err == nil && step < c.ttls.Derivatives {
+			return step
+		}
+		return c.ttls.Derivatives
+	}
+	return 0
+}
+
+func (c *Cache) store(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := c.now(); now.Sub(c.swept) >= sweepEvery {
+		c.evictExpired(now)
+	}
+	c.entries[key] = entry
+	if c.path != "" && c.saveTimer == nil {
+		c.saveTimer = time.AfterFunc(saveDelay, c.save)
+	}
+}
+
+// Flush writes pending entries to disk now.
+func (c *Cache) Flush() {
+	if c == nil || c.path == "" {
+		return
+	}
+	c.mu.Lock()
+	if c.saveTimer != nil {
+		c.saveTimer.Stop()
+	}
+	c.mu.Unlock()
+	c.save()
+}
+
+func (c *Cache) save() {
+	c.mu.Lock()
+	c.saveTimer = nil
+	c.evictExpired(c.now())
+	data, err := json.Marshal(c.entries)
+	c.mu.Unlock()
+
+	if err == nil {
+		err = fileio.WriteFile(c.path, data, 0644)
+	}
+	if err != nil {
+		logger.WarnCF("market", "Failed to save market data cache",
+			map[string]interface{}{
+				"path":  c.path,
+				"error": err.Error(),
+			})
+	}
+}
+
+// evictExpired drops the entries that expired by now. c.mu must be held.
+func (c *Cache) evictExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.Expires) {
+			delete(c.entries, key)
+		}
+	}
+	c.swept = now
+}
+
+func (c *Cache) load() {
+	if c.path == "" {
+		return
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	var entries map[string]cacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+	now := c.now()
+	for key, entry := range entries {
+		if now.Before(entry.Expires) {
+			c.entries[key] = entry
+		}
+	}
+}
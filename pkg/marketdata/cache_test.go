@@ -0,0 +1,159 @@
+package marketdata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBinance serves tickers and klines slowly and counts the requests.
+func countingBinance(t *testing.T, delay time.Duration) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(delay)
+		switch r.URL.Path {
+		case "/api/v3/ticker/24hr":
+			w.Write([]byte(`{"lastPrice":"100","priceChangePercent":"1.5"}`))
+		case "/api/v3/klines":
+			w.Write([]byte(`[[1000,"1","1","1","1","1"],[2000,"2","2","2","2","2"],[3000,"3","3","3","3","3"]]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestCacheHitsAndExpiry(t *testing.T) {
+	server, requests := countingBinance(t, 0)
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassCrypto: {NewBinance(server.URL, http.DefaultClient)},
+	})
+	cache := NewCache(TTLs{Ticker: 30 * time.Second, Candles: time.Hour}, "")
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	router.SetCache(cache)
+	ctx := context.Background()
+
+	first, err := router.Ticker(ctx, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cache == nil || first.Cache.Hit {
+		t.Fatalf("first call should be a miss: %+v", first.Cache)
+	}
+
+	now = now.Add(10 * time.Second)
+	second, err := router.Ticker(ctx, "btcusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Cache.Hit || second.Cache.AgeSeconds != 10 || second.Source != "binance" || second.Price != 100 {
+		t.Fatalf("second call should be a hit: %+v %+v", second, second.Cache)
+	}
+	if *requests != 1 {
+		t.Fatalf("expected 1 request, got %d", *requests)
+	}
+
+	now = now.Add(30 * time.Second)
+	if third, _ := router.Ticker(ctx, "BTCUSDT"); third.Cache.Hit || *requests != 2 {
+		t.Fatalf("expired entry should be refetched: %+v, %d requests", third.Cache, *requests)
+	}
+
+	// Candles fetched for a larger limit serve smaller requests; the TTL is
+	// capped at the 1m interval
+	if _, err := router.Candles(ctx, "BTCUSDT", "1m", 3); err != nil {
+		t.Fatal(err)
+	}
+	series, err := router.Candles(ctx, "BTCUSDT", "1m", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !series.Cache.Hit || len(series.Candles) != 2 || series.Candles[1].Close != 3 {
+		t.Fatalf("expected the last 2 cached candles: %+v", series)
+	}
+	if _, err := router.Candles(ctx, "BTCUSDT", "1m", 5); err != nil || *requests != 4 {
+		t.Fatalf("a larger limit should be refetched, %d requests", *requests)
+	}
+	now = now.Add(time.Minute)
+	if series, _ := router.Candles(ctx, "BTCUSDT", "1m", 2); series.Cache.Hit {
+		t.Fatal("1m candles should expire after a minute")
+	}
+
+	// Expired entries are evicted from memory even without persistence
+	now = now.Add(time.Hour)
+	if _, err := router.Ticker(ctx, "ETHUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if len(cache.entries) != 1 {
+		t.Fatalf("expected only the fresh ETH ticker in memory, got %d entries", len(cache.entries))
+	}
+}
+
+func TestCacheCoalescesConcurrentRequests(t *testing.T) {
+	server, requests := countingBinance(t, 100*time.Millisecond)
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassCrypto: {NewBinance(server.URL, http.DefaultClient)},
+	})
+	router.SetCache(NewCache(TTLs{Ticker: time.Minute}, ""))
+
+	var wg sync.WaitGroup
+	var coalesced int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker, err := router.Ticker(context.Background(), "ETHUSDT")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ticker.Cache.Coalesced || ticker.Cache.Hit {
+				atomic.AddInt32(&coalesced, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if *requests != 1 {
+		t.Fatalf("expected concurrent requests to share one fetch, got %d", *requests)
+	}
+	if coalesced != 5 && coalesced != 4 {
+		t.Fatalf("expected the other callers to share the result, got %d", coalesced)
+	}
+}
+
+func TestCachePersists(t *testing.T) {
+	server, requests := countingBinance(t, 0)
+	path := filepath.Join(t.TempDir(), "cache", "market_data.json")
+	newRouter := func() *Router {
+		router := NewRouter(map[AssetClass][]MarketDataSource{
+			ClassCrypto: {NewBinance(server.URL, http.DefaultClient)},
+		})
+		router.SetCache(NewCache(TTLs{Ticker: time.Hour}, path))
+		return router
+	}
+
+	router := newRouter()
+	if _, err := router.Ticker(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	router.cache.Flush()
+
+	// A new process picks up the saved entry
+	ticker, err := newRouter().Ticker(context.Background(), "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ticker.Cache.Hit || *requests != 1 {
+		t.Fatalf("expected a hit from disk: %+v, %d requests", ticker.Cache, *requests)
+	}
+}
@@ -0,0 +1,245 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DerivativesSource is a source that also serves perpetual futures data.
+// Symbols are spot pairs such as BTCUSDT; the source picks the perpetual
+// that tracks them.
+type DerivativesSource interface {
+	MarketDataSource
+	// FundingRates returns the last limit funding settlements, oldest first.
+	FundingRates(ctx context.Context, symbol string, limit int) (*FundingSeries, error)
+	// OpenInterest returns open interest sampled every interval, oldest first.
+	OpenInterest(ctx context.Context, symbol, interval string, limit int) (*OpenInterestSeries, error)
+	// LongShortRatio returns the ratio of accounts long to short, oldest first.
+	LongShortRatio(ctx context.Context, symbol, interval string, limit int) (*LongShortSeries, error)
+	// Basis returns the perpetual's premium over the spot index and its
+	// current funding rate.
+	Basis(ctx context.Context, symbol string) (*Basis, error)
+}
+
+// FundingRate is one funding settlement. Rate is the fraction paid by longs
+// to shorts (negative: shorts pay) per funding interval.
+type FundingRate struct {
+	Time      time.Time `json:"time"`
+	Rate      float64   `json:"rate"`
+	MarkPrice float64   `json:"mark_price,omitempty"`
+}
+
+// FundingSeries is a run of funding settlements, oldest first.
+type FundingSeries struct {
+	Symbol     string        `json:"symbol"`
+	Instrument string        `json:"instrument"` // The source's perpetual
+	Rates      []FundingRate `json:"rates"`
+	Source     string        `json:"source"`
+	Cache      *CacheInfo    `json:"cache,omitempty"`
+}
+
+// OpenInterestPoint is the open interest at one time. Sources report it in
+// contracts, in USD or both; the missing one is zero.
+type OpenInterestPoint struct {
+	Time      time.Time `json:"time"`
+	Contracts float64   `json:"contracts,omitempty"`
+	ValueUSD  float64   `json:"value_usd,omitempty"`
+}
+
+// OpenInterestSeries is open interest sampled every Interval, oldest first.
+type OpenInterestSeries struct {
+	Symbol     string              `json:"symbol"`
+	Instrument string              `json:"instrument"`
+	Interval   string              `json:"interval"`
+	Points     []OpenInterestPoint `json:"points"`
+	Source     string              `json:"source"`
+	Cache      *CacheInfo          `json:"cache,omitempty"`
+}
+
+// LongShortPoint is the ratio of accounts net long to those net short.
+// LongPct and ShortPct are zero where the source only reports the ratio.
+type LongShortPoint struct {
+	Time     time.Time `json:"time"`
+	Ratio    float64   `json:"ratio"`
+	LongPct  float64   `json:"long_pct,omitempty"`
+	ShortPct float64   `json:"short_pct,omitempty"`
+}
+
+// LongShortSeries is the long/short ratio sampled every Interval, oldest
+// first.
+type LongShortSeries struct {
+	Symbol     string           `json:"symbol"`
+	Instrument string           `json:"instrument"`
+	Interval   string           `json:"interval"`
+	Points     []LongShortPoint `json:"points"`
+	Source     string           `json:"source"`
+	Cache      *CacheInfo       `json:"cache,omitempty"`
+}
+
+// Basis compares a perpetual's mark price with the spot index it tracks.
+type Basis struct {
+	Symbol               string     `json:"symbol"`
+	Instrument           string     `json:"instrument"`
+	IndexPrice           float64    `json:"index_price"` // Spot index
+	MarkPrice            float64    `json:"mark_price"`  // Perpetual
+	Basis                float64    `json:"basis"`       // Mark minus index
+	BasisPct             float64    `json:"basis_pct"`
+	FundingRate          float64    `json:"funding_rate"` // Current period, per funding interval
+	FundingIntervalHours float64    `json:"funding_interval_hours"`
+	FundingAnnualPct     float64    `json:"funding_annualized_pct"`
+	NextFunding          time.Time  `json:"next_funding_time"`
+	Time                 time.Time  `json:"timestamp"`
+	Source               string     `json:"source"`
+	Cache                *CacheInfo `json:"cache,omitempty"`
+}
+
+// derivativesIntervals are the sampling intervals open interest and
+// long/short ratios can be asked for.
+var derivativesIntervals = []string{"5m", "15m", "30m", "1h", "2h", "4h", "6h", "12h", "1d"}
+
+// defaultFundingHours is the funding interval assumed where a source
+// doesn't report it.
+const defaultFundingHours = 8
+
+// fillBasis derives the basis and annualized funding from the prices and
+// rate a source filled in.
+func fillBasis(b *Basis) {
+	b.Basis = b.MarkPrice - b.IndexPrice
+	b.BasisPct = changePct(b.MarkPrice, b.IndexPrice)
+	if b.FundingIntervalHours <= 0 {
+		b.FundingIntervalHours = defaultFundingHours
+	}
+	b.FundingAnnualPct = b.FundingRate * (365 * 24 / b.FundingIntervalHours) * 100
+}
+
+// perpPair returns the base and margin asset of the USD-margined perpetual
+// tracking a crypto pair; pairs quoted in other USD stablecoins map to the
+// USDT perpetual.
+func perpPair(symbol string) (string, string, error) {
+	if Classify(symbol) != ClassCrypto {
+		return "", "", fmt.Errorf("%s is not a crypto pair: %w", symbol, ErrUnsupported)
+	}
+	base, quote, _ := cryptoPair(symbol)
+	switch quote {
+	case "USDT", "USDC":
+	case "USD", "BUSD", "FDUSD", "TUSD":
+		quote = "USDT"
+	default:
+		return "", "", fmt.Errorf("no USD-margined perpetual for %s: %w", symbol, ErrUnsupported)
+	}
+	return base, quote, nil
+}
+
+func (r *Router) FundingRates(ctx context.Context, symbol string, limit int) (*FundingSeries, error) {
+	symbol = Canonical(symbol)
+	result, info, err := cached(ctx, r.cache, "funding:"+symbol, limit, r.cache.ttl("derivatives", ""), func() (*FundingSeries, error) {
+		var result *FundingSeries
+		err := r.tryDerivatives(ctx, "funding", symbol, func(src DerivativesSource) error {
+			s, err := src.FundingRates(ctx, symbol, limit)
+			if err == nil {
+				s.Source = src.Name()
+				result = s
+			}
+			return err
+		})
+		return result, err
+	}, func(s *FundingSeries) {
+		if len(s.Rates) > limit {
+			s.Rates = s.Rates[len(s.Rates)-limit:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+func (r *Router) OpenInterest(ctx context.Context, symbol, interval string, limit int) (*OpenInterestSeries, error) {
+	symbol = Canonical(symbol)
+	key := "open_interest:" + symbol + ":" + interval
+	result, info, err := cached(ctx, r.cache, key, limit, r.cache.ttl("derivatives", interval), func() (*OpenInterestSeries, error) {
+		var result *OpenInterestSeries
+		err := r.tryDerivatives(ctx, "open interest", symbol, func(src DerivativesSource) error {
+			s, err := src.OpenInterest(ctx, symbol, interval, limit)
+			if err == nil {
+				s.Source = src.Name()
+				result = s
+			}
+			return err
+		})
+		return result, err
+	}, func(s *OpenInterestSeries) {
+		if len(s.Points) > limit {
+			s.Points = s.Points[len(s.Points)-limit:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+func (r *Router) LongShortRatio(ctx context.Context, symbol, interval string, limit int) (*LongShortSeries, error) {
+	symbol = Canonical(symbol)
+	key := "long_short:" + symbol + ":" + interval
+	result, info, err := cached(ctx, r.cache, key, limit, r.cache.ttl("derivatives", interval), func() (*LongShortSeries, error) {
+		var result *LongShortSeries
+		err := r.tryDerivatives(ctx, "long/short ratio", symbol, func(src DerivativesSource) error {
+			s, err := src.LongShortRatio(ctx, symbol, interval, limit)
+			if err == nil {
+				s.Source = src.Name()
+				result = s
+			}
+			return err
+		})
+		return result, err
+	}, func(s *LongShortSeries) {
+		if len(s.Points) > limit {
+			s.Points = s.Points[len(s.Points)-limit:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+func (r *Router) Basis(ctx context.Context, symbol string) (*Basis, error) {
+	symbol = Canonical(symbol)
+	result, info, err := cached(ctx, r.cache, "basis:"+symbol, 0, r.cache.ttl("derivatives", ""), func() (*Basis, error) {
+		var result *Basis
+		err := r.tryDerivatives(ctx, "basis", symbol, func(src DerivativesSource) error {
+			b, err := src.Basis(ctx, symbol)
+			if err == nil {
+				fillBasis(b)
+				b.Source = src.Name()
+				result = b
+			}
+			return err
+		})
+		return result, err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+// tryDerivatives runs fn with the derivatives sources in order.
+func (r *Router) tryDerivatives(ctx context.Context, op, symbol string, fn func(DerivativesSource) error) error {
+	if _, _, err := perpPair(symbol); err != nil {
+		return err
+	}
+	return r.try(ctx, ClassDerivatives, op, symbol, func(src MarketDataSource) error {
+		ds, ok := src.(DerivativesSource)
+		if !ok {
+			return ErrUnsupported
+		}
+		return fn(ds)
+	})
+}
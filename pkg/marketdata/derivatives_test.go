@@ -0,0 +1,160 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestBinanceDerivatives(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/fapi/v1/fundingRate":                      `[{"symbol":"BTCUSDT","fundingTime":1736928000000,"fundingRate":"0.00010000","markPrice":"97000"},{"symbol":"BTCUSDT","fundingTime":1736956800000,"fundingRate":"-0.00005000","markPrice":"96500"}]`,
+		"/futures/data/openInterestHist":            `[{"symbol":"BTCUSDT","sumOpenInterest":"80000","sumOpenInterestValue":"7760000000","timestamp":1736953200000}]`,
+		"/futures/data/globalLongShortAccountRatio": `[{"symbol":"BTCUSDT","longShortRatio":"1.5","longAccount":"0.6","shortAccount":"0.4","timestamp":1736953200000}]`,
+		"/fapi/v1/premiumIndex":                     `{"symbol":"BTCUSDT","markPrice":"97097","indexPrice":"97000","lastFundingRate":"0.0001","nextFundingTime":1736985600000,"time":1736960000000}`,
+	})
+	b := NewBinance("https://spot.invalid", http.DefaultClient)
+	b.SetFuturesURL(server.URL)
+	router := NewRouter(map[AssetClass][]MarketDataSource{ClassDerivatives: {b}})
+	ctx := context.Background()
+
+	// BUSD pairs map to the USDT perpetual
+	funding, err := router.FundingRates(ctx, "btc/busd", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if funding.Instrument != "BTCUSDT" || funding.Source != "binance" || len(funding.Rates) != 2 ||
+		funding.Rates[1].Rate != -0.00005 || funding.Rates[0].MarkPrice != 97000 {
+		t.Fatalf("unexpected funding: %+v", funding)
+	}
+
+	oi, err := router.OpenInterest(ctx, "BTCUSDT", "1h", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(oi.Points) != 1 || oi.Points[0].Contracts != 80000 || oi.Points[0].ValueUSD != 7.76e9 {
+		t.Fatalf("unexpected open interest: %+v", oi)
+	}
+	if _, err := router.OpenInterest(ctx, "BTCUSDT", "3m", 10); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported for a 3m period, got %v", err)
+	}
+
+	ratio, err := router.LongShortRatio(ctx, "BTCUSDT", "1h", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := ratio.Points[0]; p.Ratio != 1.5 || p.LongPct != 60 || p.ShortPct != 40 {
+		t.Fatalf("unexpected long/short ratio: %+v", p)
+	}
+
+	basis, err := router.Basis(ctx, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basis.Basis != 97 || math.Abs(basis.BasisPct-0.1) > 1e-9 || basis.FundingIntervalHours != 8 ||
+		math.Abs(basis.FundingAnnualPct-10.95) > 1e-9 || basis.NextFunding.UnixMilli() != 1736985600000 {
+		t.Fatalf("unexpected basis: %+v", basis)
+	}
+
+	want := []string{
+		"/fapi/v1/fundingRate?limit=2&symbol=BTCUSDT",
+		"/futures/data/openInterestHist?limit=10&period=1h&symbol=BTCUSDT",
+		"/futures/data/globalLongShortAccountRatio?limit=10&period=1h&symbol=BTCUSDT",
+		"/fapi/v1/premiumIndex?symbol=BTCUSDT",
+	}
+	if len(*queries) != len(want) {
+		t.Fatalf("unexpected queries: %v", *queries)
+	}
+	for i, q := range want {
+		if (*queries)[i] != q {
+			t.Errorf("query %d = %s, want %s", i, (*queries)[i], q)
+		}
+	}
+
+	if _, err := router.FundingRates(ctx, "EURUSD", 10); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("forex pairs have no perpetuals, got %v", err)
+	}
+}
+
+func TestOKXDerivatives(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/api/v5/public/funding-rate-history":                   `{"code":"0","msg":"","data":[{"instId":"ETH-USDT-SWAP","fundingRate":"0.0002","realizedRate":"0.00019","fundingTime":"1736956800000"},{"instId":"ETH-USDT-SWAP","fundingRate":"0.0001","realizedRate":"0.0001","fundingTime":"1736928000000"}]}`,
+		"/api/v5/rubik/stat/contracts/open-interest-volume":     `{"code":"0","msg":"","data":[["1736956800000","3000000000","100"],["1736953200000","2000000000","90"],["1736949600000","1000000000","80"]]}`,
+		"/api/v5/rubik/stat/contracts/long-short-account-ratio": `{"code":"0","msg":"","data":[["1736956800000","0.8"]]}`,
+		"/api/v5/public/mark-price":                             `{"code":"0","msg":"","data":[{"markPx":"3290","ts":"1736960000000"}]}`,
+		"/api/v5/market/index-tickers":                          `{"code":"0","msg":"","data":[{"idxPx":"3300"}]}`,
+		"/api/v5/public/funding-rate":                           `{"code":"0","msg":"","data":[{"fundingRate":"-0.0001","fundingTime":"1736971200000","nextFundingTime":"1736985600000"}]}`,
+	})
+	o := NewOKX(server.URL, http.DefaultClient)
+	ctx := context.Background()
+
+	funding, err := o.FundingRates(ctx, "ETHUSDT", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if funding.Instrument != "ETH-USDT-SWAP" || funding.Rates[0].Rate != 0.0001 || funding.Rates[1].Rate != 0.00019 {
+		t.Fatalf("funding should be oldest first with realized rates: %+v", funding)
+	}
+
+	oi, err := o.OpenInterest(ctx, "ETHUSDT", "1h", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(oi.Points) != 2 || oi.Points[0].ValueUSD != 2e9 || oi.Points[1].ValueUSD != 3e9 {
+		t.Fatalf("unexpected open interest: %+v", oi.Points)
+	}
+	if _, err := o.OpenInterest(ctx, "ETHUSDT", "15m", 2); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("OKX statistics have no 15m period, got %v", err)
+	}
+
+	ratio, err := o.LongShortRatio(ctx, "ETHUSDT", "5m", 10)
+	if err != nil || len(ratio.Points) != 1 || ratio.Points[0].Ratio != 0.8 {
+		t.Fatalf("unexpected long/short ratio: %+v (%v)", ratio, err)
+	}
+
+	basis, err := o.Basis(ctx, "ETHUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fillBasis(basis)
+	if basis.Basis != -10 || basis.FundingIntervalHours != 4 || math.Abs(basis.FundingAnnualPct+21.9) > 1e-9 {
+		t.Fatalf("unexpected basis: %+v", basis)
+	}
+
+	want := map[int]string{
+		0: "/api/v5/public/funding-rate-history?instId=ETH-USDT-SWAP&limit=5",
+		1: "/api/v5/rubik/stat/contracts/open-interest-volume?ccy=ETH&period=1H",
+		2: "/api/v5/rubik/stat/contracts/long-short-account-ratio?ccy=ETH&period=5m",
+		4: "/api/v5/market/index-tickers?instId=ETH-USDT",
+	}
+	for i, q := range want {
+		if (*queries)[i] != q {
+			t.Errorf("query %d = %s, want %s", i, (*queries)[i], q)
+		}
+	}
+}
+
+func TestDerivativesFallBack(t *testing.T) {
+	blocked, _ := stub(t, map[string]string{"/fapi/v1/premiumIndex": `451 {"code":0,"msg":"Service unavailable from a restricted location"}`})
+	okx, _ := stub(t, map[string]string{
+		"/api/v5/public/mark-price":    `{"code":"0","msg":"","data":[{"markPx":"101","ts":"1736960000000"}]}`,
+		"/api/v5/market/index-tickers": `{"code":"0","msg":"","data":[{"idxPx":"100"}]}`,
+		"/api/v5/public/funding-rate":  `{"code":"0","msg":"","data":[{"fundingRate":"0.0001","fundingTime":"1736971200000"}]}`,
+	})
+	b := NewBinance("", http.DefaultClient)
+	b.SetFuturesURL(blocked.URL)
+	// Yahoo has no derivatives and is skipped
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassDerivatives: {NewYahoo("https://yahoo.invalid", http.DefaultClient), b, NewOKX(okx.URL, http.DefaultClient)},
+	})
+
+	basis, err := router.Basis(context.Background(), "SOLUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if basis.Source != "okx" || basis.BasisPct != 1 || basis.FundingIntervalHours != 8 {
+		t.Fatalf("expected OKX's basis with the default funding interval: %+v", basis)
+	}
+}
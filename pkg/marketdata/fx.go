@@ -0,0 +1,132 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ERAPI serves daily exchange rates, including gold and silver, from the
+// keyless open.er-api.com endpoint. Tickers carry the price only.
+type ERAPI struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewERAPI(baseURL string, client *http.Client) *ERAPI {
+	if baseURL == "" {
+		baseURL = "https://open.er-api.com"
+	}
+	return &ERAPI{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (e *ERAPI) Name() string {
+	return "erapi"
+}
+
+func (e *ERAPI) FX(ctx context.Context, base string) (*FXRates, error) {
+	var data struct {
+		Result     string             `json:"result"`
+		ErrorType  string             `json:"error-type"`
+		Base       string             `json:"base_code"`
+		UpdateUnix int64              `json:"time_last_update_unix"`
+		Rates      map[string]float64 `json:"rates"`
+	}
+	if err := getJSON(ctx, e.client, e.baseURL+"/v6/latest/"+url.PathEscape(base), &data); err != nil {
+		return nil, err
+	}
+	if data.Result != "success" {
+		if data.ErrorType == "unsupported-code" {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, base)
+		}
+		return nil, fmt.Errorf("erapi error: %s", data.ErrorType)
+	}
+	return &FXRates{Base: data.Base, Rates: data.Rates, Updated: time.Unix(data.UpdateUnix, 0).UTC()}, nil
+}
+
+// Ticker prices a currency or metal pair as the inverse of the base's rate
+// against the quote currency.
+func (e *ERAPI) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	return pairTicker(ctx, e, symbol)
+}
+
+func (e *ERAPI) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	return nil, ErrUnsupported
+}
+
+func (e *ERAPI) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	return nil, ErrUnsupported
+}
+
+// Frankfurter serves the European Central Bank's reference rates through
+// api.frankfurter.app. It has no metals and updates once per working day.
+type Frankfurter struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewFrankfurter(baseURL string, client *http.Client) *Frankfurter {
+	if baseURL == "" {
+		baseURL = "https://api.frankfurter.app"
+	}
+	return &Frankfurter{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (f *Frankfurter) Name() string {
+	return "frankfurter"
+}
+
+func (f *Frankfurter) FX(ctx context.Context, base string) (*FXRates, error) {
+	var data struct {
+		Base  string             `json:"base"`
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	err := getJSON(ctx, f.client, f.baseURL+"/latest?"+url.Values{"from": {base}}.Encode(), &data)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusNotFound || httpErr.StatusCode == http.StatusUnprocessableEntity) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, base)
+	}
+	if err != nil {
+		return nil, err
+	}
+	updated, _ := time.Parse("2006-01-02", data.Date)
+	return &FXRates{Base: data.Base, Rates: data.Rates, Updated: updated}, nil
+}
+
+func (f *Frankfurter) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	return pairTicker(ctx, f, symbol)
+}
+
+func (f *Frankfurter) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	return nil, ErrUnsupported
+}
+
+func (f *Frankfurter) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	return nil, ErrUnsupported
+}
+
+// pairTicker prices a forex or metal pair from a source's rate table.
+func pairTicker(ctx context.Context, src MarketDataSource, symbol string) (*Ticker, error) {
+	base, quote, ok := currencyPair(symbol)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	rates, err := src.FX(ctx, quote)
+	if err != nil {
+		return nil, err
+	}
+	rate := rates.Rates[base]
+	if rate == 0 {
+		return nil, fmt.Errorf("%w: no %s rate", ErrNotFound, base)
+	}
+	ts := rates.Updated
+	if ts.IsZero() {
+		ts = nowUTC()
+	}
+	return &Ticker{Symbol: symbol, Price: 1 / rate, Time: ts}, nil
+}
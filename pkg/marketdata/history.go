@@ -0,0 +1,550 @@
+package marketdata
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// History keeps candles on disk so long lookbacks are downloaded once and
+// afterwards only extended. Each source, symbol and interval has its own
+// directory of monthly JSONL files:
+//
+//	candles/binance/BTCUSDT/1h/2025-01.jsonl
+//	candles/binance/BTCUSDT/1h/meta.json
+//
+// meta.json records the time spans already fetched, so a span is never
+// requested twice, even where a market was closed and returned nothing.
+type History struct {
+	dir      string
+	router   *Router
+	maxFetch int
+	now      func() time.Time
+}
+
+// Span is the time range [From, To).
+type Span struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// HistorySeries describes one stored series.
+type HistorySeries struct {
+	Source   string    `json:"source"`
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	Covered  []Span    `json:"covered"`
+	Updated  time.Time `json:"updated"`
+}
+
+// HistoryResult is a range of stored candles.
+type HistoryResult struct {
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	Source   string    `json:"source"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Candles  []Candle  `json:"candles"`
+	Fetched  int       `json:"fetched"`        // Candles downloaded for this request
+	Gaps     []Span    `json:"gaps,omitempty"` // Parts of the range without candles
+}
+
+const (
+	historyMetaFile = "meta.json"
+	historyExt      = ".jsonl"
+	// historyFlush is how many fetched candles are buffered between writes.
+	historyFlush = 10000
+	// defaultMaxFetch bounds the candles one sync downloads.
+	defaultMaxFetch = 50000
+)
+
+func NewHistory(dir string, router *Router) *History {
+	return &History{
+		dir:      dir,
+		router:   router,
+		maxFetch: defaultMaxFetch,
+		now:      time.Now,
+	}
+}
+
+// NewHistoryFromConfig stores candles under workspace/data/candles and
+// returns nil when the store is disabled.
+func NewHistoryFromConfig(cfg config.MarketHistoryConfig, workspace string, router *Router) *History {
+	if !cfg.Enabled {
+		return nil
+	}
+	h := NewHistory(filepath.Join(workspace, "data", "candles"), router)
+	if cfg.MaxFetchCandles > 0 {
+		h.maxFetch = cfg.MaxFetchCandles
+	}
+	return h
+}
+
+// Range returns symbol's stored candles in [from, to). With sync set the
+// parts of the range not fetched yet are downloaded first, which also
+// extends the series up to now.
+func (h *History) Range(ctx context.Context, symbol, interval string, from, to time.Time, sync bool) (*HistoryResult, error) {
+	symbol = Canonical(symbol)
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	if now := h.now(); to.IsZero() || to.After(now) {
+		to = now
+	}
+	from = from.UTC().Truncate(step)
+	if !from.Before(to) {
+		return nil, fmt.Errorf("empty range %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	result := &HistoryResult{Symbol: symbol, Interval: interval, From: from, To: to.UTC()}
+	var syncErr error
+	if sync {
+		result.Source, result.Fetched, syncErr = h.Sync(ctx, symbol, interval, from, to)
+	}
+	if result.Source == "" {
+		result.Source = h.storedSource(symbol, interval)
+	}
+	if result.Source == "" {
+		if syncErr != nil {
+			return nil, syncErr
+		}
+		return nil, fmt.Errorf("no stored %s candles for %s", interval, symbol)
+	}
+
+	dir := h.seriesDir(result.Source, symbol, interval)
+	result.Candles, err = readCandles(dir, from, to)
+	if err != nil {
+		return nil, err
+	}
+	result.Gaps = gaps(symbol, step, h.meta(dir).Covered, result.Candles, from, h.openCandle(to, step))
+	if syncErr != nil {
+		// Serve what was stored, but say the range may be incomplete
+		logger.WarnCF("market", "Candle history sync incomplete",
+			map[string]interface{}{
+				"symbol":   symbol,
+				"interval": interval,
+				"error":    syncErr.Error(),
+			})
+	}
+	return result, nil
+}
+
+// Sync downloads the parts of [from, to) not stored yet and returns the
+// source used and how many candles were fetched. The source already holding
+// the series is preferred; otherwise the history sources of the symbol's
+// asset class are tried in route order. The candle still forming is never
+// marked as fetched, so every sync refreshes it.
+func (h *History) Sync(ctx context.Context, symbol, interval string, from, to time.Time) (string, int, error) {
+	symbol = Canonical(symbol)
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return "", 0, err
+	}
+	sources := h.sources(symbol)
+	if len(sources) == 0 {
+		return "", 0, fmt.Errorf("no %s source keeps candle history for %s: %w", Classify(symbol), symbol, ErrUnsupported)
+	}
+	if stored := h.storedSource(symbol, interval); stored != "" {
+		sort.SliceStable(sources, func(i, j int) bool {
+			return sources[i].Name() == stored && sources[j].Name() != stored
+		})
+	}
+
+	var failures []string
+	for _, src := range sources {
+		fetched, err := h.syncFrom(ctx, src, symbol, interval, step, from.UTC().Truncate(step), to.UTC())
+		if err == nil || fetched > 0 {
+			return src.Name(), fetched, err
+		}
+		if ctx.Err() != nil {
+			return "", 0, ctx.Err()
+		}
+		if !errors.Is(err, ErrUnsupported) {
+			failures = append(failures, fmt.Sprintf("%s: %v", src.Name(), err))
+		}
+	}
+	if len(failures) == 0 {
+		return "", 0, fmt.Errorf("no source keeps %s candle history for %s: %w", interval, symbol, ErrUnsupported)
+	}
+	return "", 0, fmt.Errorf("candle history for %s failed (%s)", symbol, strings.Join(failures, "; "))
+}
+
+// syncFrom fetches the missing spans of [from, to) from src, paging back
+// from the end of each span.
+func (h *History) syncFrom(ctx context.Context, src HistorySource, symbol, interval string, step time.Duration, from, to time.Time) (int, error) {
+	dir := h.seriesDir(src.Name(), symbol, interval)
+	unlock, err := fileio.Lock(dir)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	meta := h.meta(dir)
+	closed := h.openCandle(to, step)
+	fetched := 0
+	var pending []Candle
+	var covered []Span
+	flush := func() error {
+		if len(pending) > 0 {
+			if err := writeCandles(dir, pending); err != nil {
+				return err
+			}
+			pending = nil
+		}
+		if len(covered) > 0 {
+			if err := h.saveMeta(dir, src.Name(), symbol, interval, covered); err != nil {
+				return err
+			}
+			covered = nil
+		}
+		return nil
+	}
+
+	for _, span := range subtract(Span{From: from, To: to}, meta.Covered) {
+		end := span.To
+		for {
+			if fetched >= h.maxFetch {
+				covered = append(covered, clip(Span{From: end, To: span.To}, closed))
+				return fetched, errors.Join(flush(),
+					fmt.Errorf("stopped after %d candles; request again to continue", fetched))
+			}
+			page, err := src.CandlesBefore(ctx, symbol, interval, span.From, end)
+			if err != nil {
+				covered = append(covered, clip(Span{From: end, To: span.To}, closed))
+				return fetched, errors.Join(err, flush())
+			}
+			candles := page.Candles
+			for len(candles) > 0 && !candles[len(candles)-1].Time.Before(end) {
+				candles = candles[:len(candles)-1]
+			}
+			fetched += len(candles)
+			pending = append(pending, candles...)
+			if len(candles) == 0 || !candles[0].Time.After(span.From) {
+				// Either the span's start was reached or the source has nothing older
+				covered = append(covered, clip(span, closed))
+				break
+			}
+			end = candles[0].Time
+			if len(pending) >= historyFlush {
+				covered = append(covered, clip(Span{From: end, To: span.To}, closed))
+				span.To = end
+				if err := flush(); err != nil {
+					return fetched, err
+				}
+			}
+		}
+	}
+	return fetched, flush()
+}
+
+// Series lists the stored series.
+func (h *History) Series() []HistorySeries {
+	metas, _ := filepath.Glob(filepath.Join(h.dir, "*", "*", "*", historyMetaFile))
+	series := make([]HistorySeries, 0, len(metas))
+	for _, path := range metas {
+		if meta := h.meta(filepath.Dir(path)); meta.Symbol != "" {
+			series = append(series, meta)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i], series[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.Interval != b.Interval {
+			return a.Interval < b.Interval
+		}
+		return a.Source < b.Source
+	})
+	return series
+}
+
+// Gaps returns the parts of [from, to) without stored candles: spans never
+// fetched and, for crypto, which trades around the clock, candles missing
+// inside fetched spans.
+func (h *History) Gaps(symbol, interval string, from, to time.Time) ([]Span, error) {
+	symbol = Canonical(symbol)
+	step, err := intervalDuration(interval)
+	if err != nil {
+		return nil, err
+	}
+	source := h.storedSource(symbol, interval)
+	if source == "" {
+		return []Span{{From: from, To: to}}, nil
+	}
+	dir := h.seriesDir(source, symbol, interval)
+	candles, err := readCandles(dir, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return gaps(symbol, step, h.meta(dir).Covered, candles, from.UTC().Truncate(step), h.openCandle(to.UTC(), step)), nil
+}
+
+// sources returns the history sources routed for symbol's class, in order.
+func (h *History) sources(symbol string) []HistorySource {
+	var out []HistorySource
+	for _, src := range h.router.routes[Classify(symbol)] {
+		if hs, ok := src.(HistorySource); ok {
+			out = append(out, hs)
+		}
+	}
+	return out
+}
+
+// storedSource returns the first routed source that has symbol stored.
+func (h *History) storedSource(symbol, interval string) string {
+	for _, src := range h.sources(symbol) {
+		if _, err := os.Stat(filepath.Join(h.seriesDir(src.Name(), symbol, interval), historyMetaFile)); err == nil {
+			return src.Name()
+		}
+	}
+	return ""
+}
+
+// openCandle returns the open time of the candle still forming at t, or t
+// when that is in the past.
+func (h *History) openCandle(t time.Time, step time.Duration) time.Time {
+	if open := h.now().UTC().Truncate(step); t.After(open) {
+		return open
+	}
+	return t
+}
+
+func (h *History) seriesDir(source, symbol, interval string) string {
+	return filepath.Join(h.dir, source, fileSafe(symbol), interval)
+}
+
+func (h *History) meta(dir string) HistorySeries {
+	var meta HistorySeries
+	if data, err := os.ReadFile(filepath.Join(dir, historyMetaFile)); err == nil {
+		json.Unmarshal(data, &meta)
+	}
+	return meta
+}
+
+// saveMeta adds spans to the series' fetched spans.
+func (h *History) saveMeta(dir, source, symbol, interval string, spans []Span) error {
+	return fileio.Update(filepath.Join(dir, historyMetaFile), 0644, func(current []byte) ([]byte, error) {
+		var meta HistorySeries
+		if len(current) > 0 {
+			if err := json.Unmarshal(current, &meta); err != nil {
+				return nil, fmt.Errorf("corrupt %s: %w", historyMetaFile, err)
+			}
+		}
+		meta.Source, meta.Symbol, meta.Interval = source, symbol, interval
+		for _, s := range spans {
+			meta.Covered = addSpan(meta.Covered, s)
+		}
+		meta.Updated = h.now().UTC()
+		return json.MarshalIndent(meta, "", "  ")
+	})
+}
+
+// readCandles returns the stored candles in [from, to), oldest first.
+func readCandles(dir string, from, to time.Time) ([]Candle, error) {
+	var candles []Candle
+	for month := monthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		stored, err := readMonth(filepath.Join(dir, month.Format("2006-01")+historyExt))
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range stored {
+			if !c.Time.Before(from) && c.Time.Before(to) {
+				candles = append(candles, c)
+			}
+		}
+	}
+	return candles, nil
+}
+
+// writeCandles merges candles into their monthly files; a stored candle
+// with the same open time is replaced.
+func writeCandles(dir string, candles []Candle) error {
+	byMonth := make(map[string][]Candle)
+	for _, c := range candles {
+		name := c.Time.UTC().Format("2006-01") + historyExt
+		byMonth[name] = append(byMonth[name], c)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, add := range byMonth {
+		err := fileio.Update(filepath.Join(dir, name), 0644, func(current []byte) ([]byte, error) {
+			merged := make(map[int64]Candle)
+			for _, c := range parseCandles(current) {
+				merged[c.Time.Unix()] = c
+			}
+			for _, c := range add {
+				c.Time = c.Time.UTC()
+				merged[c.Time.Unix()] = c
+			}
+			out := make([]Candle, 0, len(merged))
+			for _, c := range merged {
+				out = append(out, c)
+			}
+			sortCandles(out)
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			for _, c := range out {
+				if err := enc.Encode(c); err != nil {
+					return nil, err
+				}
+			}
+			return buf.Bytes(), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMonth(path string) ([]Candle, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseCandles(data), nil
+}
+
+// parseCandles reads JSONL candles, skipping lines that don't parse.
+func parseCandles(data []byte) []Candle {
+	var candles []Candle
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var c Candle
+		if json.Unmarshal(scanner.Bytes(), &c) == nil && !c.Time.IsZero() {
+			candles = append(candles, c)
+		}
+	}
+	return candles
+}
+
+// gaps returns the parts of [from, to) outside covered and, for crypto,
+// the runs of missing candles inside it.
+func gaps(symbol string, step time.Duration, covered []Span, candles []Candle, from, to time.Time) []Span {
+	if !from.Before(to) {
+		return nil
+	}
+	out := subtract(Span{From: from, To: to}, covered)
+	if Classify(symbol) == ClassCrypto {
+		for _, span := range covered {
+			span = clip(Span{From: maxTime(span.From, from), To: span.To}, to)
+			if !span.From.Before(span.To) {
+				continue
+			}
+			next := span.From
+			for _, c := range candles {
+				if c.Time.Before(span.From) || !c.Time.Before(span.To) {
+					continue
+				}
+				if c.Time.After(next) {
+					out = addSpan(out, Span{From: next, To: c.Time})
+				}
+				next = c.Time.Add(step)
+			}
+			if next.Before(span.To) {
+				out = addSpan(out, Span{From: next, To: span.To})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].From.Before(out[j].From) })
+	return out
+}
+
+// subtract returns the parts of want not in spans, which must be sorted
+// and disjoint.
+func subtract(want Span, spans []Span) []Span {
+	var out []Span
+	from := want.From
+	for _, s := range spans {
+		if !s.To.After(from) {
+			continue
+		}
+		if !s.From.Before(want.To) {
+			break
+		}
+		if s.From.After(from) {
+			out = append(out, Span{From: from, To: s.From})
+		}
+		from = s.To
+	}
+	if from.Before(want.To) {
+		out = append(out, Span{From: from, To: want.To})
+	}
+	return out
+}
+
+// addSpan merges s into sorted, disjoint spans.
+func addSpan(spans []Span, s Span) []Span {
+	if !s.From.Before(s.To) {
+		return spans
+	}
+	out := make([]Span, 0, len(spans)+1)
+	for _, cur := range spans {
+		switch {
+		case cur.To.Before(s.From):
+			out = append(out, cur)
+		case s.To.Before(cur.From):
+			out = append(out, s)
+			s = cur
+		default:
+			s = Span{From: minTime(s.From, cur.From), To: maxTime(s.To, cur.To)}
+		}
+	}
+	return append(out, s)
+}
+
+// clip cuts s off at end.
+func clip(s Span, end time.Time) Span {
+	if s.To.After(end) {
+		s.To = end
+	}
+	return s
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// fileSafe replaces characters that can't appear in file names, e.g. in
+// some Yahoo symbols.
+func fileSafe(symbol string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, symbol)
+}
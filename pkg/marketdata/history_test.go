@@ -0,0 +1,167 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// klineServer serves Binance klines every step from listed up to now,
+// except those in missing, honouring startTime, endTime and limit.
+func klineServer(t *testing.T, listed, now time.Time, step time.Duration, missing map[time.Time]bool) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		q := r.URL.Query()
+		end := now
+		if ms, err := strconv.ParseInt(q.Get("endTime"), 10, 64); err == nil {
+			end = time.UnixMilli(ms + 1).UTC()
+		}
+		start := listed
+		if ms, err := strconv.ParseInt(q.Get("startTime"), 10, 64); err == nil && time.UnixMilli(ms).After(start) {
+			start = time.UnixMilli(ms).UTC()
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		var rows [][]interface{}
+		for at := start; at.Before(end) && !at.After(now); at = at.Add(step) {
+			if !missing[at] {
+				rows = append(rows, []interface{}{at.UnixMilli(), "1", "2", "0.5", "1.5", "10"})
+			}
+		}
+		if len(rows) > limit {
+			if q.Has("startTime") {
+				rows = rows[:limit]
+			} else {
+				rows = rows[len(rows)-limit:]
+			}
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func newTestHistory(t *testing.T, server *httptest.Server, now time.Time) *History {
+	t.Helper()
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassCrypto: {NewBinance(server.URL, http.DefaultClient)},
+	})
+	h := NewHistory(t.TempDir(), router)
+	h.now = func() time.Time { return now }
+	return h
+}
+
+func TestHistoryBackfillsAndExtends(t *testing.T) {
+	now := time.Date(2025, 3, 2, 12, 32, 0, 0, time.UTC)
+	server, requests := klineServer(t, now.AddDate(0, -1, 0).Truncate(5*time.Minute), now, 5*time.Minute, nil)
+	h := newTestHistory(t, server, now)
+	ctx := context.Background()
+
+	// Five days of 5m candles take two pages and cross a month boundary
+	result, err := h.Range(ctx, "btc/usdt", "5m", now.AddDate(0, 0, -5), time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Candles) != 1441 || result.Fetched != 1441 || result.Source != "binance" || len(result.Gaps) != 0 {
+		t.Fatalf("expected 1441 fetched candles without gaps, got %d/%d from %s, gaps %+v",
+			len(result.Candles), result.Fetched, result.Source, result.Gaps)
+	}
+	if *requests != 2 {
+		t.Fatalf("expected 2 pages, got %d requests", *requests)
+	}
+
+	// Asking again only refreshes the candle still forming
+	atomic.StoreInt32(requests, 0)
+	result, err = h.Range(ctx, "BTCUSDT", "5m", now.AddDate(0, 0, -5), time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Candles) != 1441 || result.Fetched != 1 || *requests != 1 {
+		t.Fatalf("expected one refreshed candle, got %d fetched in %d requests (%d served)", result.Fetched, *requests, len(result.Candles))
+	}
+
+	// Extending the range back fetches only the older part
+	atomic.StoreInt32(requests, 0)
+	result, err = h.Range(ctx, "BTCUSDT", "5m", now.AddDate(0, 0, -6), now.AddDate(0, 0, -4), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Candles) != 577 || result.Fetched != 288 || *requests != 1 {
+		t.Fatalf("expected 288 older candles in one request, got %d fetched in %d requests (%d served)",
+			result.Fetched, *requests, len(result.Candles))
+	}
+
+	// Stored ranges are served without touching the source
+	atomic.StoreInt32(requests, 0)
+	result, err = h.Range(ctx, "BTCUSDT", "5m", now.AddDate(0, 0, -3), now.AddDate(0, 0, -2), false)
+	if err != nil || len(result.Candles) != 289 || *requests != 0 {
+		t.Fatalf("expected 289 candles from disk, got %d (%v) after %d requests", len(result.Candles), err, *requests)
+	}
+	if _, err := h.Range(ctx, "ETHUSDT", "5m", now.AddDate(0, 0, -1), time.Time{}, false); err == nil {
+		t.Fatal("reading a series that was never stored should fail")
+	}
+
+	series := h.Series()
+	if len(series) != 1 || series[0].Symbol != "BTCUSDT" || len(series[0].Covered) != 1 ||
+		!series[0].Covered[0].From.Equal(now.AddDate(0, 0, -6).Truncate(5*time.Minute)) || !series[0].Covered[0].To.Equal(now.Truncate(5*time.Minute)) {
+		t.Fatalf("unexpected stored series: %+v", series)
+	}
+}
+
+func TestHistoryGapsAndListing(t *testing.T) {
+	now := time.Date(2025, 3, 2, 12, 0, 0, 0, time.UTC)
+	listed := now.Add(-48 * time.Hour)
+	hole := now.Add(-10 * time.Hour)
+	server, _ := klineServer(t, listed, now, time.Hour, map[time.Time]bool{hole: true, hole.Add(time.Hour): true})
+	h := newTestHistory(t, server, now)
+	ctx := context.Background()
+
+	// The range starts before the pair was listed
+	result, err := h.Range(ctx, "BTCUSDT", "1h", now.Add(-72*time.Hour), time.Time{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Candles) != 46 {
+		t.Fatalf("expected 46 candles, got %d", len(result.Candles))
+	}
+	want := []Span{{From: now.Add(-72 * time.Hour), To: listed}, {From: hole, To: hole.Add(2 * time.Hour)}}
+	if len(result.Gaps) != 2 || !result.Gaps[0].From.Equal(want[0].From) || !result.Gaps[0].To.Equal(want[0].To) ||
+		!result.Gaps[1].From.Equal(want[1].From) || !result.Gaps[1].To.Equal(want[1].To) {
+		t.Fatalf("expected gaps %+v, got %+v", want, result.Gaps)
+	}
+
+	gaps, err := h.Gaps("BTCUSDT", "1h", now.Add(-24*time.Hour), now)
+	if err != nil || len(gaps) != 1 || !gaps[0].From.Equal(hole) {
+		t.Fatalf("expected the hole, got %+v (%v)", gaps, err)
+	}
+	if gaps, _ := h.Gaps("ETHUSDT", "1h", listed, now); len(gaps) != 1 || !gaps[0].From.Equal(listed) {
+		t.Fatalf("a series never fetched is one gap: %+v", gaps)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2025, 1, 1, h, 0, 0, 0, time.UTC) }
+	var spans []Span
+	spans = addSpan(spans, Span{From: at(5), To: at(7)})
+	spans = addSpan(spans, Span{From: at(1), To: at(2)})
+	spans = addSpan(spans, Span{From: at(2), To: at(3)})
+	spans = addSpan(spans, Span{From: at(10), To: at(12)})
+	spans = addSpan(spans, Span{From: at(6), To: at(11)})
+	if len(spans) != 2 || !spans[0].From.Equal(at(1)) || !spans[0].To.Equal(at(3)) ||
+		!spans[1].From.Equal(at(5)) || !spans[1].To.Equal(at(12)) {
+		t.Fatalf("spans not merged: %+v", spans)
+	}
+
+	missing := subtract(Span{From: at(0), To: at(14)}, spans)
+	if len(missing) != 3 || !missing[0].To.Equal(at(1)) || !missing[1].From.Equal(at(3)) ||
+		!missing[1].To.Equal(at(5)) || !missing[2].From.Equal(at(12)) {
+		t.Fatalf("unexpected missing spans: %+v", missing)
+	}
+}
@@ -0,0 +1,345 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// OKX serves crypto pairs from the OKX public market API, as an alternative
+// exchange where Binance is unavailable.
+type OKX struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOKX(baseURL string, client *http.Client) *OKX {
+	if baseURL == "" {
+		baseURL = "https://www.okx.com"
+	}
+	return &OKX{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (o *OKX) Name() string {
+	return "okx"
+}
+
+// okxBars maps intervals to OKX bar sizes; daily and longer bars use UTC
+// boundaries like Binance's.
+var okxBars = map[string]string{
+	"1m": "1m", "3m": "3m", "5m": "5m", "15m": "15m", "30m": "30m",
+	"1h": "1H", "2h": "2H", "4h": "4H", "6h": "6Hutc", "12h": "12Hutc",
+	"1d": "1Dutc", "1w": "1Wutc",
+}
+
+func (o *OKX) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	instID, err := okxInstrument(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var data []map[string]interface{}
+	if err := o.get(ctx, "/api/v5/market/ticker", url.Values{"instId": {instID}}, &data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, symbol)
+	}
+	d := data[0]
+	price, open := number(d["last"]), number(d["open24h"])
+	ts := nowUTC()
+	if ms := number(d["ts"]); ms > 0 {
+		ts = time.UnixMilli(int64(ms)).UTC()
+	}
+	return &Ticker{
+		Symbol:      symbol,
+		Price:       price,
+		Change:      price - open,
+		ChangePct:   changePct(price, open),
+		Open:        open,
+		High:        number(d["high24h"]),
+		Low:         number(d["low24h"]),
+		Volume:      number(d["vol24h"]),
+		QuoteVolume: number(d["volCcy24h"]),
+		Time:        ts,
+	}, nil
+}
+
+func (o *OKX) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	instID, err := okxInstrument(symbol)
+	if err != nil {
+		return nil, err
+	}
+	bar, ok := okxBars[interval]
+	if !ok {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	params := url.Values{"instId": {instID}, "bar": {bar}, "limit": {fmt.Sprint(min(limit, 300))}}
+	var raw [][]interface{}
+	if err := o.get(ctx, "/api/v5/market/candles", params, &raw); err != nil {
+		return nil, err
+	}
+	// OKX returns the newest candle first
+	series := &CandleSeries{Symbol: symbol, Interval: interval, Candles: klines(raw)}
+	sortCandles(series.Candles)
+	return series, nil
+}
+
+// okxHistoryPage is the most candles OKX's history endpoint returns.
+const okxHistoryPage = 100
+
+func (o *OKX) CandlesBefore(ctx context.Context, symbol, interval string, start, end time.Time) (*CandleSeries, error) {
+	instID, err := okxInstrument(symbol)
+	if err != nil {
+		return nil, err
+	}
+	bar, ok := okxBars[interval]
+	if !ok {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	// "after" pages backwards: candles older than the given timestamp, and
+	// "before" stops at candles newer than its timestamp
+	params := url.Values{
+		"instId": {instID},
+		"bar":    {bar},
+		"after":  {fmt.Sprint(end.UnixMilli())},
+		"before": {fmt.Sprint(start.UnixMilli() - 1)},
+		"limit":  {fmt.Sprint(okxHistoryPage)},
+	}
+	var raw [][]interface{}
+	if err := o.get(ctx, "/api/v5/market/history-candles", params, &raw); err != nil {
+		return nil, err
+	}
+	candles := klines(raw)
+	sortCandles(candles)
+	return &CandleSeries{Symbol: symbol, Interval: interval, Candles: since(candles, start)}, nil
+}
+
+func (o *OKX) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	instID, err := okxInstrument(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var data []struct {
+		Bids [][]interface{} `json:"bids"`
+		Asks [][]interface{} `json:"asks"`
+	}
+	params := url.Values{"instId": {instID}, "sz": {fmt.Sprint(limit)}}
+	if err := o.get(ctx, "/api/v5/market/books", params, &data); err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, symbol)
+	}
+	return &OrderBook{Symbol: symbol, Bids: levels(data[0].Bids), Asks: levels(data[0].Asks), Time: nowUTC()}, nil
+}
+
+func (o *OKX) FX(ctx context.Context, base string) (*FXRates, error) {
+	return nil, ErrUnsupported
+}
+
+func (o *OKX) FundingRates(ctx context.Context, symbol string, limit int) (*FundingSeries, error) {
+	instID, err := okxSwap(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		FundingRate  string `json:"fundingRate"`
+		RealizedRate string `json:"realizedRate"`
+		FundingTime  string `json:"fundingTime"`
+	}
+	params := url.Values{"instId": {instID}, "limit": {fmt.Sprint(min(limit, 100))}}
+	if err := o.get(ctx, "/api/v5/public/funding-rate-history", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &FundingSeries{Symbol: symbol, Instrument: instID, Rates: make([]FundingRate, 0, len(raw))}
+	for _, r := range raw {
+		rate := number(r.RealizedRate)
+		if r.RealizedRate == "" {
+			rate = number(r.FundingRate)
+		}
+		series.Rates = append(series.Rates, FundingRate{
+			Time: time.UnixMilli(int64(number(r.FundingTime))).UTC(),
+			Rate: rate,
+		})
+	}
+	// OKX returns the newest settlement first
+	slices.Reverse(series.Rates)
+	return series, nil
+}
+
+// okxPeriods maps intervals to the periods of OKX's trading statistics.
+var okxPeriods = map[string]string{"5m": "5m", "1h": "1H", "1d": "1D"}
+
+// OpenInterest reports the USD value of all OKX contracts on the base
+// currency, as OKX's statistics aggregate them.
+func (o *OKX) OpenInterest(ctx context.Context, symbol, interval string, limit int) (*OpenInterestSeries, error) {
+	ccy, period, err := okxStat(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	var raw [][]interface{}
+	params := url.Values{"ccy": {ccy}, "period": {period}}
+	if err := o.get(ctx, "/api/v5/rubik/stat/contracts/open-interest-volume", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &OpenInterestSeries{Symbol: symbol, Instrument: ccy + " contracts", Interval: interval}
+	for _, r := range raw {
+		if len(r) < 2 {
+			continue
+		}
+		series.Points = append(series.Points, OpenInterestPoint{
+			Time:     time.UnixMilli(int64(number(r[0]))).UTC(),
+			ValueUSD: number(r[1]),
+		})
+	}
+	slices.Reverse(series.Points)
+	if len(series.Points) > limit {
+		series.Points = series.Points[len(series.Points)-limit:]
+	}
+	return series, nil
+}
+
+func (o *OKX) LongShortRatio(ctx context.Context, symbol, interval string, limit int) (*LongShortSeries, error) {
+	ccy, period, err := okxStat(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	var raw [][]interface{}
+	params := url.Values{"ccy": {ccy}, "period": {period}}
+	if err := o.get(ctx, "/api/v5/rubik/stat/contracts/long-short-account-ratio", params, &raw); err != nil {
+		return nil, err
+	}
+	series := &LongShortSeries{Symbol: symbol, Instrument: ccy + " contracts", Interval: interval}
+	for _, r := range raw {
+		if len(r) < 2 {
+			continue
+		}
+		series.Points = append(series.Points, LongShortPoint{
+			Time:  time.UnixMilli(int64(number(r[0]))).UTC(),
+			Ratio: number(r[1]),
+		})
+	}
+	slices.Reverse(series.Points)
+	if len(series.Points) > limit {
+		series.Points = series.Points[len(series.Points)-limit:]
+	}
+	return series, nil
+}
+
+func (o *OKX) Basis(ctx context.Context, symbol string) (*Basis, error) {
+	instID, err := okxSwap(symbol)
+	if err != nil {
+		return nil, err
+	}
+	var mark []struct {
+		MarkPx string `json:"markPx"`
+		Ts     string `json:"ts"`
+	}
+	if err := o.get(ctx, "/api/v5/public/mark-price", url.Values{"instType": {"SWAP"}, "instId": {instID}}, &mark); err != nil {
+		return nil, err
+	}
+	var index []struct {
+		IdxPx string `json:"idxPx"`
+	}
+	indexID := strings.TrimSuffix(instID, "-SWAP")
+	if err := o.get(ctx, "/api/v5/market/index-tickers", url.Values{"instId": {indexID}}, &index); err != nil {
+		return nil, err
+	}
+	var funding []struct {
+		FundingRate     string `json:"fundingRate"`
+		FundingTime     string `json:"fundingTime"`
+		NextFundingTime string `json:"nextFundingTime"`
+	}
+	if err := o.get(ctx, "/api/v5/public/funding-rate", url.Values{"instId": {instID}}, &funding); err != nil {
+		return nil, err
+	}
+	if len(mark) == 0 || len(index) == 0 || len(funding) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, instID)
+	}
+
+	f := funding[0]
+	next := time.UnixMilli(int64(number(f.FundingTime))).UTC()
+	hours := 0.0
+	if after := number(f.NextFundingTime); after > 0 {
+		hours = time.UnixMilli(int64(after)).Sub(next).Hours()
+	}
+	ts := nowUTC()
+	if ms := number(mark[0].Ts); ms > 0 {
+		ts = time.UnixMilli(int64(ms)).UTC()
+	}
+	return &Basis{
+		Symbol:               symbol,
+		Instrument:           instID,
+		IndexPrice:           number(index[0].IdxPx),
+		MarkPrice:            number(mark[0].MarkPx),
+		FundingRate:          number(f.FundingRate),
+		FundingIntervalHours: hours,
+		NextFunding:          next,
+		Time:                 ts,
+	}, nil
+}
+
+// okxSwap returns the perpetual swap tracking a pair, e.g. BTC-USDT-SWAP.
+func okxSwap(symbol string) (string, error) {
+	base, quote, err := perpPair(symbol)
+	if err != nil {
+		return "", err
+	}
+	return base + "-" + quote + "-SWAP", nil
+}
+
+// okxStat returns the currency and period for OKX's trading statistics.
+func okxStat(symbol, interval string) (string, string, error) {
+	base, _, err := perpPair(symbol)
+	if err != nil {
+		return "", "", err
+	}
+	period, ok := okxPeriods[interval]
+	if !ok {
+		return "", "", fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	return base, period, nil
+}
+
+// get unwraps OKX's {"code": "0", "data": [...]} envelope into v.
+func (o *OKX) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	err := getJSON(ctx, o.client, o.baseURL+path+"?"+params.Encode(), &envelope)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && strings.Contains(httpErr.Body, `"51001"`) {
+		return fmt.Errorf("%w: %s", ErrNotFound, params.Get("instId"))
+	}
+	if err != nil {
+		return err
+	}
+	switch envelope.Code {
+	case "0":
+	case "51001": // Instrument ID does not exist
+		return fmt.Errorf("%w: %s", ErrNotFound, params.Get("instId"))
+	default:
+		return fmt.Errorf("okx error %s: %s", envelope.Code, envelope.Msg)
+	}
+	if err := json.Unmarshal(envelope.Data, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// okxInstrument turns BTCUSDT into OKX's BTC-USDT.
+func okxInstrument(symbol string) (string, error) {
+	if Classify(symbol) != ClassCrypto {
+		return "", ErrUnsupported
+	}
+	base, quote, _ := cryptoPair(symbol)
+	return base + "-" + quote, nil
+}
@@ -0,0 +1,377 @@
+package marketdata
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Instrument is a canonical tradable. Its ID is the symbol every result,
+// cache entry and stored file uses: exchange pairs for crypto (BTCUSDT),
+// six-letter pairs for forex and metals (EURUSD, XAUUSD) and short names
+// for indices and commodities (SPX, WTI).
+type Instrument struct {
+	ID      string            `json:"id"`
+	Class   AssetClass        `json:"class"`
+	Base    string            `json:"base,omitempty"`
+	Quote   string            `json:"quote,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Aliases []string          `json:"aliases,omitempty"`
+	Tickers map[string]string `json:"tickers,omitempty"` // Source name -> the source's symbol
+	Listed  bool              `json:"listed"`            // In the registry rather than only well-formed
+}
+
+// UnknownSymbolError is returned for symbols that can't name an instrument,
+// with the closest registered ones.
+type UnknownSymbolError struct {
+	Symbol      string
+	Suggestions []string
+}
+
+func (e *UnknownSymbolError) Error() string {
+	return fmt.Sprintf("unknown symbol %q%s", e.Symbol, didYouMean(e.Suggestions))
+}
+
+func (e *UnknownSymbolError) Unwrap() error {
+	return ErrNotFound
+}
+
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return " (did you mean " + strings.Join(suggestions, ", ") + "?)"
+}
+
+// Registry maps aliases and source notations to canonical instruments.
+// Symbols it doesn't list still resolve when they are well-formed, so any
+// exchange pair or stock ticker works without registering it.
+type Registry struct {
+	mu          sync.RWMutex
+	instruments map[string]*Instrument
+	aliases     map[string]string // Alias -> ID
+}
+
+// NewRegistry registers instruments under their IDs, aliases and source
+// tickers.
+func NewRegistry(instruments []Instrument) *Registry {
+	r := &Registry{
+		instruments: make(map[string]*Instrument),
+		aliases:     make(map[string]string),
+	}
+	for i := range instruments {
+		inst := instruments[i]
+		inst.Listed = true
+		inst.Aliases = append([]string(nil), inst.Aliases...)
+		r.instruments[inst.ID] = &inst
+		for _, alias := range inst.Aliases {
+			r.aliases[NormalizeSymbol(alias)] = inst.ID
+		}
+		for _, ticker := range inst.Tickers {
+			r.aliases[NormalizeSymbol(ticker)] = inst.ID
+		}
+	}
+	return r
+}
+
+// AddAlias makes alias resolve to the instrument symbol resolves to. Adding
+// an alias again is a no-op, and one that resolved elsewhere is moved.
+func (r *Registry) AddAlias(alias, symbol string) {
+	id := r.Canonical(symbol)
+	alias = NormalizeSymbol(alias)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if alias == "" || alias == id || r.aliases[alias] == id {
+		return
+	}
+	if prev, ok := r.instruments[r.aliases[alias]]; ok {
+		prev.Aliases = slices.DeleteFunc(prev.Aliases, func(a string) bool {
+			return NormalizeSymbol(a) == alias
+		})
+	}
+	r.aliases[alias] = id
+	if inst, ok := r.instruments[id]; ok {
+		inst.Aliases = append(inst.Aliases, alias)
+	}
+}
+
+// Canonical returns the canonical ID for a symbol: aliases are resolved,
+// separators dropped from pairs ("btc-usdt" is BTCUSDT), metals quoted in
+// USD (XAU and XAUUSDT are XAUUSD) and fiat currencies quoted in a USD
+// stablecoin read as the forex pair (EURUSDT is EURUSD). Anything else is
+// only normalized.
+func (r *Registry) Canonical(symbol string) string {
+	s := NormalizeSymbol(symbol)
+	r.mu.RLock()
+	id, ok := r.aliases[s]
+	if !ok {
+		id, ok = r.aliases[loose(s)]
+	}
+	r.mu.RUnlock()
+	if ok {
+		return id
+	}
+
+	if l := loose(s); l != s && Classify(l) != ClassEquity {
+		s = l
+	}
+	switch Classify(s) {
+	case ClassMetals:
+		base, quote, _ := metalPair(s)
+		return base + quote
+	case ClassCrypto:
+		if base, quote, _ := cryptoPair(s); currencies[base] && base != "USD" && usdStablecoins[quote] {
+			return base + "USD"
+		}
+	}
+	return s
+}
+
+// usdStablecoins are the quote assets read as USD for fiat currencies.
+var usdStablecoins = map[string]bool{"USDT": true, "USDC": true, "BUSD": true, "FDUSD": true, "TUSD": true}
+
+// loose drops the separators sources put inside symbols.
+func loose(s string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "", ".", "").Replace(s)
+}
+
+// Resolve returns the instrument a symbol names, with its ticker at each
+// source that can serve it.
+func (r *Registry) Resolve(symbol string) (Instrument, error) {
+	id := r.Canonical(symbol)
+	if !wellFormed(id) {
+		return Instrument{}, &UnknownSymbolError{Symbol: symbol, Suggestions: r.Suggest(symbol, 3)}
+	}
+	r.mu.RLock()
+	listed, ok := r.instruments[id]
+	var inst Instrument
+	if ok {
+		inst = *listed
+		inst.Aliases = append([]string(nil), listed.Aliases...)
+	}
+	r.mu.RUnlock()
+	if !ok {
+		inst = Instrument{ID: id, Class: Classify(id)}
+		switch inst.Class {
+		case ClassCrypto:
+			inst.Base, inst.Quote, _ = cryptoPair(id)
+		case ClassFX, ClassMetals:
+			inst.Base, inst.Quote, _ = currencyPair(id)
+		}
+	}
+
+	inst.Tickers = make(map[string]string)
+	for _, name := range SourceNames() {
+		if ticker, err := sourceTickers[name](id); err == nil {
+			inst.Tickers[name] = ticker
+		}
+	}
+	return inst, nil
+}
+
+// wellFormed reports whether s could be a symbol at some source.
+func wellFormed(s string) bool {
+	if len(s) == 0 || len(s) > 20 {
+		return false
+	}
+	letters := 0
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			letters++
+		case c >= '0' && c <= '9', strings.ContainsRune("^=.-&_", c):
+		default:
+			return false
+		}
+	}
+	return letters > 0
+}
+
+// Suggest returns up to n registered IDs whose ID or alias is closest to
+// symbol, for typos such as BTCUSTD or GLOD.
+func (r *Registry) Suggest(symbol string, n int) []string {
+	s := loose(NormalizeSymbol(symbol))
+	if s == "" {
+		return nil
+	}
+	maxDist := max(1, len(s)/3)
+	best := make(map[string]int)
+	r.mu.RLock()
+	consider := func(name, id string) {
+		d := distance(s, loose(name))
+		if d > maxDist {
+			return
+		}
+		if prev, ok := best[id]; !ok || d < prev {
+			best[id] = d
+		}
+	}
+	for id := range r.instruments {
+		consider(id, id)
+	}
+	for alias, id := range r.aliases {
+		consider(alias, id)
+	}
+	r.mu.RUnlock()
+
+	ids := make([]string, 0, len(best))
+	for id := range best {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if best[ids[i]] != best[ids[j]] {
+			return best[ids[i]] < best[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids[:min(n, len(ids))]
+}
+
+// distance is the edit distance between a and b, counting a swap of two
+// adjacent characters (GLOD for GOLD) as one edit.
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// Instruments lists the registered instruments of a class, or of every
+// class when class is empty, by class and ID.
+func (r *Registry) Instruments(class AssetClass) []Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []Instrument
+	for _, inst := range r.instruments {
+		if class == "" || inst.Class == class {
+			out = append(out, *inst)
+		}
+	}
+	order := make(map[AssetClass]int)
+	for i, c := range Classes {
+		order[c] = i
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Class != out[j].Class {
+			return order[out[i].Class] < order[out[j].Class]
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// ticker returns the registered symbol of an instrument at a source.
+func (r *Registry) ticker(id, source string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if inst, ok := r.instruments[id]; ok {
+		return inst.Tickers[source]
+	}
+	return ""
+}
+
+// sourceTickers translate canonical IDs into each source's notation.
+var sourceTickers = map[string]func(id string) (string, error){
+	"binance": func(id string) (string, error) {
+		if Classify(id) != ClassCrypto {
+			return "", ErrUnsupported
+		}
+		return id, nil
+	},
+	"okx":   okxInstrument,
+	"yahoo": yahooSymbol,
+	"erapi": func(id string) (string, error) {
+		base, quote, ok := currencyPair(id)
+		if !ok {
+			return "", ErrUnsupported
+		}
+		return base + "/" + quote, nil
+	},
+	"frankfurter": func(id string) (string, error) {
+		base, quote, ok := currencyPair(id)
+		if !ok || metals[base] {
+			return "", ErrUnsupported
+		}
+		return base + "/" + quote, nil
+	},
+}
+
+// Symbols is the registry every router, store and stream resolves symbols
+// with.
+var Symbols = NewRegistry(builtinInstruments)
+
+// Canonical returns the canonical ID of a symbol in Symbols.
+func Canonical(symbol string) string {
+	return Symbols.Canonical(symbol)
+}
+
+// builtinInstruments are the instruments the skills watch, with the names
+// traders and other platforms use for them.
+var builtinInstruments = []Instrument{
+	{ID: "BTCUSDT", Class: ClassCrypto, Base: "BTC", Quote: "USDT", Name: "Bitcoin", Aliases: []string{"BTC", "XBT", "XBTUSDT", "BITCOIN"}},
+	{ID: "ETHUSDT", Class: ClassCrypto, Base: "ETH", Quote: "USDT", Name: "Ethereum", Aliases: []string{"ETH", "ETHEREUM"}},
+	{ID: "SOLUSDT", Class: ClassCrypto, Base: "SOL", Quote: "USDT", Name: "Solana", Aliases: []string{"SOL", "SOLANA"}},
+	{ID: "BNBUSDT", Class: ClassCrypto, Base: "BNB", Quote: "USDT", Name: "BNB", Aliases: []string{"BNB"}},
+	{ID: "XRPUSDT", Class: ClassCrypto, Base: "XRP", Quote: "USDT", Name: "XRP", Aliases: []string{"XRP", "RIPPLE"}},
+	{ID: "ADAUSDT", Class: ClassCrypto, Base: "ADA", Quote: "USDT", Name: "Cardano", Aliases: []string{"ADA", "CARDANO"}},
+	{ID: "DOGEUSDT", Class: ClassCrypto, Base: "DOGE", Quote: "USDT", Name: "Dogecoin", Aliases: []string{"DOGE", "DOGECOIN"}},
+	{ID: "AVAXUSDT", Class: ClassCrypto, Base: "AVAX", Quote: "USDT", Name: "Avalanche", Aliases: []string{"AVAX"}},
+	{ID: "LINKUSDT", Class: ClassCrypto, Base: "LINK", Quote: "USDT", Name: "Chainlink"},
+	{ID: "DOTUSDT", Class: ClassCrypto, Base: "DOT", Quote: "USDT", Name: "Polkadot"},
+	{ID: "LTCUSDT", Class: ClassCrypto, Base: "LTC", Quote: "USDT", Name: "Litecoin", Aliases: []string{"LTC"}},
+	{ID: "TRXUSDT", Class: ClassCrypto, Base: "TRX", Quote: "USDT", Name: "Tron"},
+	{ID: "TONUSDT", Class: ClassCrypto, Base: "TON", Quote: "USDT", Name: "Toncoin"},
+
+	{ID: "EURUSD", Class: ClassFX, Base: "EUR", Quote: "USD", Name: "Euro / US Dollar", Aliases: []string{"FIBER"}},
+	{ID: "GBPUSD", Class: ClassFX, Base: "GBP", Quote: "USD", Name: "British Pound / US Dollar", Aliases: []string{"CABLE"}},
+	{ID: "USDJPY", Class: ClassFX, Base: "USD", Quote: "JPY", Name: "US Dollar / Japanese Yen"},
+	{ID: "USDCHF", Class: ClassFX, Base: "USD", Quote: "CHF", Name: "US Dollar / Swiss Franc"},
+	{ID: "AUDUSD", Class: ClassFX, Base: "AUD", Quote: "USD", Name: "Australian Dollar / US Dollar"},
+	{ID: "USDCAD", Class: ClassFX, Base: "USD", Quote: "CAD", Name: "US Dollar / Canadian Dollar"},
+	{ID: "NZDUSD", Class: ClassFX, Base: "NZD", Quote: "USD", Name: "New Zealand Dollar / US Dollar"},
+	{ID: "USDCNY", Class: ClassFX, Base: "USD", Quote: "CNY", Name: "US Dollar / Chinese Yuan"},
+	{ID: "EURGBP", Class: ClassFX, Base: "EUR", Quote: "GBP", Name: "Euro / British Pound"},
+	{ID: "EURJPY", Class: ClassFX, Base: "EUR", Quote: "JPY", Name: "Euro / Japanese Yen"},
+
+	{ID: "XAUUSD", Class: ClassMetals, Base: "XAU", Quote: "USD", Name: "Gold", Aliases: []string{"GOLD"}, Tickers: map[string]string{"yahoo": "GC=F"}},
+	{ID: "XAGUSD", Class: ClassMetals, Base: "XAG", Quote: "USD", Name: "Silver", Aliases: []string{"SILVER"}, Tickers: map[string]string{"yahoo": "SI=F"}},
+	{ID: "XPTUSD", Class: ClassMetals, Base: "XPT", Quote: "USD", Name: "Platinum", Aliases: []string{"PLATINUM"}, Tickers: map[string]string{"yahoo": "PL=F"}},
+	{ID: "XPDUSD", Class: ClassMetals, Base: "XPD", Quote: "USD", Name: "Palladium", Aliases: []string{"PALLADIUM"}, Tickers: map[string]string{"yahoo": "PA=F"}},
+
+	{ID: "SPX", Class: ClassEquity, Name: "S&P 500", Aliases: []string{"SP500", "S&P500", "US500", "SPX500"}, Tickers: map[string]string{"yahoo": "^GSPC"}},
+	{ID: "NDX", Class: ClassEquity, Name: "Nasdaq 100", Aliases: []string{"NAS100", "US100", "NASDAQ100"}, Tickers: map[string]string{"yahoo": "^NDX"}},
+	{ID: "NASDAQ", Class: ClassEquity, Name: "Nasdaq Composite", Aliases: []string{"IXIC"}, Tickers: map[string]string{"yahoo": "^IXIC"}},
+	{ID: "DJI", Class: ClassEquity, Name: "Dow Jones Industrial Average", Aliases: []string{"DOW", "DJIA", "US30"}, Tickers: map[string]string{"yahoo": "^DJI"}},
+	{ID: "RUT", Class: ClassEquity, Name: "Russell 2000", Aliases: []string{"RUSSELL2000", "US2000"}, Tickers: map[string]string{"yahoo": "^RUT"}},
+	{ID: "VIX", Class: ClassEquity, Name: "CBOE Volatility Index", Tickers: map[string]string{"yahoo": "^VIX"}},
+	{ID: "DXY", Class: ClassEquity, Name: "US Dollar Index", Aliases: []string{"USDX"}, Tickers: map[string]string{"yahoo": "DX-Y.NYB"}},
+	{ID: "FTSE", Class: ClassEquity, Name: "FTSE 100", Aliases: []string{"UK100"}, Tickers: map[string]string{"yahoo": "^FTSE"}},
+	{ID: "DAX", Class: ClassEquity, Name: "DAX 40", Aliases: []string{"GER40", "DE40"}, Tickers: map[string]string{"yahoo": "^GDAXI"}},
+	{ID: "CAC", Class: ClassEquity, Name: "CAC 40", Aliases: []string{"FRA40", "CAC40"}, Tickers: map[string]string{"yahoo": "^FCHI"}},
+	{ID: "N225", Class: ClassEquity, Name: "Nikkei 225", Aliases: []string{"NIKKEI", "JP225"}, Tickers: map[string]string{"yahoo": "^N225"}},
+	{ID: "HSI", Class: ClassEquity, Name: "Hang Seng", Aliases: []string{"HK50", "HANGSENG"}, Tickers: map[string]string{"yahoo": "^HSI"}},
+	{ID: "US10Y", Class: ClassEquity, Name: "US 10-Year Treasury Yield", Aliases: []string{"TNX"}, Tickers: map[string]string{"yahoo": "^TNX"}},
+	{ID: "WTI", Class: ClassEquity, Name: "WTI Crude Oil", Aliases: []string{"USOIL", "CRUDE"}, Tickers: map[string]string{"yahoo": "CL=F"}},
+	{ID: "BRENT", Class: ClassEquity, Name: "Brent Crude Oil", Aliases: []string{"UKOIL"}, Tickers: map[string]string{"yahoo": "BZ=F"}},
+	{ID: "NATGAS", Class: ClassEquity, Name: "Natural Gas", Aliases: []string{"NGAS"}, Tickers: map[string]string{"yahoo": "NG=F"}},
+	{ID: "COPPER", Class: ClassEquity, Name: "Copper", Tickers: map[string]string{"yahoo": "HG=F"}},
+}
@@ -0,0 +1,138 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	for symbol, want := range map[string]string{
+		"btc/usdt":  "BTCUSDT",
+		"BTC-USDT":  "BTCUSDT",
+		"btc":       "BTCUSDT",
+		"XBTUSDT":   "BTCUSDT",
+		"ethbtc":    "ETHBTC",
+		"XAU":       "XAUUSD",
+		"XAUUSDT":   "XAUUSD",
+		"gold":      "XAUUSD",
+		"GC=F":      "XAUUSD",
+		"EURUSDT":   "EURUSD",
+		"EUR/USD":   "EURUSD",
+		"GBPUSDC":   "GBPUSD",
+		"^GSPC":     "SPX",
+		"S&P 500":   "SPX",
+		"us500":     "SPX",
+		"DX-Y.NYB":  "DXY",
+		"AAPL":      "AAPL",
+		"BRK-B":     "BRK-B",
+		"CL=F":      "WTI",
+		"USDTTRY":   "USDTTRY",
+		" ethusdt ": "ETHUSDT",
+	} {
+		if got := Canonical(symbol); got != want {
+			t.Errorf("Canonical(%q) = %s, want %s", symbol, got, want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	gold, err := Symbols.Resolve("xauusdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gold.ID != "XAUUSD" || gold.Class != ClassMetals || !gold.Listed || gold.Tickers["yahoo"] != "GC=F" ||
+		gold.Tickers["erapi"] != "XAU/USD" || gold.Tickers["binance"] != "" || gold.Tickers["frankfurter"] != "" {
+		t.Fatalf("unexpected gold: %+v", gold)
+	}
+
+	pair, err := Symbols.Resolve("PEPE/USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.ID != "PEPEUSDT" || pair.Listed || pair.Base != "PEPE" || pair.Quote != "USDT" ||
+		pair.Tickers["binance"] != "PEPEUSDT" || pair.Tickers["okx"] != "PEPE-USDT" || pair.Tickers["yahoo"] != "PEPE-USD" {
+		t.Fatalf("unlisted pairs should resolve from their structure: %+v", pair)
+	}
+
+	if spx, _ := Symbols.Resolve("SP500"); spx.ID != "SPX" || spx.Tickers["yahoo"] != "^GSPC" || spx.Tickers["okx"] != "" {
+		t.Fatalf("unexpected SPX: %+v", spx)
+	}
+
+	_, err = Symbols.Resolve("BTC$USDT")
+	var unknown *UnknownSymbolError
+	if !errors.As(err, &unknown) || !errors.Is(err, ErrNotFound) || len(unknown.Suggestions) == 0 || unknown.Suggestions[0] != "BTCUSDT" {
+		t.Fatalf("expected an unknown symbol error suggesting BTCUSDT, got %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	for symbol, want := range map[string]string{
+		"BTCUSTD": "BTCUSDT",
+		"GLOD":    "XAUUSD",
+		"EURUDS":  "EURUSD",
+		"NIKEI":   "N225",
+	} {
+		if got := Symbols.Suggest(symbol, 3); len(got) == 0 || got[0] != want {
+			t.Errorf("Suggest(%s) = %v, want %s first", symbol, got, want)
+		}
+	}
+	if got := Symbols.Suggest("QQQQQQQQ", 3); len(got) != 0 {
+		t.Errorf("nothing should be close to QQQQQQQQ: %v", got)
+	}
+}
+
+func TestRegistryAliases(t *testing.T) {
+	r := NewRegistry(builtinInstruments)
+	r.AddAlias("goldspot", "xau")
+	if got := r.Canonical("GOLDSPOT"); got != "XAUUSD" {
+		t.Fatalf("configured alias resolved to %s", got)
+	}
+	if Canonical("GOLDSPOT") != "GOLDSPOT" {
+		t.Fatal("aliases of one registry should not leak into another")
+	}
+	r.AddAlias("goldspot", "XAUUSD")
+	gold, _ := r.Resolve("XAU")
+	if n := strings.Count(strings.Join(gold.Aliases, ","), "GOLDSPOT"); n != 1 {
+		t.Fatalf("adding an alias again should not duplicate it: %v", gold.Aliases)
+	}
+	r.AddAlias("goldspot", "XAG")
+	gold, _ = r.Resolve("XAU")
+	silver, _ := r.Resolve("XAG")
+	if r.Canonical("goldspot") != "XAGUSD" || slices.Contains(gold.Aliases, "GOLDSPOT") || !slices.Contains(silver.Aliases, "GOLDSPOT") {
+		t.Fatalf("a reassigned alias should move: gold %v, silver %v", gold.Aliases, silver.Aliases)
+	}
+	if len(r.Instruments(ClassMetals)) != 4 || r.Instruments("")[0].Class != ClassCrypto {
+		t.Fatalf("unexpected instrument listing: %+v", r.Instruments(ClassMetals))
+	}
+}
+
+func TestRouterCanonicalSymbols(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/v8/finance/chart/GC=F": `{"chart":{"result":[{"meta":{"symbol":"GC=F","regularMarketPrice":2650.5,"regularMarketTime":1736942400,"chartPreviousClose":2640}}]}}`,
+	})
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassMetals: {NewYahoo(server.URL, http.DefaultClient)},
+		ClassEquity: {NewYahoo(server.URL, http.DefaultClient)},
+	})
+	ctx := context.Background()
+
+	ticker, err := router.Ticker(ctx, "XAUUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Symbol != "XAUUSD" || ticker.Price != 2650.5 {
+		t.Fatalf("expected the canonical id in the result: %+v", ticker)
+	}
+	if len(*queries) != 1 || !strings.HasPrefix((*queries)[0], "/v8/finance/chart/GC=F") {
+		t.Fatalf("expected Yahoo's gold ticker, got %v", *queries)
+	}
+
+	_, err = router.Ticker(ctx, "BTCUSTD")
+	if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "did you mean BTCUSDT") {
+		t.Fatalf("expected a suggestion for a typo, got %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// factories builds the sources by config name.
+var factories = map[string]func(baseURL string, client *http.Client) MarketDataSource{
+	"binance":     func(u string, c *http.Client) MarketDataSource { return NewBinance(u, c) },
+	"okx":         func(u string, c *http.Client) MarketDataSource { return NewOKX(u, c) },
+	"yahoo":       func(u string, c *http.Client) MarketDataSource { return NewYahoo(u, c) },
+	"erapi":       func(u string, c *http.Client) MarketDataSource { return NewERAPI(u, c) },
+	"frankfurter": func(u string, c *http.Client) MarketDataSource { return NewFrankfurter(u, c) },
+}
+
+// DefaultRoutes are the source orders used for classes the config leaves
+// empty.
+var DefaultRoutes = map[AssetClass][]string{
+	ClassCrypto: {"binance", "okx", "yahoo"},
+	ClassFX:     {"erapi", "frankfurter", "yahoo"},
+	ClassMetals: {"erapi", "yahoo"},
+	ClassEquity: {"yahoo"},
+
+	ClassDerivatives: {"binance", "okx"},
+}
+
+// Router sends each request to the sources of the symbol's asset class in
+// order and returns the first answer, tagged with the source that gave it.
+// With a cache set, fresh answers are reused.
+type Router struct {
+	routes map[AssetClass][]MarketDataSource
+	cache  *Cache
+}
+
+func NewRouter(routes map[AssetClass][]MarketDataSource) *Router {
+	return &Router{routes: routes}
+}
+
+// NewRouterFromConfig builds the configured sources, sending their requests
+// through transport (http.DefaultTransport when nil), e.g. a rate limiter.
+// Unknown source names are logged and skipped.
+func NewRouterFromConfig(cfg config.MarketDataConfig, transport http.RoundTripper) *Router {
+	client := &http.Client{Timeout: 15 * time.Second, Transport: transport}
+	built := make(map[string]MarketDataSource)
+	source := func(name string) MarketDataSource {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if src, ok := built[name]; ok {
+			return src
+		}
+		factory, ok := factories[name]
+		if !ok {
+			logger.WarnCF("market", "Unknown market data source, skipping",
+				map[string]interface{}{
+					"source": name,
+				})
+			return nil
+		}
+		built[name] = factory(cfg.BaseURLs[name], client)
+		if b, ok := built[name].(*Binance); ok && cfg.BaseURLs["binance_futures"] != "" {
+			b.SetFuturesURL(cfg.BaseURLs["binance_futures"])
+		}
+		return built[name]
+	}
+
+	configured := map[AssetClass][]string{
+		ClassCrypto: cfg.Crypto,
+		ClassFX:     cfg.FX,
+		ClassMetals: cfg.Metals,
+		ClassEquity: cfg.Equity,
+
+		ClassDerivatives: cfg.Derivatives,
+	}
+
+	routes := make(map[AssetClass][]MarketDataSource)
+	for _, class := range append(Classes, ClassDerivatives) {
+		names := configured[class]
+		if len(names) == 0 {
+			names = DefaultRoutes[class]
+		}
+		for _, name := range names {
+			if src := source(name); src != nil {
+				routes[class] = append(routes[class], src)
+			}
+		}
+	}
+	return NewRouter(routes)
+}
+
+// SetCache puts a cache in front of the sources; nil disables caching.
+func (r *Router) SetCache(c *Cache) {
+	r.cache = c
+}
+
+// SourceNames lists the names NewRouterFromConfig accepts.
+func SourceNames() []string {
+	return []string{"binance", "okx", "yahoo", "erapi", "frankfurter"}
+}
+
+// Routes returns the source names tried for a class, in order.
+func (r *Router) Routes(class AssetClass) []string {
+	names := make([]string, 0, len(r.routes[class]))
+	for _, src := range r.routes[class] {
+		names = append(names, src.Name())
+	}
+	return names
+}
+
+func (r *Router) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	symbol = Canonical(symbol)
+	result, info, err := cached(ctx, r.cache, "ticker:"+symbol, 0, r.cache.ttl("ticker", ""), func() (*Ticker, error) {
+		var result *Ticker
+		err := r.try(ctx, Classify(symbol), "ticker", symbol, func(src MarketDataSource) error {
+			t, err := src.Ticker(ctx, symbol)
+			if err == nil {
+				t.Source = src.Name()
+				result = t
+			}
+			return err
+		})
+		return result, err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+// TickerResult is one symbol's outcome in Tickers.
+type TickerResult struct {
+	Symbol string
+	Ticker *Ticker
+	Err    error
+}
+
+// Tickers fetches several symbols concurrently, each with its own fallback.
+// Results are in input order.
+func (r *Router) Tickers(ctx context.Context, symbols []string) []TickerResult {
+	results := make([]TickerResult, len(symbols))
+	var wg sync.WaitGroup
+	for i, symbol := range symbols {
+		wg.Add(1)
+		go func(i int, symbol string) {
+			defer wg.Done()
+			t, err := r.Ticker(ctx, symbol)
+			results[i] = TickerResult{Symbol: Canonical(symbol), Ticker: t, Err: err}
+		}(i, symbol)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Router) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	symbol = Canonical(symbol)
+	key := "candles:" + symbol + ":" + interval
+	result, info, err := cached(ctx, r.cache, key, limit, r.cache.ttl("candles", interval), func() (*CandleSeries, error) {
+		var result *CandleSeries
+		err := r.try(ctx, Classify(symbol), "candles", symbol, func(src MarketDataSource) error {
+			c, err := src.Candles(ctx, symbol, interval, limit)
+			if err == nil {
+				c.Source = src.Name()
+				result = c
+			}
+			return err
+		})
+		return result, err
+	}, func(c *CandleSeries) {
+		// An entry fetched for more candles serves smaller requests
+		if len(c.Candles) > limit {
+			c.Candles = c.Candles[len(c.Candles)-limit:]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+func (r *Router) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	symbol = Canonical(symbol)
+	key := "orderbook:" + symbol
+	result, info, err := cached(ctx, r.cache, key, limit, r.cache.ttl("orderbook", ""), func() (*OrderBook, error) {
+		var result *OrderBook
+		err := r.try(ctx, Classify(symbol), "orderbook", symbol, func(src MarketDataSource) error {
+			ob, err := src.OrderBook(ctx, symbol, limit)
+			if err == nil {
+				ob.Source = src.Name()
+				result = ob
+			}
+			return err
+		})
+		return result, err
+	}, func(ob *OrderBook) {
+		ob.Bids = ob.Bids[:min(limit, len(ob.Bids))]
+		ob.Asks = ob.Asks[:min(limit, len(ob.Asks))]
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+// FX returns the exchange rates of a base currency from the forex sources.
+func (r *Router) FX(ctx context.Context, base string) (*FXRates, error) {
+	base = upper(base)
+	result, info, err := cached(ctx, r.cache, "fx:"+base, 0, r.cache.ttl("fx", ""), func() (*FXRates, error) {
+		var result *FXRates
+		err := r.try(ctx, ClassFX, "forex", base, func(src MarketDataSource) error {
+			rates, err := src.FX(ctx, base)
+			if err == nil {
+				rates.Source = src.Name()
+				result = rates
+			}
+			return err
+		})
+		return result, err
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = info
+	return result, nil
+}
+
+// try calls fn with each source of class until one succeeds. Sources that
+// don't support the request are skipped silently; if every source that
+// tried didn't know the symbol, the error wraps ErrNotFound.
+func (r *Router) try(ctx context.Context, class AssetClass, op, symbol string, fn func(MarketDataSource) error) error {
+	var failures []string
+	allNotFound := true
+	for _, src := range r.routes[class] {
+		err := fn(src)
+		if err == nil {
+			if len(failures) > 0 {
+				logger.InfoCF("market", "Used fallback market data source",
+					map[string]interface{}{
+						"op":     op,
+						"symbol": symbol,
+						"source": src.Name(),
+						"failed": strings.Join(failures, "; "),
+					})
+			}
+			return nil
+		}
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, ErrNotFound) {
+			allNotFound = false
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", src.Name(), err))
+	}
+
+	switch {
+	case len(failures) == 0:
+		return fmt.Errorf("no %s source supports %s for %s: %w", class, op, symbol, ErrUnsupported)
+	case allNotFound:
+		return fmt.Errorf("%w %s (%s)%s", ErrNotFound, symbol, strings.Join(failures, "; "), didYouMean(Symbols.Suggest(symbol, 3)))
+	default:
+		return fmt.Errorf("all %s sources failed (%s)", class, strings.Join(failures, "; "))
+	}
+}
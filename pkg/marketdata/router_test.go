@@ -0,0 +1,98 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRouterFallsBackAndAttributes(t *testing.T) {
+	blocked, _ := stub(t, map[string]string{
+		"/api/v3/ticker/24hr": `451 {"code":0,"msg":"Service unavailable from a restricted location"}`,
+	})
+	okx, _ := stub(t, map[string]string{
+		"/api/v5/market/ticker": `{"code":"0","data":[{"last":"110","open24h":"100"}]}`,
+	})
+	yahoo, _ := stub(t, map[string]string{
+		"/v8/finance/chart/AAPL": `{"chart":{"result":[{"meta":{"symbol":"AAPL","regularMarketPrice":200,"previousClose":190}}]}}`,
+	})
+
+	router := NewRouterFromConfig(config.MarketDataConfig{
+		Crypto: []string{"binance", "okx"},
+		Equity: []string{"binance", "yahoo"},
+		BaseURLs: map[string]string{
+			"binance": blocked.URL,
+			"okx":     okx.URL,
+			"yahoo":   yahoo.URL,
+		},
+	}, nil)
+	ctx := context.Background()
+
+	ticker, err := router.Ticker(ctx, "btc/usdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Source != "okx" || ticker.Symbol != "BTCUSDT" || ticker.Price != 110 {
+		t.Fatalf("unexpected ticker: %+v", ticker)
+	}
+
+	// Binance doesn't serve equities, so it's skipped without an error
+	ticker, err = router.Ticker(ctx, "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Source != "yahoo" || ticker.Change != 10 {
+		t.Fatalf("unexpected ticker: %+v", ticker)
+	}
+
+	results := router.Tickers(ctx, []string{"AAPL", "BTCUSDT"})
+	if results[0].Ticker.Source != "yahoo" || results[1].Ticker.Source != "okx" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	blocked, _ := stub(t, map[string]string{
+		"/api/v3/ticker/24hr": `451 restricted`,
+	})
+	unknown, _ := stub(t, map[string]string{
+		"/api/v5/market/ticker": `{"code":"51001","msg":"Instrument ID does not exist","data":[]}`,
+	})
+	router := NewRouter(map[AssetClass][]MarketDataSource{
+		ClassCrypto: {NewBinance(blocked.URL, http.DefaultClient), NewOKX(unknown.URL, http.DefaultClient)},
+	})
+	ctx := context.Background()
+
+	_, err := router.Ticker(ctx, "FOOUSDT")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("a failing source means the symbol may exist, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "binance: HTTP 451") || !strings.Contains(err.Error(), "okx:") {
+		t.Fatalf("error should list each source: %v", err)
+	}
+
+	router = NewRouter(map[AssetClass][]MarketDataSource{
+		ClassCrypto: {NewOKX(unknown.URL, http.DefaultClient)},
+	})
+	if _, err := router.Ticker(ctx, "FOOUSDT"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := router.OrderBook(ctx, "AAPL", 10); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported with no equity sources, got %v", err)
+	}
+}
+
+func TestRouterDefaults(t *testing.T) {
+	router := NewRouterFromConfig(config.MarketDataConfig{Equity: []string{"nope", "yahoo"}}, nil)
+	if got := strings.Join(router.Routes(ClassCrypto), ","); got != "binance,okx,yahoo" {
+		t.Fatalf("crypto routes = %s", got)
+	}
+	if got := strings.Join(router.Routes(ClassEquity), ","); got != "yahoo" {
+		t.Fatalf("unknown sources should be skipped, got %s", got)
+	}
+}
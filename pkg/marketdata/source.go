@@ -0,0 +1,250 @@
+// Package marketdata fetches quotes, candles, order books and exchange rates
+// from free public APIs. Each API is a MarketDataSource; a Router tries the
+// sources configured for a symbol's asset class in order, so a blocked or
+// failing exchange falls through to the next one.
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned when a source doesn't offer an operation or
+	// asset class. The router skips such sources without reporting them.
+	ErrUnsupported = errors.New("not supported by this source")
+
+	// ErrNotFound is returned when a source doesn't know the symbol.
+	ErrNotFound = errors.New("unknown symbol")
+)
+
+// MarketDataSource is one market data API.
+type MarketDataSource interface {
+	Name() string
+	Ticker(ctx context.Context, symbol string) (*Ticker, error)
+	Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error)
+	OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error)
+	FX(ctx context.Context, base string) (*FXRates, error)
+}
+
+// HistorySource is a source that can page back through candle history,
+// which the History store uses for backfills.
+type HistorySource interface {
+	MarketDataSource
+	// CandlesBefore returns symbol's candles that open at or after start and
+	// before end, oldest first. When the range holds more than one page, the
+	// page closest to end is returned.
+	CandlesBefore(ctx context.Context, symbol, interval string, start, end time.Time) (*CandleSeries, error)
+}
+
+// Ticker is a symbol's latest price and 24h (or session) statistics. Fields a
+// source doesn't provide are zero.
+type Ticker struct {
+	Symbol      string     `json:"symbol"`
+	Price       float64    `json:"price"`
+	Change      float64    `json:"price_change"`
+	ChangePct   float64    `json:"price_change_pct"`
+	Open        float64    `json:"open_price,omitempty"`
+	High        float64    `json:"high_24h,omitempty"`
+	Low         float64    `json:"low_24h,omitempty"`
+	Volume      float64    `json:"volume_24h,omitempty"`
+	QuoteVolume float64    `json:"quote_volume_24h,omitempty"`
+	Time        time.Time  `json:"timestamp"`
+	Source      string     `json:"source"`
+	Cache       *CacheInfo `json:"cache,omitempty"`
+}
+
+// Candle is one OHLCV bar, keyed by its open time.
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
+}
+
+// CandleSeries is a run of candles, oldest first.
+type CandleSeries struct {
+	Symbol   string     `json:"symbol"`
+	Interval string     `json:"interval"`
+	Candles  []Candle   `json:"candles"`
+	Source   string     `json:"source"`
+	Cache    *CacheInfo `json:"cache,omitempty"`
+}
+
+// Level is one price level of an order book.
+type Level struct {
+	Price float64 `json:"price"`
+	Qty   float64 `json:"qty"`
+}
+
+// OrderBook is a depth snapshot, best prices first.
+type OrderBook struct {
+	Symbol string     `json:"symbol"`
+	Bids   []Level    `json:"bids"`
+	Asks   []Level    `json:"asks"`
+	Time   time.Time  `json:"timestamp"`
+	Source string     `json:"source"`
+	Cache  *CacheInfo `json:"cache,omitempty"`
+}
+
+// FXRates are the units of each currency one unit of Base buys.
+type FXRates struct {
+	Base    string             `json:"base"`
+	Rates   map[string]float64 `json:"rates"`
+	Updated time.Time          `json:"last_updated"`
+	Source  string             `json:"source"`
+	Cache   *CacheInfo         `json:"cache,omitempty"`
+}
+
+// HTTPError is a non-200 response from a source.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	body := e.Body
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, body)
+}
+
+const userAgent = "Mozilla/5.0 (compatible; picoclaw/1.0)"
+
+// getJSON fetches url and decodes the JSON response into v.
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// number reads a JSON number or numeric string.
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	}
+	return 0
+}
+
+// levels converts [[price, qty, ...], ...] arrays.
+func levels(raw [][]interface{}) []Level {
+	out := make([]Level, 0, len(raw))
+	for _, l := range raw {
+		if len(l) < 2 {
+			continue
+		}
+		out = append(out, Level{Price: number(l[0]), Qty: number(l[1])})
+	}
+	return out
+}
+
+// klines converts [[openTimeMs, open, high, low, close, volume, ...], ...]
+// rows as returned by Binance and OKX.
+func klines(raw [][]interface{}) []Candle {
+	candles := make([]Candle, 0, len(raw))
+	for _, k := range raw {
+		if len(k) < 6 {
+			continue
+		}
+		candles = append(candles, Candle{
+			Time:   time.UnixMilli(int64(number(k[0]))).UTC(),
+			Open:   number(k[1]),
+			High:   number(k[2]),
+			Low:    number(k[3]),
+			Close:  number(k[4]),
+			Volume: number(k[5]),
+		})
+	}
+	return candles
+}
+
+// sortCandles orders candles oldest first.
+func sortCandles(candles []Candle) {
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+}
+
+// since drops the candles of a sorted run that open before start.
+func since(candles []Candle, start time.Time) []Candle {
+	i := sort.Search(len(candles), func(i int) bool {
+		return !candles[i].Time.Before(start)
+	})
+	return candles[i:]
+}
+
+// intervalDuration parses candle intervals such as "15m", "4h", "1d", "1w".
+func intervalDuration(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	switch interval[len(interval)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid interval %q", interval)
+}
+
+func changePct(price, prev float64) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return (price - prev) / prev * 100
+}
+
+func nowUTC() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func upper(s string) string {
+	return strings.ToUpper(strings.TrimSpace(s))
+}
@@ -0,0 +1,256 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stub serves canned bodies by request path and records the queries.
+func stub(t *testing.T, routes map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RequestURI())
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if status, rest, found := cutStatus(body); found {
+			w.WriteHeader(status)
+			body = rest
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries
+}
+
+// cutStatus reads an optional "451 " status prefix from a canned body.
+func cutStatus(body string) (int, string, bool) {
+	var status int
+	if n, _ := fmt.Sscanf(body, "%d ", &status); n == 1 && status >= 100 {
+		return status, body[4:], true
+	}
+	return 0, body, false
+}
+
+func TestClassify(t *testing.T) {
+	for symbol, want := range map[string]AssetClass{
+		"BTCUSDT": ClassCrypto,
+		"ETHBTC":  ClassCrypto,
+		"BTCUSD":  ClassCrypto,
+		"EURUSD":  ClassFX,
+		"USDJPY":  ClassFX,
+		"XAU":     ClassMetals,
+		"XAUUSD":  ClassMetals,
+		"XAUUSDT": ClassMetals,
+		"AAPL":    ClassEquity,
+		"^GSPC":   ClassEquity,
+		"SPX":     ClassEquity,
+		"GC=F":    ClassEquity,
+	} {
+		if got := Classify(NormalizeSymbol(symbol)); got != want {
+			t.Errorf("Classify(%s) = %s, want %s", symbol, got, want)
+		}
+	}
+	if NormalizeSymbol(" btc/usdt ") != "BTCUSDT" {
+		t.Error("NormalizeSymbol should upper-case and drop the separator")
+	}
+}
+
+func TestBinance(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/api/v3/ticker/24hr": `{"symbol":"BTCUSDT","lastPrice":"97000.5","priceChange":"-500","priceChangePercent":"-0.51","openPrice":"97500.5","highPrice":"98000","lowPrice":"96000","volume":"1200","quoteVolume":"116400000","closeTime":1736942400000}`,
+		"/api/v3/klines":      `[[1736935200000,"1","2","0.5","1.5","10",0],[1736938800000,"1.5","3","1","2.5","20",0]]`,
+		"/api/v3/depth":       `{"bids":[["96999","0.5"]],"asks":[["97001","1.25"]]}`,
+	})
+	b := NewBinance(server.URL, http.DefaultClient)
+	ctx := context.Background()
+
+	ticker, err := b.Ticker(ctx, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Price != 97000.5 || ticker.ChangePct != -0.51 || ticker.QuoteVolume != 116400000 ||
+		!ticker.Time.Equal(time.UnixMilli(1736942400000)) {
+		t.Fatalf("unexpected ticker: %+v", ticker)
+	}
+
+	series, err := b.Candles(ctx, "BTCUSDT", "1h", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Candles) != 2 || series.Candles[1].Close != 2.5 || series.Candles[1].Volume != 20 {
+		t.Fatalf("unexpected candles: %+v", series.Candles)
+	}
+
+	book, err := b.OrderBook(ctx, "BTCUSDT", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Bids[0] != (Level{96999, 0.5}) || book.Asks[0] != (Level{97001, 1.25}) {
+		t.Fatalf("unexpected book: %+v", book)
+	}
+	if (*queries)[1] != "/api/v3/klines?interval=1h&limit=2&symbol=BTCUSDT" {
+		t.Fatalf("unexpected query %s", (*queries)[1])
+	}
+
+	if _, err := b.Ticker(ctx, "AAPL"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("equities should be unsupported, got %v", err)
+	}
+}
+
+func TestBinanceInvalidSymbol(t *testing.T) {
+	server, _ := stub(t, map[string]string{
+		"/api/v3/ticker/24hr": `400 {"code":-1121,"msg":"Invalid symbol."}`,
+	})
+	_, err := NewBinance(server.URL, http.DefaultClient).Ticker(context.Background(), "FOOUSDT")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOKX(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/api/v5/market/ticker":          `{"code":"0","msg":"","data":[{"instId":"BTC-USDT","last":"110","open24h":"100","high24h":"120","low24h":"90","vol24h":"5","volCcy24h":"550","ts":"1736942400000"}]}`,
+		"/api/v5/market/candles":         `{"code":"0","msg":"","data":[["1736938800000","2","3","1","2.5","20"],["1736935200000","1","2","0.5","1.5","10"]]}`,
+		"/api/v5/market/history-candles": `{"code":"0","msg":"","data":[["1736938800000","2","3","1","2.5","20"],["1736935200000","1","2","0.5","1.5","10"]]}`,
+	})
+	o := NewOKX(server.URL, http.DefaultClient)
+	ctx := context.Background()
+
+	ticker, err := o.Ticker(ctx, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Price != 110 || ticker.Change != 10 || ticker.ChangePct != 10 {
+		t.Fatalf("unexpected ticker: %+v", ticker)
+	}
+
+	series, err := o.Candles(ctx, "BTCUSDT", "4h", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !series.Candles[0].Time.Before(series.Candles[1].Time) || series.Candles[1].Close != 2.5 {
+		t.Fatalf("candles should be oldest first: %+v", series.Candles)
+	}
+	if (*queries)[0] != "/api/v5/market/ticker?instId=BTC-USDT" || (*queries)[1] != "/api/v5/market/candles?bar=4H&instId=BTC-USDT&limit=2" {
+		t.Fatalf("unexpected queries: %v", *queries)
+	}
+
+	// History pages back from end and drops candles before start
+	start, end := time.UnixMilli(1736938800000), time.UnixMilli(1736942400000)
+	series, err = o.CandlesBefore(ctx, "BTCUSDT", "1h", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Candles) != 1 || series.Candles[0].Close != 2.5 {
+		t.Fatalf("unexpected history page: %+v", series.Candles)
+	}
+	if (*queries)[2] != "/api/v5/market/history-candles?after=1736942400000&bar=1H&before=1736938799999&instId=BTC-USDT&limit=100" {
+		t.Fatalf("unexpected query: %s", (*queries)[2])
+	}
+
+	server, _ = stub(t, map[string]string{
+		"/api/v5/market/ticker": `{"code":"51001","msg":"Instrument ID does not exist","data":[]}`,
+	})
+	if _, err := NewOKX(server.URL, http.DefaultClient).Ticker(ctx, "FOOUSDT"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestYahoo(t *testing.T) {
+	server, queries := stub(t, map[string]string{
+		"/v8/finance/chart/^GSPC": `{"chart":{"result":[{
+			"meta":{"symbol":"^GSPC","regularMarketPrice":5100,"regularMarketTime":1736971200,"regularMarketDayHigh":5120,"regularMarketDayLow":5050,"regularMarketVolume":1000},
+			"timestamp":[1736726400,1736812800,1736899200],
+			"indicators":{"quote":[{"open":[4900,4950,5010],"high":[4990,5010,5120],"low":[4890,4940,5050],"close":[4950,5000,null],"volume":[1,2,3]}]}
+		}],"error":null}}`,
+		"/v8/finance/chart/EURUSD=X": `{"chart":{"result":[{
+			"meta":{"symbol":"EURUSD=X","regularMarketPrice":1.09},
+			"timestamp":[1736899200,1736902800,1736906400,1736910000,1736913600],
+			"indicators":{"quote":[{"open":[1,2,3,4,5],"high":[1,2,3,9,5],"low":[1,2,3,0.5,5],"close":[1,2,3,4,5],"volume":[1,1,1,1,1]}]}
+		}],"error":null}}`,
+	})
+	y := NewYahoo(server.URL, http.DefaultClient)
+	ctx := context.Background()
+
+	ticker, err := y.Ticker(ctx, "SPX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last bar has no close yet, so the previous close is the one before it
+	if ticker.Price != 5100 || ticker.Change != 100 || ticker.ChangePct != 2 || ticker.High != 5120 {
+		t.Fatalf("unexpected ticker: %+v", ticker)
+	}
+
+	// 4h bars are built from hourly ones
+	series, err := y.Candles(ctx, "EURUSD", "4h", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Candles) != 2 {
+		t.Fatalf("expected 2 resampled bars, got %+v", series.Candles)
+	}
+	bar := series.Candles[0]
+	if bar.Open != 1 || bar.High != 9 || bar.Low != 0.5 || bar.Close != 4 || bar.Volume != 4 {
+		t.Fatalf("unexpected 4h bar: %+v", bar)
+	}
+	if len(*queries) != 2 || (*queries)[1][:len("/v8/finance/chart/EURUSD=X?interval=60m")] != "/v8/finance/chart/EURUSD=X?interval=60m" {
+		t.Fatalf("unexpected queries: %v", *queries)
+	}
+
+	if _, err := y.Ticker(ctx, "NOPE"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := y.OrderBook(ctx, "SPX", 10); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestFXSources(t *testing.T) {
+	server, _ := stub(t, map[string]string{
+		"/v6/latest/USD": `{"result":"success","base_code":"USD","time_last_update_unix":1736899200,"rates":{"EUR":0.8,"XAU":0.0005}}`,
+		"/v6/latest/XYZ": `{"result":"error","error-type":"unsupported-code"}`,
+		"/latest":        `{"amount":1.0,"base":"USD","date":"2025-01-15","rates":{"EUR":0.8}}`,
+	})
+	ctx := context.Background()
+
+	erapi := NewERAPI(server.URL, http.DefaultClient)
+	gold, err := erapi.Ticker(ctx, "XAUUSD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gold.Price != 2000 {
+		t.Fatalf("gold price = %v, want 2000", gold.Price)
+	}
+	if _, err := erapi.FX(ctx, "XYZ"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	frankfurter := NewFrankfurter(server.URL, http.DefaultClient)
+	rates, err := frankfurter.FX(ctx, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates.Rates["EUR"] != 0.8 || rates.Updated.Format("2006-01-02") != "2025-01-15" {
+		t.Fatalf("unexpected rates: %+v", rates)
+	}
+	eur, err := frankfurter.Ticker(ctx, "EURUSD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eur.Price != 1.25 {
+		t.Fatalf("EURUSD = %v, want 1.25", eur.Price)
+	}
+	// ECB rates have no metals
+	if _, err := frankfurter.Ticker(ctx, "XAUUSD"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
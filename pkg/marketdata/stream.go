@@ -0,0 +1,508 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// DefaultStreamURL is Binance's public market data websocket.
+	DefaultStreamURL = "wss://stream.binance.com:9443"
+
+	// streamReadTimeout drops a connection that has gone quiet; Binance
+	// pings every 20 seconds and tickers update every second.
+	streamReadTimeout = 2 * time.Minute
+
+	// streamStaleAfter flags quotes that haven't updated for this long.
+	streamStaleAfter = time.Minute
+
+	// streamHealthy resets the reconnect backoff once a connection has
+	// stayed up this long.
+	streamHealthy = time.Minute
+)
+
+// Tick is one streamed price update.
+type Tick struct {
+	Time  time.Time `json:"time"`
+	Price float64   `json:"price"`
+}
+
+// WindowStats summarises the ticks of the last Minutes.
+type WindowStats struct {
+	Minutes   float64 `json:"minutes"` // Span actually covered, shorter right after subscribing
+	Ticks     int     `json:"ticks"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	ChangePct float64 `json:"change_pct"`
+}
+
+// Quote is the live state of a streamed symbol.
+type Quote struct {
+	Symbol       string            `json:"symbol"`
+	Price        float64           `json:"price"`
+	ChangePct24h float64           `json:"change_pct_24h"`
+	QuoteVolume  float64           `json:"quote_volume_24h"`
+	Updated      time.Time         `json:"updated"`
+	AgeSeconds   float64           `json:"age_seconds"`
+	Stale        bool              `json:"stale,omitempty"` // Not updated recently or disconnected
+	Window       WindowStats       `json:"window"`
+	Klines       map[string]Candle `json:"klines,omitempty"` // Current (unclosed) candle per interval
+	Source       string            `json:"source"`
+}
+
+// StreamStatus describes the websocket connection.
+type StreamStatus struct {
+	URL         string     `json:"url"`
+	Connected   bool       `json:"connected"`
+	Since       *time.Time `json:"since,omitempty"`
+	Reconnects  int        `json:"reconnects"`
+	LastError   string     `json:"last_error,omitempty"`
+	Symbols     []string   `json:"symbols"`
+	Intervals   []string   `json:"intervals"`
+	WindowMins  int        `json:"window_minutes"`
+	LastMessage *time.Time `json:"last_message,omitempty"`
+}
+
+type streamSeries struct {
+	ticks        []Tick // Oldest first, pruned to the window
+	changePct24h float64
+	quoteVolume  float64
+	klines       map[string]Candle
+	updated      time.Time
+}
+
+// Stream subscribes to Binance's miniTicker and kline websocket streams and
+// keeps a rolling window of prices per symbol in memory, so tools can read
+// live prices without polling. Run keeps the connection up, reconnecting
+// with exponential backoff.
+type Stream struct {
+	url        string
+	intervals  []string
+	window     time.Duration
+	maxBackoff time.Duration
+	dialer     *websocket.Dialer
+	now        func() time.Time
+
+	mu          sync.Mutex
+	series      map[string]*streamSeries
+	conn        *websocket.Conn
+	since       time.Time
+	lastMessage time.Time
+	reconnects  int
+	lastErr     string
+	requestID   int
+}
+
+// NewStream creates a stream for symbols; it connects once Run is called.
+func NewStream(url string, symbols, intervals []string, window, maxBackoff time.Duration) *Stream {
+	if url == "" {
+		url = DefaultStreamURL
+	}
+	if window <= 0 {
+		window = time.Hour
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Minute
+	}
+	s := &Stream{
+		url:        strings.TrimRight(url, "/"),
+		intervals:  intervals,
+		window:     window,
+		maxBackoff: maxBackoff,
+		dialer:     &websocket.Dialer{HandshakeTimeout: 10 * time.Second},
+		now:        time.Now,
+		series:     make(map[string]*streamSeries),
+	}
+	for _, symbol := range symbols {
+		s.series[Canonical(symbol)] = &streamSeries{klines: make(map[string]Candle)}
+	}
+	return s
+}
+
+// NewStreamFromConfig returns nil when streaming is disabled.
+func NewStreamFromConfig(cfg config.MarketStreamConfig) *Stream {
+	if !cfg.Enabled {
+		return nil
+	}
+	var intervals []string
+	for _, interval := range cfg.Intervals {
+		if _, err := intervalDuration(interval); err != nil {
+			logger.WarnCF("market", "Skipping invalid stream interval",
+				map[string]interface{}{
+					"interval": interval,
+				})
+			continue
+		}
+		intervals = append(intervals, interval)
+	}
+	return NewStream(cfg.URL, cfg.Symbols, intervals,
+		time.Duration(cfg.WindowMinutes)*time.Minute,
+		time.Duration(cfg.MaxBackoffSeconds)*time.Second)
+}
+
+// Run connects and keeps the stream running until ctx is cancelled.
+func (s *Stream) Run(ctx context.Context) {
+	attempt := 0
+	for ctx.Err() == nil {
+		started := s.now()
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if s.now().Sub(started) >= streamHealthy {
+			attempt = 0
+		}
+
+		backoff := min(time.Second<<min(attempt, 16), s.maxBackoff)
+		// Jitter keeps several gateways from reconnecting in lockstep
+		backoff += time.Duration(rand.Int63n(int64(backoff)/4 + 1))
+		attempt++
+
+		s.mu.Lock()
+		s.reconnects++
+		if err != nil {
+			s.lastErr = err.Error()
+		}
+		s.mu.Unlock()
+		logger.WarnCF("market", "Market stream disconnected, reconnecting",
+			map[string]interface{}{
+				"error":   fmt.Sprint(err),
+				"backoff": backoff.String(),
+			})
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// session runs one connection until it fails or ctx is cancelled.
+func (s *Stream) session(ctx context.Context) error {
+	conn, _, err := s.dialer.DialContext(ctx, s.url+"/stream", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	s.mu.Lock()
+	s.conn = conn
+	s.since = s.now()
+	err = s.subscribeLocked("SUBSCRIBE", s.symbolsLocked())
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+	logger.InfoCF("market", "Market stream connected",
+		map[string]interface{}{
+			"url":     s.url,
+			"symbols": len(s.Symbols()),
+		})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		s.handle(data)
+	}
+}
+
+// streamNames lists the Binance streams of symbols.
+func (s *Stream) streamNames(symbols []string) []string {
+	names := make([]string, 0, len(symbols)*(1+len(s.intervals)))
+	for _, symbol := range symbols {
+		lower := strings.ToLower(symbol)
+		names = append(names, lower+"@miniTicker")
+		for _, interval := range s.intervals {
+			names = append(names, lower+"@kline_"+interval)
+		}
+	}
+	return names
+}
+
+// subscribeLocked sends a SUBSCRIBE or UNSUBSCRIBE request on the live
+// connection, if any.
+func (s *Stream) subscribeLocked(method string, symbols []string) error {
+	if s.conn == nil || len(symbols) == 0 {
+		return nil
+	}
+	s.requestID++
+	s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return s.conn.WriteJSON(map[string]interface{}{
+		"method": method,
+		"params": s.streamNames(symbols),
+		"id":     s.requestID,
+	})
+}
+
+type streamEvent struct {
+	Event  string `json:"e"`
+	Time   int64  `json:"E"`
+	Symbol string `json:"s"`
+
+	// miniTicker
+	Close       string `json:"c"`
+	Open        string `json:"o"`
+	QuoteVolume string `json:"q"`
+
+	// kline
+	Kline *struct {
+		Start    int64  `json:"t"`
+		Interval string `json:"i"`
+		Open     string `json:"o"`
+		High     string `json:"h"`
+		Low      string `json:"l"`
+		Close    string `json:"c"`
+		Volume   string `json:"v"`
+	} `json:"k"`
+}
+
+// handle applies one message, either combined ({"stream":..,"data":..}) or raw.
+func (s *Stream) handle(data []byte) {
+	var envelope struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && len(envelope.Data) > 0 {
+		data = envelope.Data
+	}
+	var ev streamEvent
+	if err := json.Unmarshal(data, &ev); err != nil || ev.Symbol == "" {
+		// Subscription acknowledgements and anything else we don't use
+		return
+	}
+
+	at := s.now()
+	if ev.Time > 0 {
+		at = time.UnixMilli(ev.Time).UTC()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastMessage = s.now()
+	series, ok := s.series[upper(ev.Symbol)]
+	if !ok {
+		// Unsubscribed, or a late message for a removed symbol
+		return
+	}
+
+	switch ev.Event {
+	case "24hrMiniTicker":
+		price := number(ev.Close)
+		if price <= 0 {
+			return
+		}
+		series.ticks = append(series.ticks, Tick{Time: at, Price: price})
+		series.changePct24h = changePct(price, number(ev.Open))
+		series.quoteVolume = number(ev.QuoteVolume)
+		series.updated = at
+		s.pruneLocked(series, at)
+	case "kline":
+		if ev.Kline == nil {
+			return
+		}
+		series.klines[ev.Kline.Interval] = Candle{
+			Time:   time.UnixMilli(ev.Kline.Start).UTC(),
+			Open:   number(ev.Kline.Open),
+			High:   number(ev.Kline.High),
+			Low:    number(ev.Kline.Low),
+			Close:  number(ev.Kline.Close),
+			Volume: number(ev.Kline.Volume),
+		}
+	}
+}
+
+func (s *Stream) pruneLocked(series *streamSeries, now time.Time) {
+	cutoff := now.Add(-s.window)
+	i := sort.Search(len(series.ticks), func(i int) bool {
+		return !series.ticks[i].Time.Before(cutoff)
+	})
+	if i > 0 {
+		series.ticks = append(series.ticks[:0], series.ticks[i:]...)
+	}
+}
+
+// Subscribe adds crypto symbols to the stream.
+func (s *Stream) Subscribe(symbols ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var added []string
+	for _, symbol := range symbols {
+		symbol = Canonical(symbol)
+		if Classify(symbol) != ClassCrypto {
+			return fmt.Errorf("%s is not a crypto pair; only Binance symbols can be streamed", symbol)
+		}
+		if _, ok := s.series[symbol]; !ok {
+			s.series[symbol] = &streamSeries{klines: make(map[string]Candle)}
+			added = append(added, symbol)
+		}
+	}
+	return s.subscribeLocked("SUBSCRIBE", added)
+}
+
+// Unsubscribe removes symbols and drops their windows.
+func (s *Stream) Unsubscribe(symbols ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []string
+	for _, symbol := range symbols {
+		symbol = Canonical(symbol)
+		if _, ok := s.series[symbol]; ok {
+			delete(s.series, symbol)
+			removed = append(removed, symbol)
+		}
+	}
+	return s.subscribeLocked("UNSUBSCRIBE", removed)
+}
+
+// Symbols lists the subscribed symbols.
+func (s *Stream) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.symbolsLocked()
+}
+
+func (s *Stream) symbolsLocked() []string {
+	symbols := make([]string, 0, len(s.series))
+	for symbol := range s.series {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Quote returns the live state of symbol with window stats over the last
+// minutes (the whole window when 0). ok is false until the first update.
+func (s *Stream) Quote(symbol string, minutes int) (Quote, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbol = Canonical(symbol)
+	series, ok := s.series[symbol]
+	if !ok || len(series.ticks) == 0 {
+		return Quote{}, false
+	}
+
+	now := s.now()
+	last := series.ticks[len(series.ticks)-1]
+	q := Quote{
+		Symbol:       symbol,
+		Price:        last.Price,
+		ChangePct24h: series.changePct24h,
+		QuoteVolume:  series.quoteVolume,
+		Updated:      series.updated,
+		AgeSeconds:   now.Sub(series.updated).Seconds(),
+		Stale:        s.conn == nil || now.Sub(series.updated) > streamStaleAfter,
+		Window:       s.statsLocked(series, now, minutes),
+		Source:       "binance_ws",
+	}
+	if len(series.klines) > 0 {
+		q.Klines = make(map[string]Candle, len(series.klines))
+		for interval, candle := range series.klines {
+			q.Klines[interval] = candle
+		}
+	}
+	return q, true
+}
+
+func (s *Stream) statsLocked(series *streamSeries, now time.Time, minutes int) WindowStats {
+	ticks := series.ticks
+	if minutes > 0 {
+		cutoff := now.Add(-time.Duration(minutes) * time.Minute)
+		i := sort.Search(len(ticks), func(i int) bool {
+			return !ticks[i].Time.Before(cutoff)
+		})
+		ticks = ticks[i:]
+	}
+	if len(ticks) == 0 {
+		return WindowStats{}
+	}
+	stats := WindowStats{
+		Minutes: ticks[len(ticks)-1].Time.Sub(ticks[0].Time).Minutes(),
+		Ticks:   len(ticks),
+		Open:    ticks[0].Price,
+		High:    ticks[0].Price,
+		Low:     ticks[0].Price,
+	}
+	for _, t := range ticks[1:] {
+		stats.High = max(stats.High, t.Price)
+		stats.Low = min(stats.Low, t.Price)
+	}
+	stats.ChangePct = changePct(ticks[len(ticks)-1].Price, stats.Open)
+	return stats
+}
+
+// Ticks returns a copy of symbol's ticks since the given time.
+func (s *Stream) Ticks(symbol string, since time.Time) []Tick {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series, ok := s.series[Canonical(symbol)]
+	if !ok {
+		return nil
+	}
+	i := sort.Search(len(series.ticks), func(i int) bool {
+		return !series.ticks[i].Time.Before(since)
+	})
+	return append([]Tick(nil), series.ticks[i:]...)
+}
+
+// Status describes the connection and subscriptions.
+func (s *Stream) Status() StreamStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := StreamStatus{
+		URL:        s.url,
+		Connected:  s.conn != nil,
+		Reconnects: s.reconnects,
+		LastError:  s.lastErr,
+		Symbols:    s.symbolsLocked(),
+		Intervals:  s.intervals,
+		WindowMins: int(s.window / time.Minute),
+	}
+	if s.conn != nil {
+		since := s.since
+		status.Since = &since
+	}
+	if !s.lastMessage.IsZero() {
+		last := s.lastMessage
+		status.LastMessage = &last
+	}
+	return status
+}
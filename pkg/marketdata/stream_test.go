@@ -0,0 +1,215 @@
+package marketdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type subscription struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int      `json:"id"`
+}
+
+// streamStub is a websocket server that records subscription requests and
+// lets the test push messages to, or drop, the current connection.
+type streamStub struct {
+	server *httptest.Server
+	subs   chan subscription
+	conns  chan *websocket.Conn
+}
+
+func newStreamStub(t *testing.T) *streamStub {
+	t.Helper()
+	stub := &streamStub{
+		subs:  make(chan subscription, 16),
+		conns: make(chan *websocket.Conn, 4),
+	}
+	upgrader := websocket.Upgrader{}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stream" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		stub.conns <- conn
+		for {
+			var sub subscription
+			if err := conn.ReadJSON(&sub); err != nil {
+				return
+			}
+			stub.subs <- sub
+		}
+	}))
+	t.Cleanup(stub.server.Close)
+	return stub
+}
+
+func (s *streamStub) url() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+func (s *streamStub) nextConn(t *testing.T) *websocket.Conn {
+	t.Helper()
+	select {
+	case conn := <-s.conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+		return nil
+	}
+}
+
+func (s *streamStub) nextSub(t *testing.T) subscription {
+	t.Helper()
+	select {
+	case sub := <-s.subs:
+		return sub
+	case <-time.After(5 * time.Second):
+		t.Fatal("no subscription request")
+		return subscription{}
+	}
+}
+
+func miniTicker(symbol string, at time.Time, price float64) string {
+	return fmt.Sprintf(`{"stream":"%s@miniTicker","data":{"e":"24hrMiniTicker","E":%d,"s":"%s","c":"%g","o":"100","q":"5000000"}}`,
+		strings.ToLower(symbol), at.UnixMilli(), symbol, price)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamSubscribesAndTracksPrices(t *testing.T) {
+	stub := newStreamStub(t)
+	stream := NewStream(stub.url(), []string{"btc/usdt"}, []string{"1m"}, time.Hour, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	conn := stub.nextConn(t)
+	sub := stub.nextSub(t)
+	if sub.Method != "SUBSCRIBE" || strings.Join(sub.Params, ",") != "btcusdt@miniTicker,btcusdt@kline_1m" {
+		t.Fatalf("unexpected subscription: %+v", sub)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for i, price := range []float64{100, 104, 98, 102} {
+		conn.WriteMessage(websocket.TextMessage, []byte(miniTicker("BTCUSDT", now.Add(time.Duration(i-3)*time.Minute), price)))
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(
+		`{"stream":"btcusdt@kline_1m","data":{"e":"kline","E":%d,"s":"BTCUSDT","k":{"t":%d,"i":"1m","o":"101","h":"103","l":"100","c":"102","v":"12.5"}}}`,
+		now.UnixMilli(), now.Truncate(time.Minute).UnixMilli())))
+
+	waitFor(t, "kline", func() bool {
+		q, ok := stream.Quote("BTCUSDT", 0)
+		return ok && q.Klines["1m"].Volume == 12.5
+	})
+
+	quote, _ := stream.Quote("BTCUSDT", 0)
+	if quote.Price != 102 || quote.Stale || quote.Source != "binance_ws" || quote.ChangePct24h != 2 {
+		t.Fatalf("unexpected quote: %+v", quote)
+	}
+	if w := quote.Window; w.Ticks != 4 || w.Open != 100 || w.High != 104 || w.Low != 98 || w.ChangePct != 2 || w.Minutes != 3 {
+		t.Fatalf("unexpected window: %+v", w)
+	}
+	// Only the last two ticks fall in a 2 minute window
+	quote, _ = stream.Quote("BTCUSDT", 2)
+	if w := quote.Window; w.Ticks != 2 || w.Open != 98 || w.High != 102 {
+		t.Fatalf("unexpected 2 minute window: %+v", w)
+	}
+
+	// New symbols are subscribed on the live connection
+	if err := stream.Subscribe("ETHUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if sub := stub.nextSub(t); strings.Join(sub.Params, ",") != "ethusdt@miniTicker,ethusdt@kline_1m" {
+		t.Fatalf("unexpected subscription: %+v", sub)
+	}
+	if err := stream.Subscribe("AAPL"); err == nil {
+		t.Fatal("equities can't be streamed")
+	}
+	if err := stream.Unsubscribe("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if sub := stub.nextSub(t); sub.Method != "UNSUBSCRIBE" {
+		t.Fatalf("unexpected request: %+v", sub)
+	}
+	if _, ok := stream.Quote("BTCUSDT", 0); ok || strings.Join(stream.Symbols(), ",") != "ETHUSDT" {
+		t.Fatal("unsubscribed symbols should be dropped")
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	stub := newStreamStub(t)
+	stream := NewStream(stub.url(), []string{"ETHUSDT"}, nil, time.Hour, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	conn := stub.nextConn(t)
+	stub.nextSub(t)
+	stream.Subscribe("SOLUSDT")
+	stub.nextSub(t)
+	conn.Close()
+
+	// The new connection resubscribes everything
+	conn = stub.nextConn(t)
+	if sub := stub.nextSub(t); strings.Join(sub.Params, ",") != "ethusdt@miniTicker,solusdt@miniTicker" {
+		t.Fatalf("unexpected resubscription: %+v", sub)
+	}
+	conn.WriteMessage(websocket.TextMessage, []byte(miniTicker("SOLUSDT", time.Now(), 150)))
+	waitFor(t, "tick", func() bool {
+		_, ok := stream.Quote("SOLUSDT", 0)
+		return ok
+	})
+
+	status := stream.Status()
+	if !status.Connected || status.Reconnects != 1 || status.LastError == "" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	out, _ := json.Marshal(status)
+	if !strings.Contains(string(out), `"since"`) {
+		t.Fatalf("a connected stream should report since: %s", out)
+	}
+}
+
+func TestStreamWindowPruning(t *testing.T) {
+	stream := NewStream("", []string{"BTCUSDT"}, nil, 10*time.Minute, 0)
+	start := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	for i := 0; i <= 20; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		stream.now = func() time.Time { return at }
+		stream.handle([]byte(miniTicker("BTCUSDT", at, float64(100+i))))
+	}
+
+	if ticks := stream.Ticks("BTCUSDT", time.Time{}); len(ticks) != 11 || ticks[0].Price != 110 {
+		t.Fatalf("expected the last 10 minutes of ticks, got %d starting at %v", len(ticks), ticks[0])
+	}
+	// Raw (non-combined) payloads are understood too, other symbols ignored
+	stream.handle([]byte(`{"e":"24hrMiniTicker","E":0,"s":"BTCUSDT","c":"200","o":"100"}`))
+	stream.handle([]byte(`{"e":"24hrMiniTicker","s":"DOGEUSDT","c":"1"}`))
+	stream.handle([]byte(`{"result":null,"id":1}`))
+	quote, _ := stream.Quote("BTCUSDT", 0)
+	if quote.Price != 200 || !quote.Stale {
+		t.Fatalf("expected a stale quote at 200 while disconnected: %+v", quote)
+	}
+}
@@ -0,0 +1,98 @@
+package marketdata
+
+import "strings"
+
+// AssetClass groups symbols that the same sources can serve.
+type AssetClass string
+
+const (
+	ClassCrypto AssetClass = "crypto"
+	ClassFX     AssetClass = "fx"
+	ClassMetals AssetClass = "metals"
+	ClassEquity AssetClass = "equity" // Stocks, indices and futures
+
+	// ClassDerivatives routes perpetual futures requests for crypto pairs.
+	// It is not a class symbols are classified into.
+	ClassDerivatives AssetClass = "derivatives"
+)
+
+// Classes lists the asset classes in routing order.
+var Classes = []AssetClass{ClassCrypto, ClassFX, ClassMetals, ClassEquity}
+
+// cryptoQuotes are the quote assets of exchange pairs, with longer ones first so
+// "FDUSD" and "USDT" are matched before "USD".
+var cryptoQuotes = []string{"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "BTC", "ETH", "BNB", "USD", "EUR"}
+
+var metals = map[string]bool{"XAU": true, "XAG": true, "XPT": true, "XPD": true}
+
+var currencies = map[string]bool{
+	"USD": true, "EUR": true, "GBP": true, "JPY": true, "CHF": true, "AUD": true, "CAD": true,
+	"NZD": true, "CNY": true, "CNH": true, "HKD": true, "SGD": true, "SEK": true, "NOK": true,
+	"DKK": true, "PLN": true, "CZK": true, "HUF": true, "TRY": true, "RUB": true, "INR": true,
+	"BRL": true, "MXN": true, "ZAR": true, "KRW": true, "IDR": true, "THB": true, "SAR": true,
+	"AED": true, "ILS": true,
+}
+
+// NormalizeSymbol upper-cases a symbol and drops a pair separator, so
+// "btc/usdt" becomes "BTCUSDT".
+func NormalizeSymbol(symbol string) string {
+	return strings.ReplaceAll(upper(symbol), "/", "")
+}
+
+// Classify returns the asset class of a normalized symbol: exchange pairs
+// such as BTCUSDT are crypto, XAU/XAUUSD metals, six-letter currency pairs
+// such as EURUSD forex, and anything else (AAPL, ^GSPC, GC=F) equity. Forex
+// is checked before crypto, so EURUSD isn't read as an exchange pair.
+func Classify(symbol string) AssetClass {
+	if base, _, ok := metalPair(symbol); ok && metals[base] {
+		return ClassMetals
+	}
+	if len(symbol) == 6 && currencies[symbol[:3]] && currencies[symbol[3:]] {
+		return ClassFX
+	}
+	if _, _, ok := cryptoPair(symbol); ok {
+		return ClassCrypto
+	}
+	return ClassEquity
+}
+
+// cryptoPair splits an exchange pair into base and quote asset.
+func cryptoPair(symbol string) (base, quote string, ok bool) {
+	for _, q := range cryptoQuotes {
+		if b, found := strings.CutSuffix(symbol, q); found && len(b) >= 2 {
+			return b, q, true
+		}
+	}
+	return "", "", false
+}
+
+// metalPair splits "XAU", "XAUUSD" or "XAUUSDT" into metal and currency.
+func metalPair(symbol string) (base, quote string, ok bool) {
+	if len(symbol) < 3 || !metals[symbol[:3]] {
+		return "", "", false
+	}
+	switch rest := symbol[3:]; {
+	case rest == "":
+		return symbol, "USD", true
+	case rest == "USDT" || rest == "USDC":
+		return symbol[:3], "USD", true
+	case currencies[rest]:
+		return symbol[:3], rest, true
+	}
+	return "", "", false
+}
+
+// currencyPair splits forex and metal symbols into base and quote currency.
+// A lone currency is quoted in USD.
+func currencyPair(symbol string) (base, quote string, ok bool) {
+	if base, quote, ok := metalPair(symbol); ok {
+		return base, quote, true
+	}
+	switch {
+	case len(symbol) == 3 && currencies[symbol]:
+		return symbol, "USD", true
+	case len(symbol) == 6 && currencies[symbol[:3]] && currencies[symbol[3:]]:
+		return symbol[:3], symbol[3:], true
+	}
+	return "", "", false
+}
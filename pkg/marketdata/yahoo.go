@@ -0,0 +1,321 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Yahoo serves stocks, indices, futures, forex and crypto from the Yahoo
+// Finance chart API. It has no order books and only the rates of single
+// pairs.
+type Yahoo struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewYahoo(baseURL string, client *http.Client) *Yahoo {
+	if baseURL == "" {
+		baseURL = "https://query1.finance.yahoo.com"
+	}
+	return &Yahoo{baseURL: strings.TrimRight(baseURL, "/"), client: client}
+}
+
+func (y *Yahoo) Name() string {
+	return "yahoo"
+}
+
+// yahooSymbol translates a canonical ID into Yahoo's notation. Indices,
+// commodities and spot metals (quoted as front-month futures) use the
+// ticker registered for them.
+func yahooSymbol(symbol string) (string, error) {
+	switch Classify(symbol) {
+	case ClassCrypto:
+		base, quote, _ := cryptoPair(symbol)
+		if strings.HasPrefix(quote, "USD") || strings.HasSuffix(quote, "USD") {
+			quote = "USD"
+		}
+		return base + "-" + quote, nil
+	case ClassFX:
+		return symbol + "=X", nil
+	case ClassMetals:
+		base, quote, _ := metalPair(symbol)
+		if ticker := Symbols.ticker(base+quote, "yahoo"); ticker != "" {
+			return ticker, nil
+		}
+		return "", ErrUnsupported
+	}
+	if ticker := Symbols.ticker(symbol, "yahoo"); ticker != "" {
+		return ticker, nil
+	}
+	return symbol, nil
+}
+
+// yahooIntervals maps intervals to Yahoo's; 4h candles are built from 1h
+// ones since Yahoo has no such interval.
+var yahooIntervals = map[string]string{
+	"1m": "1m", "2m": "2m", "5m": "5m", "15m": "15m", "30m": "30m",
+	"1h": "60m", "4h": "60m", "1d": "1d", "1w": "1wk",
+}
+
+type yahooChart struct {
+	Chart struct {
+		Result []struct {
+			Meta struct {
+				Symbol             string  `json:"symbol"`
+				RegularMarketPrice float64 `json:"regularMarketPrice"`
+				RegularMarketTime  int64   `json:"regularMarketTime"`
+				PreviousClose      float64 `json:"previousClose"`
+				ChartPreviousClose float64 `json:"chartPreviousClose"`
+				DayHigh            float64 `json:"regularMarketDayHigh"`
+				DayLow             float64 `json:"regularMarketDayLow"`
+				Volume             float64 `json:"regularMarketVolume"`
+			} `json:"meta"`
+			Timestamp  []int64 `json:"timestamp"`
+			Indicators struct {
+				Quote []struct {
+					Open   []*float64 `json:"open"`
+					High   []*float64 `json:"high"`
+					Low    []*float64 `json:"low"`
+					Close  []*float64 `json:"close"`
+					Volume []*float64 `json:"volume"`
+				} `json:"quote"`
+			} `json:"indicators"`
+		} `json:"result"`
+		Error *struct {
+			Code        string `json:"code"`
+			Description string `json:"description"`
+		} `json:"error"`
+	} `json:"chart"`
+}
+
+// candles returns the chart's bars, skipping those without a close.
+func (c *yahooChart) candles() []Candle {
+	if len(c.Chart.Result) == 0 || len(c.Chart.Result[0].Indicators.Quote) == 0 {
+		return nil
+	}
+	r := c.Chart.Result[0]
+	q := r.Indicators.Quote[0]
+	at := func(values []*float64, i int) float64 {
+		if i < len(values) && values[i] != nil {
+			return *values[i]
+		}
+		return 0
+	}
+	candles := make([]Candle, 0, len(r.Timestamp))
+	for i, ts := range r.Timestamp {
+		if i >= len(q.Close) || q.Close[i] == nil {
+			continue
+		}
+		candles = append(candles, Candle{
+			Time:   time.Unix(ts, 0).UTC(),
+			Open:   at(q.Open, i),
+			High:   at(q.High, i),
+			Low:    at(q.Low, i),
+			Close:  at(q.Close, i),
+			Volume: at(q.Volume, i),
+		})
+	}
+	return candles
+}
+
+func (y *Yahoo) Ticker(ctx context.Context, symbol string) (*Ticker, error) {
+	ySymbol, err := yahooSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	chart, err := y.chart(ctx, ySymbol, url.Values{"interval": {"1d"}, "range": {"5d"}})
+	if err != nil {
+		return nil, err
+	}
+	meta := chart.Chart.Result[0].Meta
+	candles := chart.candles()
+
+	t := &Ticker{
+		Symbol: symbol,
+		Price:  meta.RegularMarketPrice,
+		High:   meta.DayHigh,
+		Low:    meta.DayLow,
+		Volume: meta.Volume,
+		Time:   nowUTC(),
+	}
+	if meta.RegularMarketTime > 0 {
+		t.Time = time.Unix(meta.RegularMarketTime, 0).UTC()
+	}
+	// The last daily bar is the current session unless it has no close yet
+	prev := meta.PreviousClose
+	if n := len(candles); n > 0 {
+		last := candles[n-1]
+		if sameDay(last.Time, t.Time) {
+			t.Open = last.Open
+			if t.Price == 0 {
+				t.Price = last.Close
+			}
+			if prev == 0 && n > 1 {
+				prev = candles[n-2].Close
+			}
+		} else if prev == 0 {
+			prev = last.Close
+		}
+	}
+	if prev == 0 {
+		prev = meta.ChartPreviousClose
+	}
+	t.Change = t.Price - prev
+	t.ChangePct = changePct(t.Price, prev)
+	return t, nil
+}
+
+func (y *Yahoo) Candles(ctx context.Context, symbol, interval string, limit int) (*CandleSeries, error) {
+	ySymbol, err := yahooSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	yInterval, ok := yahooIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	step, _ := intervalDuration(interval)
+
+	// Markets are closed part of the time, so look back well past limit bars
+	lookback := step * time.Duration(limit)
+	if step < 24*time.Hour {
+		lookback *= 6
+		// Yahoo only keeps 7 days of 1m bars and 60 days of other intraday ones
+		switch {
+		case interval == "1m":
+			lookback = min(lookback, 7*24*time.Hour)
+		case step < time.Hour:
+			lookback = min(lookback, 59*24*time.Hour)
+		default:
+			lookback = min(lookback, 729*24*time.Hour)
+		}
+	} else {
+		lookback = lookback*3/2 + 4*24*time.Hour
+	}
+	now := time.Now()
+	params := url.Values{
+		"interval": {yInterval},
+		"period1":  {fmt.Sprint(now.Add(-lookback).Unix())},
+		"period2":  {fmt.Sprint(now.Unix())},
+	}
+	chart, err := y.chart(ctx, ySymbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := chart.candles()
+	if interval != "1h" && yInterval == "60m" {
+		candles = resample(candles, step)
+	}
+	if len(candles) > limit {
+		candles = candles[len(candles)-limit:]
+	}
+	return &CandleSeries{Symbol: symbol, Interval: interval, Candles: candles}, nil
+}
+
+func (y *Yahoo) CandlesBefore(ctx context.Context, symbol, interval string, start, end time.Time) (*CandleSeries, error) {
+	ySymbol, err := yahooSymbol(symbol)
+	if err != nil {
+		return nil, err
+	}
+	yInterval, ok := yahooIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("interval %s: %w", interval, ErrUnsupported)
+	}
+	step, _ := intervalDuration(interval)
+
+	// Yahoo returns the whole range at once, but only keeps recent intraday
+	// bars, so a range reaching further back than that is cut short
+	oldest := time.Time{}
+	switch {
+	case interval == "1m":
+		oldest = time.Now().Add(-7 * 24 * time.Hour)
+	case step < time.Hour:
+		oldest = time.Now().Add(-59 * 24 * time.Hour)
+	case step < 24*time.Hour:
+		oldest = time.Now().Add(-729 * 24 * time.Hour)
+	}
+	if start.Before(oldest) {
+		start = oldest
+	}
+	if !start.Before(end) {
+		return &CandleSeries{Symbol: symbol, Interval: interval}, nil
+	}
+	params := url.Values{
+		"interval": {yInterval},
+		"period1":  {fmt.Sprint(start.Unix())},
+		"period2":  {fmt.Sprint(end.Unix())},
+	}
+	chart, err := y.chart(ctx, ySymbol, params)
+	if err != nil {
+		return nil, err
+	}
+
+	candles := chart.candles()
+	if interval != "1h" && yInterval == "60m" {
+		candles = resample(candles, step)
+	}
+	// Resampled bars can open before start
+	candles = since(candles, start.Truncate(step))
+	for len(candles) > 0 && !candles[len(candles)-1].Time.Before(end) {
+		candles = candles[:len(candles)-1]
+	}
+	return &CandleSeries{Symbol: symbol, Interval: interval, Candles: candles}, nil
+}
+
+func (y *Yahoo) OrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	return nil, ErrUnsupported
+}
+
+func (y *Yahoo) FX(ctx context.Context, base string) (*FXRates, error) {
+	return nil, ErrUnsupported
+}
+
+func (y *Yahoo) chart(ctx context.Context, ySymbol string, params url.Values) (*yahooChart, error) {
+	var chart yahooChart
+	err := getJSON(ctx, y.client, y.baseURL+"/v8/finance/chart/"+url.PathEscape(ySymbol)+"?"+params.Encode(), &chart)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ySymbol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if chart.Chart.Error != nil {
+		return nil, fmt.Errorf("yahoo error %s: %s", chart.Chart.Error.Code, chart.Chart.Error.Description)
+	}
+	if len(chart.Chart.Result) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ySymbol)
+	}
+	return &chart, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// resample merges candles into bars of the given width, aligned to UTC.
+func resample(candles []Candle, width time.Duration) []Candle {
+	var out []Candle
+	for _, c := range candles {
+		start := c.Time.Truncate(width)
+		if n := len(out); n > 0 && out[n-1].Time.Equal(start) {
+			bar := &out[n-1]
+			bar.High = max(bar.High, c.High)
+			bar.Low = min(bar.Low, c.Low)
+			bar.Close = c.Close
+			bar.Volume += c.Volume
+			continue
+		}
+		c.Time = start
+		out = append(out, c)
+	}
+	return out
+}
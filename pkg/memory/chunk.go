@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxChunkChars bounds a single indexed snippet.
+	maxChunkChars = 1200
+	// maxJSONItems bounds how many trailing entries of a JSON array log are indexed.
+	maxJSONItems = 500
+)
+
+// Chunk is one retrievable snippet of a memory or data file.
+type Chunk struct {
+	ID      string    `json:"id"`      // "<path>#<n>"
+	Path    string    `json:"path"`    // Workspace-relative, slash separated
+	Source  string    `json:"source"`  // SourceMemory, SourceNotes or SourceData
+	Heading string    `json:"heading"` // Nearest markdown heading, if any
+	Line    int       `json:"line"`    // 1-based line the chunk starts at
+	Date    time.Time `json:"date"`    // Note date for daily notes, otherwise file mtime
+	Text    string    `json:"text"`
+	Hash    string    `json:"hash"` // Content hash, used for incremental re-indexing
+}
+
+const (
+	SourceMemory = "memory" // memory/MEMORY.md
+	SourceNotes  = "notes"  // memory/YYYYMM/YYYYMMDD.md and digests
+	SourceData   = "data"   // Selected files under data/
+)
+
+var dailyNoteName = regexp.MustCompile(`^(\d{8})\.md$`)
+
+// chunkFile splits file content into chunks according to its type.
+func chunkFile(relPath, source string, content []byte, modTime time.Time) []Chunk {
+	date := modTime
+	if m := dailyNoteName.FindStringSubmatch(filepath.Base(relPath)); m != nil {
+		if t, err := time.ParseInLocation("20060102", m[1], time.Local); err == nil {
+			date = t
+		}
+	}
+
+	var pieces []piece
+	switch strings.ToLower(filepath.Ext(relPath)) {
+	case ".json":
+		pieces = chunkJSON(content)
+	default:
+		pieces = chunkMarkdown(string(content))
+	}
+
+	chunks := make([]Chunk, 0, len(pieces))
+	for i, p := range pieces {
+		sum := sha256.Sum256([]byte(p.heading + "\x00" + p.text))
+		chunks = append(chunks, Chunk{
+			ID:      fmt.Sprintf("%s#%d", relPath, i),
+			Path:    relPath,
+			Source:  source,
+			Heading: p.heading,
+			Line:    p.line,
+			Date:    date,
+			Text:    p.text,
+			Hash:    hex.EncodeToString(sum[:8]),
+		})
+	}
+	return chunks
+}
+
+type piece struct {
+	heading string
+	line    int
+	text    string
+}
+
+// chunkMarkdown groups paragraphs under their nearest heading, starting a new
+// chunk at every heading and whenever a chunk would exceed maxChunkChars.
+func chunkMarkdown(content string) []piece {
+	var pieces []piece
+	var heading string
+	var buf strings.Builder
+	start := 0
+
+	flush := func() {
+		text := strings.TrimSpace(buf.String())
+		if text != "" {
+			pieces = append(pieces, piece{heading: heading, line: start, text: text})
+		}
+		buf.Reset()
+		start = 0
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			flush()
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+			continue
+		}
+		if trimmed == "" && buf.Len() > maxChunkChars/2 {
+			flush()
+			continue
+		}
+		if buf.Len()+len(line) > maxChunkChars && buf.Len() > 0 {
+			flush()
+		}
+		if start == 0 {
+			if trimmed == "" {
+				continue
+			}
+			start = i + 1
+		}
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	flush()
+	return pieces
+}
+
+// chunkJSON indexes each element of a top-level array (the append-only logs
+// skills keep under data/) and each top-level key of an object.
+func chunkJSON(content []byte) []piece {
+	var value interface{}
+	if err := json.Unmarshal(content, &value); err != nil {
+		return chunkMarkdown(string(content))
+	}
+
+	var pieces []piece
+	add := func(heading string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		text := string(data)
+		if len(text) > maxChunkChars {
+			text = strings.ToValidUTF8(text[:maxChunkChars], "")
+		}
+		pieces = append(pieces, piece{heading: heading, text: text})
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		first := 0
+		if len(v) > maxJSONItems {
+			first = len(v) - maxJSONItems
+		}
+		for i := first; i < len(v); i++ {
+			add(fmt.Sprintf("[%d]", i), v[i])
+		}
+	case map[string]interface{}:
+		if len(content) <= maxChunkChars {
+			pieces = append(pieces, piece{text: string(bytes.TrimSpace(content))})
+			break
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			add(key, v[key])
+		}
+	default:
+		add("", v)
+	}
+	return pieces
+}
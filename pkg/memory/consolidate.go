@@ -0,0 +1,461 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// maxDigestInput bounds the note text sent to the model for one digest.
+	maxDigestInput = 60000
+	// learningSimilarity is the term overlap at which two learnings from
+	// different digests count as the same lesson.
+	learningSimilarity = 0.5
+)
+
+const digestSystemPrompt = `You consolidate the notes of an AI market analyst into a digest for its long-term memory.
+Keep concrete numbers, dates, assets, predictions and outcomes; drop chatter and repetition.
+Reply with markdown containing exactly these sections:
+## Summary
+3-6 sentences.
+## Key Events
+Bullets, each starting with its date.
+## Learnings
+Bullets, each a short self-contained lesson that would still be useful months later. Write "- None" if there are none.`
+
+// Summarizer asks the model to condense prompt following the system instructions.
+type Summarizer func(ctx context.Context, system, prompt string) (string, error)
+
+// ConsolidationPolicy controls what a consolidation run changes.
+type ConsolidationPolicy struct {
+	RetentionDays int  // Raw notes older than this are pruned once their week has a digest
+	Archive       bool // Move pruned notes to memory/archive/ instead of deleting them
+	PromoteAfter  int  // Digests a learning must recur in to be promoted to a fact; 0 disables promotion
+}
+
+// DigestChange is a weekly or monthly digest written (or proposed) by a run.
+type DigestChange struct {
+	Path    string   `json:"path"`
+	Period  string   `json:"period"`
+	Sources []string `json:"sources"`
+	Content string   `json:"content"`
+}
+
+// ConsolidationReport lists the changes of a run. In a dry run nothing was
+// written and the report is the proposal.
+type ConsolidationReport struct {
+	DryRun   bool           `json:"dry_run"`
+	Digests  []DigestChange `json:"digests"`
+	Pruned   []string       `json:"pruned"`
+	Archived bool           `json:"archived"`
+	Promoted []string       `json:"promoted"`
+}
+
+// Consolidator rolls daily notes into weekly digests and weekly digests into
+// monthly ones, prunes covered raw notes and promotes recurring learnings.
+//
+// Layout:
+//   - Weekly digests: memory/digests/YYYY-Www.md (ISO weeks)
+//   - Monthly digests: memory/digests/YYYY-MM.md
+//   - Archived notes: memory/archive/YYYYMM/YYYYMMDD.md
+type Consolidator struct {
+	store     *MemoryStore
+	summarize Summarizer
+	policy    ConsolidationPolicy
+	now       func() time.Time
+}
+
+func NewConsolidator(store *MemoryStore, summarize Summarizer, policy ConsolidationPolicy) *Consolidator {
+	return &Consolidator{
+		store:     store,
+		summarize: summarize,
+		policy:    policy,
+		now:       time.Now,
+	}
+}
+
+type dailyNote struct {
+	path string
+	date time.Time
+}
+
+// Run performs one consolidation pass. With dryRun set, digests are still
+// generated so they can be reviewed, but no file is written, moved or deleted.
+func (c *Consolidator) Run(ctx context.Context, dryRun bool) (*ConsolidationReport, error) {
+	report := &ConsolidationReport{DryRun: dryRun, Archived: c.policy.Archive}
+	today := startOfDay(c.now())
+	digestDir := filepath.Join(c.store.memoryDir, "digests")
+
+	notes, err := c.listDailyNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Weekly digests for complete weeks
+	weeks := make(map[string][]dailyNote)
+	for _, note := range notes {
+		weeks[isoWeek(note.date)] = append(weeks[isoWeek(note.date)], note)
+	}
+	weekDigests := make(map[string]string) // Week -> digest content, existing or new
+	for _, week := range sortedKeys(weeks) {
+		path := filepath.Join(digestDir, week+".md")
+		if data, err := os.ReadFile(path); err == nil {
+			weekDigests[week] = string(data)
+			continue
+		}
+		start := weekStart(weeks[week][0].date)
+		if today.Before(start.AddDate(0, 0, 7)) {
+			continue // Week still in progress
+		}
+
+		change, err := c.digestWeek(ctx, week, start, weeks[week], path)
+		if err == nil {
+			err = c.record(report, change)
+		}
+		if err != nil {
+			return report, err
+		}
+		weekDigests[week] = change.Content
+	}
+
+	// Monthly digests from the weekly digests of complete months
+	months := make(map[string][]string)
+	for week := range weekDigests {
+		month := weekMonth(week)
+		months[month] = append(months[month], week)
+	}
+	// A month's last ISO week can end up to three days into the next month
+	openMonth := today.AddDate(0, 0, -7).Format("2006-01")
+	for _, month := range sortedKeys(months) {
+		if month >= openMonth {
+			continue
+		}
+		path := filepath.Join(digestDir, month+".md")
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		change, err := c.digestMonth(ctx, month, months[month], weekDigests, path)
+		if err == nil {
+			err = c.record(report, change)
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	// Prune raw notes covered by a digest
+	if c.policy.RetentionDays > 0 {
+		cutoff := today.AddDate(0, 0, -c.policy.RetentionDays)
+		for _, note := range notes {
+			if !note.date.Before(cutoff) {
+				continue
+			}
+			if _, ok := weekDigests[isoWeek(note.date)]; !ok {
+				continue
+			}
+			report.Pruned = append(report.Pruned, c.relative(note.path))
+			if dryRun {
+				continue
+			}
+			if err := c.prune(note); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// Promote learnings that recur across weekly digests
+	if c.policy.PromoteAfter > 0 {
+		promoted, err := c.promote(weekDigests, dryRun)
+		if err != nil {
+			return report, err
+		}
+		report.Promoted = promoted
+	}
+
+	logger.InfoCF("memory", "Memory consolidation finished",
+		map[string]interface{}{
+			"dry_run":  dryRun,
+			"digests":  len(report.Digests),
+			"pruned":   len(report.Pruned),
+			"promoted": len(report.Promoted),
+		})
+	return report, nil
+}
+
+// record adds a digest to the report, writing it unless this is a dry run.
+func (c *Consolidator) record(report *ConsolidationReport, change DigestChange) error {
+	if !report.DryRun {
+		if err := fileio.WriteFile(change.Path, []byte(change.Content), 0644); err != nil {
+			return err
+		}
+	}
+	change.Path = c.relative(change.Path)
+	report.Digests = append(report.Digests, change)
+	return nil
+}
+
+func (c *Consolidator) digestWeek(ctx context.Context, week string, start time.Time, notes []dailyNote, path string) (DigestChange, error) {
+	end := start.AddDate(0, 0, 6)
+	var sources []string
+	var inputs []string
+	for _, note := range notes {
+		data, err := os.ReadFile(note.path)
+		if err != nil {
+			return DigestChange{}, err
+		}
+		sources = append(sources, c.relative(note.path))
+		inputs = append(inputs, fmt.Sprintf("### %s\n%s", note.date.Format("2006-01-02 (Monday)"), data))
+	}
+
+	title := fmt.Sprintf("Week %s (%s to %s)", week, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	return c.digest(ctx, title, "daily notes", sources, inputs, path, week)
+}
+
+func (c *Consolidator) digestMonth(ctx context.Context, month string, weeks []string, weekDigests map[string]string, path string) (DigestChange, error) {
+	sort.Strings(weeks)
+	var sources []string
+	var inputs []string
+	for _, week := range weeks {
+		sources = append(sources, "memory/digests/"+week+".md")
+		inputs = append(inputs, weekDigests[week])
+	}
+	return c.digest(ctx, "Month "+month, "weekly digests", sources, inputs, path, month)
+}
+
+func (c *Consolidator) digest(ctx context.Context, title, kind string, sources, inputs []string, path, period string) (DigestChange, error) {
+	perInput := maxDigestInput / max(len(inputs), 1)
+	for i, input := range inputs {
+		if len(input) > perInput {
+			inputs[i] = strings.ToValidUTF8(input[:perInput], "") + "\n... (truncated)"
+		}
+	}
+
+	prompt := fmt.Sprintf("Write the digest for %s from these %d %s:\n\n%s", title, len(inputs), kind, strings.Join(inputs, "\n\n"))
+	content, err := c.summarize(ctx, digestSystemPrompt, prompt)
+	if err != nil {
+		return DigestChange{}, fmt.Errorf("failed to digest %s: %w", period, err)
+	}
+
+	header := fmt.Sprintf("# %s\n\nConsolidated from %d %s on %s.\n\n", title, len(inputs), kind, c.now().Format("2006-01-02"))
+	return DigestChange{
+		Path:    path,
+		Period:  period,
+		Sources: sources,
+		Content: header + strings.TrimSpace(content) + "\n",
+	}, nil
+}
+
+func (c *Consolidator) prune(note dailyNote) error {
+	if !c.policy.Archive {
+		return os.Remove(note.path)
+	}
+	rel, err := filepath.Rel(c.store.memoryDir, note.path)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(c.store.memoryDir, "archive", rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(note.path, dest); err != nil {
+		return err
+	}
+	os.Remove(filepath.Dir(note.path)) // Drop the month directory once empty
+	return nil
+}
+
+var learningsSection = regexp.MustCompile(`(?s)##\s*Learnings\s*\n(.*?)(\n##\s|\z)`)
+
+// promote remembers learnings that appear in at least PromoteAfter weekly
+// digests and are not already covered by a fact.
+func (c *Consolidator) promote(weekDigests map[string]string, dryRun bool) ([]string, error) {
+	type cluster struct {
+		text  string
+		terms map[string]bool
+		weeks map[string]bool
+	}
+	var clusters []*cluster
+
+	for _, week := range sortedKeys(weekDigests) {
+		m := learningsSection.FindStringSubmatch(weekDigests[week])
+		if m == nil {
+			continue
+		}
+		for _, line := range strings.Split(m[1], "\n") {
+			text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+			if text == "" || strings.EqualFold(strings.Trim(text, "."), "none") {
+				continue
+			}
+			terms := termSet(text)
+			var match *cluster
+			for _, cl := range clusters {
+				if jaccard(terms, cl.terms) >= learningSimilarity {
+					match = cl
+					break
+				}
+			}
+			if match == nil {
+				match = &cluster{terms: terms, weeks: make(map[string]bool)}
+				clusters = append(clusters, match)
+			}
+			match.text = text // Keep the most recent wording
+			match.weeks[week] = true
+		}
+	}
+
+	facts, err := c.store.Facts("")
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted []string
+	for _, cl := range clusters {
+		if len(cl.weeks) < c.policy.PromoteAfter {
+			continue
+		}
+		known := false
+		for _, fact := range facts {
+			if jaccard(cl.terms, termSet(fact.Text)) >= learningSimilarity {
+				known = true
+				break
+			}
+		}
+		if known {
+			continue
+		}
+		promoted = append(promoted, cl.text)
+		if dryRun {
+			continue
+		}
+		if _, _, err := c.store.Remember(cl.text, []string{"learning", "promoted"}, "consolidation"); err != nil {
+			return promoted, err
+		}
+	}
+	return promoted, nil
+}
+
+func (c *Consolidator) listDailyNotes() ([]dailyNote, error) {
+	months, err := os.ReadDir(c.store.memoryDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var notes []dailyNote
+	for _, month := range months {
+		if !month.IsDir() || len(month.Name()) != 6 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(c.store.memoryDir, month.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			m := dailyNoteName.FindStringSubmatch(file.Name())
+			if m == nil {
+				continue
+			}
+			date, err := time.ParseInLocation("20060102", m[1], time.Local)
+			if err != nil {
+				continue
+			}
+			notes = append(notes, dailyNote{path: filepath.Join(c.store.memoryDir, month.Name(), file.Name()), date: date})
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].date.Before(notes[j].date) })
+	return notes, nil
+}
+
+func (c *Consolidator) relative(path string) string {
+	if rel, err := filepath.Rel(c.store.workspace, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// String renders the report for the CLI and logs.
+func (r *ConsolidationReport) String() string {
+	var sb strings.Builder
+	if r.DryRun {
+		sb.WriteString("Memory consolidation (dry run, nothing was changed)\n")
+	} else {
+		sb.WriteString("Memory consolidation\n")
+	}
+	if len(r.Digests) == 0 && len(r.Pruned) == 0 && len(r.Promoted) == 0 {
+		sb.WriteString("\nNothing to consolidate.\n")
+		return sb.String()
+	}
+
+	for _, d := range r.Digests {
+		fmt.Fprintf(&sb, "\n+ %s (from %d sources)\n", d.Path, len(d.Sources))
+		if r.DryRun {
+			for _, line := range strings.Split(strings.TrimRight(d.Content, "\n"), "\n") {
+				sb.WriteString("  | " + line + "\n")
+			}
+		}
+	}
+	if len(r.Pruned) > 0 {
+		verb := "Delete"
+		if r.Archived {
+			verb = "Archive to memory/archive/"
+		}
+		fmt.Fprintf(&sb, "\n%s %d raw notes:\n", verb, len(r.Pruned))
+		for _, path := range r.Pruned {
+			sb.WriteString("  - " + path + "\n")
+		}
+	}
+	if len(r.Promoted) > 0 {
+		sb.WriteString("\nPromote to long-term facts:\n")
+		for _, text := range r.Promoted {
+			sb.WriteString("  - " + text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// isoWeek returns the ISO 8601 week of t as "YYYY-Www".
+func isoWeek(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// weekStart returns the Monday of t's ISO week.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return startOfDay(t).AddDate(0, 0, -offset)
+}
+
+// weekMonth attributes an ISO week to the month containing its Thursday.
+func weekMonth(week string) string {
+	var year, num int
+	fmt.Sscanf(week, "%d-W%d", &year, &num)
+	// January 4th is always in week 1
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.Local)
+	thursday := weekStart(jan4).AddDate(0, 0, (num-1)*7+3)
+	return thursday.Format("2006-01")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsolidation(t *testing.T) {
+	dir := t.TempDir()
+	for _, day := range []string{"20260105", "20260107", "20260113", "20260120", "20260309"} {
+		writeFile(t, dir, "memory/"+day[:6]+"/"+day+".md", "# "+day+"\n\nGold up again.\n")
+	}
+
+	var prompts []string
+	summarize := func(ctx context.Context, system, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		if strings.Contains(prompt, "weekly digests") {
+			return "## Summary\nA month of gold strength.\n## Key Events\n- None\n## Learnings\n- None", nil
+		}
+		return "## Summary\nGold rose.\n## Key Events\n- 2026-01-05 gold up\n## Learnings\n- Gold tends to rally into FOMC meetings.\n", nil
+	}
+
+	newConsolidator := func() *Consolidator {
+		c := NewConsolidator(NewMemoryStore(dir), summarize, ConsolidationPolicy{RetentionDays: 30, Archive: true, PromoteAfter: 3})
+		c.now = func() time.Time { return time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local) }
+		return c
+	}
+
+	report, err := newConsolidator().Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, d := range report.Digests {
+		paths = append(paths, d.Path)
+	}
+	want := "memory/digests/2026-W02.md,memory/digests/2026-W03.md,memory/digests/2026-W04.md,memory/digests/2026-01.md"
+	if strings.Join(paths, ",") != want {
+		t.Fatalf("digests = %v, want %s", paths, want)
+	}
+	if len(report.Pruned) != 4 || len(report.Promoted) != 1 {
+		t.Fatalf("unexpected proposal: %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory", "digests")); !os.IsNotExist(err) {
+		t.Fatal("dry run wrote digests")
+	}
+	if !strings.Contains(report.String(), "| ## Learnings") {
+		t.Errorf("dry run report should show digest contents:\n%s", report)
+	}
+
+	if _, err := newConsolidator().Run(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory", "digests", "2026-01.md")); err != nil {
+		t.Errorf("monthly digest missing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory", "archive", "202601", "20260105.md")); err != nil {
+		t.Errorf("note not archived: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory", "202603", "20260309.md")); err != nil {
+		t.Errorf("current week's note was pruned: %v", err)
+	}
+	facts, _ := NewMemoryStore(dir).Facts("promoted")
+	if len(facts) != 1 || !strings.Contains(facts[0].Text, "FOMC") {
+		t.Errorf("learning not promoted: %+v", facts)
+	}
+
+	// A second run finds nothing left to do and makes no model calls
+	prompts = nil
+	report, err = newConsolidator().Run(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 0 || len(report.Digests)+len(report.Pruned)+len(report.Promoted) != 0 {
+		t.Errorf("second run was not a no-op: %+v (%d prompts)", report, len(prompts))
+	}
+}
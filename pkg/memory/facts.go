@@ -0,0 +1,291 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+)
+
+// duplicateSimilarity is the term overlap (Jaccard) above which a new fact
+// is treated as a restatement of an existing one.
+const duplicateSimilarity = 0.8
+
+const (
+	factsStartMarker = "<!-- picoclaw:facts:start - managed by the memory tool, edits here are overwritten -->"
+	factsEndMarker   = "<!-- picoclaw:facts:end -->"
+)
+
+// ErrFactNotFound is returned for operations on an unknown fact id.
+var ErrFactNotFound = errors.New("fact not found")
+
+// Fact is one structured long-term memory. Facts live in memory/facts.json
+// and are rendered into a managed section of MEMORY.md for humans.
+type Fact struct {
+	ID      string   `json:"id"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags,omitempty"`
+	Source  string   `json:"source,omitempty"` // Session the fact came from, e.g. "telegram:12345"
+	Created int64    `json:"created"`
+	Updated int64    `json:"updated"`
+}
+
+type factsFile struct {
+	NextID int    `json:"next_id"`
+	Facts  []Fact `json:"facts"`
+}
+
+func (ms *MemoryStore) loadFacts() (*factsFile, error) {
+	file := &factsFile{NextID: 1}
+	data, err := os.ReadFile(ms.factsFile)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ms.factsFile, err)
+	}
+	return file, nil
+}
+
+// saveFacts writes facts.json and re-renders MEMORY.md. Callers hold the
+// facts lock.
+func (ms *MemoryStore) saveFacts(file *factsFile) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileio.WriteFile(ms.factsFile, data, 0644); err != nil {
+		return err
+	}
+	return ms.WriteLongTerm(renderFactsInto(ms.ReadLongTerm(), file.Facts))
+}
+
+// Remember stores a new fact. If a near-identical fact exists, it is
+// refreshed instead (tags merged, text replaced) and duplicate is true.
+func (ms *MemoryStore) Remember(text string, tags []string, source string) (fact Fact, duplicate bool, err error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Fact{}, false, errors.New("fact text is empty")
+	}
+
+	unlock, err := fileio.Lock(ms.factsFile)
+	if err != nil {
+		return Fact{}, false, err
+	}
+	defer unlock()
+
+	file, err := ms.loadFacts()
+	if err != nil {
+		return Fact{}, false, err
+	}
+
+	now := time.Now().UnixMilli()
+	tags = normalizeTags(tags)
+	if i := findDuplicate(file.Facts, text); i >= 0 {
+		existing := &file.Facts[i]
+		existing.Text = text
+		existing.Tags = normalizeTags(append(existing.Tags, tags...))
+		existing.Updated = now
+		return *existing, true, ms.saveFacts(file)
+	}
+
+	fact = Fact{
+		ID:      fmt.Sprintf("f%d", file.NextID),
+		Text:    text,
+		Tags:    tags,
+		Source:  source,
+		Created: now,
+		Updated: now,
+	}
+	file.NextID++
+	file.Facts = append(file.Facts, fact)
+	return fact, false, ms.saveFacts(file)
+}
+
+// UpdateFact replaces the text and, when tags is non-nil, the tags of a fact.
+func (ms *MemoryStore) UpdateFact(id, text string, tags []string) (Fact, error) {
+	unlock, err := fileio.Lock(ms.factsFile)
+	if err != nil {
+		return Fact{}, err
+	}
+	defer unlock()
+
+	file, err := ms.loadFacts()
+	if err != nil {
+		return Fact{}, err
+	}
+	for i := range file.Facts {
+		fact := &file.Facts[i]
+		if fact.ID != id {
+			continue
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			fact.Text = text
+		}
+		if tags != nil {
+			fact.Tags = normalizeTags(tags)
+		}
+		fact.Updated = time.Now().UnixMilli()
+		return *fact, ms.saveFacts(file)
+	}
+	return Fact{}, fmt.Errorf("%w: %s", ErrFactNotFound, id)
+}
+
+// Forget deletes a fact and returns it.
+func (ms *MemoryStore) Forget(id string) (Fact, error) {
+	unlock, err := fileio.Lock(ms.factsFile)
+	if err != nil {
+		return Fact{}, err
+	}
+	defer unlock()
+
+	file, err := ms.loadFacts()
+	if err != nil {
+		return Fact{}, err
+	}
+	for i, fact := range file.Facts {
+		if fact.ID == id {
+			file.Facts = append(file.Facts[:i], file.Facts[i+1:]...)
+			return fact, ms.saveFacts(file)
+		}
+	}
+	return Fact{}, fmt.Errorf("%w: %s", ErrFactNotFound, id)
+}
+
+// Facts returns the stored facts, oldest first. A non-empty tag filters them.
+func (ms *MemoryStore) Facts(tag string) ([]Fact, error) {
+	unlock, err := fileio.Lock(ms.factsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	file, err := ms.loadFacts()
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		return file.Facts, nil
+	}
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	var filtered []Fact
+	for _, fact := range file.Facts {
+		for _, t := range fact.Tags {
+			if t == tag {
+				filtered = append(filtered, fact)
+				break
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// findDuplicate returns the index of the fact most similar to text if it is
+// above duplicateSimilarity, or -1.
+func findDuplicate(facts []Fact, text string) int {
+	terms := termSet(text)
+	best, bestScore := -1, 0.0
+	for i, fact := range facts {
+		score := jaccard(terms, termSet(fact.Text))
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if bestScore >= duplicateSimilarity {
+		return best
+	}
+	return -1
+}
+
+func termSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, tok := range Tokenize(text) {
+		set[tok] = true
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for term := range a {
+		if b[term] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(tag, "#")))
+		tag = strings.ReplaceAll(tag, " ", "_")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// renderFactsInto replaces the managed facts section of MEMORY.md, appending
+// it when absent. Hand-written content outside the markers is kept.
+func renderFactsInto(content string, facts []Fact) string {
+	section := renderFacts(facts)
+
+	start := strings.Index(content, factsStartMarker)
+	end := strings.Index(content, factsEndMarker)
+	if start >= 0 && end > start {
+		return content[:start] + section + content[end+len(factsEndMarker):]
+	}
+
+	if strings.TrimSpace(content) == "" {
+		content = "# Long-term Memory\n"
+	}
+	return strings.TrimRight(content, "\n") + "\n\n" + section + "\n"
+}
+
+func renderFacts(facts []Fact) string {
+	groups := make(map[string][]Fact)
+	for _, fact := range facts {
+		group := "general"
+		if len(fact.Tags) > 0 {
+			group = fact.Tags[0]
+		}
+		groups[group] = append(groups[group], fact)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(factsStartMarker)
+	sb.WriteString("\n## Facts\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n### %s\n", name)
+		for _, fact := range groups[name] {
+			fmt.Fprintf(&sb, "- %s (%s, %s", fact.Text, fact.ID, time.UnixMilli(fact.Updated).Format("2006-01-02"))
+			for _, tag := range fact.Tags[min(1, len(fact.Tags)):] {
+				fmt.Fprintf(&sb, " #%s", tag)
+			}
+			sb.WriteString(")\n")
+		}
+	}
+	sb.WriteString(factsEndMarker)
+	return sb.String()
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFactsLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	store := NewMemoryStore(dir)
+	store.WriteLongTerm("# Long-term Memory\n\nHand-written notes stay.\n")
+
+	fact, duplicate, err := store.Remember("The user trades EURUSD on the London open", []string{"User", "#eurusd"}, "telegram:1")
+	if err != nil || duplicate {
+		t.Fatalf("Remember: %v, duplicate=%v", err, duplicate)
+	}
+	if fact.ID != "f1" || strings.Join(fact.Tags, ",") != "eurusd,user" || fact.Source != "telegram:1" {
+		t.Fatalf("unexpected fact: %+v", fact)
+	}
+
+	again, duplicate, err := store.Remember("User trades EURUSD on the London open.", []string{"fx"}, "cli:direct")
+	if err != nil || !duplicate || again.ID != "f1" {
+		t.Fatalf("expected a merge into f1, got %+v duplicate=%v err=%v", again, duplicate, err)
+	}
+	if strings.Join(again.Tags, ",") != "eurusd,fx,user" {
+		t.Errorf("tags not merged: %v", again.Tags)
+	}
+
+	other, _, _ := store.Remember("Gold tends to rally into FOMC", []string{"pattern"}, "")
+	if other.ID != "f2" {
+		t.Fatalf("expected f2, got %s", other.ID)
+	}
+
+	if _, err := store.UpdateFact("f2", "Gold tends to fade after FOMC", nil); err != nil {
+		t.Fatal(err)
+	}
+	content := store.ReadLongTerm()
+	for _, want := range []string{"Hand-written notes stay.", "### pattern", "Gold tends to fade after FOMC (f2", "### eurusd", "#fx #user"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("MEMORY.md missing %q:\n%s", want, content)
+		}
+	}
+
+	if _, err := store.Forget("f1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Forget("f1"); !errors.Is(err, ErrFactNotFound) {
+		t.Errorf("expected ErrFactNotFound, got %v", err)
+	}
+	facts, _ := store.Facts("pattern")
+	if len(facts) != 1 || facts[0].ID != "f2" {
+		t.Errorf("unexpected facts: %+v", facts)
+	}
+
+	// The rendered section is replaced in place, not appended again
+	content = store.ReadLongTerm()
+	if strings.Count(content, factsStartMarker) != 1 || strings.Contains(content, "EURUSD") {
+		t.Errorf("stale render:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "memory", "facts.json")); err != nil {
+		t.Errorf("facts.json not written: %v", err)
+	}
+}
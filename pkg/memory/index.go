@@ -0,0 +1,390 @@
+// Package memory indexes the agent's long-term memory, daily notes and
+// selected data files so that relevant snippets can be retrieved on demand
+// instead of loading everything into the system prompt.
+package memory
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// refreshInterval throttles how often the files are re-scanned for changes.
+	refreshInterval = 5 * time.Second
+)
+
+// Result is a scored chunk returned by a search.
+type Result struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// SearchOptions narrows a search.
+type SearchOptions struct {
+	Limit   int
+	Sources []string  // Empty means all sources
+	Since   time.Time // Zero means no lower bound
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	chunks  []Chunk
+}
+
+// Index is an in-memory BM25 full-text index over the memory files of a
+// workspace. It re-scans the files lazily, re-chunking only those whose size
+// or modification time changed.
+type Index struct {
+	workspace string
+	dataGlobs []string
+
+	mu          sync.RWMutex
+	files       map[string]*fileState
+	chunks      []Chunk
+	terms       []map[string]int // Term frequencies per chunk
+	lengths     []int
+	docFreq     map[string]int
+	avgLength   float64
+	lastRefresh time.Time
+	listeners   []func([]Chunk)
+}
+
+// NewIndex creates an index over workspace/memory and the given glob patterns,
+// relative to workspace/data.
+func NewIndex(workspace string, dataGlobs []string) *Index {
+	return &Index{
+		workspace: workspace,
+		dataGlobs: dataGlobs,
+		files:     make(map[string]*fileState),
+		docFreq:   make(map[string]int),
+	}
+}
+
+// OnChange registers a callback that receives the full chunk list whenever
+// the indexed files change.
+func (idx *Index) OnChange(fn func([]Chunk)) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.listeners = append(idx.listeners, fn)
+}
+
+// Refresh re-scans the indexed files. Unless force is set, calls within
+// refreshInterval of the previous scan are no-ops.
+func (idx *Index) Refresh(force bool) error {
+	idx.mu.Lock()
+	if !force && time.Since(idx.lastRefresh) < refreshInterval {
+		idx.mu.Unlock()
+		return nil
+	}
+	idx.lastRefresh = time.Now()
+
+	seen := make(map[string]bool)
+	changed := false
+	for relPath, source := range idx.collectFiles() {
+		seen[relPath] = true
+		info, err := os.Stat(filepath.Join(idx.workspace, filepath.FromSlash(relPath)))
+		if err != nil {
+			continue
+		}
+		state, ok := idx.files[relPath]
+		if ok && state.modTime.Equal(info.ModTime()) && state.size == info.Size() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(idx.workspace, filepath.FromSlash(relPath)))
+		if err != nil {
+			logger.WarnCF("memory", "Failed to read file for indexing",
+				map[string]interface{}{
+					"path":  relPath,
+					"error": err.Error(),
+				})
+			continue
+		}
+		idx.files[relPath] = &fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			chunks:  chunkFile(relPath, source, content, info.ModTime()),
+		}
+		changed = true
+	}
+	for relPath := range idx.files {
+		if !seen[relPath] {
+			delete(idx.files, relPath)
+			changed = true
+		}
+	}
+
+	if !changed {
+		idx.mu.Unlock()
+		return nil
+	}
+	idx.rebuild()
+	chunks := append([]Chunk(nil), idx.chunks...)
+	listeners := append([]func([]Chunk){}, idx.listeners...)
+	idx.mu.Unlock()
+
+	logger.DebugCF("memory", "Memory index rebuilt",
+		map[string]interface{}{
+			"files":  len(seen),
+			"chunks": len(chunks),
+		})
+	for _, fn := range listeners {
+		fn(chunks)
+	}
+	return nil
+}
+
+// collectFiles returns the workspace-relative paths to index with their source.
+func (idx *Index) collectFiles() map[string]string {
+	files := make(map[string]string)
+	memoryDir := filepath.Join(idx.workspace, "memory")
+
+	filepath.Walk(memoryDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && path == filepath.Join(memoryDir, "archive") {
+			return filepath.SkipDir // Pruned notes, superseded by digests
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(idx.workspace, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "memory/MEMORY.md" {
+			files[rel] = SourceMemory
+		} else {
+			files[rel] = SourceNotes
+		}
+		return nil
+	})
+
+	dataDir := filepath.Join(idx.workspace, "data")
+	for _, pattern := range idx.dataGlobs {
+		matches, err := filepath.Glob(filepath.Join(dataDir, filepath.FromSlash(pattern)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(idx.workspace, match)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				files[filepath.ToSlash(rel)] = SourceData
+			}
+		}
+	}
+	return files
+}
+
+// rebuild recomputes the postings from the per-file chunks. Callers hold mu.
+func (idx *Index) rebuild() {
+	paths := make([]string, 0, len(idx.files))
+	for path := range idx.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	idx.chunks = idx.chunks[:0]
+	idx.terms = idx.terms[:0]
+	idx.lengths = idx.lengths[:0]
+	idx.docFreq = make(map[string]int)
+	total := 0
+
+	for _, path := range paths {
+		for _, chunk := range idx.files[path].chunks {
+			tokens := Tokenize(chunk.Heading + " " + chunk.Text)
+			tf := make(map[string]int, len(tokens))
+			for _, tok := range tokens {
+				tf[tok]++
+			}
+			for term := range tf {
+				idx.docFreq[term]++
+			}
+			idx.chunks = append(idx.chunks, chunk)
+			idx.terms = append(idx.terms, tf)
+			idx.lengths = append(idx.lengths, len(tokens))
+			total += len(tokens)
+		}
+	}
+
+	idx.avgLength = 0
+	if len(idx.chunks) > 0 {
+		idx.avgLength = float64(total) / float64(len(idx.chunks))
+	}
+}
+
+// Chunks returns every indexed chunk.
+func (idx *Index) Chunks() []Chunk {
+	idx.Refresh(false)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]Chunk(nil), idx.chunks...)
+}
+
+// Len returns the number of indexed chunks.
+func (idx *Index) Len() int {
+	idx.Refresh(false)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.chunks)
+}
+
+// Search returns the chunks ranking highest for query under BM25, newest
+// first among equal scores.
+func (idx *Index) Search(query string, opts SearchOptions) []Result {
+	idx.Refresh(false)
+
+	queryTerms := Tokenize(query)
+	if len(queryTerms) == 0 {
+		return nil
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 5
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.chunks))
+	unique := make(map[string]bool)
+	var results []Result
+	for i, chunk := range idx.chunks {
+		if !matchesOptions(chunk, opts) {
+			continue
+		}
+		score := 0.0
+		for k := range unique {
+			delete(unique, k)
+		}
+		for _, term := range queryTerms {
+			if unique[term] {
+				continue
+			}
+			unique[term] = true
+			tf := float64(idx.terms[i][term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(idx.docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(idx.lengths[i])/idx.avgLength
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		if score > 0 {
+			results = append(results, Result{Chunk: chunk, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Date.After(results[j].Date)
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results
+}
+
+func matchesOptions(chunk Chunk, opts SearchOptions) bool {
+	if !opts.Since.IsZero() && chunk.Date.Before(opts.Since) {
+		return false
+	}
+	if len(opts.Sources) == 0 {
+		return true
+	}
+	for _, source := range opts.Sources {
+		if source == chunk.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// stopwords are dropped from both documents and queries.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "for": true, "from": true, "has": true, "have": true,
+	"in": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "were": true,
+	"will": true, "with": true, "what": true, "when": true, "which": true, "who": true,
+	"do": true, "does": true, "did": true, "i": true, "you": true, "we": true, "my": true,
+}
+
+// Tokenize lowercases text and splits it into index terms. Latin words are
+// lightly stemmed; Han, Hiragana, Katakana and Hangul characters are indexed
+// individually since those scripts don't separate words with spaces.
+func Tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		tok := string(word)
+		word = word[:0]
+		if stopwords[tok] {
+			return
+		}
+		tokens = append(tokens, stem(tok))
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		case r == '.' && len(word) > 0 && unicode.IsDigit(word[len(word)-1]):
+			// Keep decimals such as "1.25" together
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// stem strips a few common English suffixes so that "rallied", "rallies"
+// and "rally" share a term.
+func stem(word string) string {
+	word = strings.TrimRight(word, ".")
+	if len(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ied") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return word[:len(word)-3]
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "es") && len(word) > 4 && strings.ContainsAny(word[len(word)-3:len(word)-2], "sxz"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	}
+	return word
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, dir, "memory/MEMORY.md", "# Long-term Memory\n\n## User\nThe user trades EURUSD and prefers short answers.\n\n## Lessons\nBreakouts during the Asian session usually fail.\n")
+	writeFile(t, dir, "memory/202501/20250114.md", "# 2025-01-14\n\nGold rallied after the CPI surprise; XAU closed at 2680.\n")
+	writeFile(t, dir, "memory/202501/20250115.md", "# 2025-01-15\n\nQuiet day. Bitcoin ranged between 96k and 98k.\n")
+	writeFile(t, dir, "data/macro/reasoning_log.json", `[{"event":"FOMC","note":"hawkish hold, dollar bid"},{"event":"NFP","note":"strong payrolls, yields higher"}]`)
+	writeFile(t, dir, "data/macro/current_triggers.json", `{"active":["CPI"]}`)
+	return dir
+}
+
+func TestSearchRanksRelevantChunks(t *testing.T) {
+	idx := NewIndex(newTestWorkspace(t), []string{"macro/reasoning_log.json"})
+
+	results := idx.Search("gold CPI", SearchOptions{Limit: 3})
+	if len(results) == 0 {
+		t.Fatal("expected results")
+	}
+	top := results[0]
+	if top.Path != "memory/202501/20250114.md" || top.Source != SourceNotes {
+		t.Fatalf("unexpected top result: %+v", top)
+	}
+	if got := top.Date.Format("2006-01-02"); got != "2025-01-14" {
+		t.Errorf("note date = %s, want 2025-01-14", got)
+	}
+
+	results = idx.Search("payrolls", SearchOptions{})
+	if len(results) != 1 || results[0].Source != SourceData || results[0].Heading != "[1]" {
+		t.Fatalf("expected the NFP log entry, got %+v", results)
+	}
+
+	if results := idx.Search("CPI", SearchOptions{Sources: []string{SourceMemory}}); len(results) != 0 {
+		t.Errorf("source filter ignored: %+v", results)
+	}
+	if results := idx.Search("active", SearchOptions{}); len(results) != 0 {
+		t.Errorf("data file outside the configured globs was indexed: %+v", results)
+	}
+
+	since := time.Date(2025, 1, 15, 0, 0, 0, 0, time.Local)
+	for _, r := range idx.Search("gold bitcoin", SearchOptions{Since: since}) {
+		if r.Date.Before(since) {
+			t.Errorf("result before since: %+v", r)
+		}
+	}
+}
+
+func TestRefreshPicksUpChanges(t *testing.T) {
+	dir := newTestWorkspace(t)
+	idx := NewIndex(dir, nil)
+
+	var notified int
+	idx.OnChange(func(chunks []Chunk) { notified = len(chunks) })
+
+	if results := idx.Search("ethereum", SearchOptions{}); len(results) != 0 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	writeFile(t, dir, "memory/202501/20250116.md", "# 2025-01-16\n\nEthereum broke out above 3500.\n")
+	os.Remove(filepath.Join(dir, "memory/202501/20250115.md"))
+	if err := idx.Refresh(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := idx.Search("ethereum", SearchOptions{}); len(results) != 1 {
+		t.Fatalf("new note not indexed: %+v", results)
+	}
+	if results := idx.Search("bitcoin", SearchOptions{}); len(results) != 0 {
+		t.Fatalf("deleted note still indexed: %+v", results)
+	}
+	if notified != idx.Len() {
+		t.Errorf("listener saw %d chunks, index has %d", notified, idx.Len())
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Gold rallied to 2,680.50 — the rallies continue; 黄金上涨")
+	want := []string{"gold", "rally", "2", "680.50", "rally", "continue", "黄", "金", "上", "涨"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// rrfK dampens the weight of top ranks in reciprocal rank fusion.
+const rrfK = 60
+
+// Retriever finds the memory chunks relevant to a query.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, opts SearchOptions) []Result
+}
+
+// Retrieve implements Retriever with keyword search.
+func (idx *Index) Retrieve(ctx context.Context, query string, opts SearchOptions) []Result {
+	return idx.Search(query, opts)
+}
+
+// Hybrid combines keyword and embedding search with reciprocal rank fusion,
+// so that a chunk found by both ranks above one found by either alone.
+type Hybrid struct {
+	keyword *Index
+	vectors *VectorIndex
+}
+
+func NewHybrid(keyword *Index, vectors *VectorIndex) *Hybrid {
+	return &Hybrid{keyword: keyword, vectors: vectors}
+}
+
+// Retrieve implements Retriever. When the embeddings endpoint fails it falls
+// back to keyword results alone.
+func (h *Hybrid) Retrieve(ctx context.Context, query string, opts SearchOptions) []Result {
+	if opts.Limit <= 0 {
+		opts.Limit = 5
+	}
+	candidates := opts
+	candidates.Limit = opts.Limit * 3
+
+	keyword := h.keyword.Search(query, candidates)
+	semantic, err := h.vectors.Search(ctx, query, candidates)
+	if err != nil {
+		logger.WarnCF("memory", "Semantic memory search failed, using keyword results",
+			map[string]interface{}{
+				"error": err.Error(),
+			})
+		if len(keyword) > opts.Limit {
+			keyword = keyword[:opts.Limit]
+		}
+		return keyword
+	}
+
+	return fuseRankings(opts.Limit, keyword, semantic)
+}
+
+// fuseRankings merges ranked lists by reciprocal rank fusion. The returned
+// scores are the fused scores.
+func fuseRankings(limit int, rankings ...[]Result) []Result {
+	scores := make(map[string]float64)
+	chunks := make(map[string]Chunk)
+	for _, ranking := range rankings {
+		for rank, r := range ranking {
+			scores[r.ID] += 1.0 / float64(rrfK+rank+1)
+			chunks[r.ID] = r.Chunk
+		}
+	}
+
+	fused := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		fused = append(fused, Result{Chunk: chunks[id], Score: score})
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if fused[i].Score != fused[j].Score {
+			return fused[i].Score > fused[j].Score
+		}
+		return fused[i].ID < fused[j].ID
+	})
+	if len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
@@ -4,22 +4,26 @@
 //
 // Copyright (c) 2026 PicoClaw contributors
 
-package agent
+package memory
 
 import (
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
 )
 
 // MemoryStore manages persistent memory for the agent.
 // - Long-term memory: memory/MEMORY.md
+// - Structured facts: memory/facts.json, rendered into MEMORY.md
 // - Daily notes: memory/YYYYMM/YYYYMMDD.md
 type MemoryStore struct {
 	workspace  string
 	memoryDir  string
 	memoryFile string
+	factsFile  string
 }
 
 // NewMemoryStore creates a new MemoryStore with the given workspace path.
@@ -35,13 +39,14 @@ func NewMemoryStore(workspace string) *MemoryStore {
 		workspace:  workspace,
 		memoryDir:  memoryDir,
 		memoryFile: memoryFile,
+		factsFile:  filepath.Join(memoryDir, "facts.json"),
 	}
 }
 
-// getTodayFile returns the path to today's daily note file (memory/YYYYMM/YYYYMMDD.md).
-func (ms *MemoryStore) getTodayFile() string {
-	today := time.Now().Format("20060102")      // YYYYMMDD
-	monthDir := today[:6]                       // YYYYMM
+// TodayFile returns the path to today's daily note file (memory/YYYYMM/YYYYMMDD.md).
+func (ms *MemoryStore) TodayFile() string {
+	today := time.Now().Format("20060102") // YYYYMMDD
+	monthDir := today[:6]                  // YYYYMM
 	filePath := filepath.Join(ms.memoryDir, monthDir, today+".md")
 	return filePath
 }
@@ -57,13 +62,13 @@ func (ms *MemoryStore) ReadLongTerm() string {
 
 // WriteLongTerm writes content to the long-term memory file (MEMORY.md).
 func (ms *MemoryStore) WriteLongTerm(content string) error {
-	return os.WriteFile(ms.memoryFile, []byte(content), 0644)
+	return fileio.WriteFile(ms.memoryFile, []byte(content), 0644)
 }
 
 // ReadToday reads today's daily note.
 // Returns empty string if the file doesn't exist.
 func (ms *MemoryStore) ReadToday() string {
-	todayFile := ms.getTodayFile()
+	todayFile := ms.TodayFile()
 	if data, err := os.ReadFile(todayFile); err == nil {
 		return string(data)
 	}
@@ -73,28 +78,18 @@ func (ms *MemoryStore) ReadToday() string {
 // AppendToday appends content to today's daily note.
 // If the file doesn't exist, it creates a new file with a date header.
 func (ms *MemoryStore) AppendToday(content string) error {
-	todayFile := ms.getTodayFile()
-
-	// Ensure month directory exists
-	monthDir := filepath.Dir(todayFile)
-	os.MkdirAll(monthDir, 0755)
-
-	var existingContent string
-	if data, err := os.ReadFile(todayFile); err == nil {
-		existingContent = string(data)
-	}
-
-	var newContent string
-	if existingContent == "" {
-		// Add header for new day
-		header := fmt.Sprintf("# %s\n\n", time.Now().Format("2006-01-02"))
-		newContent = header + content
-	} else {
+	todayFile := ms.TodayFile()
+
+	// Locked read-modify-write, so concurrent jobs don't drop each other's notes
+	return fileio.Update(todayFile, 0644, func(existing []byte) ([]byte, error) {
+		if len(existing) == 0 {
+			// Add header for new day
+			header := fmt.Sprintf("# %s\n\n", time.Now().Format("2006-01-02"))
+			return []byte(header + content), nil
+		}
 		// Append to existing content
-		newContent = existingContent + "\n" + content
-	}
-
-	return os.WriteFile(todayFile, []byte(newContent), 0644)
+		return []byte(string(existing) + "\n" + content), nil
+	})
 }
 
 // GetRecentDailyNotes returns daily notes from the last N days.
@@ -104,8 +99,8 @@ func (ms *MemoryStore) GetRecentDailyNotes(days int) string {
 
 	for i := 0; i < days; i++ {
 		date := time.Now().AddDate(0, 0, -i)
-		dateStr := date.Format("20060102")      // YYYYMMDD
-		monthDir := dateStr[:6]                 // YYYYMM
+		dateStr := date.Format("20060102") // YYYYMMDD
+		monthDir := dateStr[:6]            // YYYYMM
 		filePath := filepath.Join(ms.memoryDir, monthDir, dateStr+".md")
 
 		if data, err := os.ReadFile(filePath); err == nil {
@@ -0,0 +1,280 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// syncTimeout bounds one background embedding pass.
+const syncTimeout = 5 * time.Minute
+
+// Embedder turns texts into vectors. providers.EmbeddingsClient implements it.
+type Embedder interface {
+	Model() string
+	Embed(ctx context.Context, inputs []string) ([][]float32, error)
+}
+
+// VectorIndex keeps embeddings of the chunks of an Index in a small on-disk
+// store. Vectors are keyed by chunk content hash, so only new or edited
+// chunks are embedded when the memory files change.
+type VectorIndex struct {
+	index    *Index
+	embedder Embedder
+	path     string
+
+	mu      sync.RWMutex
+	vectors map[string][]float32 // Chunk hash -> unit-length vector
+
+	syncing sync.Mutex // Serializes Sync
+
+	syncMu  sync.Mutex // Guards running and pending
+	running bool
+	pending bool
+}
+
+type vectorFile struct {
+	Model   string            `json:"model"`
+	Vectors map[string]string `json:"vectors"` // Chunk hash -> base64 little-endian float32
+}
+
+// NewVectorIndex loads the vector store at path and re-embeds changed chunks
+// in the background whenever index changes.
+func NewVectorIndex(index *Index, embedder Embedder, path string) *VectorIndex {
+	v := &VectorIndex{
+		index:    index,
+		embedder: embedder,
+		path:     path,
+		vectors:  make(map[string][]float32),
+	}
+	if err := v.load(); err != nil {
+		logger.WarnCF("memory", "Failed to load memory vectors, re-embedding",
+			map[string]interface{}{
+				"path":  path,
+				"error": err.Error(),
+			})
+	}
+	index.OnChange(func([]Chunk) { v.syncInBackground() })
+	return v
+}
+
+func (v *VectorIndex) load() error {
+	data, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var file vectorFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if file.Model != v.embedder.Model() {
+		return nil // Vectors of another model are not comparable
+	}
+
+	for hash, encoded := range file.Vectors {
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw)%4 != 0 {
+			continue
+		}
+		vec := make([]float32, len(raw)/4)
+		for i := range vec {
+			vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+		v.vectors[hash] = vec
+	}
+	return nil
+}
+
+func (v *VectorIndex) save() error {
+	v.mu.RLock()
+	file := vectorFile{Model: v.embedder.Model(), Vectors: make(map[string]string, len(v.vectors))}
+	for hash, vec := range v.vectors {
+		raw := make([]byte, len(vec)*4)
+		for i, f := range vec {
+			binary.LittleEndian.PutUint32(raw[i*4:], math.Float32bits(f))
+		}
+		file.Vectors[hash] = base64.StdEncoding.EncodeToString(raw)
+	}
+	v.mu.RUnlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	return fileio.WriteFile(v.path, data, 0644)
+}
+
+// syncInBackground starts a Sync unless one is running, in which case the
+// running one makes another pass when it finishes.
+func (v *VectorIndex) syncInBackground() {
+	v.syncMu.Lock()
+	if v.running {
+		v.pending = true
+		v.syncMu.Unlock()
+		return
+	}
+	v.running = true
+	v.syncMu.Unlock()
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+			if err := v.Sync(ctx); err != nil {
+				logger.WarnCF("memory", "Failed to embed memory chunks",
+					map[string]interface{}{
+						"error": err.Error(),
+					})
+			}
+			cancel()
+
+			v.syncMu.Lock()
+			if !v.pending {
+				v.running = false
+				v.syncMu.Unlock()
+				return
+			}
+			v.pending = false
+			v.syncMu.Unlock()
+		}
+	}()
+}
+
+// Sync embeds chunks that have no vector yet, drops vectors of chunks that no
+// longer exist and saves the store.
+func (v *VectorIndex) Sync(ctx context.Context) error {
+	v.syncing.Lock()
+	defer v.syncing.Unlock()
+
+	chunks := v.index.Chunks()
+
+	live := make(map[string]bool, len(chunks))
+	var missing []Chunk
+	v.mu.RLock()
+	for _, chunk := range chunks {
+		if live[chunk.Hash] {
+			continue
+		}
+		live[chunk.Hash] = true
+		if _, ok := v.vectors[chunk.Hash]; !ok {
+			missing = append(missing, chunk)
+		}
+	}
+	stale := 0
+	for hash := range v.vectors {
+		if !live[hash] {
+			stale++
+		}
+	}
+	v.mu.RUnlock()
+
+	if len(missing) == 0 && stale == 0 {
+		return nil
+	}
+
+	inputs := make([]string, len(missing))
+	for i, chunk := range missing {
+		inputs[i] = embeddingInput(chunk)
+	}
+	var vectors [][]float32
+	if len(inputs) > 0 {
+		var err error
+		if vectors, err = v.embedder.Embed(ctx, inputs); err != nil {
+			return err
+		}
+	}
+
+	v.mu.Lock()
+	for i, chunk := range missing {
+		v.vectors[chunk.Hash] = normalize(vectors[i])
+	}
+	for hash := range v.vectors {
+		if !live[hash] {
+			delete(v.vectors, hash)
+		}
+	}
+	v.mu.Unlock()
+
+	logger.InfoCF("memory", "Memory vectors updated",
+		map[string]interface{}{
+			"embedded": len(missing),
+			"removed":  stale,
+		})
+	return v.save()
+}
+
+// Search returns the chunks closest to query by cosine similarity. Chunks
+// that have not been embedded yet are skipped.
+func (v *VectorIndex) Search(ctx context.Context, query string, opts SearchOptions) ([]Result, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 5
+	}
+	embedded, err := v.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(embedded) != 1 {
+		return nil, fmt.Errorf("expected one query vector, got %d", len(embedded))
+	}
+	q := normalize(embedded[0])
+
+	chunks := v.index.Chunks()
+	v.mu.RLock()
+	var results []Result
+	for _, chunk := range chunks {
+		vec, ok := v.vectors[chunk.Hash]
+		if !ok || len(vec) != len(q) || !matchesOptions(chunk, opts) {
+			continue
+		}
+		var dot float64
+		for i := range q {
+			dot += float64(q[i]) * float64(vec[i])
+		}
+		results = append(results, Result{Chunk: chunk, Score: dot})
+	}
+	v.mu.RUnlock()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, nil
+}
+
+func embeddingInput(chunk Chunk) string {
+	if chunk.Heading == "" {
+		return chunk.Text
+	}
+	return chunk.Heading + "\n" + chunk.Text
+}
+
+func normalize(vec []float32) []float32 {
+	var sum float64
+	for _, f := range vec {
+		sum += float64(f) * float64(f)
+	}
+	if sum == 0 {
+		return vec
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(vec))
+	for i, f := range vec {
+		out[i] = float32(float64(f) / norm)
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// conceptEmbedder maps words onto a few concept dimensions so that synonyms
+// such as "gold" and "xau" land on the same axis.
+type conceptEmbedder struct {
+	calls  int
+	inputs int
+}
+
+var concepts = [][]string{
+	{"gold", "xau", "bullion"},
+	{"rally", "rallied", "surged", "jumped"},
+	{"bitcoin", "btc"},
+	{"quiet", "ranged", "flat"},
+}
+
+func (e *conceptEmbedder) Model() string { return "concepts" }
+
+func (e *conceptEmbedder) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	e.calls++
+	e.inputs += len(inputs)
+	vectors := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		vec := make([]float32, len(concepts)+1)
+		vec[len(concepts)] = 0.1
+		for _, word := range strings.Fields(strings.ToLower(input)) {
+			word = strings.Trim(word, ".,;:")
+			for dim, synonyms := range concepts {
+				for _, s := range synonyms {
+					if word == s {
+						vec[dim]++
+					}
+				}
+			}
+		}
+		vectors[i] = vec
+	}
+	return vectors, nil
+}
+
+func TestHybridFindsParaphrases(t *testing.T) {
+	dir := newTestWorkspace(t)
+	idx := NewIndex(dir, nil)
+	embedder := &conceptEmbedder{}
+	vectors := NewVectorIndex(idx, embedder, filepath.Join(dir, "index", "memory_vectors.json"))
+	if err := vectors.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := idx.Search("bullion surged", SearchOptions{}); len(results) != 0 {
+		t.Fatalf("keyword search should miss the paraphrase, got %+v", results)
+	}
+	results := NewHybrid(idx, vectors).Retrieve(context.Background(), "bullion surged", SearchOptions{Limit: 2})
+	if len(results) == 0 || results[0].Path != "memory/202501/20250114.md" {
+		t.Fatalf("expected the gold note first, got %+v", results)
+	}
+}
+
+func TestVectorIndexIsIncremental(t *testing.T) {
+	dir := newTestWorkspace(t)
+	path := filepath.Join(dir, "index", "memory_vectors.json")
+	idx := NewIndex(dir, nil)
+	embedder := &conceptEmbedder{}
+	vectors := NewVectorIndex(idx, embedder, path)
+	if err := vectors.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	total := idx.Len()
+	if embedder.inputs != total {
+		t.Fatalf("embedded %d chunks, want %d", embedder.inputs, total)
+	}
+
+	// A fresh store loads the saved vectors and embeds only the new note
+	writeFile(t, dir, "memory/202501/20250116.md", "# 2025-01-16\n\nBTC jumped.\n")
+	reloaded := &conceptEmbedder{}
+	idx = NewIndex(dir, nil)
+	vectors = NewVectorIndex(idx, reloaded, path)
+	if err := vectors.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.inputs != 1 {
+		t.Fatalf("re-embedded %d chunks, want 1", reloaded.inputs)
+	}
+
+	results, err := vectors.Search(context.Background(), "bitcoin rally", SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != "memory/202501/20250116.md" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
@@ -0,0 +1,275 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+)
+
+// Cassette modes.
+const (
+	CassetteRecord = "record" // always call the real provider and record the exchange
+	CassetteReplay = "replay" // only serve recorded responses, never touch the network
+	CassetteAuto   = "auto"   // replay when a recording exists, otherwise record
+)
+
+// CassetteProvider records request/response pairs to a file and replays them
+// deterministically. Requests are matched on their normalized non-system
+// messages only, so volatile details such as the current time in the system
+// prompt, the set of registered tools, tool call IDs or timestamps in tool
+// results do not break replay. Identical requests are answered in the order they were recorded.
+type CassetteProvider struct {
+	mu           sync.Mutex
+	inner        LLMProvider
+	path         string
+	mode         string
+	interactions []CassetteInteraction
+	index        map[string][]int
+	served       map[string]int
+	replacements [][2]string
+}
+
+// CassetteInteraction is one recorded exchange.
+type CassetteInteraction struct {
+	Request  CassetteRequest `json:"request"`
+	Response *LLMResponse    `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// CassetteRequest is the normalized form of a Chat request.
+type CassetteRequest struct {
+	Model    string            `json:"model,omitempty"`
+	Tools    []string          `json:"tools,omitempty"`
+	Messages []CassetteMessage `json:"messages"`
+}
+
+type CassetteMessage struct {
+	Role      string `json:"role"`
+	Content   string `json:"content,omitempty"`
+	ToolCalls string `json:"tool_calls,omitempty"`
+	Images    int    `json:"images,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []CassetteInteraction `json:"interactions"`
+}
+
+var cassetteVolatile = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?( \(\w+\))?`), "<datetime>"},
+	{regexp.MustCompile(`\b(19|20)\d{2}-?[01]\d-?[0-3]\d\b`), "<date>"},
+	{regexp.MustCompile(`\b1[6-9]\d{8}(\d{3})?\b`), "<unix-time>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ns|µs|ms|s)\b`), "<duration>"},
+}
+
+// NewCassetteProvider opens (or, when recording, creates) the cassette at
+// path. inner may be nil in replay mode.
+func NewCassetteProvider(inner LLMProvider, path, mode string) (*CassetteProvider, error) {
+	switch mode {
+	case CassetteRecord, CassetteReplay, CassetteAuto:
+	case "":
+		mode = CassetteAuto
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q (want record, replay or auto)", mode)
+	}
+	if inner == nil && mode != CassetteReplay {
+		return nil, fmt.Errorf("cassette mode %q needs a real provider", mode)
+	}
+
+	p := &CassetteProvider{
+		inner:  inner,
+		path:   path,
+		mode:   mode,
+		index:  make(map[string][]int),
+		served: make(map[string]int),
+	}
+
+	if mode != CassetteRecord {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			var file cassetteFile
+			if err := json.Unmarshal(data, &file); err != nil {
+				return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+			}
+			p.interactions = file.Interactions
+		case os.IsNotExist(err) && mode == CassetteAuto:
+		default:
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+	}
+	p.reindex()
+
+	return p, nil
+}
+
+// Redact replaces a literal string (e.g. a temporary workspace path) with a
+// stable placeholder before requests are matched or recorded. Call it before
+// the first Chat.
+func (p *CassetteProvider) Redact(value, placeholder string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if value != "" {
+		p.replacements = append(p.replacements, [2]string{value, placeholder})
+	}
+	p.reindex()
+}
+
+func (p *CassetteProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	p.mu.Lock()
+	req := p.normalize(messages, tools, model)
+	key := p.key(req)
+
+	if p.mode != CassetteRecord {
+		if recorded := p.index[key]; p.served[key] < len(recorded) {
+			interaction := p.interactions[recorded[p.served[key]]]
+			p.served[key]++
+			p.mu.Unlock()
+			if interaction.Error != "" {
+				return nil, fmt.Errorf("%s", interaction.Error)
+			}
+			return interaction.Response, nil
+		}
+		if p.mode == CassetteReplay {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("cassette %s has no recorded response for this request (%s)", filepath.Base(p.path), describeLast(req))
+		}
+	}
+	p.mu.Unlock()
+
+	response, err := p.inner.Chat(ctx, messages, tools, model, options)
+
+	interaction := CassetteInteraction{Request: req, Response: response}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interactions = append(p.interactions, interaction)
+	p.index[key] = append(p.index[key], len(p.interactions)-1)
+	p.served[key] = len(p.index[key])
+	if saveErr := p.save(); saveErr != nil && err == nil {
+		return nil, saveErr
+	}
+
+	return response, err
+}
+
+func (p *CassetteProvider) GetDefaultModel() string {
+	if p.inner != nil {
+		return p.inner.GetDefaultModel()
+	}
+	return ""
+}
+
+// Unused returns how many recorded interactions have not been replayed, which
+// tests can assert on to catch turns that made fewer calls than expected.
+func (p *CassetteProvider) Unused() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	unused := 0
+	for key, recorded := range p.index {
+		unused += len(recorded) - p.served[key]
+	}
+	return unused
+}
+
+func (p *CassetteProvider) reindex() {
+	p.index = make(map[string][]int)
+	for i, interaction := range p.interactions {
+		key := p.key(interaction.Request)
+		p.index[key] = append(p.index[key], i)
+	}
+}
+
+func (p *CassetteProvider) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: p.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := fileio.WriteFile(p.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// normalize reduces a request to the parts that determine the answer, with
+// volatile values replaced by placeholders. System messages are left out:
+// they embed the clock, host details and installed skills.
+func (p *CassetteProvider) normalize(messages []Message, tools []ToolDefinition, model string) CassetteRequest {
+	req := CassetteRequest{Model: model}
+
+	for _, t := range tools {
+		req.Tools = append(req.Tools, t.Function.Name)
+	}
+	sort.Strings(req.Tools)
+
+	for _, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		cm := CassetteMessage{Role: m.Role, Content: p.scrub(m.Content), Images: len(m.Parts)}
+		if len(m.ToolCalls) > 0 {
+			calls := make([]string, 0, len(m.ToolCalls))
+			for _, tc := range m.ToolCalls {
+				name, args := tc.Name, ""
+				if tc.Function != nil {
+					name, args = tc.Function.Name, tc.Function.Arguments
+				} else if tc.Arguments != nil {
+					data, _ := json.Marshal(tc.Arguments)
+					args = string(data)
+				}
+				calls = append(calls, name+"("+args+")")
+			}
+			cm.ToolCalls = p.scrub(strings.Join(calls, "; "))
+		}
+		req.Messages = append(req.Messages, cm)
+	}
+
+	return req
+}
+
+func (p *CassetteProvider) scrub(s string) string {
+	for _, r := range p.replacements {
+		s = strings.ReplaceAll(s, r[0], r[1])
+	}
+	for _, v := range cassetteVolatile {
+		s = v.re.ReplaceAllString(s, v.placeholder)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// key hashes a request. Requests loaded from disk are scrubbed again so that
+// redactions added after recording still apply.
+func (p *CassetteProvider) key(req CassetteRequest) string {
+	h := sha256.New()
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00", m.Role, p.scrub(m.Content), p.scrub(m.ToolCalls), m.Images)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func describeLast(req CassetteRequest) string {
+	if len(req.Messages) == 0 {
+		return "no messages"
+	}
+	last := req.Messages[len(req.Messages)-1]
+	content := last.Content
+	if len(content) > 80 {
+		content = content[:80] + "..."
+	}
+	return fmt.Sprintf("%d messages, last %s: %q", len(req.Messages), last.Role, content)
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "turn.json")
+	ctx := context.Background()
+
+	turn := func(workspace, now string) []Message {
+		return []Message{
+			{Role: "system", Content: "Current time: " + now + "\nWorkspace: " + workspace},
+			{Role: "user", Content: "list files in " + workspace},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_" + now, Type: "function", Function: &FunctionCall{Name: "list_dir", Arguments: `{"path":"` + workspace + `"}`}}}},
+			{Role: "tool", ToolCallID: "call_" + now, Content: "notes.md (modified " + now + ")"},
+		}
+	}
+
+	inner := NewScriptedProvider(Reply("one file: notes.md"))
+	recorder, err := NewCassetteProvider(inner, path, CassetteRecord)
+	if err != nil {
+		t.Fatalf("NewCassetteProvider(record): %v", err)
+	}
+	recorder.Redact("/tmp/run-1", "<workspace>")
+	if _, err := recorder.Chat(ctx, turn("/tmp/run-1", "2026-02-11T10:00:00Z"), nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("record Chat: %v", err)
+	}
+
+	player, err := NewCassetteProvider(nil, path, CassetteReplay)
+	if err != nil {
+		t.Fatalf("NewCassetteProvider(replay): %v", err)
+	}
+	player.Redact("/tmp/run-2", "<workspace>")
+
+	resp, err := player.Chat(ctx, turn("/tmp/run-2", "2026-03-01T08:30:00Z"), nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("replay Chat: %v", err)
+	}
+	if resp.Content != "one file: notes.md" {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if player.Unused() != 0 {
+		t.Errorf("Unused = %d, want 0", player.Unused())
+	}
+
+	_, err = player.Chat(ctx, []Message{{Role: "user", Content: "something else"}}, nil, "gpt-4o", nil)
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("err = %v, want a cassette miss", err)
+	}
+}
@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	defaultEmbeddingModel = "text-embedding-3-small"
+	embeddingBatchSize    = 64
+)
+
+// EmbeddingsClient calls an OpenAI-compatible /embeddings endpoint. Local
+// servers such as Ollama and llama.cpp expose the same API.
+type EmbeddingsClient struct {
+	apiKey     string
+	apiBase    string
+	model      string
+	dimensions int
+	httpClient *http.Client
+}
+
+func NewEmbeddingsClient(apiKey, apiBase, model string, dimensions int) *EmbeddingsClient {
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+	return &EmbeddingsClient{
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		model:      model,
+		dimensions: dimensions,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// Model returns the embedding model name. Vectors from different models are
+// not comparable, so stores key their contents by it.
+func (c *EmbeddingsClient) Model() string {
+	return c.model
+}
+
+// Embed returns one vector per input, in input order. Large inputs are sent
+// in batches.
+func (c *EmbeddingsClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(inputs))
+		batch, err := c.embedBatch(ctx, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (c *EmbeddingsClient) embedBatch(ctx context.Context, inputs []string) ([][]float32, error) {
+	requestBody := map[string]interface{}{
+		"model": c.model,
+		"input": inputs,
+	}
+	if c.dimensions > 0 {
+		requestBody["dimensions"] = c.dimensions
+	}
+
+	jsonData, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiBase+"/embeddings", bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings API error: %s", string(body))
+	}
+
+	var apiResponse struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(apiResponse.Data) != len(inputs) {
+		return nil, fmt.Errorf("embeddings API returned %d vectors for %d inputs", len(apiResponse.Data), len(inputs))
+	}
+
+	sort.Slice(apiResponse.Data, func(i, j int) bool {
+		return apiResponse.Data[i].Index < apiResponse.Data[j].Index
+	})
+	vectors := make([][]float32, len(apiResponse.Data))
+	for i, d := range apiResponse.Data {
+		vectors[i] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// CreateEmbeddingsClient builds the embeddings client from config, falling
+// back to the OpenAI provider settings. It returns nil when neither is set.
+func CreateEmbeddingsClient(cfg *config.Config) *EmbeddingsClient {
+	e := cfg.Providers.Embeddings
+	apiKey, apiBase := e.APIKey, e.APIBase
+	if apiBase == "" {
+		if cfg.Providers.OpenAI.APIKey == "" {
+			return nil
+		}
+		apiKey = cfg.Providers.OpenAI.APIKey
+		apiBase = cfg.Providers.OpenAI.APIBase
+		if apiBase == "" {
+			apiBase = "https://api.openai.com/v1"
+		}
+	}
+	return NewEmbeddingsClient(apiKey, apiBase, e.Model, e.Dimensions)
+}
@@ -0,0 +1,62 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEmbeddingsClientBatchesAndOrders(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		requests++
+		var body struct {
+			Model      string   `json:"model"`
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "nomic-embed-text" || body.Dimensions != 2 {
+			t.Errorf("unexpected request: %+v", body)
+		}
+
+		// Answer out of order, as the API allows
+		type item struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		data := make([]item, 0, len(body.Input))
+		for i := len(body.Input) - 1; i >= 0; i-- {
+			data = append(data, item{Index: i, Embedding: []float32{float32(len(body.Input[i])), 1}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	inputs := make([]string, embeddingBatchSize+3)
+	for i := range inputs {
+		inputs[i] = string(make([]byte, i))
+	}
+
+	client := NewEmbeddingsClient("", server.URL+"/v1/", "nomic-embed-text", 2)
+	vectors, err := client.Embed(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if len(vectors) != len(inputs) {
+		t.Fatalf("got %d vectors, want %d", len(vectors), len(inputs))
+	}
+	for i, vec := range vectors {
+		if int(vec[0]) != i {
+			t.Fatalf("vector %d belongs to input %d", i, int(vec[0]))
+		}
+	}
+}
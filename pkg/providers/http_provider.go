@@ -39,6 +39,11 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 		return nil, fmt.Errorf("API base not configured")
 	}
 
+	// Zhipu's vision models expect raw base64 image data rather than data URLs
+	if strings.Contains(p.apiBase, "bigmodel.cn") {
+		messages = stripImageDataURLPrefix(messages)
+	}
+
 	requestBody := map[string]interface{}{
 		"model":    model,
 		"messages": messages,
@@ -62,6 +67,12 @@ func (p *HTTPProvider) Chat(ctx context.Context, messages []Message, tools []Too
 		requestBody["temperature"] = temperature
 	}
 
+	if format, ok := options["response_format"].(map[string]interface{}); ok {
+		if responseFormat := p.responseFormat(format); responseFormat != nil {
+			requestBody["response_format"] = responseFormat
+		}
+	}
+
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -166,11 +177,73 @@ func (p *HTTPProvider) parseResponse(body []byte) (*LLMResponse, error) {
 	}, nil
 }
 
+// stripImageDataURLPrefix returns a copy of messages whose image parts carry
+// bare base64 payloads instead of "data:<mime>;base64,..." URLs.
+func stripImageDataURLPrefix(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		out[i] = m
+		if len(m.Parts) == 0 {
+			continue
+		}
+		parts := make([]ContentPart, len(m.Parts))
+		for j, part := range m.Parts {
+			parts[j] = part
+			if part.ImageURL != nil && strings.HasPrefix(part.ImageURL.URL, "data:") {
+				if idx := strings.Index(part.ImageURL.URL, ","); idx > 0 {
+					parts[j].ImageURL = &ImageURL{URL: part.ImageURL.URL[idx+1:], Detail: part.ImageURL.Detail}
+				}
+			}
+		}
+		out[i].Parts = parts
+	}
+	return out
+}
+
+// responseFormat adapts a json_schema response format to what the backend
+// accepts: DeepSeek and Zhipu only support plain JSON mode, and Anthropic's
+// OpenAI-compatible endpoint ignores the option altogether.
+func (p *HTTPProvider) responseFormat(format map[string]interface{}) map[string]interface{} {
+	switch {
+	case strings.Contains(p.apiBase, "anthropic.com"):
+		return nil
+	case strings.Contains(p.apiBase, "deepseek.com"), strings.Contains(p.apiBase, "bigmodel.cn"):
+		return map[string]interface{}{"type": "json_object"}
+	default:
+		return format
+	}
+}
+
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
 
+// CreateProvider builds the provider for the configured model, wrapped in a
+// cassette when one is configured. Replay mode needs no API key.
 func CreateProvider(cfg *config.Config) (LLMProvider, error) {
+	cassette := cfg.Providers.Cassette
+	if cassette.Path == "" {
+		return createHTTPProvider(cfg)
+	}
+
+	var inner LLMProvider
+	if cassette.Mode != CassetteReplay {
+		provider, err := createHTTPProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		inner = provider
+	}
+
+	provider, err := NewCassetteProvider(inner, cassette.Path, cassette.Mode)
+	if err != nil {
+		return nil, err
+	}
+	provider.Redact(cfg.WorkspacePath(), "<workspace>")
+	return provider, nil
+}
+
+func createHTTPProvider(cfg *config.Config) (LLMProvider, error) {
 	model := cfg.Agents.Defaults.Model
 
 	var apiKey, apiBase string
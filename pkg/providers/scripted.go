@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ScriptedStep is one canned answer of a ScriptedProvider.
+type ScriptedStep struct {
+	// Expect, when set, must return nil for the request the step answers;
+	// an error fails the Chat call, which surfaces as a failed turn.
+	Expect   func(messages []Message) error
+	Response *LLMResponse
+	Err      error
+}
+
+// ScriptedCall records a request received by a ScriptedProvider.
+type ScriptedCall struct {
+	Messages []Message
+	Tools    []string
+	Model    string
+	Options  map[string]interface{}
+}
+
+// ScriptedProvider is a fake LLMProvider that answers each Chat call with the
+// next step of a fixed script, for tests that need to drive the agent loop
+// through tool calls without a network.
+type ScriptedProvider struct {
+	mu    sync.Mutex
+	steps []ScriptedStep
+	calls []ScriptedCall
+	next  int
+}
+
+func NewScriptedProvider(steps ...ScriptedStep) *ScriptedProvider {
+	return &ScriptedProvider{steps: steps}
+}
+
+// Reply is a step answering with plain text.
+func Reply(content string) ScriptedStep {
+	return ScriptedStep{Response: &LLMResponse{Content: content, FinishReason: "stop"}}
+}
+
+// CallTool is a step asking for a single tool call.
+func CallTool(name string, args map[string]interface{}) ScriptedStep {
+	return ScriptedStep{Response: &LLMResponse{
+		ToolCalls:    []ToolCall{{Name: name, Arguments: args}},
+		FinishReason: "tool_calls",
+	}}
+}
+
+// Then attaches an expectation about the request to a step.
+func (s ScriptedStep) Then(expect func(messages []Message) error) ScriptedStep {
+	s.Expect = expect
+	return s
+}
+
+// Add appends steps to the script.
+func (p *ScriptedProvider) Add(steps ...ScriptedStep) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.steps = append(p.steps, steps...)
+}
+
+func (p *ScriptedProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]interface{}) (*LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	call := ScriptedCall{
+		Messages: append([]Message(nil), messages...),
+		Model:    model,
+		Options:  options,
+	}
+	for _, t := range tools {
+		call.Tools = append(call.Tools, t.Function.Name)
+	}
+	p.calls = append(p.calls, call)
+
+	if p.next >= len(p.steps) {
+		return nil, fmt.Errorf("scripted provider: unexpected call %d, script has %d steps", len(p.calls), len(p.steps))
+	}
+	step := p.steps[p.next]
+	p.next++
+
+	if step.Expect != nil {
+		if err := step.Expect(messages); err != nil {
+			return nil, fmt.Errorf("scripted provider: step %d: %w", p.next, err)
+		}
+	}
+	if step.Err != nil {
+		return nil, step.Err
+	}
+
+	// Hand out a copy with stable tool call IDs so callers can't alter the script
+	response := *step.Response
+	response.ToolCalls = make([]ToolCall, len(step.Response.ToolCalls))
+	for i, tc := range step.Response.ToolCalls {
+		if tc.ID == "" {
+			tc.ID = fmt.Sprintf("call_%d_%d", p.next, i)
+		}
+		response.ToolCalls[i] = tc
+	}
+	return &response, nil
+}
+
+func (p *ScriptedProvider) GetDefaultModel() string {
+	return "scripted"
+}
+
+// Calls returns the requests received so far.
+func (p *ScriptedProvider) Calls() []ScriptedCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ScriptedCall(nil), p.calls...)
+}
+
+// Remaining returns how many steps have not been used yet.
+func (p *ScriptedProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.steps) - p.next
+}
@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+)
+
+// JSONSchemaFormat builds the OpenAI-style "response_format" chat option that
+// asks the model to answer with a JSON document matching schema. Providers
+// that cannot enforce a schema degrade it (see HTTPProvider), so callers must
+// still validate the reply with CheckStructured.
+func JSONSchemaFormat(name string, schema map[string]interface{}) map[string]interface{} {
+	if name == "" {
+		name = "response"
+	}
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   name,
+			"schema": schema,
+			"strict": false,
+		},
+	}
+}
+
+// SchemaError is returned when a model reply is not valid JSON or does not
+// match the requested schema.
+type SchemaError struct {
+	Errors []jsonschema.ValidationError
+}
+
+func (e *SchemaError) Error() string {
+	return "response does not match schema:\n" + jsonschema.FormatErrors(e.Errors, 20)
+}
+
+// CheckStructured extracts the JSON document from a model reply (tolerating
+// markdown code fences and surrounding prose) and validates it against schema.
+func CheckStructured(content string, schema *jsonschema.Schema) (json.RawMessage, error) {
+	doc := ExtractJSON(content)
+	if doc == "" {
+		return nil, &SchemaError{Errors: []jsonschema.ValidationError{{Path: "$", Message: "no JSON document found in the reply"}}}
+	}
+	if errs := schema.ValidateJSON([]byte(doc)); len(errs) > 0 {
+		return nil, &SchemaError{Errors: errs}
+	}
+	return json.RawMessage(doc), nil
+}
+
+// RepairMessage builds the follow-up user message that feeds validation
+// errors back to the model.
+func RepairMessage(err error) Message {
+	return Message{
+		Role: "user",
+		Content: fmt.Sprintf(`Your previous reply was rejected: %v
+
+Reply again with only the corrected JSON document. Do not add commentary or code fences.`, err),
+	}
+}
+
+// ExtractJSON returns the JSON object or array contained in content, or ""
+// if there is none.
+func ExtractJSON(content string) string {
+	s := strings.TrimSpace(content)
+
+	if idx := strings.Index(s, "```"); idx >= 0 {
+		rest := s[idx+3:]
+		if nl := strings.Index(rest, "\n"); nl >= 0 {
+			rest = rest[nl+1:]
+		}
+		if end := strings.Index(rest, "```"); end >= 0 {
+			s = strings.TrimSpace(rest[:end])
+		}
+	}
+
+	if json.Valid([]byte(s)) && (strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")) {
+		return s
+	}
+
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := "}"
+	if s[start] == '[' {
+		closer = "]"
+	}
+	for end := strings.LastIndex(s, closer); end > start; end = strings.LastIndex(s[:end], closer) {
+		if candidate := s[start : end+1]; json.Valid([]byte(candidate)) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// ChatJSON asks the model for a JSON document matching schema without tools,
+// feeding validation errors back for up to maxRepairs further attempts.
+func ChatJSON(ctx context.Context, provider LLMProvider, messages []Message, model, schemaName string, schema *jsonschema.Schema, options map[string]interface{}, maxRepairs int) (json.RawMessage, error) {
+	opts := make(map[string]interface{}, len(options)+1)
+	for k, v := range options {
+		opts[k] = v
+	}
+	opts["response_format"] = JSONSchemaFormat(schemaName, schema.Map())
+
+	messages = append([]Message(nil), messages...)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		response, err := provider.Chat(ctx, messages, nil, model, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := CheckStructured(response.Content, schema)
+		if err == nil {
+			return doc, nil
+		}
+		lastErr = err
+
+		messages = append(messages,
+			Message{Role: "assistant", Content: response.Content},
+			RepairMessage(err),
+		)
+	}
+
+	return nil, fmt.Errorf("no valid JSON after %d attempts: %w", maxRepairs+1, lastErr)
+}
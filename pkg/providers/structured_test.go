@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{`Here you go: [1, 2] hope that helps`, `[1, 2]`},
+		{`Result: {"a": {"b": 2}} (see {note})`, `{"a": {"b": 2}}`},
+		{`no json here`, ``},
+	}
+	for _, tt := range tests {
+		if got := ExtractJSON(tt.in); got != tt.want {
+			t.Errorf("ExtractJSON(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestChatJSONRepairsInvalidReply(t *testing.T) {
+	schema, _ := jsonschema.Parse([]byte(`{"type": "object", "required": ["score"], "properties": {"score": {"type": "integer"}}}`))
+	provider := NewScriptedProvider(Reply(`{"score": "high"}`), Reply(`{"score": 8}`))
+
+	doc, err := ChatJSON(context.Background(), provider, []Message{{Role: "user", Content: "score it"}}, "test", "score", schema, nil, 2)
+	if err != nil {
+		t.Fatalf("ChatJSON: %v", err)
+	}
+	if string(doc) != `{"score": 8}` {
+		t.Errorf("doc = %s", doc)
+	}
+	calls := provider.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("calls = %d, want 2", len(calls))
+	}
+	repair := calls[1].Messages[len(calls[1].Messages)-1]
+	if repair.Role != "user" || !strings.Contains(repair.Content, "$.score: expected integer") {
+		t.Errorf("repair message = %+v, want the validation error fed back", repair)
+	}
+}
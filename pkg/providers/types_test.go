@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageMarshalPlainContent(t *testing.T) {
+	data, err := json.Marshal(Message{Role: "user", Content: "hello"})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	json.Unmarshal(data, &decoded)
+	if decoded["content"] != "hello" {
+		t.Errorf("content = %v, want plain string", decoded["content"])
+	}
+}
+
+func TestMessageMarshalWithParts(t *testing.T) {
+	msg := Message{
+		Role:    "user",
+		Content: "what is on this chart?",
+		Parts: []ContentPart{
+			{Type: "image_url", ImageURL: &ImageURL{URL: "data:image/jpeg;base64,AAAA"}},
+		},
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("content is not an array of parts: %v (%s)", err, data)
+	}
+	if decoded.Role != "user" || len(decoded.Content) != 2 {
+		t.Fatalf("unexpected message: %s", data)
+	}
+	if decoded.Content[0].Type != "text" || decoded.Content[0].Text != msg.Content {
+		t.Errorf("first part = %+v, want the text", decoded.Content[0])
+	}
+	if decoded.Content[1].ImageURL == nil || decoded.Content[1].ImageURL.URL != "data:image/jpeg;base64,AAAA" {
+		t.Errorf("second part = %+v, want the image", decoded.Content[1])
+	}
+}
+
+func TestStripImageDataURLPrefix(t *testing.T) {
+	in := []Message{{
+		Role:  "user",
+		Parts: []ContentPart{{Type: "image_url", ImageURL: &ImageURL{URL: "data:image/jpeg;base64,AAAA"}}},
+	}}
+
+	out := stripImageDataURLPrefix(in)
+	if got := out[0].Parts[0].ImageURL.URL; got != "AAAA" {
+		t.Errorf("URL = %q, want bare base64", got)
+	}
+	if in[0].Parts[0].ImageURL.URL != "data:image/jpeg;base64,AAAA" {
+		t.Errorf("input messages were modified")
+	}
+}
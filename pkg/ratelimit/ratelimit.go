@@ -0,0 +1,289 @@
+// Package ratelimit keeps outgoing HTTP requests within each upstream's
+// limits. Every host gets a token bucket; hosts that answer 429 or 418 are
+// left alone for the Retry-After time (or an exponential backoff), and hosts
+// that report their used request weight, like Binance, are paused before
+// the budget runs out.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Limit is the budget of the hosts matching Host, an exact name or a glob
+// such as "*.binance.com".
+type Limit struct {
+	Host        string
+	Rate        float64 // Requests per second; 0 means unlimited
+	Burst       int
+	WeightLimit int // Per-minute weight budget reported in X-MBX-USED-WEIGHT headers
+}
+
+const (
+	// weightHeadroom pauses a host once this share of its weight budget is used.
+	weightHeadroom = 0.9
+
+	// maxBackoff caps the exponential backoff after repeated 429/418s.
+	maxBackoff = 30 * time.Minute
+)
+
+// LimitError is returned instead of sending a request that would have to
+// wait longer than the limiter allows.
+type LimitError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limited by %s, next request allowed in %s",
+		e.Host, time.Until(e.Until).Round(time.Second))
+}
+
+type hostState struct {
+	limit        Limit
+	tokens       float64
+	last         time.Time
+	blockedUntil time.Time
+	strikes      int // Consecutive 429/418 responses
+}
+
+// Limiter tracks the budget of every host it has seen. It is safe for
+// concurrent use and meant to be shared by all tools.
+type Limiter struct {
+	limits   []Limit
+	fallback Limit
+	maxWait  time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// New creates a limiter. Hosts no limit matches get fallback. Requests that
+// would wait longer than maxWait fail with a LimitError.
+func New(limits []Limit, fallback Limit, maxWait time.Duration) *Limiter {
+	return &Limiter{
+		limits:   limits,
+		fallback: fallback,
+		maxWait:  maxWait,
+		now:      time.Now,
+		hosts:    make(map[string]*hostState),
+	}
+}
+
+// NewFromConfig returns nil when rate limiting is disabled; a nil Limiter
+// passes requests through unchanged.
+func NewFromConfig(cfg config.RateLimitConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	limits := make([]Limit, 0, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		limits = append(limits, Limit{
+			Host:        strings.ToLower(h.Host),
+			Rate:        float64(h.RequestsPerMinute) / 60,
+			Burst:       h.Burst,
+			WeightLimit: h.WeightPerMinute,
+		})
+	}
+	fallback := Limit{Rate: float64(cfg.DefaultRequestsPerMinute) / 60}
+	return New(limits, fallback, time.Duration(cfg.MaxWaitSeconds)*time.Second)
+}
+
+// Wrap returns a transport that applies the limiter to base
+// (http.DefaultTransport when nil).
+func (l *Limiter) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if l == nil {
+		return base
+	}
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	if err := t.limiter.acquire(req.Context(), host); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limiter.observe(host, resp)
+	}
+	return resp, err
+}
+
+func (l *Limiter) state(host string) *hostState {
+	st, ok := l.hosts[host]
+	if !ok {
+		limit := l.fallback
+		for _, candidate := range l.limits {
+			if ok, _ := path.Match(candidate.Host, host); ok || candidate.Host == host {
+				limit = candidate
+				break
+			}
+		}
+		if limit.Burst <= 0 {
+			limit.Burst = max(1, int(limit.Rate*10))
+		}
+		st = &hostState{limit: limit, tokens: float64(limit.Burst), last: l.now()}
+		l.hosts[host] = st
+	}
+	return st
+}
+
+// acquire waits for the host's backoff to end and takes a token.
+func (l *Limiter) acquire(ctx context.Context, host string) error {
+	for {
+		l.mu.Lock()
+		st := l.state(host)
+		now := l.now()
+		if now.Before(st.blockedUntil) {
+			until := st.blockedUntil
+			l.mu.Unlock()
+			if err := l.wait(ctx, host, until); err != nil {
+				return err
+			}
+			continue
+		}
+		if st.limit.Rate <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+
+		// Refill, then reserve a token; a negative balance is a queue position
+		elapsed := now.Sub(st.last).Seconds()
+		st.tokens = min(float64(st.limit.Burst), st.tokens+elapsed*st.limit.Rate)
+		st.last = now
+		st.tokens--
+		if st.tokens >= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		until := now.Add(time.Duration(-st.tokens / st.limit.Rate * float64(time.Second)))
+		l.mu.Unlock()
+
+		if err := l.wait(ctx, host, until); err != nil {
+			// Give the reservation back
+			l.mu.Lock()
+			st.tokens++
+			l.mu.Unlock()
+			return err
+		}
+		return nil
+	}
+}
+
+func (l *Limiter) wait(ctx context.Context, host string, until time.Time) error {
+	d := until.Sub(l.now())
+	if d <= 0 {
+		return nil
+	}
+	if d > l.maxWait {
+		return &LimitError{Host: host, Until: until}
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return &LimitError{Host: host, Until: until}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// observe updates the host's state from a response.
+func (l *Limiter) observe(host string, resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st := l.state(host)
+	now := l.now()
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot:
+		// Binance answers 418 once an IP is banned for ignoring 429s
+		st.strikes++
+		backoff := retryAfter(resp.Header, now)
+		if backoff <= 0 {
+			base := 30 * time.Second
+			if resp.StatusCode == http.StatusTeapot {
+				base = 2 * time.Minute
+			}
+			backoff = min(base<<min(st.strikes-1, 10), maxBackoff)
+		}
+		st.blockedUntil = now.Add(backoff)
+		logger.WarnCF("ratelimit", "Upstream rate limit hit, backing off",
+			map[string]interface{}{
+				"host":    host,
+				"status":  resp.StatusCode,
+				"backoff": backoff.String(),
+			})
+	case resp.StatusCode < 400:
+		st.strikes = 0
+	}
+
+	if st.limit.WeightLimit > 0 {
+		if used := usedWeight(resp.Header); used > 0 {
+			if float64(used) >= float64(st.limit.WeightLimit)*weightHeadroom {
+				// The weight window resets at the start of each minute
+				reset := now.Truncate(time.Minute).Add(time.Minute)
+				if reset.After(st.blockedUntil) {
+					st.blockedUntil = reset
+					logger.WarnCF("ratelimit", "Request weight nearly used up, pausing until the next minute",
+						map[string]interface{}{
+							"host":   host,
+							"used":   used,
+							"budget": st.limit.WeightLimit,
+						})
+				}
+			}
+		}
+	}
+}
+
+// retryAfter reads a Retry-After header in seconds or as an HTTP date. The
+// server's wait is honored in full; only the fallback backoff is capped.
+func retryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// usedWeight reads Binance's X-MBX-USED-WEIGHT-1M (or the older
+// X-MBX-USED-WEIGHT) header.
+func usedWeight(h http.Header) int {
+	for _, name := range []string{"X-Mbx-Used-Weight-1m", "X-Mbx-Used-Weight"} {
+		if v := h.Get(name); v != "" {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, error) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestTokenBucketSpacesRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// 20 requests per second with a burst of 2
+	limiter := New(nil, Limit{Rate: 20, Burst: 2}, time.Second)
+	client := &http.Client{Transport: limiter.Wrap(nil)}
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if _, err := get(t, client, server.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two go out at once, the other two wait ~50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("requests were not spaced out: %s", elapsed)
+	}
+
+	// A request that would wait past maxWait fails instead
+	strict := New(nil, Limit{Rate: 0.1, Burst: 1}, 100*time.Millisecond)
+	client = &http.Client{Transport: strict.Wrap(nil)}
+	if _, err := get(t, client, server.URL); err != nil {
+		t.Fatal(err)
+	}
+	_, err := get(t, client, server.URL)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+}
+
+func TestBackoffOn429(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	limiter := New(nil, Limit{}, time.Second)
+	client := &http.Client{Transport: limiter.Wrap(nil)}
+
+	resp, err := get(t, client, server.URL)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the 429 to be passed on, got %v, %v", resp, err)
+	}
+	_, err = get(t, client, server.URL)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || time.Until(limitErr.Until) < 100*time.Second {
+		t.Fatalf("expected to back off for the Retry-After time, got %v", err)
+	}
+	if requests != 1 {
+		t.Fatalf("the host was called during its backoff: %d requests", requests)
+	}
+
+	// Without Retry-After, repeated 418s back off exponentially
+	st := limiter.hosts[hostOf(server.URL)]
+	limiter.mu.Lock()
+	st.blockedUntil = time.Time{}
+	limiter.mu.Unlock()
+	limiter.observe(hostOf(server.URL), &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{}})
+	first := time.Until(st.blockedUntil)
+	limiter.observe(hostOf(server.URL), &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{}})
+	if second := time.Until(st.blockedUntil); second < first*3/2 {
+		t.Fatalf("backoff did not grow: %s then %s", first, second)
+	}
+
+	// A ban longer than the backoff cap is waited out in full
+	limiter.observe(hostOf(server.URL), &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{"Retry-After": {"7200"}}})
+	if wait := time.Until(st.blockedUntil); wait < 119*time.Minute {
+		t.Fatalf("Retry-After was cut short: %s", wait)
+	}
+}
+
+func TestBinanceWeightHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", r.URL.Query().Get("used"))
+	}))
+	defer server.Close()
+
+	limiter := New([]Limit{{Host: "127.0.0.*", Rate: 100, WeightLimit: 1000}}, Limit{}, 0)
+	client := &http.Client{Transport: limiter.Wrap(nil)}
+
+	if _, err := get(t, client, server.URL+"?used=500"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(t, client, server.URL+"?used=950"); err != nil {
+		t.Fatal(err)
+	}
+	// 95% of the budget is used, so the host pauses until the next minute
+	_, err := get(t, client, server.URL)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Until.Second() != 0 || time.Until(limitErr.Until) > time.Minute {
+		t.Fatalf("expected a pause until the next minute, got %v", err)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	if NewFromConfig(config.RateLimitConfig{Enabled: false}) != nil {
+		t.Fatal("a disabled config should give a nil limiter")
+	}
+	var nilLimiter *Limiter
+	if nilLimiter.Wrap(nil) != http.DefaultTransport {
+		t.Fatal("a nil limiter should pass requests through")
+	}
+
+	limiter := NewFromConfig(config.DefaultConfig().Tools.RateLimit)
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if st := limiter.state("api.binance.com"); st.limit.WeightLimit != 6000 || st.limit.Rate != 10 {
+		t.Fatalf("unexpected binance limit: %+v", st.limit)
+	}
+	if st := limiter.state("feeds.example.com"); st.limit.Rate != 2 || st.limit.Burst != 20 {
+		t.Fatalf("unexpected default limit: %+v", st.limit)
+	}
+}
+
+func hostOf(raw string) string {
+	u, _ := url.Parse(raw)
+	return u.Hostname()
+}
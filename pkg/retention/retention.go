@@ -0,0 +1,350 @@
+// Package retention keeps the workspace data directory bounded. Policies
+// limit the age, count and total size of the files in a data/ subdirectory,
+// optionally thinning old snapshots first and bundling what they remove into
+// compressed archives.
+package retention
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+)
+
+// ArchiveDir is the data/ subdirectory that holds archive bundles.
+const ArchiveDir = "archive"
+
+// Policy bounds the files matching Pattern directly inside data/<Path>. Zero
+// limits are disabled.
+type Policy struct {
+	Path            string
+	Pattern         string
+	MaxAge          time.Duration
+	MaxCount        int
+	MaxBytes        int64
+	DownsampleAfter time.Duration
+	DownsampleEvery time.Duration
+	Archive         bool
+}
+
+// PoliciesFromConfig converts the configured policies.
+func PoliciesFromConfig(cfg []config.RetentionPolicyConfig) ([]Policy, error) {
+	policies := make([]Policy, 0, len(cfg))
+	for _, pc := range cfg {
+		clean := filepath.Clean(pc.Path)
+		if pc.Path == "" || filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+			return nil, fmt.Errorf("retention policy path %q must be a subdirectory of data/", pc.Path)
+		}
+		p := Policy{
+			Path:            clean,
+			Pattern:         pc.Pattern,
+			MaxAge:          time.Duration(pc.MaxAgeDays) * 24 * time.Hour,
+			MaxCount:        pc.MaxCount,
+			MaxBytes:        int64(pc.MaxSizeMB) << 20,
+			DownsampleAfter: time.Duration(pc.DownsampleAfterDays) * 24 * time.Hour,
+			Archive:         pc.Archive,
+		}
+		if pc.DownsampleInterval != "" {
+			every, err := parseInterval(pc.DownsampleInterval)
+			if err != nil {
+				return nil, fmt.Errorf("retention policy %s: %w", pc.Path, err)
+			}
+			p.DownsampleEvery = every
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// parseInterval accepts Go durations plus a "d" suffix for days.
+func parseInterval(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if _, err := fmt.Sscanf(days, "%d", &n); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid downsample_interval %q (use e.g. \"1h\" or \"1d\")", s)
+	}
+	return d, nil
+}
+
+// Removal is a file a policy removes and why.
+type Removal struct {
+	Path   string `json:"path"` // Relative to data/
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"` // "downsample", "age", "count" or "size"
+}
+
+// PolicyReport is the outcome of one policy.
+type PolicyReport struct {
+	Path       string    `json:"path"`
+	Removed    []Removal `json:"removed,omitempty"`
+	Archive    string    `json:"archive,omitempty"` // Bundle the removed files went to
+	FreedBytes int64     `json:"freed_bytes"`
+	Kept       int       `json:"kept"`
+	KeptBytes  int64     `json:"kept_bytes"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Report is the outcome of a retention run.
+type Report struct {
+	DryRun   bool           `json:"dry_run"`
+	Policies []PolicyReport `json:"policies"`
+}
+
+// Removed returns the number of files removed (or to be removed).
+func (r *Report) Removed() (files int, bytes int64) {
+	for _, p := range r.Policies {
+		files += len(p.Removed)
+		bytes += p.FreedBytes
+	}
+	return files, bytes
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	verb := "Removed"
+	if r.DryRun {
+		verb = "Would remove"
+	}
+	for _, p := range r.Policies {
+		switch {
+		case p.Error != "":
+			fmt.Fprintf(&sb, "%s: error: %s\n", p.Path, p.Error)
+		case len(p.Removed) == 0:
+			fmt.Fprintf(&sb, "%s: nothing to remove (%d files, %s)\n", p.Path, p.Kept, FormatBytes(p.KeptBytes))
+		default:
+			counts := make(map[string]int)
+			for _, rm := range p.Removed {
+				counts[rm.Reason]++
+			}
+			reasons := make([]string, 0, len(counts))
+			for _, reason := range []string{"downsample", "age", "count", "size"} {
+				if counts[reason] > 0 {
+					reasons = append(reasons, fmt.Sprintf("%d %s", counts[reason], reason))
+				}
+			}
+			fmt.Fprintf(&sb, "%s: %s %d files, %s (%s); %d files, %s kept\n", p.Path, verb, len(p.Removed),
+				FormatBytes(p.FreedBytes), strings.Join(reasons, ", "), p.Kept, FormatBytes(p.KeptBytes))
+			if p.Archive != "" {
+				fmt.Fprintf(&sb, "  archived to %s\n", p.Archive)
+			}
+		}
+	}
+	return sb.String()
+}
+
+type file struct {
+	rel     string
+	abs     string
+	size    int64
+	modTime time.Time
+}
+
+// Apply runs the policies against dataDir. With dryRun set nothing is
+// changed. A failing policy is reported and doesn't stop the others.
+func Apply(dataDir string, policies []Policy, now time.Time, dryRun bool) *Report {
+	report := &Report{DryRun: dryRun}
+	for _, p := range policies {
+		pr, err := apply(dataDir, p, now, dryRun)
+		if err != nil {
+			pr.Error = err.Error()
+		}
+		report.Policies = append(report.Policies, pr)
+	}
+	return report
+}
+
+func apply(dataDir string, p Policy, now time.Time, dryRun bool) (PolicyReport, error) {
+	pr := PolicyReport{Path: filepath.ToSlash(p.Path)}
+	files, err := listFiles(dataDir, p)
+	if err != nil {
+		return pr, err
+	}
+
+	reasons := selectRemovals(files, p, now)
+	var removed []file
+	for i, f := range files {
+		if reason, ok := reasons[i]; ok {
+			pr.Removed = append(pr.Removed, Removal{Path: f.rel, Size: f.size, Reason: reason})
+			pr.FreedBytes += f.size
+			removed = append(removed, f)
+		} else {
+			pr.Kept++
+			pr.KeptBytes += f.size
+		}
+	}
+	if dryRun || len(removed) == 0 {
+		return pr, nil
+	}
+
+	if p.Archive {
+		name := filepath.Join(dataDir, ArchiveDir, p.Path, now.UTC().Format("2006-01-02_150405")+".tar.gz")
+		bundle, err := writeBundle(name, removed)
+		if err != nil {
+			return pr, fmt.Errorf("archiving: %w", err)
+		}
+		rel, _ := filepath.Rel(dataDir, bundle)
+		pr.Archive = filepath.ToSlash(rel)
+	}
+	for _, f := range removed {
+		if err := os.Remove(f.abs); err != nil && !os.IsNotExist(err) {
+			return pr, err
+		}
+	}
+	return pr, nil
+}
+
+// listFiles returns the files a policy covers, newest first.
+func listFiles(dataDir string, p Policy) ([]file, error) {
+	dir := filepath.Join(dataDir, p.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	pattern := p.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+	var files []file
+	for _, e := range entries {
+		// Skip subdirectories, lock and temp files
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, e.Name()); !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{
+			rel:     filepath.ToSlash(filepath.Join(p.Path, e.Name())),
+			abs:     filepath.Join(dir, e.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// selectRemovals decides which of files (newest first) a policy removes, by
+// index. Downsampling runs first, then the age, count and size limits apply
+// to what is left.
+func selectRemovals(files []file, p Policy, now time.Time) map[int]string {
+	reasons := make(map[int]string)
+
+	if p.DownsampleEvery > 0 {
+		seen := make(map[int64]bool)
+		for i, f := range files {
+			if now.Sub(f.modTime) < p.DownsampleAfter {
+				continue
+			}
+			// Files are newest first, so the first one seen in a bucket is kept
+			bucket := f.modTime.UnixNano() / int64(p.DownsampleEvery)
+			if seen[bucket] {
+				reasons[i] = "downsample"
+			}
+			seen[bucket] = true
+		}
+	}
+
+	kept := 0
+	var keptBytes int64
+	for i, f := range files {
+		if _, ok := reasons[i]; ok {
+			continue
+		}
+		switch {
+		case p.MaxAge > 0 && now.Sub(f.modTime) > p.MaxAge:
+			reasons[i] = "age"
+		case p.MaxCount > 0 && kept >= p.MaxCount:
+			reasons[i] = "count"
+		case p.MaxBytes > 0 && keptBytes+f.size > p.MaxBytes:
+			reasons[i] = "size"
+		default:
+			kept++
+			keptBytes += f.size
+		}
+	}
+	return reasons
+}
+
+// writeBundle stores files in a gzipped tar named by their data/ paths and
+// returns the bundle's path. The archive is streamed to disk, so its size
+// isn't bounded by memory.
+func writeBundle(path string, files []file) (string, error) {
+	// Runs within the same second must not overwrite each other's bundle
+	if _, err := os.Stat(path); err == nil {
+		path = strings.TrimSuffix(path, ".tar.gz") + fmt.Sprintf("_%d.tar.gz", time.Now().UnixNano())
+	}
+
+	return path, fileio.WriteStream(path, 0644, func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		for _, f := range files {
+			if err := addToBundle(tw, f); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+func addToBundle(tw *tar.Writer, f file) error {
+	src, err := os.Open(f.abs)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	hdr := &tar.Header{
+		Name:    f.rel,
+		Mode:    0644,
+		Size:    f.size,
+		ModTime: f.modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, src, f.size); err != nil {
+		return fmt.Errorf("%s: %w", f.rel, err)
+	}
+	return nil
+}
+
+// FormatBytes renders a size like "12.3 MB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,207 @@
+package retention
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+var now = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func writeAt(t *testing.T, dataDir, rel string, size int, mod time.Time) {
+	t.Helper()
+	path := filepath.Join(dataDir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func remaining(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestApplyAgeCountAndSize(t *testing.T) {
+	dataDir := t.TempDir()
+	for i, age := range []int{1, 2, 3, 10, 40} {
+		writeAt(t, dataDir, "reports/r"+string(rune('a'+i))+".md", 100, now.AddDate(0, 0, -age))
+	}
+	writeAt(t, dataDir, "reports/keep.txt", 100, now.AddDate(-1, 0, 0))
+
+	policies := []Policy{{Path: "reports", Pattern: "*.md", MaxAge: 30 * 24 * time.Hour, MaxCount: 3}}
+	report := Apply(dataDir, policies, now, false)
+	pr := report.Policies[0]
+	if pr.Error != "" {
+		t.Fatal(pr.Error)
+	}
+	reasons := map[string]string{}
+	for _, rm := range pr.Removed {
+		reasons[rm.Path] = rm.Reason
+	}
+	if reasons["reports/re.md"] != "age" || reasons["reports/rd.md"] != "count" || len(reasons) != 2 {
+		t.Fatalf("unexpected removals: %+v", pr.Removed)
+	}
+	if pr.Kept != 3 || pr.FreedBytes != 200 {
+		t.Fatalf("kept %d, freed %d", pr.Kept, pr.FreedBytes)
+	}
+	if got := remaining(t, filepath.Join(dataDir, "reports")); strings.Join(got, ",") != "keep.txt,ra.md,rb.md,rc.md" {
+		t.Fatalf("remaining files: %v", got)
+	}
+
+	// The size limit keeps the newest files that fit
+	report = Apply(dataDir, []Policy{{Path: "reports", MaxBytes: 250}}, now, false)
+	if got := remaining(t, filepath.Join(dataDir, "reports")); strings.Join(got, ",") != "ra.md,rb.md" {
+		t.Fatalf("remaining after size limit: %v", got)
+	}
+	if files, bytes := report.Removed(); files != 2 || bytes != 200 {
+		t.Fatalf("Removed() = %d, %d", files, bytes)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	dataDir := t.TempDir()
+	writeAt(t, dataDir, "scans/old.json", 10, now.AddDate(0, 0, -60))
+	writeAt(t, dataDir, "scans/new.json", 10, now)
+
+	report := Apply(dataDir, []Policy{{Path: "scans", MaxAge: 24 * time.Hour, Archive: true}}, now, true)
+	if len(report.Policies[0].Removed) != 1 || !strings.Contains(report.String(), "Would remove 1 files") {
+		t.Fatalf("unexpected dry-run report: %s", report.String())
+	}
+	if got := remaining(t, filepath.Join(dataDir, "scans")); len(got) != 2 {
+		t.Fatalf("dry run removed files: %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, ArchiveDir)); !os.IsNotExist(err) {
+		t.Fatal("dry run wrote an archive")
+	}
+}
+
+func TestApplyDownsampleAndArchive(t *testing.T) {
+	dataDir := t.TempDir()
+	// Three recent scans stay; six older ones, two per hour, thin out to one per hour
+	base := now.Add(-3 * 24 * time.Hour).Truncate(time.Hour)
+	var old []string
+	for i := 0; i < 6; i++ {
+		name := "scans/old_" + string(rune('a'+i)) + ".json"
+		writeAt(t, dataDir, name, 10, base.Add(time.Duration(i)*30*time.Minute))
+		old = append(old, name)
+	}
+	for i := 0; i < 3; i++ {
+		writeAt(t, dataDir, "scans/new_"+string(rune('a'+i))+".json", 10, now.Add(-time.Duration(i)*time.Minute))
+	}
+
+	policies := []Policy{{
+		Path:            "scans",
+		Pattern:         "*.json",
+		DownsampleAfter: 2 * 24 * time.Hour,
+		DownsampleEvery: time.Hour,
+		Archive:         true,
+	}}
+	pr := Apply(dataDir, policies, now, false).Policies[0]
+	if pr.Error != "" {
+		t.Fatal(pr.Error)
+	}
+	if len(pr.Removed) != 3 || pr.Kept != 6 {
+		t.Fatalf("removed %d, kept %d: %+v", len(pr.Removed), pr.Kept, pr.Removed)
+	}
+	// The newest file of each hour is kept
+	for _, name := range []string{"old_b.json", "old_d.json", "old_f.json"} {
+		if _, err := os.Stat(filepath.Join(dataDir, "scans", name)); err != nil {
+			t.Fatalf("%s should be kept: %v", name, err)
+		}
+	}
+
+	if !strings.HasPrefix(pr.Archive, "archive/scans/") || !strings.HasSuffix(pr.Archive, ".tar.gz") {
+		t.Fatalf("unexpected archive path %q", pr.Archive)
+	}
+	f, err := os.Open(filepath.Join(dataDir, filepath.FromSlash(pr.Archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var bundled []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		bundled = append(bundled, hdr.Name)
+	}
+	sort.Strings(bundled)
+	if strings.Join(bundled, ",") != strings.Join([]string{old[0], old[2], old[4]}, ",") {
+		t.Fatalf("bundle holds %v", bundled)
+	}
+}
+
+func TestPoliciesFromConfig(t *testing.T) {
+	policies, err := PoliciesFromConfig([]config.RetentionPolicyConfig{
+		{Path: "scans/", MaxAgeDays: 30, MaxSizeMB: 2, DownsampleAfterDays: 2, DownsampleInterval: "1d"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := policies[0]
+	if p.Path != "scans" || p.MaxAge != 30*24*time.Hour || p.MaxBytes != 2<<20 || p.DownsampleEvery != 24*time.Hour {
+		t.Fatalf("unexpected policy: %+v", p)
+	}
+
+	for _, bad := range []config.RetentionPolicyConfig{
+		{Path: "../outside"},
+		{Path: ""},
+		{Path: "scans", DownsampleInterval: "often"},
+	} {
+		if _, err := PoliciesFromConfig([]config.RetentionPolicyConfig{bad}); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	dataDir := t.TempDir()
+	writeAt(t, dataDir, "scans/a.json", 300, now)
+	writeAt(t, dataDir, "scans/daily_log/2025-01-01.jsonl", 200, now.AddDate(0, -2, 0))
+	writeAt(t, dataDir, "reports/r.md", 100, now)
+
+	usage, err := Usage(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[0].Path != "scans" || usage[0].Files != 2 || usage[0].Bytes != 500 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+	if !usage[0].Oldest.Equal(now.AddDate(0, -2, 0)) {
+		t.Fatalf("oldest = %v", usage[0].Oldest)
+	}
+
+	if usage, err := Usage(filepath.Join(dataDir, "missing")); err != nil || len(usage) != 0 {
+		t.Fatalf("missing dir: %v, %v", usage, err)
+	}
+}
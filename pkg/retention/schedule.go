@@ -0,0 +1,52 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// startDelay postpones the first run so it doesn't compete with startup work.
+const startDelay = 5 * time.Minute
+
+// Schedule applies the policies every interval until ctx is cancelled.
+func Schedule(ctx context.Context, dataDir string, policies []Policy, interval time.Duration, dryRun bool) {
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+
+	go func() {
+		timer := time.NewTimer(startDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			report := Apply(dataDir, policies, time.Now(), dryRun)
+			files, bytes := report.Removed()
+			for _, p := range report.Policies {
+				if p.Error != "" {
+					logger.ErrorCF("retention", "Retention policy failed",
+						map[string]interface{}{
+							"path":  p.Path,
+							"error": p.Error,
+						})
+				}
+			}
+			if files > 0 {
+				logger.InfoCF("retention", "Applied data retention",
+					map[string]interface{}{
+						"dry_run": dryRun,
+						"files":   files,
+						"freed":   FormatBytes(bytes),
+						"report":  report.String(),
+					})
+			}
+			timer.Reset(interval)
+		}
+	}()
+}
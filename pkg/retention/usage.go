@@ -0,0 +1,72 @@
+package retention
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DirUsage is the disk usage of one top-level data/ subdirectory.
+type DirUsage struct {
+	Path   string    `json:"path"`
+	Files  int       `json:"files"`
+	Bytes  int64     `json:"bytes"`
+	Oldest time.Time `json:"oldest,omitempty"`
+	Newest time.Time `json:"newest,omitempty"`
+}
+
+// Usage sums the files under each top-level subdirectory of dataDir, largest
+// first. Files directly in dataDir are reported as ".".
+func Usage(dataDir string) ([]DirUsage, error) {
+	byDir := make(map[string]*DirUsage)
+	err := filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dataDir {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(dataDir, path)
+		top := "."
+		if parts := strings.SplitN(filepath.ToSlash(rel), "/", 2); len(parts) == 2 {
+			top = parts[0]
+		}
+		u := byDir[top]
+		if u == nil {
+			u = &DirUsage{Path: top}
+			byDir[top] = u
+		}
+		u.Files++
+		u.Bytes += info.Size()
+		if u.Oldest.IsZero() || info.ModTime().Before(u.Oldest) {
+			u.Oldest = info.ModTime()
+		}
+		if info.ModTime().After(u.Newest) {
+			u.Newest = info.ModTime()
+		}
+		return nil
+	})
+
+	usage := make([]DirUsage, 0, len(byDir))
+	for _, u := range byDir {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Bytes == usage[j].Bytes {
+			return usage[i].Path < usage[j].Path
+		}
+		return usage[i].Bytes > usage[j].Bytes
+	})
+	return usage, err
+}
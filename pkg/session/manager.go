@@ -7,15 +7,27 @@ import (
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/fileio"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 type Session struct {
-	Key      string              `json:"key"`
-	Messages []providers.Message `json:"messages"`
-	Summary  string              `json:"summary,omitempty"`
-	Created  time.Time           `json:"created"`
-	Updated  time.Time           `json:"updated"`
+	Key       string              `json:"key"`
+	Messages  []providers.Message `json:"messages"`
+	Summary   string              `json:"summary,omitempty"`
+	Truncated *TruncatedTurn      `json:"truncated,omitempty"`
+	Created   time.Time           `json:"created"`
+	Updated   time.Time           `json:"updated"`
+}
+
+// TruncatedTurn records a turn that ran out of tool iterations before the model
+// produced a final answer, so the user can ask to continue it. Messages holds
+// the turn's tool calls and results, which are replayed when it is resumed.
+type TruncatedTurn struct {
+	Request    string              `json:"request"`
+	Messages   []providers.Message `json:"messages,omitempty"`
+	Iterations int                 `json:"iterations"`
+	At         time.Time           `json:"at"`
 }
 
 type SessionManager struct {
@@ -121,6 +133,62 @@ func (sm *SessionManager) SetSummary(key string, summary string) {
 	}
 }
 
+// GetTruncated returns the truncated turn pending for a session, if any.
+func (sm *SessionManager) GetTruncated(key string) *TruncatedTurn {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return nil
+	}
+	return session.Truncated
+}
+
+// SetTruncated marks (or with nil, clears) a session's truncated turn.
+func (sm *SessionManager) SetTruncated(key string, turn *TruncatedTurn) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if ok {
+		session.Truncated = turn
+		session.Updated = time.Now()
+	}
+}
+
+// RemoveToolMessages removes the given assistant tool-call and tool-result
+// messages from a session, matching them by tool call ID so that history
+// truncated in the meantime doesn't matter.
+func (sm *SessionManager) RemoveToolMessages(key string, messages []providers.Message) {
+	ids := make(map[string]bool)
+	for _, m := range messages {
+		for _, tc := range m.ToolCalls {
+			ids[tc.ID] = true
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return
+	}
+	kept := session.Messages[:0]
+	for _, m := range session.Messages {
+		if m.Role == "tool" && ids[m.ToolCallID] || len(m.ToolCalls) > 0 && ids[m.ToolCalls[0].ID] {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	session.Messages = kept
+	session.Updated = time.Now()
+}
+
 func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -161,7 +229,7 @@ func (sm *SessionManager) Save(session *Session) error {
 		return err
 	}
 
-	return os.WriteFile(sessionPath, data, 0644)
+	return fileio.WriteFile(sessionPath, data, 0644)
 }
 
 func (sm *SessionManager) loadSessions() error {
@@ -6,12 +6,24 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 type SkillMetadata struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// StorageSchemas maps data/ paths the skill writes to JSON Schema files
+	// relative to the skill directory, from the "storage_schemas" key of the
+	// frontmatter metadata
+	StorageSchemas map[string]string `json:"storage_schemas,omitempty"`
+}
+
+// StorageSchema is a storage contract declared by a skill.
+type StorageSchema struct {
+	Skill      string
+	Pattern    string // Path or glob relative to data/
+	SchemaPath string // Absolute path of the schema file
 }
 
 type SkillInfo struct {
@@ -231,9 +243,56 @@ func (sl *SkillsLoader) getSkillMetadata(skillPath string) *SkillMetadata {
 	// Fall back to simple YAML parsing
 	yamlMeta := sl.parseSimpleYAML(frontmatter)
 	return &SkillMetadata{
-		Name:        yamlMeta["name"],
-		Description: yamlMeta["description"],
+		Name:           yamlMeta["name"],
+		Description:    yamlMeta["description"],
+		StorageSchemas: parseStorageSchemas(yamlMeta["metadata"]),
+	}
+}
+
+// parseStorageSchemas reads "storage_schemas" from a metadata value such as
+// {"droidclaw":{"emoji":"📈","storage_schemas":{"regime/current_regime.json":"output.schema.json"}}}.
+func parseStorageSchemas(metadata string) map[string]string {
+	var namespaces map[string]struct {
+		StorageSchemas map[string]string `json:"storage_schemas"`
+	}
+	if metadata == "" || json.Unmarshal([]byte(metadata), &namespaces) != nil {
+		return nil
+	}
+	var schemas map[string]string
+	for _, ns := range namespaces {
+		for pattern, schema := range ns.StorageSchemas {
+			if schemas == nil {
+				schemas = make(map[string]string)
+			}
+			schemas[pattern] = schema
+		}
+	}
+	return schemas
+}
+
+// StorageSchemas returns the storage contracts declared by the available
+// skills, following the same override order as ListSkills.
+func (sl *SkillsLoader) StorageSchemas() []StorageSchema {
+	var contracts []StorageSchema
+	for _, skill := range sl.ListSkills() {
+		metadata := sl.getSkillMetadata(skill.Path)
+		if metadata == nil {
+			continue
+		}
+		patterns := make([]string, 0, len(metadata.StorageSchemas))
+		for pattern := range metadata.StorageSchemas {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			contracts = append(contracts, StorageSchema{
+				Skill:      skill.Name,
+				Pattern:    pattern,
+				SchemaPath: filepath.Join(filepath.Dir(skill.Path), metadata.StorageSchemas[pattern]),
+			})
+		}
 	}
+	return contracts
 }
 
 // parseSimpleYAML parses simple key: value YAML format
@@ -0,0 +1,565 @@
+// Package timeseries stores append-only logs as JSON Lines segments, one
+// file per UTC day, so appends never rewrite earlier entries.
+//
+// A series lives in its own directory:
+//
+//	scans/daily_log/2025-01-14.jsonl   daily segment
+//	scans/daily_log/2025-01-15.jsonl
+//	scans/daily_log/2024-12.jsonl      compacted monthly segment
+//
+// Every entry carries a timestamp, either its own "timestamp", "ts", "time"
+// or "date" field or one added on append, which picks its segment and is
+// used for range queries.
+package timeseries
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+)
+
+// SegmentExt is the file extension of series segments.
+const SegmentExt = ".jsonl"
+
+// TimestampField is added to object entries that have no timestamp.
+const TimestampField = "timestamp"
+
+var timestampFields = []string{TimestampField, "ts", "time", "date"}
+
+// Series is a time-series stored as JSONL segments in a directory. Writers
+// hold the series lock, so a compaction never removes a segment mid-append.
+type Series struct {
+	dir string
+}
+
+// Entry is a stored entry with its timestamp.
+type Entry struct {
+	Time time.Time
+	Data json.RawMessage
+}
+
+// Segment describes one segment file of a series.
+type Segment struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Size  int64     `json:"size"`
+}
+
+func Open(dir string) *Series {
+	return &Series{dir: dir}
+}
+
+// Exists reports whether dir holds a series with at least one segment.
+func Exists(dir string) bool {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+SegmentExt))
+	for _, m := range matches {
+		if _, _, ok := parseSegmentName(filepath.Base(m)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Series) Dir() string {
+	return s.dir
+}
+
+// Append stores entry in the segment for its timestamp and returns that
+// timestamp. Non-JSON input is stored as a string entry.
+func (s *Series) Append(data []byte) (time.Time, error) {
+	line, ts, err := normalizeEntry(data, time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	unlock, err := fileio.Lock(s.dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return time.Time{}, err
+	}
+
+	path := filepath.Join(s.dir, ts.UTC().Format("2006-01-02")+SegmentExt)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return time.Time{}, err
+	}
+	return ts, nil
+}
+
+// Range returns the entries with from <= time < to in time order. A zero
+// from or to leaves that side unbounded.
+func (s *Series) Range(from, to time.Time) ([]Entry, error) {
+	segments, err := s.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, seg := range segments {
+		if !from.IsZero() && !seg.End.After(from) {
+			continue
+		}
+		if !to.IsZero() && !seg.Start.Before(to) {
+			continue
+		}
+		segEntries, err := readSegment(filepath.Join(s.dir, seg.Name), seg.Start)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range segEntries {
+			if !from.IsZero() && e.Time.Before(from) {
+				continue
+			}
+			if !to.IsZero() && !e.Time.Before(to) {
+				continue
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// Segments lists the segment files of the series ordered by start time.
+func (s *Series) Segments() ([]Segment, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var segments []Segment
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		start, end, ok := parseSegmentName(de.Name())
+		if !ok {
+			continue
+		}
+		seg := Segment{Name: de.Name(), Start: start, End: end}
+		if info, err := de.Info(); err == nil {
+			seg.Size = info.Size()
+		}
+		segments = append(segments, seg)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].Start.Equal(segments[j].Start) {
+			// Monthly segments sort before the daily ones they overlap
+			return segments[i].End.After(segments[j].End)
+		}
+		return segments[i].Start.Before(segments[j].Start)
+	})
+	return segments, nil
+}
+
+// CompactReport summarizes a compaction.
+type CompactReport struct {
+	Months   []string `json:"months"`
+	Merged   int      `json:"merged_segments"`
+	Entries  int      `json:"entries"`
+	Dropped  int      `json:"duplicates_dropped"`
+	BytesIn  int64    `json:"bytes_in"`
+	BytesOut int64    `json:"bytes_out"`
+}
+
+// Compact merges the daily segments of every month that ended before
+// `before` into one monthly segment, sorted by time with exact duplicate
+// entries removed. It is safe to re-run after an interrupted compaction.
+func (s *Series) Compact(before time.Time) (*CompactReport, error) {
+	unlock, err := fileio.Lock(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	segments, err := s.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	byMonth := make(map[string][]Segment)
+	for _, seg := range segments {
+		if seg.End.Equal(seg.Start.AddDate(0, 0, 1)) {
+			month := seg.Start.Format("2006-01")
+			monthEnd := time.Date(seg.Start.Year(), seg.Start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			if monthEnd.After(before) {
+				continue
+			}
+			byMonth[month] = append(byMonth[month], seg)
+		}
+	}
+
+	report := &CompactReport{}
+	months := make([]string, 0, len(byMonth))
+	for month := range byMonth {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	for _, month := range months {
+		if err := s.compactMonth(month, byMonth[month], report); err != nil {
+			return report, fmt.Errorf("compacting %s: %w", month, err)
+		}
+		report.Months = append(report.Months, month)
+	}
+	return report, nil
+}
+
+func (s *Series) compactMonth(month string, daily []Segment, report *CompactReport) error {
+	monthStart, _ := time.Parse("2006-01", month)
+	monthPath := filepath.Join(s.dir, month+SegmentExt)
+
+	sources := daily
+	if info, err := os.Stat(monthPath); err == nil {
+		sources = append([]Segment{{Name: month + SegmentExt, Start: monthStart, Size: info.Size()}}, daily...)
+	}
+
+	var entries []Entry
+	seen := make(map[string]bool)
+	for _, seg := range sources {
+		segEntries, err := readSegment(filepath.Join(s.dir, seg.Name), seg.Start)
+		if err != nil {
+			return err
+		}
+		report.BytesIn += seg.Size
+		for _, e := range segEntries {
+			key := string(e.Data)
+			if seen[key] {
+				report.Dropped++
+				continue
+			}
+			seen[key] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.Write(e.Data)
+		buf.WriteByte('\n')
+	}
+	if err := fileio.WriteFile(monthPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	// The monthly segment is complete; dropping the daily ones last means a
+	// crash here only leaves duplicates that the next run removes.
+	for _, seg := range daily {
+		if err := os.Remove(filepath.Join(s.dir, seg.Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	report.Merged += len(daily)
+	report.Entries += len(entries)
+	report.BytesOut += int64(buf.Len())
+	return nil
+}
+
+// Migrate moves the entries of a legacy log file into the series and
+// renames the file to <file>.migrated. The file is a JSON array, a single
+// document or JSON Lines (see parseLog); entries without a timestamp get the
+// file's modification time. It returns the number of entries migrated.
+func (s *Series) Migrate(path string) (int, error) {
+	unlock, err := fileio.Lock(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	bySegment, n, err := segmentLog(content, info.ModTime())
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return 0, err
+	}
+	for name, buf := range bySegment {
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return 0, err
+		}
+		_, werr := f.Write(buf.Bytes())
+		cerr := f.Close()
+		if werr != nil {
+			return 0, werr
+		}
+		if cerr != nil {
+			return 0, cerr
+		}
+	}
+
+	if err := os.Rename(path, path+".migrated"); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Replace makes content, in any format Migrate reads, the whole series:
+// segments it doesn't fill are removed and the others rewritten. Entries
+// without a timestamp get the current time. It returns the number of
+// entries stored.
+func (s *Series) Replace(content []byte) (int, error) {
+	bySegment, n, err := segmentLog(content, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	unlock, err := fileio.Lock(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	segments, err := s.Segments()
+	if err != nil {
+		return 0, err
+	}
+	for _, seg := range segments {
+		if _, ok := bySegment[seg.Name]; !ok {
+			if err := os.Remove(filepath.Join(s.dir, seg.Name)); err != nil && !os.IsNotExist(err) {
+				return 0, err
+			}
+		}
+	}
+	for name, buf := range bySegment {
+		if err := fileio.WriteFile(filepath.Join(s.dir, name), buf.Bytes(), 0644); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Remove deletes the series with all its segments.
+func (s *Series) Remove() error {
+	unlock, err := fileio.Lock(s.dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return os.RemoveAll(s.dir)
+}
+
+// segmentLog groups the entries of a log by the daily segment they belong
+// to and returns them with their count.
+func segmentLog(content []byte, fallback time.Time) (map[string]*bytes.Buffer, int, error) {
+	items, times := parseLog(content, fallback)
+	bySegment := make(map[string]*bytes.Buffer)
+	for i, item := range items {
+		line, ts, err := normalizeEntry(item, times[i])
+		if err != nil {
+			return nil, 0, err
+		}
+		name := ts.UTC().Format("2006-01-02") + SegmentExt
+		if bySegment[name] == nil {
+			bySegment[name] = &bytes.Buffer{}
+		}
+		bySegment[name].Write(line)
+		bySegment[name].WriteByte('\n')
+	}
+	return bySegment, len(items), nil
+}
+
+// parseLog splits a log into its entries with the time to use for those
+// without a timestamp. A JSON array holds one entry per element and any
+// other JSON document is a single entry. Anything else is read as JSON
+// Lines, where the "[<RFC 3339 time>] <text>" lines of plain-text appends
+// keep their time.
+func parseLog(content []byte, fallback time.Time) ([][]byte, []time.Time) {
+	var items [][]byte
+	var times []time.Time
+	trimmed := bytes.TrimSpace(content)
+	if json.Valid(trimmed) {
+		var array []json.RawMessage
+		if err := json.Unmarshal(trimmed, &array); err != nil {
+			array = []json.RawMessage{trimmed}
+		}
+		for _, item := range array {
+			items = append(items, item)
+			times = append(times, fallback)
+		}
+		return items, times
+	}
+
+	for _, line := range bytes.Split(trimmed, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		at := fallback
+		if rest, ok := bytes.CutPrefix(line, []byte("[")); ok {
+			if stamp, text, ok := bytes.Cut(rest, []byte("] ")); ok {
+				if ts, err := time.Parse(time.RFC3339, string(stamp)); err == nil {
+					line, at = text, ts
+				}
+			}
+		}
+		items = append(items, line)
+		times = append(times, at)
+	}
+	return items, times
+}
+
+// normalizeEntry compacts data to a single JSON line and determines its
+// timestamp, adding TimestampField to objects that lack one.
+func normalizeEntry(data []byte, now time.Time) ([]byte, time.Time, error) {
+	trimmed := bytes.TrimSpace(data)
+	if !json.Valid(trimmed) {
+		wrapped, err := json.Marshal(map[string]interface{}{
+			TimestampField: now.UTC().Format(time.RFC3339),
+			"text":         string(trimmed),
+		})
+		return wrapped, now, err
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(trimmed, &obj); err != nil {
+		// Arrays and scalars are wrapped so they can carry a timestamp
+		wrapped, err := json.Marshal(map[string]interface{}{
+			TimestampField: now.UTC().Format(time.RFC3339),
+			"value":        json.RawMessage(trimmed),
+		})
+		return wrapped, now, err
+	}
+
+	if ts, ok := objectTime(obj); ok {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, trimmed); err != nil {
+			return nil, time.Time{}, err
+		}
+		return buf.Bytes(), ts, nil
+	}
+
+	obj[TimestampField] = now.UTC().Format(time.RFC3339)
+	line, err := json.Marshal(obj)
+	return line, now, err
+}
+
+// EntryTime extracts the timestamp of a stored entry.
+func EntryTime(data []byte) (time.Time, bool) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return time.Time{}, false
+	}
+	return objectTime(obj)
+}
+
+func objectTime(obj map[string]interface{}) (time.Time, bool) {
+	for _, field := range timestampFields {
+		if ts, ok := ParseTime(obj[field]); ok {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime accepts RFC 3339 strings, dates, and unix seconds or
+// milliseconds.
+func ParseTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		s := strings.TrimSpace(t)
+		for _, layout := range timeLayouts {
+			if ts, err := time.Parse(layout, s); err == nil {
+				return ts, true
+			}
+		}
+	case float64:
+		if t <= 0 {
+			return time.Time{}, false
+		}
+		if t > 1e12 {
+			return time.UnixMilli(int64(t)).UTC(), true
+		}
+		return time.Unix(int64(t), 0).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// parseSegmentName returns the time span covered by a daily
+// (2006-01-02.jsonl) or monthly (2006-01.jsonl) segment.
+func parseSegmentName(name string) (start, end time.Time, ok bool) {
+	base, found := strings.CutSuffix(name, SegmentExt)
+	if !found {
+		return start, end, false
+	}
+	if day, err := time.Parse("2006-01-02", base); err == nil {
+		return day, day.AddDate(0, 0, 1), true
+	}
+	if month, err := time.Parse("2006-01", base); err == nil {
+		return month, month.AddDate(0, 1, 0), true
+	}
+	return start, end, false
+}
+
+func readSegment(path string, fallback time.Time) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// Skip blank lines and a torn final write
+		if len(line) == 0 || !json.Valid(line) {
+			continue
+		}
+		ts, ok := EntryTime(line)
+		if !ok {
+			ts = fallback
+		}
+		entries = append(entries, Entry{Time: ts, Data: append(json.RawMessage(nil), line...)})
+	}
+	return entries, scanner.Err()
+}
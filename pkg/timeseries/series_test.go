@@ -0,0 +1,201 @@
+package timeseries
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	ts, _ := time.Parse("2006-01-02", s)
+	return ts
+}
+
+func TestAppendSegmentsAndRange(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "scans", "daily_log"))
+	for _, entry := range []string{
+		`{"timestamp": "2025-01-14T23:55:00Z", "btc": 1}`,
+		`{"timestamp": "2025-01-15T00:05:00Z", "btc": 2}`,
+		`{"date": "2025-01-16", "btc": 3}`,
+		`{"ts": 1737072000000, "btc": 4}`,
+	} {
+		if _, err := s.Append([]byte(entry)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	segments, err := s.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 4 || segments[0].Name != "2025-01-14.jsonl" || segments[3].Name != "2025-01-17.jsonl" {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+
+	entries, err := s.Range(date("2025-01-15"), date("2025-01-17"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Time.Day() != 15 || entries[1].Time.Day() != 16 {
+		t.Fatalf("Range returned %+v", entries)
+	}
+
+	all, _ := s.Range(time.Time{}, time.Time{})
+	if len(all) != 4 {
+		t.Errorf("unbounded Range returned %d entries, want 4", len(all))
+	}
+}
+
+func TestAppendAddsTimestamp(t *testing.T) {
+	s := Open(t.TempDir())
+	before := time.Now().Add(-time.Second)
+	ts, err := s.Append([]byte(`{"note": "no time"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Before(before) {
+		t.Errorf("timestamp %v is older than the append", ts)
+	}
+	if _, err := s.Append([]byte(`plain text`)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := s.Range(time.Time{}, time.Time{})
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	var obj map[string]interface{}
+	json.Unmarshal(entries[1].Data, &obj)
+	if obj["text"] != "plain text" || obj[TimestampField] == nil {
+		t.Errorf("plain text entry stored as %s", entries[1].Data)
+	}
+}
+
+func TestConcurrentAppends(t *testing.T) {
+	s := Open(t.TempDir())
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// A second handle on the same series, as a subagent's tool would have
+			Open(s.Dir()).Append([]byte(`{"timestamp": "2025-01-15T10:00:00Z", "n": ` + string(rune('0'+i%10)) + `}`))
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := s.Range(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 50 {
+		t.Errorf("got %d entries after 50 concurrent appends", len(entries))
+	}
+}
+
+func TestCompactMergesPastMonths(t *testing.T) {
+	s := Open(t.TempDir())
+	for _, entry := range []string{
+		`{"timestamp": "2024-12-30T10:00:00Z", "v": 2}`,
+		`{"timestamp": "2024-12-02T10:00:00Z", "v": 1}`,
+		`{"timestamp": "2024-12-02T10:00:00Z", "v": 1}`,
+		`{"timestamp": "2025-01-03T10:00:00Z", "v": 3}`,
+	} {
+		s.Append([]byte(entry))
+	}
+
+	report, err := s.Compact(date("2025-01-10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Months) != 1 || report.Months[0] != "2024-12" || report.Merged != 2 || report.Dropped != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	segments, _ := s.Segments()
+	if len(segments) != 2 || segments[0].Name != "2024-12.jsonl" || segments[1].Name != "2025-01-03.jsonl" {
+		t.Fatalf("segments after compaction: %+v", segments)
+	}
+
+	entries, _ := s.Range(date("2024-12-01"), date("2025-01-01"))
+	if len(entries) != 2 || !entries[0].Time.Before(entries[1].Time) {
+		t.Errorf("compacted month has entries %+v", entries)
+	}
+
+	// A late entry for a compacted month is merged by the next run
+	s.Append([]byte(`{"timestamp": "2024-12-15T10:00:00Z", "v": 4}`))
+	if _, err := s.Compact(date("2025-01-10")); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = s.Range(date("2024-12-01"), date("2025-01-01"))
+	if len(entries) != 3 {
+		t.Errorf("got %d December entries after recompaction, want 3", len(entries))
+	}
+}
+
+func TestMigrateJSONArray(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "history.json")
+	os.WriteFile(legacy, []byte(`[{"timestamp": "2025-01-14T10:00:00Z", "v": 1}, {"v": 2}]`), 0644)
+	mtime := date("2025-01-13")
+	os.Chtimes(legacy, mtime, mtime)
+
+	s := Open(filepath.Join(dir, "history"))
+	n, err := s.Migrate(legacy)
+	if err != nil || n != 2 {
+		t.Fatalf("Migrate = %d, %v", n, err)
+	}
+	if _, err := os.Stat(legacy + ".migrated"); err != nil {
+		t.Errorf("legacy file not renamed: %v", err)
+	}
+	if !Exists(s.Dir()) {
+		t.Error("series does not exist after migration")
+	}
+
+	entries, _ := s.Range(time.Time{}, time.Time{})
+	if len(entries) != 2 || !entries[0].Time.Equal(mtime) {
+		t.Errorf("migrated entries %+v; the untimed one should use the file mtime", entries)
+	}
+}
+
+func TestMigrateJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "log.jsonl")
+	os.WriteFile(legacy, []byte("{\"timestamp\": \"2025-01-14T10:00:00Z\", \"v\": 1}\n"+
+		"[2025-01-15T08:00:00Z] {\"v\": 2}\n[2025-01-16T09:30:00Z] BTC broke 100k\n"), 0644)
+
+	s := Open(filepath.Join(dir, "log"))
+	if n, err := s.Migrate(legacy); err != nil || n != 3 {
+		t.Fatalf("Migrate = %d, %v", n, err)
+	}
+	entries, _ := s.Range(time.Time{}, time.Time{})
+	if len(entries) != 3 || !entries[1].Time.Equal(time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC)) ||
+		!strings.Contains(string(entries[2].Data), "BTC broke 100k") {
+		t.Errorf("the plain-text append times should be kept: %+v", entries)
+	}
+}
+
+func TestReplaceAndRemove(t *testing.T) {
+	s := Open(filepath.Join(t.TempDir(), "log"))
+	s.Append([]byte(`{"timestamp": "2025-01-10T10:00:00Z", "v": 0}`))
+
+	n, err := s.Replace([]byte("{\"timestamp\": \"2025-01-14T10:00:00Z\", \"v\": 1}\n{\"timestamp\": \"2025-01-15T10:00:00Z\", \"v\": 2}\n"))
+	if err != nil || n != 2 {
+		t.Fatalf("Replace = %d, %v", n, err)
+	}
+	segments, _ := s.Segments()
+	if len(segments) != 2 || segments[0].Name != "2025-01-14.jsonl" {
+		t.Errorf("the earlier segment should be gone: %+v", segments)
+	}
+
+	if err := s.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if Exists(s.Dir()) {
+		t.Error("series still exists after Remove")
+	}
+}
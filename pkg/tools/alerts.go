@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/alerts"
+)
+
+// AlertsTool manages the price alert rules the gateway evaluates in Go, so
+// the agent is only woken when one fires.
+type AlertsTool struct {
+	engine  *alerts.Engine
+	channel string
+	chatID  string
+	mu      sync.RWMutex
+}
+
+func NewAlertsTool(engine *alerts.Engine) *AlertsTool {
+	return &AlertsTool{
+		engine: engine,
+	}
+}
+
+func (t *AlertsTool) Name() string {
+	return "alerts"
+}
+
+func (t *AlertsTool) Description() string {
+	return `Manage price alert rules. The gateway checks them continuously without using the model and only wakes you (or messages the chat) when one fires.
+Actions:
+- "create": Add a rule. Kinds:
+  - "percent_move": price moves "percent" % off its low/high within "window_minutes" (default 5)
+  - "zscore": move over "window_minutes" (default 60) is "z_score" (default 2) standard deviations, using the volatility noise profile
+  - "level_cross": price crosses "level"
+  - "volume_spike": current "interval" candle (default 5m) has "multiplier" (default 3) times the average volume
+  "direction" up/down limits a rule to one side. "on_fire": "agent" (default) wakes you with "note" as instructions; "notify" sends the alert text to this chat.
+  Rules stay quiet until the condition clears and "cooldown_minutes" pass.
+- "list": Rules with their latest value and when they last fired
+- "delete": Remove the rule "rule_id"
+- "history": Alerts fired in the last "hours" (default 24)`
+}
+
+func (t *AlertsTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"create", "list", "delete", "history"},
+				"description": "Action to perform",
+			},
+			"symbol": map[string]interface{}{
+				"type":        "string",
+				"description": "Symbol to watch (e.g., BTCUSDT, XAUUSD, SPX)",
+			},
+			"kind": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"percent_move", "zscore", "level_cross", "volume_spike"},
+				"description": "Rule kind",
+			},
+			"percent": map[string]interface{}{
+				"type":        "number",
+				"description": "Move in percent for percent_move",
+			},
+			"window_minutes": map[string]interface{}{
+				"type":        "integer",
+				"description": "Window for percent_move and zscore",
+			},
+			"z_score": map[string]interface{}{
+				"type":        "number",
+				"description": "Threshold in standard deviations for zscore",
+			},
+			"level": map[string]interface{}{
+				"type":        "number",
+				"description": "Price level for level_cross",
+			},
+			"direction": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"up", "down", "any"},
+				"description": "Only fire on moves (or crosses) in this direction (default: any)",
+			},
+			"multiplier": map[string]interface{}{
+				"type":        "number",
+				"description": "Volume multiple of the average for volume_spike",
+			},
+			"interval": map[string]interface{}{
+				"type":        "string",
+				"description": "Candle interval for volume_spike: 1m, 5m, 15m, 1h, 4h, 1d",
+			},
+			"cooldown_minutes": map[string]interface{}{
+				"type":        "integer",
+				"description": "Minimum minutes between two alerts of the rule",
+			},
+			"on_fire": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"agent", "notify"},
+				"description": "What happens when the rule fires (default: agent)",
+			},
+			"note": map[string]interface{}{
+				"type":        "string",
+				"description": "Instructions for the agent when the rule fires",
+			},
+			"rule_id": map[string]interface{}{
+				"type":        "string",
+				"description": "Rule ID for delete",
+			},
+			"hours": map[string]interface{}{
+				"type":        "number",
+				"description": "How far back history goes (default: 24)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// SetContext sets the chat that new rules alert.
+func (t *AlertsTool) SetContext(channel, chatID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.channel = channel
+	t.chatID = chatID
+}
+
+func (t *AlertsTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return "", fmt.Errorf("action is required")
+	}
+
+	switch action {
+	case "create":
+		return t.create(args)
+	case "list":
+		return t.list()
+	case "delete":
+		id, _ := args["rule_id"].(string)
+		if id == "" {
+			return "Error: rule_id is required for delete", nil
+		}
+		if err := t.engine.Remove(id); err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		return fmt.Sprintf("Deleted rule %s", id), nil
+	case "history":
+		return t.history(args)
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *AlertsTool) create(args map[string]interface{}) (string, error) {
+	rule := alerts.Rule{
+		Symbol:          stringArg(args, "symbol"),
+		Kind:            alerts.Kind(stringArg(args, "kind")),
+		Percent:         numberArg(args, "percent"),
+		WindowMinutes:   int(numberArg(args, "window_minutes")),
+		ZScore:          numberArg(args, "z_score"),
+		Level:           numberArg(args, "level"),
+		Direction:       stringArg(args, "direction"),
+		Multiplier:      numberArg(args, "multiplier"),
+		Interval:        stringArg(args, "interval"),
+		CooldownMinutes: int(numberArg(args, "cooldown_minutes")),
+		Action:          stringArg(args, "on_fire"),
+		Note:            stringArg(args, "note"),
+	}
+	t.mu.RLock()
+	rule.Channel, rule.ChatID = t.channel, t.chatID
+	t.mu.RUnlock()
+
+	rule, err := t.engine.Add(rule)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	return fmt.Sprintf("Created rule %s: %s (cooldown %dm, on fire: %s)",
+		rule.ID, rule.Describe(), rule.CooldownMinutes, rule.Action), nil
+}
+
+func (t *AlertsTool) list() (string, error) {
+	rules := t.engine.Status()
+	if len(rules) == 0 {
+		return "No alert rules.", nil
+	}
+	out, _ := json.MarshalIndent(rules, "", "  ")
+	return string(out), nil
+}
+
+func (t *AlertsTool) history(args map[string]interface{}) (string, error) {
+	hours := numberArg(args, "hours")
+	if hours <= 0 {
+		hours = 24
+	}
+	events, err := t.engine.History(time.Now().Add(-time.Duration(hours * float64(time.Hour))))
+	if err != nil {
+		return fmt.Sprintf("Error reading alert history: %v", err), nil
+	}
+	if len(events) == 0 {
+		return fmt.Sprintf("No alerts fired in the last %gh.", hours), nil
+	}
+	var sb strings.Builder
+	for _, ev := range events {
+		fmt.Fprintf(&sb, "%s [%s] %s\n", ev.Time.Format(time.RFC3339), ev.RuleID, ev.Message)
+	}
+	return sb.String(), nil
+}
+
+func stringArg(args map[string]interface{}, key string) string {
+	s, _ := args[key].(string)
+	return s
+}
+
+func numberArg(args map[string]interface{}, key string) float64 {
+	n, _ := args[key].(float64)
+	return n
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/calendar"
+)
+
+// EconomicCalendarTool serves scheduled economic releases with their
+// consensus, previous and actual values.
+type EconomicCalendarTool struct {
+	calendar *calendar.Calendar
+}
+
+func NewEconomicCalendarTool(cal *calendar.Calendar) *EconomicCalendarTool {
+	return &EconomicCalendarTool{
+		calendar: cal,
+	}
+}
+
+func (t *EconomicCalendarTool) Name() string {
+	return "economic_calendar"
+}
+
+func (t *EconomicCalendarTool) Description() string {
+	return `Scheduled economic releases (CPI, NFP, FOMC, PMI, GDP, ...) with time, country, importance, type, consensus, previous and actual values. Times are UTC.
+Actions:
+- "upcoming": Events in the next "hours" (default 24)
+- "released": Events already released on "date" (YYYY-MM-DD, default today)
+- "record": Add an event, or update a known one by "id" (e.g. fill in "actual" after the release). New events need "title", "country" and "time"
+"importance" (low/medium/high) sets the minimum importance and "country" (currency code, e.g. USD) limits the results.`
+}
+
+func (t *EconomicCalendarTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"upcoming", "released", "record"},
+				"description": "Action to perform",
+			},
+			"hours": map[string]interface{}{
+				"type":        "number",
+				"description": "Look-ahead for upcoming (default: 24)",
+			},
+			"date": map[string]interface{}{
+				"type":        "string",
+				"description": "Day for released, YYYY-MM-DD (default: today)",
+			},
+			"importance": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"low", "medium", "high"},
+				"description": "Minimum importance for queries, or the event's importance for record",
+			},
+			"country": map[string]interface{}{
+				"type":        "string",
+				"description": "Currency or country code (e.g., USD, EUR); comma-separated for several",
+			},
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "Event ID for record",
+			},
+			"title": map[string]interface{}{
+				"type":        "string",
+				"description": "Event title for record (e.g., CPI m/m)",
+			},
+			"time": map[string]interface{}{
+				"type":        "string",
+				"description": "Release time for record, RFC3339 or YYYY-MM-DDTHH:MM in UTC",
+			},
+			"type": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"inflation", "employment", "growth", "central_bank", "other"},
+				"description": "Event type for record (default: from the title)",
+			},
+			"consensus": map[string]interface{}{
+				"type":        "string",
+				"description": "Consensus forecast for record",
+			},
+			"previous": map[string]interface{}{
+				"type":        "string",
+				"description": "Previous value for record",
+			},
+			"actual": map[string]interface{}{
+				"type":        "string",
+				"description": "Actual released value for record",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *EconomicCalendarTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return "", fmt.Errorf("action is required")
+	}
+
+	var countries []string
+	if country := stringArg(args, "country"); country != "" {
+		countries = strings.Split(country, ",")
+	}
+	importance := stringArg(args, "importance")
+
+	switch action {
+	case "upcoming":
+		hours := numberArg(args, "hours")
+		if hours <= 0 {
+			hours = 24
+		}
+		events, err := t.calendar.Upcoming(ctx, time.Duration(hours*float64(time.Hour)), importance, countries)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		if len(events) == 0 {
+			return fmt.Sprintf("No economic events in the next %gh.", hours), nil
+		}
+		return calendarResult(events)
+	case "released":
+		date := time.Now().UTC()
+		if s := stringArg(args, "date"); s != "" {
+			d, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				return fmt.Sprintf("Error: invalid date %q, use YYYY-MM-DD", s), nil
+			}
+			date = d
+		}
+		events, err := t.calendar.Released(ctx, date, importance, countries)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		if len(events) == 0 {
+			return fmt.Sprintf("No economic events released on %s.", date.Format("2006-01-02")), nil
+		}
+		return calendarResult(events)
+	case "record":
+		return t.record(ctx, args, countries)
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *EconomicCalendarTool) record(ctx context.Context, args map[string]interface{}, countries []string) (string, error) {
+	e := calendar.Event{
+		ID:         stringArg(args, "id"),
+		Title:      stringArg(args, "title"),
+		Type:       stringArg(args, "type"),
+		Importance: stringArg(args, "importance"),
+		Consensus:  stringArg(args, "consensus"),
+		Previous:   stringArg(args, "previous"),
+		Actual:     stringArg(args, "actual"),
+	}
+	if len(countries) > 0 {
+		e.Country = strings.TrimSpace(countries[0])
+	}
+	if s := stringArg(args, "time"); s != "" {
+		at, err := parseHistoryTime(s)
+		if err != nil {
+			return fmt.Sprintf("Error: invalid time %q, use RFC3339 or YYYY-MM-DDTHH:MM", s), nil
+		}
+		e.Time = at
+	}
+	recorded, err := t.calendar.Record(ctx, e)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	out, _ := json.MarshalIndent(recorded, "", "  ")
+	return fmt.Sprintf("Recorded in %s:\n%s", t.calendar.File(), out), nil
+}
+
+func calendarResult(events []calendar.Event) (string, error) {
+	out, _ := json.MarshalIndent(map[string]interface{}{
+		"count":  len(events),
+		"events": events,
+	}, "", "  ")
+	return string(out), nil
+}
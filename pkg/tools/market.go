@@ -3,38 +3,64 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/marketdata"
 )
 
 // MarketDataTool provides real-time and historical market data from free APIs.
-// Supports crypto (Binance), forex/commodities (exchangerate), and general markets.
+// Requests go through a router that picks the sources configured for the
+// symbol's asset class (crypto, forex, metals, equities) and falls back to
+// the next one when a source fails.
 type MarketDataTool struct {
-	client *http.Client
+	router     *marketdata.Router
+	history    *marketdata.History // nil when the candle store is disabled
+	maxCandles int
 }
 
-func NewMarketDataTool() *MarketDataTool {
+func NewMarketDataTool(router *marketdata.Router) *MarketDataTool {
 	return &MarketDataTool{
-		client: &http.Client{Timeout: 15 * time.Second},
+		router: router,
 	}
 }
 
+// SetHistory enables the history action, which returns at most maxCandles
+// candles.
+func (t *MarketDataTool) SetHistory(h *marketdata.History, maxCandles int) {
+	t.history = h
+	t.maxCandles = maxCandles
+}
+
 func (t *MarketDataTool) Name() string {
 	return "market_data"
 }
 
 func (t *MarketDataTool) Description() string {
-	return `Get real-time market data for crypto, forex, and commodities.
+	return `Get real-time market data for crypto, forex, metals, stocks and indices.
 Actions:
-- "ticker": Get current price, 24h change, volume for a symbol (e.g., BTCUSDT, ETHUSDT)
+- "ticker": Get current price, 24h change, volume for a symbol
 - "candles": Get OHLCV candlestick data (intervals: 1m,5m,15m,1h,4h,1d)
-- "orderbook": Get order book snapshot (top bids/asks)
+- "orderbook": Get order book snapshot (top bids/asks, crypto only)
 - "multi_ticker": Get tickers for multiple symbols at once
 - "forex": Get forex exchange rates (base currency like USD, EUR)
-For crypto symbols, use Binance format: BTCUSDT, ETHUSDT, etc.`
+- "funding": Perpetual futures funding rate history (crypto; positive = longs pay shorts)
+- "open_interest": Perpetual futures open interest over time (intervals: 5m,15m,30m,1h,2h,4h,6h,12h,1d)
+- "long_short": Ratio of accounts long to short on perpetual futures over time
+- "basis": Perpetual mark price vs spot index, with the current funding rate annualized
+- "history": Get candles for any range ("start"/"end" or the last "days") from the local candle store.
+  Missing parts are downloaded once and kept, so long lookbacks are cheap to repeat; "gaps" lists what
+  is still missing. Without a symbol, lists the stored series.
+- "symbols": Resolve "symbol" to its canonical id, asset class and ticker at each source, with suggestions
+  for unknown symbols. Without a symbol, lists the registered instruments and their aliases.
+Symbols: crypto in exchange format (BTCUSDT, ETHUSDT), forex pairs (EURUSD), metals (XAUUSD, XAGUSD),
+stocks (AAPL), indices (SPX, NDX, DXY, VIX or Yahoo symbols like ^GSPC) and futures (CL=F).
+Aliases resolve to canonical ids (BTC/USDT and BTC -> BTCUSDT, XAUUSDT and GOLD -> XAUUSD, EURUSDT -> EURUSD,
+^GSPC -> SPX); results carry the canonical id in "symbol", so use it when saving data.
+Every result names the "source" it came from; if a source is down the next configured one is used.
+Recent results are reused for a short time; "cache" shows whether a result was cached and its age.`
 }
 
 func (t *MarketDataTool) Parameters() map[string]interface{} {
@@ -43,12 +69,12 @@ func (t *MarketDataTool) Parameters() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"action": map[string]interface{}{
 				"type":        "string",
-				"enum":        []string{"ticker", "candles", "orderbook", "multi_ticker", "forex"},
+				"enum":        []string{"ticker", "candles", "orderbook", "multi_ticker", "forex", "funding", "open_interest", "long_short", "basis", "history", "symbols"},
 				"description": "Action to perform",
 			},
 			"symbol": map[string]interface{}{
 				"type":        "string",
-				"description": "Trading symbol (e.g., BTCUSDT, ETHUSDT for crypto; USD, EUR for forex)",
+				"description": "Symbol (e.g., BTCUSDT, EURUSD, XAUUSD, AAPL, SPX; USD, EUR as forex base)",
 			},
 			"symbols": map[string]interface{}{
 				"type":        "array",
@@ -61,7 +87,23 @@ func (t *MarketDataTool) Parameters() map[string]interface{} {
 			},
 			"limit": map[string]interface{}{
 				"type":        "integer",
-				"description": "Number of results (default: 24 for candles, 10 for orderbook)",
+				"description": "Number of results (default: 24 for candles and derivatives series, 21 funding settlements, 10 for orderbook; for history the latest candles of the range are kept)",
+			},
+			"start": map[string]interface{}{
+				"type":        "string",
+				"description": "Start of the history range (RFC3339 or YYYY-MM-DD)",
+			},
+			"end": map[string]interface{}{
+				"type":        "string",
+				"description": "End of the history range (default: now)",
+			},
+			"days": map[string]interface{}{
+				"type":        "number",
+				"description": "History range as days before end when start is not given (default: 30)",
+			},
+			"refresh": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Download missing candles before answering history (default: true); false reads the store only",
 			},
 		},
 		"required": []string{"action"},
@@ -85,6 +127,16 @@ func (t *MarketDataTool) Execute(ctx context.Context, args map[string]interface{
 		return t.getMultiTicker(ctx, args)
 	case "forex":
 		return t.getForex(ctx, args)
+	case "funding":
+		return t.getFunding(ctx, args)
+	case "open_interest", "long_short":
+		return t.getDerivativesSeries(ctx, action, args)
+	case "basis":
+		return t.getBasis(ctx, args)
+	case "history":
+		return t.getHistory(ctx, args)
+	case "symbols":
+		return t.getSymbols(args)
 	default:
 		return "", fmt.Errorf("unknown action: %s", action)
 	}
@@ -95,41 +147,13 @@ func (t *MarketDataTool) getTicker(ctx context.Context, args map[string]interfac
 	if symbol == "" {
 		return "Error: symbol is required for ticker", nil
 	}
-	symbol = strings.ToUpper(symbol)
 
-	// Special handling for Spot Gold (XAUUSD)
-	if symbol == "XAUUSD" || symbol == "XAU" {
-		return t.getForexRateAsTicker(ctx, "XAU")
-	}
-
-	url := fmt.Sprintf("https://api.binance.com/api/v3/ticker/24hr?symbol=%s", symbol)
-	body, err := t.httpGet(ctx, url)
+	ticker, err := t.router.Ticker(ctx, symbol)
 	if err != nil {
-		if strings.Contains(err.Error(), "400") {
-			return fmt.Sprintf("Error: Invalid symbol '%s'. Note: For Spot Gold use 'XAUUSD'.", symbol), nil
-		}
-		return fmt.Sprintf("Error fetching ticker: %v", err), nil
+		return marketError("ticker", symbol, err), nil
 	}
 
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return fmt.Sprintf("Error parsing response: %v", err), nil
-	}
-
-	result := map[string]interface{}{
-		"symbol":           data["symbol"],
-		"price":            data["lastPrice"],
-		"price_change":     data["priceChange"],
-		"price_change_pct": data["priceChangePercent"],
-		"high_24h":         data["highPrice"],
-		"low_24h":          data["lowPrice"],
-		"volume_24h":       data["volume"],
-		"quote_volume_24h": data["quoteVolume"],
-		"open_price":       data["openPrice"],
-		"timestamp":        time.Now().UTC().Format(time.RFC3339),
-	}
-
-	out, _ := json.MarshalIndent(result, "", "  ")
+	out, _ := json.MarshalIndent(ticker, "", "  ")
 	return string(out), nil
 }
 
@@ -138,7 +162,6 @@ func (t *MarketDataTool) getCandles(ctx context.Context, args map[string]interfa
 	if symbol == "" {
 		return "Error: symbol is required for candles", nil
 	}
-	symbol = strings.ToUpper(symbol)
 
 	interval := "1h"
 	if i, ok := args["interval"].(string); ok && i != "" {
@@ -153,49 +176,20 @@ func (t *MarketDataTool) getCandles(ctx context.Context, args map[string]interfa
 		}
 	}
 
-	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=%d",
-		symbol, interval, limit)
-
-	body, err := t.httpGet(ctx, url)
+	series, err := t.router.Candles(ctx, symbol, interval, limit)
 	if err != nil {
-		return fmt.Sprintf("Error fetching candles: %v", err), nil
-	}
-
-	var rawCandles [][]interface{}
-	if err := json.Unmarshal(body, &rawCandles); err != nil {
-		return fmt.Sprintf("Error parsing candles: %v", err), nil
-	}
-
-	type Candle struct {
-		Time   string `json:"time"`
-		Open   string `json:"open"`
-		High   string `json:"high"`
-		Low    string `json:"low"`
-		Close  string `json:"close"`
-		Volume string `json:"volume"`
-	}
-
-	candles := make([]Candle, 0, len(rawCandles))
-	for _, c := range rawCandles {
-		if len(c) < 6 {
-			continue
-		}
-		ts := int64(c[0].(float64))
-		candles = append(candles, Candle{
-			Time:   time.UnixMilli(ts).UTC().Format("2006-01-02 15:04"),
-			Open:   fmt.Sprintf("%v", c[1]),
-			High:   fmt.Sprintf("%v", c[2]),
-			Low:    fmt.Sprintf("%v", c[3]),
-			Close:  fmt.Sprintf("%v", c[4]),
-			Volume: fmt.Sprintf("%v", c[5]),
-		})
+		return marketError("candles", symbol, err), nil
 	}
 
 	result := map[string]interface{}{
-		"symbol":   symbol,
-		"interval": interval,
-		"count":    len(candles),
-		"candles":  candles,
+		"symbol":   series.Symbol,
+		"interval": series.Interval,
+		"count":    len(series.Candles),
+		"candles":  series.Candles,
+		"source":   series.Source,
+	}
+	if series.Cache != nil {
+		result["cache"] = series.Cache
 	}
 
 	out, _ := json.MarshalIndent(result, "", "  ")
@@ -207,7 +201,6 @@ func (t *MarketDataTool) getOrderbook(ctx context.Context, args map[string]inter
 	if symbol == "" {
 		return "Error: symbol is required for orderbook", nil
 	}
-	symbol = strings.ToUpper(symbol)
 
 	limit := 10
 	if l, ok := args["limit"].(float64); ok && int(l) > 0 {
@@ -217,93 +210,71 @@ func (t *MarketDataTool) getOrderbook(ctx context.Context, args map[string]inter
 		}
 	}
 
-	url := fmt.Sprintf("https://api.binance.com/api/v3/depth?symbol=%s&limit=%d", symbol, limit)
-	body, err := t.httpGet(ctx, url)
+	book, err := t.router.OrderBook(ctx, symbol, limit)
 	if err != nil {
-		return fmt.Sprintf("Error fetching orderbook: %v", err), nil
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return fmt.Sprintf("Error parsing orderbook: %v", err), nil
+		return marketError("orderbook", symbol, err), nil
 	}
 
-	result := map[string]interface{}{
-		"symbol":    symbol,
-		"bids":      data["bids"],
-		"asks":      data["asks"],
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	}
-
-	out, _ := json.MarshalIndent(result, "", "  ")
+	out, _ := json.MarshalIndent(book, "", "  ")
 	return string(out), nil
 }
 
 func (t *MarketDataTool) getMultiTicker(ctx context.Context, args map[string]interface{}) (string, error) {
-	symbolsRaw, ok := args["symbols"]
-	if !ok {
+	symbols := stringList(args["symbols"])
+	if _, ok := args["symbols"]; !ok {
 		// Default watchlist for economic monitoring
-		symbolsRaw = []interface{}{"BTCUSDT", "ETHUSDT", "XAUUSDT", "EURUSDT", "SOLUSDT"}
-	}
-
-	var symbols []string
-	switch v := symbolsRaw.(type) {
-	case []interface{}:
-		for _, s := range v {
-			if str, ok := s.(string); ok {
-				symbols = append(symbols, strings.ToUpper(str))
-			}
-		}
-	case []string:
-		for _, s := range v {
-			symbols = append(symbols, strings.ToUpper(s))
-		}
+		symbols = []string{"BTCUSDT", "ETHUSDT", "XAUUSD", "EURUSD", "SOLUSDT"}
 	}
 
 	if len(symbols) == 0 {
 		return "Error: symbols list is empty", nil
 	}
 
-	// Binance allows fetching multiple tickers with a single request
-	symbolsJSON, _ := json.Marshal(symbols)
-	url := fmt.Sprintf("https://api.binance.com/api/v3/ticker/24hr?symbols=%s", string(symbolsJSON))
-
-	body, err := t.httpGet(ctx, url)
-	if err != nil {
-		return fmt.Sprintf("Error fetching multi ticker: %v", err), nil
-	}
-
-	var tickers []map[string]interface{}
-	if err := json.Unmarshal(body, &tickers); err != nil {
-		return fmt.Sprintf("Error parsing response: %v", err), nil
-	}
-
 	type TickerSummary struct {
-		Symbol    string `json:"symbol"`
-		Price     string `json:"price"`
-		Change    string `json:"change_pct"`
-		High      string `json:"high_24h"`
-		Low       string `json:"low_24h"`
-		Volume    string `json:"volume"`
-	}
-
-	summaries := make([]TickerSummary, 0, len(tickers))
-	for _, t := range tickers {
+		Symbol string  `json:"symbol"`
+		Price  float64 `json:"price"`
+		Change float64 `json:"change_pct"`
+		High   float64 `json:"high_24h,omitempty"`
+		Low    float64 `json:"low_24h,omitempty"`
+		Volume float64 `json:"volume,omitempty"`
+		Source string  `json:"source"`
+		Cached bool    `json:"cached,omitempty"`
+	}
+
+	summaries := make([]TickerSummary, 0, len(symbols))
+	failed := make(map[string]string)
+	for _, r := range t.router.Tickers(ctx, symbols) {
+		if r.Err != nil {
+			failed[r.Symbol] = r.Err.Error()
+			continue
+		}
+		volume := r.Ticker.QuoteVolume
+		if volume == 0 {
+			volume = r.Ticker.Volume
+		}
 		summaries = append(summaries, TickerSummary{
-			Symbol: fmt.Sprintf("%v", t["symbol"]),
-			Price:  fmt.Sprintf("%v", t["lastPrice"]),
-			Change: fmt.Sprintf("%v%%", t["priceChangePercent"]),
-			High:   fmt.Sprintf("%v", t["highPrice"]),
-			Low:    fmt.Sprintf("%v", t["lowPrice"]),
-			Volume: fmt.Sprintf("%v", t["quoteVolume"]),
+			Symbol: r.Ticker.Symbol,
+			Price:  r.Ticker.Price,
+			Change: r.Ticker.ChangePct,
+			High:   r.Ticker.High,
+			Low:    r.Ticker.Low,
+			Volume: volume,
+			Source: r.Ticker.Source,
+			Cached: r.Ticker.Cache != nil && r.Ticker.Cache.Hit,
 		})
 	}
+	if len(summaries) == 0 {
+		return fmt.Sprintf("Error fetching multi ticker: %v", failed), nil
+	}
 
 	result := map[string]interface{}{
 		"count":     len(summaries),
 		"tickers":   summaries,
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	}
+	if len(failed) > 0 {
+		result["errors"] = failed
+	}
 
 	out, _ := json.MarshalIndent(result, "", "  ")
 	return string(out), nil
@@ -315,93 +286,286 @@ func (t *MarketDataTool) getForex(ctx context.Context, args map[string]interface
 		base = strings.ToUpper(b)
 	}
 
-	// Use exchangerate.host free API (no key required)
-	url := fmt.Sprintf("https://open.er-api.com/v6/latest/%s", base)
-	body, err := t.httpGet(ctx, url)
+	rates, err := t.router.FX(ctx, base)
 	if err != nil {
-		return fmt.Sprintf("Error fetching forex rates: %v", err), nil
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(body, &data); err != nil {
-		return fmt.Sprintf("Error parsing forex data: %v", err), nil
-	}
-
-	// Extract key currencies
-	rates, ok := data["rates"].(map[string]interface{})
-	if !ok {
-		return "Error: unexpected forex API response format", nil
+		return marketError("forex rates", base, err), nil
 	}
 
 	keyCurrencies := []string{"EUR", "GBP", "JPY", "CHF", "AUD", "CAD", "CNY", "SAR", "AED", "TRY", "RUB", "INR", "BRL", "XAU"}
 	filtered := make(map[string]interface{})
 	for _, cur := range keyCurrencies {
-		if rate, exists := rates[cur]; exists {
+		if rate, exists := rates.Rates[cur]; exists {
 			filtered[cur] = rate
 		}
 	}
 
 	result := map[string]interface{}{
-		"base":         base,
+		"base":         rates.Base,
 		"rates":        filtered,
-		"all_rates":    len(rates),
-		"last_updated": data["time_last_update_utc"],
+		"all_rates":    len(rates.Rates),
+		"last_updated": rates.Updated.Format(time.RFC3339),
+		"source":       rates.Source,
 		"timestamp":    time.Now().UTC().Format(time.RFC3339),
 	}
+	if rates.Cache != nil {
+		result["cache"] = rates.Cache
+	}
 
 	out, _ := json.MarshalIndent(result, "", "  ")
 	return string(out), nil
 }
 
-func (t *MarketDataTool) getForexRateAsTicker(ctx context.Context, symbol string) (string, error) {
-	// Use EUR as base to get XAU price in EUR, then convert or just get USD base
-	// Actually ER-API with USD base gives XAU rate (1/price)
-	body, err := t.httpGet(ctx, "https://open.er-api.com/v6/latest/USD")
-	if err != nil {
-		return fmt.Sprintf("Error fetching gold price: %v", err), nil
+func (t *MarketDataTool) getFunding(ctx context.Context, args map[string]interface{}) (string, error) {
+	symbol, _ := args["symbol"].(string)
+	if symbol == "" {
+		return "Error: symbol is required for funding", nil
+	}
+	// Three settlements a day, so a week by default
+	limit := 21
+	if l, ok := args["limit"].(float64); ok && int(l) > 0 {
+		limit = min(int(l), 100)
 	}
 
-	var data map[string]interface{}
-	json.Unmarshal(body, &data)
-	rates := data["rates"].(map[string]interface{})
+	series, err := t.router.FundingRates(ctx, symbol, limit)
+	if err != nil {
+		return marketError("funding rates", symbol, err), nil
+	}
 
-	rate, ok := rates[symbol].(float64)
-	if !ok || rate == 0 {
-		return fmt.Sprintf("Error: Price data for %s currently unavailable via Forex API", symbol), nil
+	result := map[string]interface{}{
+		"symbol":     series.Symbol,
+		"instrument": series.Instrument,
+		"count":      len(series.Rates),
+		"rates":      series.Rates,
+		"source":     series.Source,
+	}
+	if n := len(series.Rates); n > 0 {
+		sum := 0.0
+		for _, r := range series.Rates {
+			sum += r.Rate
+		}
+		result["latest_rate"] = series.Rates[n-1].Rate
+		result["average_rate"] = sum / float64(n)
+		if n > 1 {
+			// Annualize by the spacing of the settlements
+			hours := series.Rates[n-1].Time.Sub(series.Rates[0].Time).Hours() / float64(n-1)
+			if hours > 0 {
+				result["average_annualized_pct"] = sum / float64(n) * (365 * 24 / hours) * 100
+			}
+		}
 	}
+	if series.Cache != nil {
+		result["cache"] = series.Cache
+	}
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	return string(out), nil
+}
 
-	price := 1.0 / rate // XAU rate is 1 oz per USD, so price is 1/rate
+func (t *MarketDataTool) getDerivativesSeries(ctx context.Context, action string, args map[string]interface{}) (string, error) {
+	symbol, _ := args["symbol"].(string)
+	if symbol == "" {
+		return fmt.Sprintf("Error: symbol is required for %s", action), nil
+	}
+	interval := "1h"
+	if i, ok := args["interval"].(string); ok && i != "" {
+		interval = i
+	}
+	limit := 24
+	if l, ok := args["limit"].(float64); ok && int(l) > 0 {
+		limit = min(int(l), 100)
+	}
 
-	result := map[string]interface{}{
-		"symbol":           symbol + "USD",
-		"price":            fmt.Sprintf("%.2f", price),
-		"price_change":     "0.00",
-		"price_change_pct": "0.00",
-		"source":           "Forex API (Spot)",
-		"timestamp":        time.Now().UTC().Format(time.RFC3339),
+	var result map[string]interface{}
+	if action == "open_interest" {
+		series, err := t.router.OpenInterest(ctx, symbol, interval, limit)
+		if err != nil {
+			return marketError("open interest", symbol, err), nil
+		}
+		result = map[string]interface{}{
+			"symbol":     series.Symbol,
+			"instrument": series.Instrument,
+			"interval":   series.Interval,
+			"count":      len(series.Points),
+			"points":     series.Points,
+			"source":     series.Source,
+		}
+		if n := len(series.Points); n > 1 {
+			first, last := series.Points[0], series.Points[n-1]
+			if first.Contracts > 0 {
+				result["change_pct"] = (last.Contracts - first.Contracts) / first.Contracts * 100
+			} else if first.ValueUSD > 0 {
+				result["change_pct"] = (last.ValueUSD - first.ValueUSD) / first.ValueUSD * 100
+			}
+		}
+		if series.Cache != nil {
+			result["cache"] = series.Cache
+		}
+	} else {
+		series, err := t.router.LongShortRatio(ctx, symbol, interval, limit)
+		if err != nil {
+			return marketError("long/short ratio", symbol, err), nil
+		}
+		result = map[string]interface{}{
+			"symbol":     series.Symbol,
+			"instrument": series.Instrument,
+			"interval":   series.Interval,
+			"count":      len(series.Points),
+			"points":     series.Points,
+			"source":     series.Source,
+		}
+		if n := len(series.Points); n > 0 {
+			result["latest_ratio"] = series.Points[n-1].Ratio
+		}
+		if series.Cache != nil {
+			result["cache"] = series.Cache
+		}
 	}
 
 	out, _ := json.MarshalIndent(result, "", "  ")
 	return string(out), nil
 }
 
-func (t *MarketDataTool) httpGet(ctx context.Context, url string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func (t *MarketDataTool) getBasis(ctx context.Context, args map[string]interface{}) (string, error) {
+	symbol, _ := args["symbol"].(string)
+	if symbol == "" {
+		return "Error: symbol is required for basis", nil
+	}
+
+	basis, err := t.router.Basis(ctx, symbol)
+	if err != nil {
+		return marketError("basis", symbol, err), nil
+	}
+
+	out, _ := json.MarshalIndent(basis, "", "  ")
+	return string(out), nil
+}
+
+func (t *MarketDataTool) getHistory(ctx context.Context, args map[string]interface{}) (string, error) {
+	if t.history == nil {
+		return "Error: the candle history store is disabled (tools.market_data.history.enabled)", nil
+	}
+	symbol, _ := args["symbol"].(string)
+	if symbol == "" {
+		series := t.history.Series()
+		if len(series) == 0 {
+			return "No candle history stored yet.", nil
+		}
+		out, _ := json.MarshalIndent(series, "", "  ")
+		return string(out), nil
+	}
+
+	interval := "1h"
+	if i, ok := args["interval"].(string); ok && i != "" {
+		interval = i
+	}
+	end := time.Now()
+	if e, ok := args["end"].(string); ok && e != "" {
+		parsed, err := parseHistoryTime(e)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		end = parsed
+	}
+	days := 30.0
+	if d, ok := args["days"].(float64); ok && d > 0 {
+		days = d
+	}
+	start := end.Add(-time.Duration(days * float64(24*time.Hour)))
+	if s, ok := args["start"].(string); ok && s != "" {
+		parsed, err := parseHistoryTime(s)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		start = parsed
+	}
+	refresh := true
+	if r, ok := args["refresh"].(bool); ok {
+		refresh = r
+	}
+
+	result, err := t.history.Range(ctx, symbol, interval, start, end, refresh)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return marketError("candle history", symbol, err), nil
 	}
-	req.Header.Set("User-Agent", userAgent)
 
-	resp, err := t.client.Do(req)
+	limit := t.maxCandles
+	if l, ok := args["limit"].(float64); ok && int(l) > 0 && (limit <= 0 || int(l) < limit) {
+		limit = int(l)
+	}
+	total := len(result.Candles)
+	if limit > 0 && total > limit {
+		result.Candles = result.Candles[total-limit:]
+	}
+
+	out := map[string]interface{}{
+		"symbol":   result.Symbol,
+		"interval": result.Interval,
+		"source":   result.Source,
+		"from":     result.From.Format(time.RFC3339),
+		"to":       result.To.Format(time.RFC3339),
+		"count":    len(result.Candles),
+		"fetched":  result.Fetched,
+		"candles":  result.Candles,
+	}
+	if len(result.Candles) < total {
+		out["truncated"] = fmt.Sprintf("range holds %d candles; returned the latest %d", total, len(result.Candles))
+	}
+	if len(result.Gaps) > 0 {
+		out["gaps"] = result.Gaps
+	}
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return string(data), nil
+}
+
+// getSymbols resolves a symbol to its canonical instrument and per-source
+// tickers, or lists the registered instruments when no symbol is given.
+func (t *MarketDataTool) getSymbols(args map[string]interface{}) (string, error) {
+	symbol := stringArg(args, "symbol")
+	if symbol == "" {
+		instruments := marketdata.Symbols.Instruments("")
+		lines := make([]string, 0, len(instruments))
+		for _, inst := range instruments {
+			line := fmt.Sprintf("%s [%s] %s", inst.ID, inst.Class, inst.Name)
+			if len(inst.Aliases) > 0 {
+				line += " (aliases: " + strings.Join(inst.Aliases, ", ") + ")"
+			}
+			lines = append(lines, line)
+		}
+		return fmt.Sprintf("%d registered instruments; other well-formed exchange pairs, forex pairs and stock tickers also work:\n%s",
+			len(instruments), strings.Join(lines, "\n")), nil
+	}
+
+	inst, err := marketdata.Symbols.Resolve(symbol)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	result := map[string]interface{}{
+		"input":      symbol,
+		"instrument": inst,
+	}
+	if !inst.Listed {
+		if suggestions := marketdata.Symbols.Suggest(symbol, 3); len(suggestions) > 0 && suggestions[0] != inst.ID {
+			result["did_you_mean"] = suggestions
+		}
 	}
-	defer resp.Body.Close()
+	out, _ := json.MarshalIndent(result, "", "  ")
+	return string(out), nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+// parseHistoryTime reads RFC3339 timestamps and plain dates (UTC).
+func parseHistoryTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC3339 or YYYY-MM-DD", s)
+}
 
-	return io.ReadAll(resp.Body)
+// marketError renders a router failure for the model.
+func marketError(what, symbol string, err error) string {
+	if errors.Is(err, marketdata.ErrNotFound) {
+		return fmt.Sprintf("Error: Invalid symbol '%s' for %s. Use e.g. BTCUSDT, EURUSD, XAUUSD or AAPL. (%v)", symbol, what, err)
+	}
+	return fmt.Sprintf("Error fetching %s: %v", what, err)
 }
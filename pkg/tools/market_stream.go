@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/marketdata"
+)
+
+// MarketStreamTool reads live crypto prices from the gateway's websocket
+// stream, which keeps a rolling window per symbol, so checking for fast
+// moves doesn't need a market_data request.
+type MarketStreamTool struct {
+	stream *marketdata.Stream
+}
+
+func NewMarketStreamTool(stream *marketdata.Stream) *MarketStreamTool {
+	return &MarketStreamTool{
+		stream: stream,
+	}
+}
+
+func (t *MarketStreamTool) Name() string {
+	return "market_stream"
+}
+
+func (t *MarketStreamTool) Description() string {
+	return `Read live crypto prices streamed from the Binance websocket (updated every second while the gateway runs).
+Actions:
+- "latest": Live price, 24h change and rolling-window stats (open/high/low/change over the last "minutes") for "symbols" (default: all streamed)
+- "status": Connection state and streamed symbols
+- "subscribe": Start streaming "symbols" (crypto pairs like BTCUSDT)
+- "unsubscribe": Stop streaming "symbols"
+Quotes marked "stale" haven't updated for a minute; use market_data for those and for non-crypto symbols.`
+}
+
+func (t *MarketStreamTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"latest", "status", "subscribe", "unsubscribe"},
+				"description": "Action to perform",
+			},
+			"symbols": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Crypto symbols, e.g. [\"BTCUSDT\", \"ETHUSDT\"]",
+			},
+			"minutes": map[string]interface{}{
+				"type":        "integer",
+				"description": "Window for the latest stats in minutes (default: the whole stream window)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *MarketStreamTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return "", fmt.Errorf("action is required")
+	}
+
+	switch action {
+	case "latest":
+		return t.latest(args)
+	case "status":
+		out, _ := json.MarshalIndent(t.stream.Status(), "", "  ")
+		return string(out), nil
+	case "subscribe", "unsubscribe":
+		symbols := stringList(args["symbols"])
+		if len(symbols) == 0 {
+			return fmt.Sprintf("Error: symbols are required for %s", action), nil
+		}
+		var err error
+		if action == "subscribe" {
+			err = t.stream.Subscribe(symbols...)
+		} else {
+			err = t.stream.Unsubscribe(symbols...)
+		}
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		return fmt.Sprintf("Streaming: %s", strings.Join(t.stream.Symbols(), ", ")), nil
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *MarketStreamTool) latest(args map[string]interface{}) (string, error) {
+	minutes := 0
+	if m, ok := args["minutes"].(float64); ok && m > 0 {
+		minutes = int(m)
+	}
+	symbols := stringList(args["symbols"])
+	if len(symbols) == 0 {
+		symbols = t.stream.Symbols()
+	}
+
+	quotes := make([]marketdata.Quote, 0, len(symbols))
+	var missing []string
+	for _, symbol := range symbols {
+		if quote, ok := t.stream.Quote(symbol, minutes); ok {
+			quotes = append(quotes, quote)
+		} else {
+			missing = append(missing, marketdata.Canonical(symbol))
+		}
+	}
+
+	result := map[string]interface{}{
+		"connected": t.stream.Status().Connected,
+		"quotes":    quotes,
+	}
+	if len(missing) > 0 {
+		// Not subscribed, or subscribed but no update has arrived yet
+		result["no_data"] = missing
+	}
+	out, _ := json.MarshalIndent(result, "", "  ")
+	return string(out), nil
+}
@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// MemoryTool manages structured long-term facts and daily notes, so the
+// agent no longer edits MEMORY.md by hand.
+type MemoryTool struct {
+	store         *memory.MemoryStore
+	originChannel string
+	originChatID  string
+}
+
+func NewMemoryTool(store *memory.MemoryStore) *MemoryTool {
+	return &MemoryTool{store: store}
+}
+
+func (t *MemoryTool) Name() string {
+	return "memory"
+}
+
+func (t *MemoryTool) Description() string {
+	return `Manage long-term memory. Facts are short, self-contained statements with ids and tags; they are rendered into memory/MEMORY.md automatically.
+Actions:
+- "remember": Store a fact (requires text, optional tags). Near-identical facts are merged instead of duplicated
+- "update": Correct a fact (requires id, plus new text and/or tags). Prefer this over adding a contradicting fact
+- "forget": Delete a fact that is wrong or obsolete (requires id)
+- "list": List facts, optionally only those with a tag
+- "append_daily": Add an observation to today's daily note (requires text)`
+}
+
+func (t *MemoryTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"remember", "update", "forget", "list", "append_daily"},
+				"description": "Action to perform",
+			},
+			"text": map[string]interface{}{
+				"type":        "string",
+				"description": "Fact or note text (for remember, update and append_daily)",
+			},
+			"tags": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Tags such as 'user', 'pattern', 'correlation' or an asset (for remember and update)",
+			},
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "Fact ID, e.g. 'f12' (for update and forget)",
+			},
+			"tag": map[string]interface{}{
+				"type":        "string",
+				"description": "Only list facts with this tag (for list)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *MemoryTool) SetContext(channel, chatID string) {
+	t.originChannel = channel
+	t.originChatID = chatID
+}
+
+func (t *MemoryTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return "", fmt.Errorf("action is required")
+	}
+	if t.store == nil {
+		return "Error: memory store not configured", nil
+	}
+
+	switch action {
+	case "remember":
+		return t.remember(args)
+	case "update":
+		return t.update(args)
+	case "forget":
+		return t.forget(args)
+	case "list":
+		return t.list(args)
+	case "append_daily":
+		return t.appendDaily(args)
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *MemoryTool) remember(args map[string]interface{}) (string, error) {
+	text, _ := args["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return "Error: text is required", nil
+	}
+
+	source := ""
+	if t.originChannel != "" {
+		source = t.originChannel + ":" + t.originChatID
+	}
+	fact, duplicate, err := t.store.Remember(text, stringList(args["tags"]), source)
+	if err != nil {
+		return fmt.Sprintf("Error: failed to remember: %v", err), nil
+	}
+	if duplicate {
+		return fmt.Sprintf("Already remembered as %s; refreshed it: %s", fact.ID, fact.Text), nil
+	}
+	return fmt.Sprintf("Remembered %s: %s", fact.ID, fact.Text), nil
+}
+
+func (t *MemoryTool) update(args map[string]interface{}) (string, error) {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return "Error: id is required", nil
+	}
+	text, _ := args["text"].(string)
+	_, hasTags := args["tags"]
+	if strings.TrimSpace(text) == "" && !hasTags {
+		return "Error: text or tags is required", nil
+	}
+
+	var tags []string
+	if hasTags {
+		tags = append([]string{}, stringList(args["tags"])...)
+	}
+	fact, err := t.store.UpdateFact(id, text, tags)
+	if err != nil {
+		return factError(err), nil
+	}
+	return fmt.Sprintf("Updated %s: %s", fact.ID, fact.Text), nil
+}
+
+func (t *MemoryTool) forget(args map[string]interface{}) (string, error) {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return "Error: id is required", nil
+	}
+	fact, err := t.store.Forget(id)
+	if err != nil {
+		return factError(err), nil
+	}
+	return fmt.Sprintf("Forgot %s: %s", fact.ID, fact.Text), nil
+}
+
+func (t *MemoryTool) list(args map[string]interface{}) (string, error) {
+	tag, _ := args["tag"].(string)
+	facts, err := t.store.Facts(tag)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	if len(facts) == 0 {
+		if tag != "" {
+			return fmt.Sprintf("No facts tagged %q.", tag), nil
+		}
+		return "No facts remembered yet.", nil
+	}
+
+	lines := make([]string, 0, len(facts))
+	for _, fact := range facts {
+		line := fmt.Sprintf("- %s: %s", fact.ID, fact.Text)
+		if len(fact.Tags) > 0 {
+			line += " #" + strings.Join(fact.Tags, " #")
+		}
+		line += fmt.Sprintf(" (updated %s)", time.UnixMilli(fact.Updated).Format("2006-01-02"))
+		lines = append(lines, line)
+	}
+	return fmt.Sprintf("%d facts:\n%s", len(facts), strings.Join(lines, "\n")), nil
+}
+
+func (t *MemoryTool) appendDaily(args map[string]interface{}) (string, error) {
+	text, _ := args["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return "Error: text is required", nil
+	}
+	entry := fmt.Sprintf("## %s\n\n%s\n", time.Now().Format("15:04"), strings.TrimSpace(text))
+	if err := t.store.AppendToday(entry); err != nil {
+		return fmt.Sprintf("Error: failed to append to daily note: %v", err), nil
+	}
+	return "Added to today's note", nil
+}
+
+func factError(err error) string {
+	if errors.Is(err, memory.ErrFactNotFound) {
+		return fmt.Sprintf("Error: %v. Use action \"list\" to see fact ids.", err)
+	}
+	return fmt.Sprintf("Error: %v", err)
+}
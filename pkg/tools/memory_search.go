@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const maxMemorySearchResults = 20
+
+// MemorySearchTool runs full-text searches over long-term memory, daily notes
+// and indexed data files.
+type MemorySearchTool struct {
+	retriever memory.Retriever
+}
+
+func NewMemorySearchTool(retriever memory.Retriever) *MemorySearchTool {
+	return &MemorySearchTool{retriever: retriever}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) Description() string {
+	return `Search long-term memory (MEMORY.md), all daily notes and indexed data logs by keywords. Only the most relevant memories are shown in your context, so use this to recall older notes, past decisions, patterns and reasoning. Results are ranked by relevance.`
+}
+
+func (t *MemorySearchTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Keywords to search for, e.g. 'gold CPI surprise' or 'EURUSD breakout failed'",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of results (default 5, max %d)", maxMemorySearchResults),
+			},
+			"source": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"all", memory.SourceMemory, memory.SourceNotes, memory.SourceData},
+				"description": "Restrict to long-term memory, daily notes or data files (default: all)",
+			},
+			"since": map[string]interface{}{
+				"type":        "string",
+				"description": "Only return entries dated on or after this day (YYYY-MM-DD)",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	if t.retriever == nil {
+		return "Error: memory index not configured", nil
+	}
+
+	opts := memory.SearchOptions{Limit: 5}
+	if n, ok := args["limit"].(float64); ok && n > 0 {
+		opts.Limit = int(math.Min(n, maxMemorySearchResults))
+	}
+	if source, _ := args["source"].(string); source != "" && source != "all" {
+		opts.Sources = []string{source}
+	}
+	if since, _ := args["since"].(string); since != "" {
+		day, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return fmt.Sprintf("Error: invalid since date %q, expected YYYY-MM-DD", since), nil
+		}
+		opts.Since = day
+	}
+
+	results := t.retriever.Retrieve(ctx, query, opts)
+	if len(results) == 0 {
+		return fmt.Sprintf("No memories found for %q.", query), nil
+	}
+
+	entries := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		entry := map[string]interface{}{
+			"path":   r.Path,
+			"source": r.Source,
+			"date":   r.Date.Format("2006-01-02"),
+			"score":  math.Round(r.Score*10000) / 10000,
+			"text":   r.Text,
+		}
+		if r.Heading != "" {
+			entry["heading"] = r.Heading
+		}
+		if r.Line > 0 {
+			entry["line"] = r.Line
+		}
+		entries = append(entries, entry)
+	}
+
+	output, _ := json.MarshalIndent(map[string]interface{}{
+		"query":   query,
+		"results": entries,
+	}, "", "  ")
+	return string(output), nil
+}
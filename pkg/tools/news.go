@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
 )
 
 // NewsFeedTool provides economic news and calendar from free RSS/API sources.
@@ -22,6 +24,11 @@ func NewNewsFeedTool() *NewsFeedTool {
 	}
 }
 
+// SetRateLimiter sends the feed requests through a shared rate limiter.
+func (t *NewsFeedTool) SetRateLimiter(limiter *ratelimit.Limiter) {
+	t.client.Transport = limiter.Wrap(t.client.Transport)
+}
+
 func (t *NewsFeedTool) Name() string {
 	return "news_feed"
 }
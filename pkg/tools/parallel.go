@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	maxParallelTasks        = 10
+	defaultParallelTimeout  = 300 * time.Second
+	maxParallelResultLength = 6000
+)
+
+// ParallelTasksTool fans a list of tasks out to subagents, waits for all of
+// them and returns the collected results to the calling turn, instead of
+// having each subagent announce itself asynchronously on the bus.
+type ParallelTasksTool struct {
+	manager       *SubagentManager
+	originChannel string
+	originChatID  string
+}
+
+func NewParallelTasksTool(manager *SubagentManager) *ParallelTasksTool {
+	return &ParallelTasksTool{
+		manager:       manager,
+		originChannel: "cli",
+		originChatID:  "direct",
+	}
+}
+
+func (t *ParallelTasksTool) Name() string {
+	return "parallel_tasks"
+}
+
+func (t *ParallelTasksTool) Description() string {
+	return fmt.Sprintf(`Run several independent tasks in parallel subagents and wait for all of their results (up to %d tasks).
+Use it to research competing hypotheses or several assets at once, then merge the findings yourself.
+Each task must be self-contained; put information every subagent needs in "context".
+Tasks that do not finish within timeout_seconds are cancelled and reported as timed out.`, maxParallelTasks)
+}
+
+func (t *ParallelTasksTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tasks": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Independent tasks, one per subagent",
+			},
+			"labels": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional short labels, in the same order as tasks",
+			},
+			"context": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional shared background prepended to every task",
+			},
+			"tools": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional subset of tools the subagents may use",
+			},
+			"model": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional model override for the subagents",
+			},
+			"max_iterations": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional lower tool iteration limit per subagent",
+			},
+			"timeout_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "How long to wait for all results (default 300)",
+			},
+		},
+		"required": []string{"tasks"},
+	}
+}
+
+func (t *ParallelTasksTool) SetContext(channel, chatID string) {
+	t.originChannel = channel
+	t.originChatID = chatID
+}
+
+type parallelOutcome struct {
+	label  string
+	id     string
+	status string
+	result string
+	iters  int
+}
+
+func (t *ParallelTasksTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	if t.manager == nil {
+		return "Error: Subagent manager not configured", nil
+	}
+
+	tasks := stringList(args["tasks"])
+	if len(tasks) == 0 {
+		return "", fmt.Errorf("tasks is required")
+	}
+	if len(tasks) > maxParallelTasks {
+		return fmt.Sprintf("Error: at most %d tasks can run in parallel, got %d", maxParallelTasks, len(tasks)), nil
+	}
+	for i, task := range tasks {
+		if strings.TrimSpace(task) == "" {
+			return fmt.Sprintf("Error: task %d is empty", i+1), nil
+		}
+	}
+
+	labels := stringList(args["labels"])
+	shared, _ := args["context"].(string)
+
+	opts := SpawnOptions{Tools: stringList(args["tools"]), Silent: true}
+	opts.Model, _ = args["model"].(string)
+	if n, ok := args["max_iterations"].(float64); ok {
+		opts.MaxIterations = int(n)
+	}
+
+	timeout := defaultParallelTimeout
+	if n, ok := args["timeout_seconds"].(float64); ok && n > 0 {
+		timeout = time.Duration(n) * time.Second
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	outcomes := make([]parallelOutcome, len(tasks))
+
+	// Fan out. When the per-chat cap is reached, wait for a slot to free up.
+	for i, task := range tasks {
+		outcomes[i].label = utils.Truncate(task, 60)
+		if i < len(labels) && labels[i] != "" {
+			outcomes[i].label = labels[i]
+		}
+		if shared != "" {
+			task = fmt.Sprintf("%s\n\n## Context\n%s", task, shared)
+		}
+
+		taskOpts := opts
+		taskOpts.Label = outcomes[i].label
+		for outcomes[i].id == "" && outcomes[i].status == "" {
+			spawned, err := t.manager.SpawnWithOptions(ctx, task, taskOpts, t.originChannel, t.originChatID)
+			switch {
+			case err == nil:
+				outcomes[i].id = spawned.ID
+			case errors.Is(err, ErrSubagentLimit):
+				select {
+				case <-waitCtx.Done():
+					outcomes[i].status = "timed out"
+					outcomes[i].result = "Not started: no subagent slot became free before the timeout."
+				case <-time.After(250 * time.Millisecond):
+				}
+			default:
+				outcomes[i].status = SubagentFailed
+				outcomes[i].result = fmt.Sprintf("Error: %v", err)
+			}
+		}
+	}
+
+	// Fan in
+	for i := range outcomes {
+		if outcomes[i].id == "" {
+			continue
+		}
+		task, err := t.manager.Wait(waitCtx, outcomes[i].id)
+		if err != nil {
+			t.manager.Cancel(outcomes[i].id)
+			outcomes[i].status = "timed out"
+			outcomes[i].result = fmt.Sprintf("Cancelled after %s without a result.", timeout)
+			continue
+		}
+		outcomes[i].status = task.Status
+		outcomes[i].result = task.Result
+		outcomes[i].iters = task.Iterations
+	}
+
+	return formatParallelOutcomes(outcomes, time.Since(start)), nil
+}
+
+func formatParallelOutcomes(outcomes []parallelOutcome, elapsed time.Duration) string {
+	counts := make(map[string]int)
+	for _, o := range outcomes {
+		counts[o.status]++
+	}
+	summary := make([]string, 0, len(counts))
+	for _, status := range []string{SubagentCompleted, SubagentFailed, SubagentCancelled, "timed out"} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Ran %d subagents in %s: %s.\n", len(outcomes), elapsed.Round(time.Second), strings.Join(summary, ", "))
+
+	for i, o := range outcomes {
+		fmt.Fprintf(&sb, "\n### [%d] %s: %s", i+1, o.label, o.status)
+		if o.iters > 0 {
+			fmt.Fprintf(&sb, " (%d iterations)", o.iters)
+		}
+		sb.WriteString("\n")
+
+		result := o.result
+		if len(result) > maxParallelResultLength {
+			result = result[:maxParallelResultLength] + fmt.Sprintf("\n... (truncated; use spawn action \"result\" with task_id %s for the full text)", o.id)
+		}
+		sb.WriteString(result)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// stringList converts a JSON array argument to a slice of strings.
+func stringList(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -3,10 +3,12 @@ package tools
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 type ToolRegistry struct {
@@ -92,6 +94,43 @@ func (r *ToolRegistry) GetDefinitions() []map[string]interface{} {
 	return definitions
 }
 
+// ProviderDefinitions returns the tool definitions, sorted by name, in the
+// form LLM providers expect.
+func (r *ToolRegistry) ProviderDefinitions() []providers.ToolDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]providers.ToolDefinition, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, providers.ToolDefinition{
+			Type: "function",
+			Function: providers.ToolFunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  tool.Parameters(),
+			},
+		})
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// Subset returns a new registry sharing the named tools. Unknown names are skipped.
+func (r *ToolRegistry) Subset(names []string) *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	subset := NewToolRegistry()
+	for _, name := range names {
+		if tool, ok := r.tools[name]; ok {
+			subset.tools[name] = tool
+		}
+	}
+	return subset
+}
+
 // List returns a list of all registered tool names.
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
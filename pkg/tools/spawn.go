@@ -2,7 +2,12 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type SpawnTool struct {
@@ -24,23 +29,50 @@ func (t *SpawnTool) Name() string {
 }
 
 func (t *SpawnTool) Description() string {
-	return "Spawn a subagent to handle a task in the background. Use this for complex or time-consuming tasks that can run independently. The subagent will complete the task and report back when done."
+	return `Spawn a subagent to handle a task in the background, and manage running subagents. Subagents run their own tool loop (data fetching, storage, web) and report back when done.
+Actions:
+- "spawn" (default): Start a subagent. Optionally restrict its "tools", pick a "model" or lower "max_iterations"
+- "status": Show the state of one subagent (requires task_id)
+- "list": List subagents started from this chat
+- "cancel": Stop a running subagent (requires task_id)
+- "result": Get the full result of a finished subagent (requires task_id)`
 }
 
 func (t *SpawnTool) Parameters() map[string]interface{} {
 	return map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"spawn", "status", "list", "cancel", "result"},
+				"description": "Action to perform (default: spawn)",
+			},
 			"task": map[string]interface{}{
 				"type":        "string",
-				"description": "The task for subagent to complete",
+				"description": "The task for subagent to complete (for spawn)",
 			},
 			"label": map[string]interface{}{
 				"type":        "string",
 				"description": "Optional short label for the task (for display)",
 			},
+			"tools": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional subset of tools the subagent may use",
+			},
+			"model": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional model override for the subagent",
+			},
+			"max_iterations": map[string]interface{}{
+				"type":        "integer",
+				"description": "Optional lower tool iteration limit for the subagent",
+			},
+			"task_id": map[string]interface{}{
+				"type":        "string",
+				"description": "Subagent ID for status, cancel and result (e.g. 'subagent-3')",
+			},
 		},
-		"required": []string{"task"},
 	}
 }
 
@@ -50,21 +82,131 @@ func (t *SpawnTool) SetContext(channel, chatID string) {
 }
 
 func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	if t.manager == nil {
+		return "Error: Subagent manager not configured", nil
+	}
+
+	action, _ := args["action"].(string)
+	if action == "" {
+		action = "spawn"
+	}
+
+	switch action {
+	case "spawn":
+		return t.spawn(ctx, args)
+	case "status":
+		return t.status(args)
+	case "list":
+		return t.list()
+	case "cancel":
+		return t.cancel(args)
+	case "result":
+		return t.result(args)
+	default:
+		return "", fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *SpawnTool) spawn(ctx context.Context, args map[string]interface{}) (string, error) {
 	task, ok := args["task"].(string)
-	if !ok {
+	if !ok || task == "" {
 		return "", fmt.Errorf("task is required")
 	}
 
-	label, _ := args["label"].(string)
-
-	if t.manager == nil {
-		return "Error: Subagent manager not configured", nil
+	opts := SpawnOptions{}
+	opts.Label, _ = args["label"].(string)
+	opts.Model, _ = args["model"].(string)
+	if n, ok := args["max_iterations"].(float64); ok {
+		opts.MaxIterations = int(n)
 	}
+	opts.Tools = stringList(args["tools"])
 
-	result, err := t.manager.Spawn(ctx, task, label, t.originChannel, t.originChatID)
+	spawned, err := t.manager.SpawnWithOptions(ctx, task, opts, t.originChannel, t.originChatID)
 	if err != nil {
-		return "", fmt.Errorf("failed to spawn subagent: %w", err)
+		return fmt.Sprintf("Error: failed to spawn subagent: %v", err), nil
+	}
+
+	name := spawned.ID
+	if spawned.Label != "" {
+		name = fmt.Sprintf("'%s' (%s)", spawned.Label, spawned.ID)
 	}
+	return fmt.Sprintf("Spawned subagent %s with tools [%s], up to %d iterations. It will report back when done; use action \"status\" or \"result\" with task_id %s to check on it.",
+		name, strings.Join(spawned.Tools, ", "), spawned.MaxIterations, spawned.ID), nil
+}
+
+func (t *SpawnTool) lookup(args map[string]interface{}) (*SubagentTask, string) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return nil, "Error: task_id is required"
+	}
+	task, ok := t.manager.GetTask(taskID)
+	if !ok {
+		return nil, fmt.Sprintf("Error: subagent %s not found", taskID)
+	}
+	return task, ""
+}
+
+func (t *SpawnTool) status(args map[string]interface{}) (string, error) {
+	task, errMsg := t.lookup(args)
+	if task == nil {
+		return errMsg, nil
+	}
+
+	result := map[string]interface{}{
+		"id":         task.ID,
+		"label":      task.Label,
+		"status":     task.Status,
+		"iterations": fmt.Sprintf("%d/%d", task.Iterations, task.MaxIterations),
+		"model":      task.Model,
+		"tools":      task.Tools,
+		"created":    time.UnixMilli(task.Created).Format(time.RFC3339),
+	}
+	if task.Finished > 0 {
+		result["finished"] = time.UnixMilli(task.Finished).Format(time.RFC3339)
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	return string(output), nil
+}
+
+func (t *SpawnTool) list() (string, error) {
+	var lines []string
+	for _, task := range t.manager.ListTasks() {
+		if task.OriginChannel != t.originChannel || task.OriginChatID != t.originChatID {
+			continue
+		}
+		label := task.Label
+		if label == "" {
+			label = utils.Truncate(task.Task, 60)
+		}
+		lines = append(lines, fmt.Sprintf("- %s [%s] %s (started %s)",
+			task.ID, task.Status, label, time.UnixMilli(task.Created).Format("2006-01-02 15:04")))
+	}
+
+	if len(lines) == 0 {
+		return "No subagents have been started from this chat.", nil
+	}
+	return "Subagents:\n" + strings.Join(lines, "\n"), nil
+}
 
-	return result, nil
+func (t *SpawnTool) cancel(args map[string]interface{}) (string, error) {
+	taskID, _ := args["task_id"].(string)
+	if taskID == "" {
+		return "Error: task_id is required", nil
+	}
+	if err := t.manager.Cancel(taskID); err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	return fmt.Sprintf("Cancelled subagent %s", taskID), nil
+}
+
+func (t *SpawnTool) result(args map[string]interface{}) (string, error) {
+	task, errMsg := t.lookup(args)
+	if task == nil {
+		return errMsg, nil
+	}
+	if task.Status == SubagentRunning {
+		return fmt.Sprintf("Subagent %s is still running (%d/%d iterations used).", task.ID, task.Iterations, task.MaxIterations), nil
+	}
+	return fmt.Sprintf("Subagent %s %s.\n\n%s", task.ID, task.Status, task.Result), nil
 }
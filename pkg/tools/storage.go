@@ -3,23 +3,31 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/contracts"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+	"github.com/sipeed/picoclaw/pkg/timeseries"
 )
 
 // StorageTool provides structured data storage within the workspace.
 // All operations are sandboxed to the workspace/data/ directory for security.
+// Writes and appends to paths with a schema contract are validated first.
 type StorageTool struct {
-	dataDir string
+	dataDir   string
+	contracts *contracts.Registry
 }
 
 func NewStorageTool(workspace string) *StorageTool {
 	dataDir := filepath.Join(workspace, "data")
 	os.MkdirAll(dataDir, 0755)
-	return &StorageTool{dataDir: dataDir}
+	return &StorageTool{dataDir: dataDir, contracts: contracts.NewRegistry(workspace)}
 }
 
 func (t *StorageTool) Name() string {
@@ -29,11 +37,18 @@ func (t *StorageTool) Name() string {
 func (t *StorageTool) Description() string {
 	return `Read, write, append, and list structured data files in the workspace data directory.
 Actions:
-- "write": Write JSON data to a file (creates directories automatically)
-- "read": Read a file's contents
-- "append": Append a JSON entry to an existing file (for logs/time-series)
+- "write": Write JSON data to a file (creates directories automatically). Pass "if_version" from a previous read to avoid overwriting changes made by another job in between
+- "read": Read a file's contents, followed by its [version: ...]
+- "append": Append a JSON entry to an existing file. For logs written often use a ".jsonl" path: entries go to append-only daily segments and get a "timestamp" if they have none
 - "list": List files in a directory
-- "delete": Delete a file
+- "delete": Delete a file, or a whole ".jsonl" series
+- "query": Select, filter, sort and aggregate entries of a JSON file without reading all of it.
+  "select" is a JSONPath-style selector ("$.scans[-5:]", "patterns[*]", "$..price"); "where" takes clauses like "score >= 7", "asset == 'BTC'", "tags contains macro" or "note exists".
+  Example: {"action": "query", "path": "scans/daily_log.jsonl", "where": ["change_pct > 3"], "sort_by": "change_pct", "order": "desc", "head": 5, "fields": ["date", "symbol", "change_pct"]}
+- "compact": Merge a ".jsonl" series' daily segments of past months into monthly segments
+- "migrate": Convert a ".json" array log into a ".jsonl" series (appends to either name then go to the series)
+Use "from"/"to" with read or query on a series to load only a time range. A "write" to a ".jsonl" series replaces all its entries with "data" as a JSON array or JSON Lines.
+Writes and appends to paths with a schema contract (workspace schemas.json or a skill's storage_schemas) are validated first; on errors nothing is written.
 All paths are relative to the workspace data/ directory. Example paths: "scans/2025-01-15.json", "reports/daily.json"`
 }
 
@@ -43,7 +58,7 @@ func (t *StorageTool) Parameters() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"action": map[string]interface{}{
 				"type":        "string",
-				"enum":        []string{"write", "read", "append", "list", "delete"},
+				"enum":        []string{"write", "read", "append", "list", "delete", "query", "compact", "migrate"},
 				"description": "Storage action to perform",
 			},
 			"path": map[string]interface{}{
@@ -54,6 +69,58 @@ func (t *StorageTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "Data to write or append (JSON string or plain text)",
 			},
+			"if_version": map[string]interface{}{
+				"type":        "string",
+				"description": "For write: only write if the file is still at this version (from a previous read); use \"\" to require that the file doesn't exist",
+			},
+			"select": map[string]interface{}{
+				"type":        "string",
+				"description": "JSONPath-style selector for the entries to query (default: the whole file, e.g. a top-level array)",
+			},
+			"where": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Filter clauses that must all match, e.g. \"score >= 7\", \"prices.BTC < 60000\", \"asset == 'ETH'\"",
+			},
+			"sort_by": map[string]interface{}{
+				"type":        "string",
+				"description": "Field to sort entries by (dotted paths allowed)",
+			},
+			"order": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"asc", "desc"},
+				"description": "Sort order (default: asc)",
+			},
+			"head": map[string]interface{}{
+				"type":        "integer",
+				"description": "Return only the first N entries",
+			},
+			"tail": map[string]interface{}{
+				"type":        "integer",
+				"description": "Return only the last N entries",
+			},
+			"fields": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Only include these fields of each entry",
+			},
+			"aggregate": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string", "enum": []string{"count", "min", "max", "mean", "sum"}},
+				"description": "Return aggregates over the matching entries instead of the entries",
+			},
+			"from": map[string]interface{}{
+				"type":        "string",
+				"description": "Start of the time range for a .jsonl series, inclusive (date or RFC 3339, e.g. '2025-01-15')",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "End of the time range for a .jsonl series, exclusive",
+			},
+			"field": map[string]interface{}{
+				"type":        "string",
+				"description": "Numeric field for min, max, mean and sum aggregates",
+			},
 		},
 		"required": []string{"action"},
 	}
@@ -76,11 +143,42 @@ func (t *StorageTool) Execute(ctx context.Context, args map[string]interface{})
 		return t.listData(args)
 	case "delete":
 		return t.deleteData(args)
+	case "query":
+		return t.queryData(args)
+	case "compact":
+		return t.compactSeries(args)
+	case "migrate":
+		return t.migrateSeries(args)
 	default:
 		return "", fmt.Errorf("unknown action: %s", action)
 	}
 }
 
+// checkContract validates data against the schema contract for path, if
+// any, and returns the error message for the model or "".
+func (t *StorageTool) checkContract(path, data string, entry bool) string {
+	contract, err := t.contracts.Lookup(path)
+	if err != nil {
+		return fmt.Sprintf("Error: cannot check the schema contract for %s: %v", path, err)
+	}
+	if contract == nil {
+		return ""
+	}
+
+	var errs []jsonschema.ValidationError
+	what := "Data"
+	if entry {
+		errs = contract.ValidateEntry([]byte(data))
+		what = "Entry"
+	} else {
+		errs = contract.ValidateDocument([]byte(data))
+	}
+	if len(errs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Error: %s for %s %s\nNothing was written. Fix the data and try again.", what, path, contract.Describe(errs))
+}
+
 func (t *StorageTool) resolvePath(relPath string) (string, error) {
 	if relPath == "" {
 		return "", fmt.Errorf("path is required")
@@ -120,17 +218,44 @@ func (t *StorageTool) writeData(args map[string]interface{}) (string, error) {
 		return fmt.Sprintf("Error: %v", err), nil
 	}
 
-	// Create parent directories
-	dir := filepath.Dir(absPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Sprintf("Error creating directory: %v", err), nil
+	// Time-series paths replace the series' entries
+	if series, ok := t.seriesFor(path); ok {
+		if _, ok := args["if_version"]; ok {
+			return fmt.Sprintf("Error: %s is a time-series, which has no version; write it without if_version", path), nil
+		}
+		if msg := t.checkSeriesContract(path, data); msg != "" {
+			return msg, nil
+		}
+		return t.writeSeries(series, data, path)
+	}
+
+	if msg := t.checkContract(path, data, false); msg != "" {
+		return msg, nil
+	}
+
+	// With if_version the write only succeeds if nobody changed the file
+	// since it was read
+	if expected, ok := args["if_version"].(string); ok {
+		version, err := fileio.WriteFileIf(absPath, []byte(data), 0644, fileio.Version(expected))
+		if errors.Is(err, fileio.ErrConflict) {
+			return fmt.Sprintf("Error: %s was modified since it was read (%v). Read it again, reapply your change and write with the new version.", path, err), nil
+		}
+		if err != nil {
+			return fmt.Sprintf("Error writing file: %v", err), nil
+		}
+		return fmt.Sprintf("Written %d bytes to %s [version: %s]", len(data), path, version), nil
 	}
 
-	if err := os.WriteFile(absPath, []byte(data), 0644); err != nil {
+	// Take the lock appends and versioned writes hold, so a plain write
+	// can't land in the middle of their read-modify-write cycle
+	err = fileio.WithLock(absPath, func() error {
+		return fileio.WriteFile(absPath, []byte(data), 0644)
+	})
+	if err != nil {
 		return fmt.Sprintf("Error writing file: %v", err), nil
 	}
 
-	return fmt.Sprintf("Written %d bytes to %s", len(data), path), nil
+	return fmt.Sprintf("Written %d bytes to %s [version: %s]", len(data), path, fileio.VersionOf([]byte(data))), nil
 }
 
 func (t *StorageTool) readData(args map[string]interface{}) (string, error) {
@@ -144,12 +269,25 @@ func (t *StorageTool) readData(args map[string]interface{}) (string, error) {
 		return fmt.Sprintf("Error: %v", err), nil
 	}
 
-	content, err := os.ReadFile(absPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Sprintf("File not found: %s", path), nil
+	var content []byte
+	var version fileio.Version
+	if series, ok := t.seriesFor(path); ok {
+		if _, err := t.adoptLegacy(series); err != nil {
+			return fmt.Sprintf("Error %v", err), nil
+		}
+		entries, err := rangeSeries(series, args)
+		if err != nil {
+			return fmt.Sprintf("Error reading series: %v", err), nil
+		}
+		content, _ = json.MarshalIndent(entries, "", "  ")
+	} else {
+		content, version, err = fileio.ReadFile(absPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Sprintf("File not found: %s", path), nil
+			}
+			return fmt.Sprintf("Error reading file: %v", err), nil
 		}
-		return fmt.Sprintf("Error reading file: %v", err), nil
 	}
 
 	// Truncate very large files
@@ -157,6 +295,9 @@ func (t *StorageTool) readData(args map[string]interface{}) (string, error) {
 	if len(result) > 50000 {
 		result = result[:50000] + fmt.Sprintf("\n... (truncated, total %d bytes)", len(content))
 	}
+	if version != "" {
+		result += fmt.Sprintf("\n[version: %s]", version)
+	}
 
 	return result, nil
 }
@@ -183,6 +324,15 @@ func (t *StorageTool) appendData(args map[string]interface{}) (string, error) {
 		return fmt.Sprintf("Error creating directory: %v", err), nil
 	}
 
+	if msg := t.checkContract(path, data, true); msg != "" {
+		return msg, nil
+	}
+
+	// Time-series paths append a line to today's segment
+	if series, ok := t.seriesFor(path); ok {
+		return t.appendSeries(series, data, path)
+	}
+
 	// For JSON files, try to append to a JSON array
 	if strings.HasSuffix(path, ".json") {
 		return t.appendJSON(absPath, data, path)
@@ -190,13 +340,7 @@ func (t *StorageTool) appendData(args map[string]interface{}) (string, error) {
 
 	// For other files, simple line append with timestamp
 	entry := fmt.Sprintf("[%s] %s\n", time.Now().UTC().Format(time.RFC3339), data)
-	f, err := os.OpenFile(absPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Sprintf("Error opening file: %v", err), nil
-	}
-	defer f.Close()
-
-	if _, err := f.WriteString(entry); err != nil {
+	if err := fileio.AppendFile(absPath, []byte(entry), 0644); err != nil {
 		return fmt.Sprintf("Error appending: %v", err), nil
 	}
 
@@ -204,16 +348,6 @@ func (t *StorageTool) appendData(args map[string]interface{}) (string, error) {
 }
 
 func (t *StorageTool) appendJSON(absPath, data, relPath string) (string, error) {
-	// Read existing content
-	var entries []json.RawMessage
-	if existing, err := os.ReadFile(absPath); err == nil {
-		// Try to parse as array
-		if err := json.Unmarshal(existing, &entries); err != nil {
-			// If not an array, wrap existing content as first entry
-			entries = []json.RawMessage{existing}
-		}
-	}
-
 	// Parse new data as JSON, wrap in raw message
 	var newEntry json.RawMessage
 	if err := json.Unmarshal([]byte(data), &newEntry); err != nil {
@@ -222,19 +356,27 @@ func (t *StorageTool) appendJSON(absPath, data, relPath string) (string, error)
 		newEntry = wrapped
 	}
 
-	entries = append(entries, newEntry)
-
-	// Write back
-	out, err := json.MarshalIndent(entries, "", "  ")
+	// Read, append and write back under the file lock, so concurrent jobs
+	// appending to the same log don't lose entries
+	var total int
+	err := fileio.Update(absPath, 0644, func(existing []byte) ([]byte, error) {
+		var entries []json.RawMessage
+		if existing != nil {
+			// Try to parse as array
+			if err := json.Unmarshal(existing, &entries); err != nil {
+				// If not an array, wrap existing content as first entry
+				entries = []json.RawMessage{existing}
+			}
+		}
+		entries = append(entries, newEntry)
+		total = len(entries)
+		return json.MarshalIndent(entries, "", "  ")
+	})
 	if err != nil {
-		return fmt.Sprintf("Error marshaling JSON: %v", err), nil
-	}
-
-	if err := os.WriteFile(absPath, out, 0644); err != nil {
-		return fmt.Sprintf("Error writing file: %v", err), nil
+		return fmt.Sprintf("Error appending JSON: %v", err), nil
 	}
 
-	return fmt.Sprintf("Appended JSON entry to %s (total: %d entries)", relPath, len(entries)), nil
+	return fmt.Sprintf("Appended JSON entry to %s (total: %d entries)", relPath, total), nil
 }
 
 func (t *StorageTool) listData(args map[string]interface{}) (string, error) {
@@ -265,12 +407,20 @@ func (t *StorageTool) listData(args map[string]interface{}) (string, error) {
 
 	files := make([]fileInfo, 0, len(entries))
 	for _, entry := range entries {
+		// Skip lock and temp files
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
 		fi := fileInfo{
 			Name: entry.Name(),
 			Type: "file",
 		}
 		if entry.IsDir() {
 			fi.Type = "dir"
+			if timeseries.Exists(filepath.Join(absPath, entry.Name())) {
+				fi.Type = "series"
+				fi.Name += timeseries.SegmentExt
+			}
 		}
 		if info, err := entry.Info(); err == nil {
 			fi.Size = info.Size()
@@ -289,6 +439,58 @@ func (t *StorageTool) listData(args map[string]interface{}) (string, error) {
 	return string(out), nil
 }
 
+func (t *StorageTool) queryData(args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return "Error: path is required for query", nil
+	}
+
+	absPath, err := t.resolvePath(path)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+
+	q, err := parseStorageQuery(args)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+
+	var doc interface{}
+	if series, ok := t.seriesFor(path); ok {
+		if _, err := t.adoptLegacy(series); err != nil {
+			return fmt.Sprintf("Error %v", err), nil
+		}
+		entries, err := rangeSeries(series, args)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+		doc = entries
+	} else {
+		content, err := os.ReadFile(absPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Sprintf("File not found: %s", path), nil
+			}
+			return fmt.Sprintf("Error reading file: %v", err), nil
+		}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return fmt.Sprintf("Error: %s is not valid JSON: %v", path, err), nil
+		}
+	}
+
+	result, err := q.run(doc)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	result["path"] = path
+
+	out, _ := json.MarshalIndent(result, "", "  ")
+	if len(out) > 50000 {
+		return string(out[:50000]) + fmt.Sprintf("\n... (truncated, total %d bytes; narrow the query with where, fields or head/tail)", len(out)), nil
+	}
+	return string(out), nil
+}
+
 func (t *StorageTool) deleteData(args map[string]interface{}) (string, error) {
 	path, _ := args["path"].(string)
 	if path == "" {
@@ -300,6 +502,26 @@ func (t *StorageTool) deleteData(args map[string]interface{}) (string, error) {
 		return fmt.Sprintf("Error: %v", err), nil
 	}
 
+	// Time-series paths delete the series with all its segments, and the
+	// flat log of plain appends it replaces
+	if series, ok := t.seriesFor(path); ok {
+		flat := series.Dir() + timeseries.SegmentExt
+		_, err := os.Stat(flat)
+		hasFlat := err == nil
+		if !timeseries.Exists(series.Dir()) && !hasFlat {
+			return fmt.Sprintf("File not found: %s", path), nil
+		}
+		if err := series.Remove(); err != nil {
+			return fmt.Sprintf("Error deleting series: %v", err), nil
+		}
+		if hasFlat {
+			if err := os.Remove(flat); err != nil {
+				return fmt.Sprintf("Error deleting file: %v", err), nil
+			}
+		}
+		return fmt.Sprintf("Deleted series %s", path), nil
+	}
+
 	if err := os.Remove(absPath); err != nil {
 		if os.IsNotExist(err) {
 			return fmt.Sprintf("File not found: %s", path), nil
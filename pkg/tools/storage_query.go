@@ -0,0 +1,560 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// storageQuery selects, filters, sorts and aggregates the entries of a JSON
+// document, so skills can read the slice of a log they need instead of the
+// whole file.
+type storageQuery struct {
+	Selector  string
+	Where     []string
+	SortBy    string
+	Desc      bool
+	Head      int
+	Tail      int
+	Fields    []string
+	Aggregate []string
+	Field     string
+}
+
+func parseStorageQuery(args map[string]interface{}) (storageQuery, error) {
+	q := storageQuery{
+		Where:     stringList(args["where"]),
+		Fields:    stringList(args["fields"]),
+		Aggregate: stringList(args["aggregate"]),
+	}
+	q.Selector, _ = args["select"].(string)
+	q.SortBy, _ = args["sort_by"].(string)
+	q.Field, _ = args["field"].(string)
+	if order, _ := args["order"].(string); order == "desc" {
+		q.Desc = true
+	}
+	if n, ok := args["head"].(float64); ok {
+		q.Head = int(n)
+	}
+	if n, ok := args["tail"].(float64); ok {
+		q.Tail = int(n)
+	}
+	// A single where clause or aggregate passed as a string
+	if s, ok := args["where"].(string); ok && s != "" {
+		q.Where = []string{s}
+	}
+	if s, ok := args["aggregate"].(string); ok && s != "" {
+		q.Aggregate = []string{s}
+	}
+
+	for _, agg := range q.Aggregate {
+		switch agg {
+		case "count":
+		case "min", "max", "mean", "sum":
+			if q.Field == "" {
+				return q, fmt.Errorf("aggregate %q requires field", agg)
+			}
+		default:
+			return q, fmt.Errorf("unknown aggregate %q (use count, min, max, mean or sum)", agg)
+		}
+	}
+	return q, nil
+}
+
+// run applies the query to doc and returns the JSON-ready result.
+func (q storageQuery) run(doc interface{}) (map[string]interface{}, error) {
+	nodes, multi, err := evalPath(doc, q.Selector)
+	if err != nil {
+		return nil, err
+	}
+	items := nodes
+	if !multi && len(nodes) == 1 {
+		if list, ok := nodes[0].([]interface{}); ok {
+			items = list
+		}
+	}
+	total := len(items)
+
+	filters := make([]predicate, 0, len(q.Where))
+	for _, clause := range q.Where {
+		p, err := parsePredicate(clause)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, p)
+	}
+	if len(filters) > 0 {
+		kept := items[:0:0]
+		for _, item := range items {
+			match := true
+			for _, p := range filters {
+				if !p.matches(item) {
+					match = false
+					break
+				}
+			}
+			if match {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	matched := len(items)
+
+	if q.SortBy != "" {
+		sort.SliceStable(items, func(i, j int) bool {
+			c := compareValues(fieldValue(items[i], q.SortBy), fieldValue(items[j], q.SortBy))
+			if q.Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if q.Head > 0 && q.Head < len(items) {
+		items = items[:q.Head]
+	}
+	if q.Tail > 0 && q.Tail < len(items) {
+		items = items[len(items)-q.Tail:]
+	}
+
+	result := map[string]interface{}{
+		"total":   total,
+		"matched": matched,
+	}
+
+	if len(q.Aggregate) > 0 {
+		aggregates := make(map[string]interface{})
+		for _, agg := range q.Aggregate {
+			aggregates[agg] = aggregate(agg, items, q.Field)
+		}
+		result["aggregates"] = aggregates
+		if q.Field != "" {
+			result["field"] = q.Field
+		}
+		return result, nil
+	}
+
+	if len(q.Fields) > 0 {
+		projected := make([]interface{}, len(items))
+		for i, item := range items {
+			row := make(map[string]interface{}, len(q.Fields))
+			for _, field := range q.Fields {
+				row[field] = fieldValue(item, field)
+			}
+			projected[i] = row
+		}
+		items = projected
+	}
+	result["returned"] = len(items)
+	result["results"] = items
+	return result, nil
+}
+
+func aggregate(kind string, items []interface{}, field string) interface{} {
+	if kind == "count" {
+		if field == "" {
+			return len(items)
+		}
+		n := 0
+		for _, item := range items {
+			if fieldValue(item, field) != nil {
+				n++
+			}
+		}
+		return n
+	}
+
+	var values []float64
+	for _, item := range items {
+		if f, ok := toNumber(fieldValue(item, field)); ok {
+			values = append(values, f)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch kind {
+	case "min":
+		m := values[0]
+		for _, v := range values[1:] {
+			m = math.Min(m, v)
+		}
+		return m
+	case "max":
+		m := values[0]
+		for _, v := range values[1:] {
+			m = math.Max(m, v)
+		}
+		return m
+	default:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		if kind == "sum" {
+			return sum
+		}
+		return sum / float64(len(values))
+	}
+}
+
+// evalPath evaluates a JSONPath-style selector such as "$.scans[-5:]",
+// "patterns[*].name" or "$..price". multi reports whether the selector can
+// match several nodes (wildcards, slices, recursive descent), in which case
+// the nodes themselves are the entries.
+func evalPath(doc interface{}, selector string) (nodes []interface{}, multi bool, err error) {
+	steps, err := parsePath(selector)
+	if err != nil {
+		return nil, false, err
+	}
+	nodes = []interface{}{doc}
+	for _, step := range steps {
+		var next []interface{}
+		for _, node := range nodes {
+			next = append(next, step.apply(node)...)
+		}
+		nodes = next
+		if step.kind != stepKey && step.kind != stepIndex {
+			multi = true
+		}
+	}
+	return nodes, multi, nil
+}
+
+type stepKind int
+
+const (
+	stepKey stepKind = iota
+	stepIndex
+	stepWildcard
+	stepSlice
+	stepRecursive
+)
+
+type pathStep struct {
+	kind       stepKind
+	key        string
+	index      int
+	start, end *int
+}
+
+func (s pathStep) apply(node interface{}) []interface{} {
+	switch s.kind {
+	case stepKey:
+		if obj, ok := node.(map[string]interface{}); ok {
+			if v, ok := obj[s.key]; ok {
+				return []interface{}{v}
+			}
+		}
+	case stepIndex:
+		if list, ok := node.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []interface{}{list[i]}
+			}
+		}
+	case stepWildcard:
+		switch v := node.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]interface{}, len(keys))
+			for i, k := range keys {
+				out[i] = v[k]
+			}
+			return out
+		}
+	case stepSlice:
+		if list, ok := node.([]interface{}); ok {
+			start, end := 0, len(list)
+			if s.start != nil {
+				start = clampIndex(*s.start, len(list))
+			}
+			if s.end != nil {
+				end = clampIndex(*s.end, len(list))
+			}
+			if start < end {
+				return list[start:end]
+			}
+		}
+	case stepRecursive:
+		var out []interface{}
+		collectKey(node, s.key, &out)
+		return out
+	}
+	return nil
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return max(0, min(i, n))
+}
+
+func collectKey(node interface{}, key string, out *[]interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		if val, ok := v[key]; ok {
+			*out = append(*out, val)
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collectKey(v[k], key, out)
+		}
+	case []interface{}:
+		for _, item := range v {
+			collectKey(item, key, out)
+		}
+	}
+}
+
+func parsePath(selector string) ([]pathStep, error) {
+	s := strings.TrimSpace(selector)
+	s = strings.TrimPrefix(s, "$")
+	var steps []pathStep
+
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			s = s[2:]
+			name, rest := splitName(s)
+			if name == "" {
+				return nil, fmt.Errorf("invalid selector %q: expected a key after '..'", selector)
+			}
+			steps = append(steps, pathStep{kind: stepRecursive, key: name})
+			s = rest
+		case strings.HasPrefix(s, ".*"):
+			steps = append(steps, pathStep{kind: stepWildcard})
+			s = s[2:]
+		case s[0] == '.':
+			s = s[1:]
+		case s[0] == '[':
+			end := strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid selector %q: unclosed '['", selector)
+			}
+			step, err := parseBracket(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+			}
+			steps = append(steps, step)
+			s = s[end+1:]
+		default:
+			name, rest := splitName(s)
+			if name == "*" {
+				steps = append(steps, pathStep{kind: stepWildcard})
+			} else {
+				steps = append(steps, pathStep{kind: stepKey, key: name})
+			}
+			s = rest
+		}
+	}
+	return steps, nil
+}
+
+func splitName(s string) (string, string) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+func parseBracket(inner string) (pathStep, error) {
+	switch {
+	case inner == "*":
+		return pathStep{kind: stepWildcard}, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return pathStep{kind: stepKey, key: inner[1 : len(inner)-1]}, nil
+	case strings.Contains(inner, ":"):
+		parts := strings.SplitN(inner, ":", 2)
+		step := pathStep{kind: stepSlice}
+		for i, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return step, fmt.Errorf("bad slice bound %q", part)
+			}
+			if i == 0 {
+				step.start = &n
+			} else {
+				step.end = &n
+			}
+		}
+		return step, nil
+	default:
+		n, err := strconv.Atoi(inner)
+		if err != nil {
+			return pathStep{kind: stepKey, key: inner}, nil
+		}
+		return pathStep{kind: stepIndex, index: n}, nil
+	}
+}
+
+// fieldValue returns the value at a dotted field path inside item, such as
+// "score" or "prices.BTC", or nil.
+func fieldValue(item interface{}, field string) interface{} {
+	if field == "" || field == "$" {
+		return item
+	}
+	nodes, _, err := evalPath(item, field)
+	if err != nil || len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+type predicate struct {
+	field string
+	op    string
+	value interface{}
+}
+
+var predicateOps = []string{">=", "<=", "!=", "==", ">", "<", "=", " contains ", " startswith ", " exists", " missing"}
+
+// parsePredicate parses a where clause such as "score >= 7",
+// "asset == 'BTC'", "tags contains macro" or "note exists".
+func parsePredicate(clause string) (predicate, error) {
+	lower := strings.ToLower(clause)
+	for _, op := range predicateOps {
+		idx := strings.Index(lower, op)
+		if idx <= 0 {
+			continue
+		}
+		p := predicate{
+			field: strings.TrimSpace(clause[:idx]),
+			op:    strings.TrimSpace(op),
+		}
+		if p.op == "=" {
+			p.op = "=="
+		}
+		if p.op != "exists" && p.op != "missing" {
+			p.value = parseLiteral(strings.TrimSpace(clause[idx+len(op):]))
+		}
+		return p, nil
+	}
+	return predicate{}, fmt.Errorf("invalid where clause %q: expected '<field> <op> <value>' with op one of ==, !=, >, >=, <, <=, contains, startswith, exists, missing", clause)
+}
+
+func parseLiteral(s string) interface{} {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return s[1 : len(s)-1]
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	return s
+}
+
+func (p predicate) matches(item interface{}) bool {
+	v := fieldValue(item, p.field)
+	switch p.op {
+	case "exists":
+		return v != nil
+	case "missing":
+		return v == nil
+	case "contains":
+		switch actual := v.(type) {
+		case string:
+			return strings.Contains(strings.ToLower(actual), strings.ToLower(fmt.Sprint(p.value)))
+		case []interface{}:
+			for _, elem := range actual {
+				if compareValues(elem, p.value) == 0 {
+					return true
+				}
+			}
+		}
+		return false
+	case "startswith":
+		s, ok := v.(string)
+		return ok && strings.HasPrefix(strings.ToLower(s), strings.ToLower(fmt.Sprint(p.value)))
+	}
+
+	if v == nil {
+		return p.op == "!=" && p.value != nil
+	}
+	c := compareValues(v, p.value)
+	switch p.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// compareValues orders numbers numerically (including numeric strings),
+// strings lexically (so ISO dates sort in time order) and nil first.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if fa, ok := toNumber(a); ok {
+		if fb, ok := toNumber(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb)
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testScanLog = `[
+  {"date": "2025-01-13", "symbol": "BTC", "change_pct": 1.2, "prices": {"usd": 94000}},
+  {"date": "2025-01-14", "symbol": "ETH", "change_pct": 4.5, "prices": {"usd": 3300}, "tags": ["breakout"]},
+  {"date": "2025-01-15", "symbol": "BTC", "change_pct": -2.1, "prices": {"usd": 92000}},
+  {"date": "2025-01-16", "symbol": "SOL", "change_pct": 7.8, "prices": {"usd": 210}, "tags": ["breakout", "volume"]},
+  {"date": "2025-01-17", "symbol": "BTC", "change_pct": 3.4, "prices": {"usd": 99000}}
+]`
+
+func queryStorage(t *testing.T, args map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "data", "scans", "daily_log.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"updated": "2025-01-17", "scans": `+testScanLog+`}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args["action"] = "query"
+	args["path"] = "scans/daily_log.json"
+	out, err := NewStorageTool(workspace).Execute(context.Background(), args)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("query output is not JSON: %v\n%s", err, out)
+	}
+	return result
+}
+
+func TestStorageQueryFilterSortHead(t *testing.T) {
+	result := queryStorage(t, map[string]interface{}{
+		"select":  "$.scans",
+		"where":   []interface{}{"change_pct > 3"},
+		"sort_by": "change_pct",
+		"order":   "desc",
+		"head":    float64(2),
+		"fields":  []interface{}{"symbol", "prices.usd"},
+	})
+
+	if result["total"] != float64(5) || result["matched"] != float64(3) || result["returned"] != float64(2) {
+		t.Fatalf("unexpected counts: %v", result)
+	}
+	rows := result["results"].([]interface{})
+	first := rows[0].(map[string]interface{})
+	if first["symbol"] != "SOL" || first["prices.usd"] != float64(210) {
+		t.Errorf("first row = %v, want SOL at 210", first)
+	}
+	if rows[1].(map[string]interface{})["symbol"] != "ETH" {
+		t.Errorf("second row = %v, want ETH", rows[1])
+	}
+}
+
+func TestStorageQuerySelectorsAndPredicates(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want []string
+	}{
+		{"slice", map[string]interface{}{"select": "scans[-2:]"}, []string{"SOL", "BTC"}},
+		{"tail", map[string]interface{}{"select": "$.scans[*]", "tail": float64(1)}, []string{"BTC"}},
+		{"string equality", map[string]interface{}{"select": "scans", "where": "symbol == 'ETH'"}, []string{"ETH"}},
+		{"contains", map[string]interface{}{"select": "scans", "where": []interface{}{"tags contains volume"}}, []string{"SOL"}},
+		{"date range", map[string]interface{}{"select": "scans", "where": []interface{}{"date >= 2025-01-15", "symbol != SOL"}}, []string{"BTC", "BTC"}},
+		{"missing", map[string]interface{}{"select": "scans", "where": []interface{}{"tags missing", "prices.usd < 95000"}}, []string{"BTC", "BTC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := queryStorage(t, tt.args)
+			rows, _ := result["results"].([]interface{})
+			if len(rows) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d: %v", len(rows), len(tt.want), result)
+			}
+			for i, row := range rows {
+				if got := row.(map[string]interface{})["symbol"]; got != tt.want[i] {
+					t.Errorf("row %d symbol = %v, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStorageQueryAggregates(t *testing.T) {
+	result := queryStorage(t, map[string]interface{}{
+		"select":    "scans",
+		"where":     []interface{}{"symbol == BTC"},
+		"aggregate": []interface{}{"count", "min", "max", "mean"},
+		"field":     "prices.usd",
+	})
+
+	aggregates := result["aggregates"].(map[string]interface{})
+	want := map[string]float64{"count": 3, "min": 92000, "max": 99000, "mean": 95000}
+	for k, v := range want {
+		if aggregates[k] != v {
+			t.Errorf("%s = %v, want %v", k, aggregates[k], v)
+		}
+	}
+	if _, ok := result["results"]; ok {
+		t.Error("aggregate queries should not return entries")
+	}
+}
+
+func TestStorageQueryRecursiveDescent(t *testing.T) {
+	result := queryStorage(t, map[string]interface{}{
+		"select":    "$..usd",
+		"aggregate": "max",
+		"field":     "$",
+	})
+	if got := result["aggregates"].(map[string]interface{})["max"]; got != float64(99000) {
+		t.Errorf("max = %v, want 99000", got)
+	}
+}
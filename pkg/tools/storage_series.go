@@ -0,0 +1,193 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/timeseries"
+)
+
+// seriesFor maps a storage path to a time-series. "x.jsonl" always names the
+// series stored in the x/ directory; "x.json" does once it has been migrated.
+func (t *StorageTool) seriesFor(path string) (series *timeseries.Series, ok bool) {
+	var stem string
+	switch filepath.Ext(path) {
+	case timeseries.SegmentExt:
+		stem = strings.TrimSuffix(path, timeseries.SegmentExt)
+	case ".json":
+		stem = strings.TrimSuffix(path, ".json")
+	default:
+		return nil, false
+	}
+
+	dir, err := t.resolvePath(stem)
+	if err != nil {
+		return nil, false
+	}
+	if filepath.Ext(path) == ".json" && !timeseries.Exists(dir) {
+		return nil, false
+	}
+	return timeseries.Open(dir), true
+}
+
+// adoptLegacy migrates the logs a series replaces, the x.json array log and
+// the flat x.jsonl file of plain appends, into it the first time the series
+// is used. It returns a note on what it migrated, or "".
+func (t *StorageTool) adoptLegacy(series *timeseries.Series) (string, error) {
+	var notes []string
+	for _, ext := range []string{".json", timeseries.SegmentExt} {
+		legacy := series.Dir() + ext
+		if info, err := os.Stat(legacy); err != nil || info.IsDir() {
+			continue
+		}
+		n, err := series.Migrate(legacy)
+		if err != nil {
+			return "", fmt.Errorf("migrating %s: %w", filepath.Base(legacy), err)
+		}
+		notes = append(notes, fmt.Sprintf("migrated %d entries from %s", n, filepath.Base(legacy)))
+	}
+	return strings.Join(notes, "; "), nil
+}
+
+func (t *StorageTool) appendSeries(series *timeseries.Series, data, relPath string) (string, error) {
+	migrated, err := t.adoptLegacy(series)
+	if err != nil {
+		return fmt.Sprintf("Error %v", err), nil
+	}
+
+	ts, err := series.Append([]byte(data))
+	if err != nil {
+		return fmt.Sprintf("Error appending: %v", err), nil
+	}
+
+	result := fmt.Sprintf("Appended entry to %s (segment %s)", relPath, ts.UTC().Format("2006-01-02")+timeseries.SegmentExt)
+	if migrated != "" {
+		result += "; " + migrated
+	}
+	return result, nil
+}
+
+// writeSeries replaces the series with data, a JSON array or JSON Lines.
+func (t *StorageTool) writeSeries(series *timeseries.Series, data, relPath string) (string, error) {
+	if _, err := t.adoptLegacy(series); err != nil {
+		return fmt.Sprintf("Error %v", err), nil
+	}
+	n, err := series.Replace([]byte(data))
+	if err != nil {
+		return fmt.Sprintf("Error writing series: %v", err), nil
+	}
+	return fmt.Sprintf("Written %d entries to series %s", n, relPath), nil
+}
+
+// checkSeriesContract validates the entries of data written to a series:
+// each line of JSON Lines, or the whole document otherwise.
+func (t *StorageTool) checkSeriesContract(path, data string) string {
+	if json.Valid([]byte(data)) {
+		return t.checkContract(path, data, false)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if msg := t.checkContract(path, line, true); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// rangeSeries loads the series entries within the optional from/to args.
+func rangeSeries(series *timeseries.Series, args map[string]interface{}) ([]interface{}, error) {
+	from, err := timeArg(args, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := timeArg(args, "to")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := series.Range(from, to)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		var doc interface{}
+		if err := json.Unmarshal(e.Data, &doc); err == nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func timeArg(args map[string]interface{}, key string) (time.Time, error) {
+	v, _ := args[key].(string)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	ts, ok := timeseries.ParseTime(v)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s must be a date (YYYY-MM-DD) or RFC 3339 time, got %q", key, v)
+	}
+	return ts, nil
+}
+
+func (t *StorageTool) compactSeries(args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return "Error: path is required for compact", nil
+	}
+	series, ok := t.seriesFor(path)
+	if ok {
+		if _, err := t.adoptLegacy(series); err != nil {
+			return fmt.Sprintf("Error %v", err), nil
+		}
+	}
+	if !ok || !timeseries.Exists(series.Dir()) {
+		return fmt.Sprintf("Error: %s is not a time-series (use a .jsonl path)", path), nil
+	}
+
+	now := time.Now().UTC()
+	report, err := series.Compact(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return fmt.Sprintf("Error compacting %s: %v", path, err), nil
+	}
+	if len(report.Months) == 0 {
+		return fmt.Sprintf("Nothing to compact in %s: only the current month has daily segments", path), nil
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	return string(out), nil
+}
+
+func (t *StorageTool) migrateSeries(args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return "Error: path is required for migrate", nil
+	}
+	stem := strings.TrimSuffix(strings.TrimSuffix(path, ".json"), timeseries.SegmentExt)
+
+	legacy, err := t.resolvePath(stem + ".json")
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	dir, err := t.resolvePath(stem)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+	if _, err := os.Stat(legacy); os.IsNotExist(err) {
+		return fmt.Sprintf("File not found: %s.json", stem), nil
+	}
+
+	n, err := timeseries.Open(dir).Migrate(legacy)
+	if err != nil {
+		return fmt.Sprintf("Error migrating %s.json: %v", stem, err), nil
+	}
+	return fmt.Sprintf("Migrated %d entries from %s.json to series %s%s (original kept as %s.json.migrated). Appends to either path now go to the series.",
+		n, stem, stem, timeseries.SegmentExt, stem), nil
+}
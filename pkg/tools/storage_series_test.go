@@ -0,0 +1,120 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorageSeriesMigratesOnFirstAppend(t *testing.T) {
+	workspace := t.TempDir()
+	legacy := filepath.Join(workspace, "data", "scans", "daily_log.json")
+	os.MkdirAll(filepath.Dir(legacy), 0755)
+	os.WriteFile(legacy, []byte(`[{"timestamp": "2025-01-14T10:00:00Z", "btc": 94000}]`), 0644)
+
+	tool := NewStorageTool(workspace)
+	ctx := context.Background()
+	out, _ := tool.Execute(ctx, map[string]interface{}{
+		"action": "append",
+		"path":   "scans/daily_log.jsonl",
+		"data":   `{"timestamp": "2025-01-15T10:00:00Z", "btc": 96000}`,
+	})
+	if !strings.Contains(out, "segment 2025-01-15.jsonl") || !strings.Contains(out, "migrated 1 entries") {
+		t.Fatalf("append result: %s", out)
+	}
+
+	// The old name keeps working and resolves to the series
+	tool.Execute(ctx, map[string]interface{}{
+		"action": "append",
+		"path":   "scans/daily_log.json",
+		"data":   `{"timestamp": "2025-01-16T10:00:00Z", "btc": 97000}`,
+	})
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy array file was recreated: %v", err)
+	}
+
+	out, _ = tool.Execute(ctx, map[string]interface{}{
+		"action":    "query",
+		"path":      "scans/daily_log.jsonl",
+		"from":      "2025-01-15",
+		"aggregate": []interface{}{"count", "max"},
+		"field":     "btc",
+	})
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("query output: %s", out)
+	}
+	aggregates := result["aggregates"].(map[string]interface{})
+	if aggregates["count"] != float64(2) || aggregates["max"] != float64(97000) {
+		t.Errorf("aggregates = %v", aggregates)
+	}
+
+	out, _ = tool.Execute(ctx, map[string]interface{}{"action": "list", "path": "scans"})
+	if !strings.Contains(out, `"series"`) {
+		t.Errorf("list does not show the series: %s", out)
+	}
+}
+
+func TestStorageSeriesWriteReadDelete(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewStorageTool(workspace)
+	ctx := context.Background()
+	run := func(args map[string]interface{}) string {
+		t.Helper()
+		out, err := tool.Execute(ctx, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	out := run(map[string]interface{}{
+		"action": "write",
+		"path":   "scans/log.jsonl",
+		"data":   "{\"timestamp\": \"2025-01-14T10:00:00Z\", \"btc\": 94000}\n{\"timestamp\": \"2025-01-15T10:00:00Z\", \"btc\": 96000}\n",
+	})
+	if !strings.Contains(out, "Written 2 entries") {
+		t.Fatalf("write result: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "data", "scans", "log.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("write created a flat file beside the series: %v", err)
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(run(map[string]interface{}{"action": "read", "path": "scans/log.jsonl"})), &entries); err != nil || len(entries) != 2 {
+		t.Fatalf("read after write: %v %v", entries, err)
+	}
+
+	if out := run(map[string]interface{}{"action": "delete", "path": "scans/log.jsonl"}); !strings.Contains(out, "Deleted series") {
+		t.Fatalf("delete result: %s", out)
+	}
+	if out := run(map[string]interface{}{"action": "read", "path": "scans/log.jsonl"}); out != "[]" {
+		t.Errorf("read after delete: %s", out)
+	}
+	if out := run(map[string]interface{}{"action": "delete", "path": "scans/log.jsonl"}); !strings.Contains(out, "File not found") {
+		t.Errorf("second delete: %s", out)
+	}
+}
+
+func TestStorageSeriesAdoptsFlatLog(t *testing.T) {
+	workspace := t.TempDir()
+	flat := filepath.Join(workspace, "data", "alerts", "fired.jsonl")
+	os.MkdirAll(filepath.Dir(flat), 0755)
+	os.WriteFile(flat, []byte("[2025-01-14T10:00:00Z] {\"symbol\": \"BTCUSDT\"}\n[2025-01-15T11:00:00Z] {\"symbol\": \"ETHUSDT\"}\n"), 0644)
+
+	tool := NewStorageTool(workspace)
+	out, _ := tool.Execute(context.Background(), map[string]interface{}{
+		"action": "query",
+		"path":   "alerts/fired.jsonl",
+		"where":  []interface{}{"symbol == 'ETHUSDT'"},
+	})
+	if !strings.Contains(out, `"timestamp": "2025-01-15T11:00:00Z"`) {
+		t.Fatalf("the flat log's entries should be queryable: %s", out)
+	}
+	if _, err := os.Stat(flat + ".migrated"); err != nil {
+		t.Errorf("flat log not migrated: %v", err)
+	}
+}
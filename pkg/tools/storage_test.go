@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileio"
+)
+
+func TestStorageWriteWaitsForLock(t *testing.T) {
+	workspace := t.TempDir()
+	path := filepath.Join(workspace, "data", "regime", "current_regime.json")
+	tool := NewStorageTool(workspace)
+
+	// Another writer (an append or a versioned write) is mid-update
+	unlock, err := fileio.Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan string)
+	go func() {
+		out, _ := tool.Execute(context.Background(), map[string]interface{}{
+			"action": "write",
+			"path":   "regime/current_regime.json",
+			"data":   `{"assets": {}}`,
+		})
+		done <- out
+	}()
+
+	select {
+	case out := <-done:
+		t.Fatalf("write went through while the file was locked: %s", out)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+
+	if out := <-done; !strings.Contains(out, "Written") {
+		t.Fatalf("write result: %s", out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"assets": {}}` {
+		t.Errorf("content = %s", data)
+	}
+}
+
+func TestStructuredSaveToSeries(t *testing.T) {
+	workspace := t.TempDir()
+	tool := NewStructuredOutputTool(nil, "", workspace)
+	out, err := tool.Execute(context.Background(), map[string]interface{}{
+		"schema":  `{"type": "object", "required": ["score"], "properties": {"score": {"type": "integer"}}}`,
+		"data":    `{"timestamp": "2025-01-15T10:00:00Z", "score": 8}`,
+		"save_to": "scores/log.jsonl",
+	})
+	if err != nil || !strings.Contains(out, "written to scores/log.jsonl") {
+		t.Fatalf("save_to result: %s (%v)", out, err)
+	}
+
+	read, _ := NewStorageTool(workspace).Execute(context.Background(), map[string]interface{}{
+		"action": "read",
+		"path":   "scores/log.jsonl",
+	})
+	if !strings.Contains(read, `"score": 8`) {
+		t.Errorf("saved document should be an entry of the series: %s", read)
+	}
+}
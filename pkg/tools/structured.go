@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/jsonschema"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// StructuredOutputTool produces JSON documents that are guaranteed to match a
+// JSON Schema, so skills can hand well-formed files to each other.
+// It either validates a document the agent composed itself, returning the
+// validation errors so the agent can fix them, or generates one with a
+// separate schema-constrained model call that repairs its own mistakes.
+type StructuredOutputTool struct {
+	provider   providers.LLMProvider
+	model      string
+	workspace  string
+	storage    *StorageTool
+	maxRepairs int
+}
+
+func NewStructuredOutputTool(provider providers.LLMProvider, model, workspace string) *StructuredOutputTool {
+	return &StructuredOutputTool{
+		provider:   provider,
+		model:      model,
+		workspace:  workspace,
+		storage:    NewStorageTool(workspace),
+		maxRepairs: 2,
+	}
+}
+
+func (t *StructuredOutputTool) Name() string {
+	return "structured_output"
+}
+
+func (t *StructuredOutputTool) Description() string {
+	return `Produce a JSON document that matches a JSON Schema and optionally save it to the data directory.
+Modes:
+- Validate: pass "data" (the JSON you composed). Invalid data is rejected with a list of errors to fix; call again with the corrected data.
+- Generate: pass "instructions" (and optional "input" material) to have the model write the document under the schema.
+Give the schema either inline ("schema") or as a file path relative to the workspace ("schema_path", e.g. "skills/opportunity_scorer/output.schema.json").
+"save_to" is relative to the data/ directory, like the storage tool. Use this instead of storage "write" whenever a skill defines an output schema.`
+}
+
+func (t *StructuredOutputTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"schema_path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path of a JSON Schema file relative to the workspace",
+			},
+			"schema": map[string]interface{}{
+				"type":        "string",
+				"description": "Inline JSON Schema (used when schema_path is not given)",
+			},
+			"data": map[string]interface{}{
+				"type":        "string",
+				"description": "JSON document to validate (validate mode)",
+			},
+			"instructions": map[string]interface{}{
+				"type":        "string",
+				"description": "What the document should contain (generate mode)",
+			},
+			"input": map[string]interface{}{
+				"type":        "string",
+				"description": "Source material for generate mode, e.g. tool results to transform",
+			},
+			"save_to": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional file path relative to data/ to write the validated document to",
+			},
+		},
+	}
+}
+
+func (t *StructuredOutputTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	schema, err := t.loadSchema(args)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), nil
+	}
+
+	var doc json.RawMessage
+	switch {
+	case args["data"] != nil:
+		data, ok := args["data"].(string)
+		if !ok {
+			// Some models pass the document as an object rather than a string
+			raw, _ := json.Marshal(args["data"])
+			data = string(raw)
+		}
+		doc, err = providers.CheckStructured(data, schema)
+		if err != nil {
+			return fmt.Sprintf("Error: %v\nFix these problems and call structured_output again.", err), nil
+		}
+
+	case args["instructions"] != nil:
+		instructions, _ := args["instructions"].(string)
+		input, _ := args["input"].(string)
+		doc, err = t.generate(ctx, schema, instructions, input)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err), nil
+		}
+
+	default:
+		return "Error: either data or instructions is required", nil
+	}
+
+	var pretty interface{}
+	json.Unmarshal(doc, &pretty)
+	output, _ := json.MarshalIndent(pretty, "", "  ")
+
+	saveTo, _ := args["save_to"].(string)
+	if saveTo == "" {
+		return string(output), nil
+	}
+
+	// Saved like a storage write: under the file's lock, into the series for
+	// .jsonl paths, and checked against the destination's own contract
+	result, err := t.storage.writeData(map[string]interface{}{
+		"path": saveTo,
+		"data": string(output),
+	})
+	if err != nil || !strings.HasPrefix(result, "Written") {
+		return result, err
+	}
+
+	return fmt.Sprintf("Valid JSON (%d bytes) written to %s", len(output), saveTo), nil
+}
+
+func (t *StructuredOutputTool) loadSchema(args map[string]interface{}) (*jsonschema.Schema, error) {
+	if schemaPath, _ := args["schema_path"].(string); schemaPath != "" {
+		clean := filepath.Clean(schemaPath)
+		if filepath.IsAbs(clean) || strings.Contains(clean, "..") {
+			return nil, fmt.Errorf("schema_path must be relative to the workspace")
+		}
+		schema, err := jsonschema.Load(filepath.Join(t.workspace, clean))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load schema %s: %v", schemaPath, err)
+		}
+		return schema, nil
+	}
+
+	if inline, _ := args["schema"].(string); inline != "" {
+		return jsonschema.Parse([]byte(inline))
+	}
+
+	return nil, fmt.Errorf("schema_path or schema is required")
+}
+
+func (t *StructuredOutputTool) generate(ctx context.Context, schema *jsonschema.Schema, instructions, input string) (json.RawMessage, error) {
+	schemaJSON, _ := json.MarshalIndent(schema.Map(), "", "  ")
+
+	prompt := instructions
+	if input != "" {
+		prompt += "\n\n## Input\n" + input
+	}
+
+	messages := []providers.Message{
+		{
+			Role:    "system",
+			Content: "You write JSON documents. Reply with only a JSON document matching this JSON Schema, with no commentary or code fences:\n" + string(schemaJSON),
+		},
+		{
+			Role:    "user",
+			Content: prompt,
+		},
+	}
+
+	return providers.ChatJSON(ctx, t.provider, messages, t.model, "structured_output", schema, map[string]interface{}{
+		"max_tokens":  4096,
+		"temperature": 0.2,
+	}, t.maxRepairs)
+}
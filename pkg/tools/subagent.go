@@ -2,78 +2,312 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileio"
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// Subagent task states.
+const (
+	SubagentRunning     = "running"
+	SubagentCompleted   = "completed"
+	SubagentFailed      = "failed"
+	SubagentCancelled   = "cancelled"
+	SubagentInterrupted = "interrupted" // the process stopped before the task finished
+)
+
+// maxStoredSubagentTasks bounds how many finished tasks are kept on disk.
+const maxStoredSubagentTasks = 100
+
+// ErrSubagentLimit is returned when an origin already has the maximum number
+// of subagents running.
+var ErrSubagentLimit = errors.New("subagent limit reached")
+
 type SubagentTask struct {
-	ID            string
-	Task          string
+	ID            string   `json:"id"`
+	Task          string   `json:"task"`
+	Label         string   `json:"label,omitempty"`
+	OriginChannel string   `json:"origin_channel"`
+	OriginChatID  string   `json:"origin_chat_id"`
+	Model         string   `json:"model,omitempty"`
+	Tools         []string `json:"tools,omitempty"`
+	MaxIterations int      `json:"max_iterations"`
+	Iterations    int      `json:"iterations"`
+	Status        string   `json:"status"`
+	Result        string   `json:"result,omitempty"`
+	Created       int64    `json:"created"`
+	Finished      int64    `json:"finished,omitempty"`
+}
+
+// SpawnOptions narrows what a single subagent may do. Zero values fall back
+// to the configured defaults.
+type SpawnOptions struct {
 	Label         string
-	OriginChannel string
-	OriginChatID  string
-	Status        string
-	Result        string
-	Created       int64
+	Tools         []string
+	Model         string
+	MaxIterations int
+	Silent        bool // Don't announce the result on the bus; the caller waits for it
+}
+
+// SubagentToolExecutor runs a tool call on behalf of a subagent. The agent
+// loop supplies one that applies the same approval rules as the main agent.
+type SubagentToolExecutor func(ctx context.Context, tc providers.ToolCall, channel, chatID string) (string, error)
+
+type subagentRun struct {
+	cancel    context.CancelFunc
+	cancelled bool
+	done      chan struct{}
 }
 
 type SubagentManager struct {
 	tasks     map[string]*SubagentTask
+	running   map[string]*subagentRun
 	mu        sync.RWMutex
 	provider  providers.LLMProvider
 	bus       *bus.MessageBus
 	workspace string
+	model     string
+	config    config.SubagentsConfig
+	tools     *ToolRegistry
+	executor  SubagentToolExecutor
+	storePath string
 	nextID    int
 }
 
-func NewSubagentManager(provider providers.LLMProvider, workspace string, bus *bus.MessageBus) *SubagentManager {
-	return &SubagentManager{
+// NewSubagentManager creates a manager and restores task state from
+// workspace/subagents/tasks.json. Tasks that were still running when the
+// process stopped are marked interrupted.
+func NewSubagentManager(provider providers.LLMProvider, model string, cfg config.SubagentsConfig, workspace string, bus *bus.MessageBus) *SubagentManager {
+	sm := &SubagentManager{
 		tasks:     make(map[string]*SubagentTask),
+		running:   make(map[string]*subagentRun),
 		provider:  provider,
 		bus:       bus,
 		workspace: workspace,
+		model:     model,
+		config:    cfg,
+		storePath: filepath.Join(workspace, "subagents", "tasks.json"),
 		nextID:    1,
 	}
+	sm.load()
+	return sm
+}
+
+// SetTools sets the registry subagent tools are taken from. Only the tools
+// named in the subagent config are ever offered.
+func (sm *SubagentManager) SetTools(registry *ToolRegistry) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.tools = registry
+}
+
+// SetToolExecutor overrides how subagent tool calls are executed.
+func (sm *SubagentManager) SetToolExecutor(executor SubagentToolExecutor) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.executor = executor
 }
 
 func (sm *SubagentManager) Spawn(ctx context.Context, task, label, originChannel, originChatID string) (string, error) {
+	spawned, err := sm.SpawnWithOptions(ctx, task, SpawnOptions{Label: label}, originChannel, originChatID)
+	if err != nil {
+		return "", err
+	}
+
+	if label != "" {
+		return fmt.Sprintf("Spawned subagent '%s' (%s) for task: %s", label, spawned.ID, task), nil
+	}
+	return fmt.Sprintf("Spawned subagent %s for task: %s", spawned.ID, task), nil
+}
+
+// SpawnWithOptions starts a subagent in the background and returns a
+// snapshot of its task.
+func (sm *SubagentManager) SpawnWithOptions(ctx context.Context, task string, opts SpawnOptions, originChannel, originChatID string) (*SubagentTask, error) {
+	toolNames, err := sm.allowedTools(opts.Tools)
+	if err != nil {
+		return nil, err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	taskID := fmt.Sprintf("subagent-%d", sm.nextID)
-	sm.nextID++
+	if limit := sm.config.MaxConcurrentPerOrigin; limit > 0 {
+		active := 0
+		for id := range sm.running {
+			if t := sm.tasks[id]; t.OriginChannel == originChannel && t.OriginChatID == originChatID {
+				active++
+			}
+		}
+		if active >= limit {
+			return nil, fmt.Errorf("%w: %d subagents are already running for this chat (limit %d); wait for one to finish or cancel one", ErrSubagentLimit, active, limit)
+		}
+	}
+
+	model := opts.Model
+	if model == "" {
+		model = sm.config.Model
+	}
+	if model == "" {
+		model = sm.model
+	}
+
+	maxIterations := sm.config.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = 10
+	}
+	if opts.MaxIterations > 0 && opts.MaxIterations < maxIterations {
+		maxIterations = opts.MaxIterations
+	}
 
 	subagentTask := &SubagentTask{
-		ID:            taskID,
+		ID:            fmt.Sprintf("subagent-%d", sm.nextID),
 		Task:          task,
-		Label:         label,
+		Label:         opts.Label,
 		OriginChannel: originChannel,
 		OriginChatID:  originChatID,
-		Status:        "running",
+		Model:         model,
+		Tools:         toolNames,
+		MaxIterations: maxIterations,
+		Status:        SubagentRunning,
 		Created:       time.Now().UnixMilli(),
 	}
-	sm.tasks[taskID] = subagentTask
+	sm.nextID++
+	sm.tasks[subagentTask.ID] = subagentTask
 
-	go sm.runTask(ctx, subagentTask)
+	// Subagents outlive the turn that spawned them
+	var runCtx context.Context
+	var cancel context.CancelFunc
+	if sm.config.TimeoutSeconds > 0 {
+		runCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), time.Duration(sm.config.TimeoutSeconds)*time.Second)
+	} else {
+		runCtx, cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	run := &subagentRun{cancel: cancel, done: make(chan struct{})}
+	sm.running[subagentTask.ID] = run
+	sm.saveLocked()
 
-	if label != "" {
-		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
+	logger.InfoCF("subagent", "Subagent spawned",
+		map[string]interface{}{
+			"id":    subagentTask.ID,
+			"model": model,
+			"tools": toolNames,
+		})
+
+	go sm.runTask(runCtx, subagentTask, run, opts.Silent)
+
+	snapshot := *subagentTask
+	return &snapshot, nil
+}
+
+// allowedTools validates a requested tool subset against the configured one.
+func (sm *SubagentManager) allowedTools(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return append([]string(nil), sm.config.Tools...), nil
+	}
+
+	allowed := make(map[string]bool, len(sm.config.Tools))
+	for _, name := range sm.config.Tools {
+		allowed[name] = true
+	}
+	for _, name := range requested {
+		if !allowed[name] {
+			return nil, fmt.Errorf("tool %q is not available to subagents (allowed: %s)", name, strings.Join(sm.config.Tools, ", "))
+		}
+	}
+	return requested, nil
+}
+
+func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, run *subagentRun, silent bool) {
+	defer close(run.done)
+	defer run.cancel()
+
+	result, iterations, err := sm.runLoop(ctx, task)
+
+	sm.mu.Lock()
+	task.Iterations = iterations
+	task.Finished = time.Now().UnixMilli()
+	switch {
+	case run.cancelled:
+		task.Status = SubagentCancelled
+		task.Result = "Cancelled before completion."
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		task.Status = SubagentFailed
+		task.Result = fmt.Sprintf("Error: timed out after %ds", sm.config.TimeoutSeconds)
+	case err != nil:
+		task.Status = SubagentFailed
+		task.Result = fmt.Sprintf("Error: %v", err)
+	default:
+		task.Status = SubagentCompleted
+		task.Result = result
+	}
+	delete(sm.running, task.ID)
+	sm.saveLocked()
+	snapshot := *task
+	sm.mu.Unlock()
+
+	logger.InfoCF("subagent", "Subagent finished",
+		map[string]interface{}{
+			"id":         snapshot.ID,
+			"status":     snapshot.Status,
+			"iterations": snapshot.Iterations,
+		})
+
+	// Send announce message back to main agent
+	if sm.bus != nil && !silent && snapshot.Status != SubagentCancelled {
+		announceContent := fmt.Sprintf("Task '%s' (%s) %s.\n\nResult:\n%s", snapshot.Label, snapshot.ID, snapshot.Status, snapshot.Result)
+		sm.bus.PublishInbound(bus.InboundMessage{
+			Channel:  "system",
+			SenderID: fmt.Sprintf("subagent:%s", snapshot.ID),
+			// Format: "original_channel:original_chat_id" for routing back
+			ChatID:  fmt.Sprintf("%s:%s", snapshot.OriginChannel, snapshot.OriginChatID),
+			Content: announceContent,
+		})
 	}
-	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask) {
-	task.Status = "running"
-	task.Created = time.Now().UnixMilli()
+// runLoop is a bounded agent loop restricted to the task's tools. It returns
+// the final report and the number of iterations used.
+func (sm *SubagentManager) runLoop(ctx context.Context, task *SubagentTask) (string, int, error) {
+	sm.mu.RLock()
+	registry := sm.tools
+	executor := sm.executor
+	sm.mu.RUnlock()
+
+	toolDefs := []providers.ToolDefinition{}
+	allowed := make(map[string]bool, len(task.Tools))
+	if registry != nil {
+		subset := registry.Subset(task.Tools)
+		toolDefs = subset.ProviderDefinitions()
+		for _, def := range toolDefs {
+			allowed[def.Function.Name] = true
+		}
+	}
+	if executor == nil && registry != nil {
+		executor = func(ctx context.Context, tc providers.ToolCall, channel, chatID string) (string, error) {
+			return registry.ExecuteWithContext(ctx, tc.Name, tc.Arguments, channel, chatID)
+		}
+	}
 
 	messages := []providers.Message{
 		{
-			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and report the result.",
+			Role: "system",
+			Content: fmt.Sprintf(`You are a subagent working on a single task for the main agent.
+Use the available tools to gather what you need, then reply with a concise, self-contained report of your findings; it is handed back to the main agent verbatim.
+Your workspace is %s. Data files live under its data/ directory.
+Current time: %s`, sm.workspace, time.Now().Format("2006-01-02 15:04 (Monday)")),
 		},
 		{
 			Role:    "user",
@@ -81,48 +315,210 @@ func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask) {
 		},
 	}
 
-	response, err := sm.provider.Chat(ctx, messages, nil, sm.provider.GetDefaultModel(), map[string]interface{}{
-		"max_tokens": 4096,
+	options := map[string]interface{}{
+		"max_tokens": sm.maxTokens(),
+	}
+
+	iteration := 0
+	for iteration < task.MaxIterations {
+		iteration++
+
+		response, err := sm.provider.Chat(ctx, messages, toolDefs, task.Model, options)
+		if err != nil {
+			return "", iteration, err
+		}
+
+		if len(response.ToolCalls) == 0 {
+			return response.Content, iteration, nil
+		}
+
+		assistantMsg := providers.Message{Role: "assistant", Content: response.Content}
+		for _, tc := range response.ToolCalls {
+			argumentsJSON, _ := json.Marshal(tc.Arguments)
+			assistantMsg.ToolCalls = append(assistantMsg.ToolCalls, providers.ToolCall{
+				ID:   tc.ID,
+				Type: "function",
+				Function: &providers.FunctionCall{
+					Name:      tc.Name,
+					Arguments: string(argumentsJSON),
+				},
+			})
+		}
+		messages = append(messages, assistantMsg)
+
+		for _, tc := range response.ToolCalls {
+			var result string
+			if !allowed[tc.Name] {
+				result = fmt.Sprintf("Error: tool '%s' is not available to this subagent", tc.Name)
+			} else if result, err = executor(ctx, tc, task.OriginChannel, task.OriginChatID); err != nil {
+				result = fmt.Sprintf("Error: %v", err)
+			}
+			messages = append(messages, providers.Message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: tc.ID,
+			})
+		}
+
+		sm.mu.Lock()
+		task.Iterations = iteration
+		sm.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return "", iteration, ctx.Err()
+		}
+	}
+
+	// Out of iterations: ask for a report of what was found so far
+	messages = append(messages, providers.Message{
+		Role:    "user",
+		Content: fmt.Sprintf("You have used all %d tool iterations. Without calling any more tools, report what you found so far and what is still missing.", task.MaxIterations),
 	})
+	response, err := sm.provider.Chat(ctx, messages, nil, task.Model, options)
+	if err != nil {
+		return "", iteration, err
+	}
+	return response.Content, iteration, nil
+}
+
+func (sm *SubagentManager) maxTokens() int {
+	if sm.config.MaxTokens > 0 {
+		return sm.config.MaxTokens
+	}
+	return 4096
+}
 
+// Cancel stops a running subagent.
+func (sm *SubagentManager) Cancel(taskID string) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if err != nil {
-		task.Status = "failed"
-		task.Result = fmt.Sprintf("Error: %v", err)
-	} else {
-		task.Status = "completed"
-		task.Result = response.Content
+	task, ok := sm.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("subagent %s not found", taskID)
 	}
+	run, ok := sm.running[taskID]
+	if !ok {
+		return fmt.Errorf("subagent %s is not running (status: %s)", taskID, task.Status)
+	}
+	run.cancelled = true
+	run.cancel()
+	return nil
+}
 
-	// Send announce message back to main agent
-	if sm.bus != nil {
-		announceContent := fmt.Sprintf("Task '%s' completed.\n\nResult:\n%s", task.Label, task.Result)
-		sm.bus.PublishInbound(bus.InboundMessage{
-			Channel:  "system",
-			SenderID: fmt.Sprintf("subagent:%s", task.ID),
-			// Format: "original_channel:original_chat_id" for routing back
-			ChatID:  fmt.Sprintf("%s:%s", task.OriginChannel, task.OriginChatID),
-			Content: announceContent,
-		})
+// Wait blocks until the subagent finishes or ctx is done, and returns a
+// snapshot of the task.
+func (sm *SubagentManager) Wait(ctx context.Context, taskID string) (*SubagentTask, error) {
+	sm.mu.RLock()
+	run, running := sm.running[taskID]
+	sm.mu.RUnlock()
+
+	if running {
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	task, ok := sm.GetTask(taskID)
+	if !ok {
+		return nil, fmt.Errorf("subagent %s not found", taskID)
 	}
+	return task, nil
 }
 
+// GetTask returns a snapshot of a task.
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 	task, ok := sm.tasks[taskID]
-	return task, ok
+	if !ok {
+		return nil, false
+	}
+	snapshot := *task
+	return &snapshot, true
 }
 
+// ListTasks returns snapshots of all known tasks, oldest first.
 func (sm *SubagentManager) ListTasks() []*SubagentTask {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	tasks := make([]*SubagentTask, 0, len(sm.tasks))
 	for _, task := range sm.tasks {
-		tasks = append(tasks, task)
+		snapshot := *task
+		tasks = append(tasks, &snapshot)
 	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Created < tasks[j].Created
+	})
 	return tasks
 }
+
+func (sm *SubagentManager) load() {
+	data, err := os.ReadFile(sm.storePath)
+	if err != nil {
+		return
+	}
+
+	var tasks []*SubagentTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		logger.WarnCF("subagent", "Ignoring unreadable subagent state",
+			map[string]interface{}{
+				"path":  sm.storePath,
+				"error": err.Error(),
+			})
+		return
+	}
+
+	for _, task := range tasks {
+		if task.Status == SubagentRunning {
+			task.Status = SubagentInterrupted
+			task.Result = "Interrupted by a restart before it finished."
+		}
+		sm.tasks[task.ID] = task
+		if n, err := strconv.Atoi(strings.TrimPrefix(task.ID, "subagent-")); err == nil && n >= sm.nextID {
+			sm.nextID = n + 1
+		}
+	}
+}
+
+// saveLocked persists task state, dropping the oldest finished tasks beyond
+// maxStoredSubagentTasks. Callers must hold sm.mu.
+func (sm *SubagentManager) saveLocked() {
+	tasks := make([]*SubagentTask, 0, len(sm.tasks))
+	for _, task := range sm.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Created < tasks[j].Created
+	})
+
+	for excess := len(tasks) - maxStoredSubagentTasks; excess > 0; {
+		pruned := false
+		for i, task := range tasks {
+			if _, running := sm.running[task.ID]; !running {
+				delete(sm.tasks, task.ID)
+				tasks = append(tasks[:i], tasks[i+1:]...)
+				excess--
+				pruned = true
+				break
+			}
+		}
+		if !pruned {
+			break
+		}
+	}
+
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err == nil {
+		err = fileio.WriteFile(sm.storePath, data, 0644)
+	}
+	if err != nil {
+		logger.ErrorCF("subagent", "Failed to save subagent state",
+			map[string]interface{}{
+				"error": err.Error(),
+			})
+	}
+}
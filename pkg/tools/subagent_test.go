@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type echoTool struct{}
+
+func (echoTool) Name() string                       { return "echo" }
+func (echoTool) Description() string                { return "Echo the text argument" }
+func (echoTool) Parameters() map[string]interface{} { return map[string]interface{}{"type": "object"} }
+func (echoTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	return fmt.Sprintf("echo: %v", args["text"]), nil
+}
+
+// blockingProvider never answers until its context is cancelled.
+type blockingProvider struct{}
+
+func (blockingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingProvider) GetDefaultModel() string { return "" }
+
+func testSubagentConfig() config.SubagentsConfig {
+	return config.SubagentsConfig{MaxIterations: 5, MaxConcurrentPerOrigin: 1, Tools: []string{"echo"}}
+}
+
+func TestSubagentRunsToolLoop(t *testing.T) {
+	workspace := t.TempDir()
+	provider := providers.NewScriptedProvider(
+		providers.CallTool("echo", map[string]interface{}{"text": "hi"}),
+		providers.CallTool("exec", map[string]interface{}{"command": "rm -rf /"}),
+		providers.Reply("done").Then(func(messages []providers.Message) error {
+			if last := messages[len(messages)-1]; !strings.Contains(last.Content, "not available") {
+				return fmt.Errorf("tool outside the subset was not refused: %q", last.Content)
+			}
+			return nil
+		}),
+	)
+
+	registry := NewToolRegistry()
+	registry.Register(echoTool{})
+	registry.Register(NewExecTool(workspace))
+
+	sm := NewSubagentManager(provider, "test-model", testSubagentConfig(), workspace, nil)
+	sm.SetTools(registry)
+
+	spawned, err := sm.SpawnWithOptions(context.Background(), "say hi", SpawnOptions{}, "telegram", "1")
+	if err != nil {
+		t.Fatalf("SpawnWithOptions: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	task, err := sm.Wait(ctx, spawned.ID)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if task.Status != SubagentCompleted || task.Result != "done" || task.Iterations != 3 {
+		t.Errorf("task = %+v", task)
+	}
+	if calls := provider.Calls(); len(calls[0].Tools) != 1 || calls[0].Tools[0] != "echo" || calls[0].Model != "test-model" {
+		t.Errorf("first call offered tools %v with model %q", calls[0].Tools, calls[0].Model)
+	}
+
+	restored := NewSubagentManager(provider, "test-model", testSubagentConfig(), workspace, nil)
+	if got, ok := restored.GetTask(spawned.ID); !ok || got.Result != "done" {
+		t.Errorf("task state was not persisted: %+v", got)
+	}
+}
+
+func TestSubagentCapCancelAndRestart(t *testing.T) {
+	workspace := t.TempDir()
+	sm := NewSubagentManager(blockingProvider{}, "", testSubagentConfig(), workspace, nil)
+
+	first, err := sm.SpawnWithOptions(context.Background(), "slow", SpawnOptions{}, "telegram", "1")
+	if err != nil {
+		t.Fatalf("SpawnWithOptions: %v", err)
+	}
+	if _, err := sm.SpawnWithOptions(context.Background(), "second", SpawnOptions{}, "telegram", "1"); err == nil {
+		t.Errorf("expected the per-origin cap to refuse a second subagent")
+	}
+	if _, err := sm.SpawnWithOptions(context.Background(), "other chat", SpawnOptions{}, "telegram", "2"); err != nil {
+		t.Errorf("cap should be per origin: %v", err)
+	}
+
+	// A manager started while tasks were running sees them as interrupted
+	restarted := NewSubagentManager(blockingProvider{}, "", testSubagentConfig(), workspace, nil)
+	if got, _ := restarted.GetTask(first.ID); got == nil || got.Status != SubagentInterrupted {
+		t.Errorf("restored task = %+v, want interrupted", got)
+	}
+
+	if err := sm.Cancel(first.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	task, err := sm.Wait(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if task.Status != SubagentCancelled {
+		t.Errorf("status = %s, want %s", task.Status, SubagentCancelled)
+	}
+}
+
+func TestParallelTasksCollectsResults(t *testing.T) {
+	answer := func(messages []providers.Message) (*providers.LLMResponse, error) {
+		task := messages[1].Content
+		return &providers.LLMResponse{Content: "findings for " + strings.SplitN(task, "\n", 2)[0]}, nil
+	}
+	sm := NewSubagentManager(funcProvider(answer), "", testSubagentConfig(), t.TempDir(), nil)
+	tool := NewParallelTasksTool(sm)
+
+	out, err := tool.Execute(context.Background(), map[string]interface{}{
+		"tasks":   []interface{}{"check DXY", "check real yields", "check ETF flows"},
+		"labels":  []interface{}{"dollar", "", "flows"},
+		"context": "Gold rose 2% in 4h",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+
+	for _, want := range []string{
+		"Ran 3 subagents",
+		"3 completed",
+		"### [1] dollar: completed",
+		"findings for check DXY",
+		"### [2] check real yields: completed",
+		"### [3] flows: completed",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+type funcProvider func(messages []providers.Message) (*providers.LLMResponse, error)
+
+func (f funcProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, options map[string]interface{}) (*providers.LLMResponse, error) {
+	return f(messages)
+}
+
+func (f funcProvider) GetDefaultModel() string { return "" }
@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/ratelimit"
 )
 
 const (
@@ -134,6 +136,7 @@ func (t *WebSearchTool) Execute(ctx context.Context, args map[string]interface{}
 
 type WebFetchTool struct {
 	maxChars int
+	limiter  *ratelimit.Limiter
 }
 
 func NewWebFetchTool(maxChars int) *WebFetchTool {
@@ -145,6 +148,11 @@ func NewWebFetchTool(maxChars int) *WebFetchTool {
 	}
 }
 
+// SetRateLimiter sends fetches through a shared rate limiter.
+func (t *WebFetchTool) SetRateLimiter(limiter *ratelimit.Limiter) {
+	t.limiter = limiter
+}
+
 func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
@@ -206,12 +214,12 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]interface{})
 
 	client := &http.Client{
 		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
+		Transport: t.limiter.Wrap(&http.Transport{
 			MaxIdleConns:        10,
 			IdleConnTimeout:     30 * time.Second,
 			DisableCompression:  false,
 			TLSHandshakeTimeout: 15 * time.Second,
-		},
+		}),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 5 {
 				return fmt.Errorf("stopped after 5 redirects")
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var imageMimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// maxImagePixels bounds the decoded size of an image. Decoding allocates
+// memory for every pixel, so a small file claiming huge dimensions is refused
+// before it is decoded.
+const maxImagePixels = 24_000_000
+
+// IsImageFile reports whether a local path or URL looks like an image by extension.
+func IsImageFile(path string) bool {
+	_, ok := imageMimeTypes[imageExt(path)]
+	return ok
+}
+
+// EncodeImageDataURL loads an image from a local path or http(s) URL, scales it
+// down so neither side exceeds maxDimension, re-encodes it as JPEG within
+// maxBytes and returns it as a base64 data URL.
+// Formats the standard library cannot decode (webp) are passed through as-is
+// when they already fit within maxBytes.
+func EncodeImageDataURL(ctx context.Context, path string, maxDimension, maxBytes int) (string, error) {
+	raw, err := readImageSource(ctx, path, maxBytes*8)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		mime, known := imageMimeTypes[imageExt(path)]
+		if known && len(raw) <= maxBytes {
+			return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(raw), nil
+		}
+		return "", fmt.Errorf("unsupported image %s: %w", filepath.Base(path), err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return "", fmt.Errorf("image %s is too large (%dx%d pixels)", filepath.Base(path), cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("unsupported image %s: %w", filepath.Base(path), err)
+	}
+
+	img = resizeImage(img, maxDimension)
+
+	for _, quality := range []int{85, 70, 55} {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, flattenImage(img), &jpeg.Options{Quality: quality}); err != nil {
+			return "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		if maxBytes <= 0 || buf.Len() <= maxBytes {
+			return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+		}
+	}
+
+	return "", fmt.Errorf("image %s exceeds %d bytes after compression", filepath.Base(path), maxBytes)
+}
+
+func imageExt(path string) string {
+	if u, err := url.Parse(path); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		path = u.Path
+	}
+	return strings.ToLower(filepath.Ext(path))
+}
+
+func readImageSource(ctx context.Context, path string, limit int) ([]byte, error) {
+	if limit <= 0 {
+		limit = 32 << 20
+	}
+
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(reqCtx, "GET", path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download image: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download image: HTTP %d", resp.StatusCode)
+		}
+		return readLimited(resp.Body, limit)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLimited(f, limit)
+}
+
+func readLimited(r io.Reader, limit int) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("image larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// resizeImage scales img down with area averaging so its longest side is at
+// most maxDimension. Smaller images are returned unchanged. Pixels are read
+// straight from the decoded buffers rather than through image.Image.At, which
+// allocates for every pixel.
+func resizeImage(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if maxDimension <= 0 || (w <= maxDimension && h <= maxDimension) {
+		return img
+	}
+
+	scale := float64(maxDimension) / float64(w)
+	if h > w {
+		scale = float64(maxDimension) / float64(h)
+	}
+	nw := max(1, int(float64(w)*scale))
+	nh := max(1, int(float64(h)*scale))
+
+	// pixel returns the premultiplied 8-bit color at (x, y)
+	var pixel func(x, y int) (r, g, b, a uint32)
+	switch src := img.(type) {
+	case *image.YCbCr:
+		pixel = func(x, y int) (uint32, uint32, uint32, uint32) {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, b := color.YCbCrToRGB(src.Y[yi], src.Cb[ci], src.Cr[ci])
+			return uint32(r), uint32(g), uint32(b), 255
+		}
+	default:
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(bounds)
+			draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+		}
+		pixel = func(x, y int) (uint32, uint32, uint32, uint32) {
+			p := rgba.Pix[rgba.PixOffset(x, y):]
+			return uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		sy0 := bounds.Min.Y + y*h/nh
+		sy1 := max(sy0+1, bounds.Min.Y+(y+1)*h/nh)
+		for x := 0; x < nw; x++ {
+			sx0 := bounds.Min.X + x*w/nw
+			sx1 := max(sx0+1, bounds.Min.X+(x+1)*w/nw)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := pixel(sx, sy)
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// flattenImage draws img onto a white background, since JPEG has no alpha.
+func flattenImage(img image.Image) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewRGBA(bounds)
+	draw.Draw(dst, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, bounds, img, bounds.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeImage(t *testing.T, name string, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if strings.HasSuffix(name, ".png") {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func decodeDataURL(t *testing.T, dataURL string) (image.Image, int) {
+	t.Helper()
+	encoded, ok := strings.CutPrefix(dataURL, "data:image/jpeg;base64,")
+	if !ok {
+		t.Fatalf("expected a JPEG data URL, got %.40s", dataURL)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, len(raw)
+}
+
+func noise(w, h int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rng.Intn(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+func TestEncodeImageDownscales(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3000, 1000))
+	for i := range src.Pix {
+		src.Pix[i] = []uint8{200, 40, 40, 255}[i%4]
+	}
+	for _, name := range []string{"wide.png", "wide.jpg"} {
+		dataURL, err := EncodeImageDataURL(context.Background(), writeImage(t, name, src), 1024, 1<<20)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		img, _ := decodeDataURL(t, dataURL)
+		if b := img.Bounds(); b.Dx() != 1024 || b.Dy() != 341 {
+			t.Errorf("%s: resized to %dx%d, want 1024x341", name, b.Dx(), b.Dy())
+		}
+		r, g, b, _ := img.At(500, 170).RGBA()
+		if r>>8 < 190 || g>>8 > 55 || b>>8 > 55 {
+			t.Errorf("%s: color changed by resizing: %d,%d,%d", name, r>>8, g>>8, b>>8)
+		}
+	}
+}
+
+func TestEncodeImageQualityFallback(t *testing.T) {
+	src := noise(160, 160)
+	sizes := map[int]int{}
+	for _, quality := range []int{85, 55} {
+		var buf bytes.Buffer
+		jpeg.Encode(&buf, src, &jpeg.Options{Quality: quality})
+		sizes[quality] = buf.Len()
+	}
+	path := writeImage(t, "noise.png", src)
+
+	maxBytes := (sizes[85] + sizes[55]) / 2
+	dataURL, err := EncodeImageDataURL(context.Background(), path, 1024, maxBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, size := decodeDataURL(t, dataURL); size > maxBytes || size >= sizes[85] {
+		t.Errorf("expected a lower quality under %d bytes, got %d (q85 is %d)", maxBytes, size, sizes[85])
+	}
+
+	if _, err := EncodeImageDataURL(context.Background(), path, 1024, sizes[55]-1); err == nil || !strings.Contains(err.Error(), "after compression") {
+		t.Errorf("expected an error when even the lowest quality is too large, got %v", err)
+	}
+}
+
+func TestEncodeImageRejects(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	text := filepath.Join(dir, "notes.png")
+	os.WriteFile(text, []byte("not an image"), 0644)
+	if _, err := EncodeImageDataURL(ctx, text, 1024, 10); err == nil || !strings.Contains(err.Error(), "unsupported image") {
+		t.Errorf("expected undecodable data to be refused, got %v", err)
+	}
+
+	// A valid PNG header claiming 50000x50000 pixels must not be decoded
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	raw := buf.Bytes()
+	ihdr := raw[8+8 : 8+8+13] // After the signature and the chunk length and type
+	binary.BigEndian.PutUint32(ihdr[0:4], 50000)
+	binary.BigEndian.PutUint32(ihdr[4:8], 50000)
+	binary.BigEndian.PutUint32(raw[8+8+13:], crc32.ChecksumIEEE(raw[8+4:8+8+13]))
+	bomb := filepath.Join(dir, "bomb.png")
+	os.WriteFile(bomb, raw, 0644)
+	if _, err := EncodeImageDataURL(ctx, bomb, 1024, 1<<20); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected oversized dimensions to be refused, got %v", err)
+	}
+}
+
+func TestResizeImageYCbCr(t *testing.T) {
+	src := image.NewYCbCr(image.Rect(0, 0, 400, 200), image.YCbCrSubsampleRatio420)
+	y, cb, cr := color.RGBToYCbCr(30, 120, 220)
+	for i := range src.Y {
+		src.Y[i] = y
+	}
+	for i := range src.Cb {
+		src.Cb[i], src.Cr[i] = cb, cr
+	}
+	img := resizeImage(src, 100)
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Fatalf("resized to %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+	want := color.RGBAModel.Convert(src.At(0, 0)).(color.RGBA)
+	if got := img.(*image.RGBA).RGBAAt(60, 20); got != want {
+		t.Errorf("pixel = %v, want %v", got, want)
+	}
+}